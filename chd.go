@@ -0,0 +1,84 @@
+package rom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+const (
+	chdTag        = "MComprHD"
+	chdHeaderSize = 124
+)
+
+// See the following for reference:
+//
+// * https://github.com/mamedev/mame/blob/master/src/lib/util/chd.h
+
+// CHDHeader holds the checksums stored in the header of a MAME CHD file.
+// These cover the uncompressed data and metadata rather than the file itself
+// and so are what is listed in <disk> elements of a dat file
+type CHDHeader struct {
+	// Version is the CHD format version
+	Version uint32
+	// MD5 is only present in version 3 and earlier
+	MD5 []byte
+	// SHA1 is only present in version 3 and later
+	SHA1 []byte
+}
+
+var (
+	errNotCHD         = errors.New("not a chd")
+	errUnsupportedCHD = errors.New("unsupported chd version")
+)
+
+var chdOffsets = map[uint32]struct {
+	length uint32
+	md5    int
+	sha1   int
+}{
+	1: {76, 44, -1},
+	2: {80, 44, -1},
+	3: {120, 44, 80},
+	4: {108, -1, 48},
+	5: {124, -1, 84},
+}
+
+// ReadCHDHeader reads the header of a CHD file from r and returns the
+// checksums contained within
+func ReadCHDHeader(r io.Reader) (*CHDHeader, error) {
+	b := make([]byte, chdHeaderSize)
+	n, err := io.ReadFull(r, b)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	b = b[:n]
+
+	if len(b) < 16 || !bytes.Equal(b[0:8], []byte(chdTag)) {
+		return nil, errNotCHD
+	}
+
+	h := &CHDHeader{
+		Version: binary.BigEndian.Uint32(b[12:16]),
+	}
+
+	offsets, ok := chdOffsets[h.Version]
+	if !ok {
+		return nil, errUnsupportedCHD
+	}
+
+	if binary.BigEndian.Uint32(b[8:12]) != offsets.length || uint32(len(b)) < offsets.length {
+		return nil, errNotCHD
+	}
+
+	if offsets.md5 >= 0 {
+		h.MD5 = append([]byte(nil), b[offsets.md5:offsets.md5+16]...)
+	}
+
+	if offsets.sha1 >= 0 {
+		h.SHA1 = append([]byte(nil), b[offsets.sha1:offsets.sha1+20]...)
+	}
+
+	return h, nil
+}
@@ -0,0 +1,70 @@
+package rom
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func chdHeader(version, length uint32, checksums map[int][]byte) []byte {
+	b := make([]byte, length)
+	copy(b, chdTag)
+	binary.BigEndian.PutUint32(b[8:], length)
+	binary.BigEndian.PutUint32(b[12:], version)
+	for offset, c := range checksums {
+		copy(b[offset:], c)
+	}
+	return b
+}
+
+func TestReadCHDHeader(t *testing.T) {
+	md5 := bytes.Repeat([]byte{0xaa}, 16)
+	sha1 := bytes.Repeat([]byte{0xbb}, 20)
+
+	tables := map[string]struct {
+		b    []byte
+		want *CHDHeader
+		err  error
+	}{
+		"v3": {
+			chdHeader(3, 120, map[int][]byte{44: md5, 80: sha1}),
+			&CHDHeader{Version: 3, MD5: md5, SHA1: sha1},
+			nil,
+		},
+		"v4": {
+			chdHeader(4, 108, map[int][]byte{48: sha1}),
+			&CHDHeader{Version: 4, SHA1: sha1},
+			nil,
+		},
+		"v5": {
+			append(chdHeader(5, 124, map[int][]byte{84: sha1}), 0x00, 0x01),
+			&CHDHeader{Version: 5, SHA1: sha1},
+			nil,
+		},
+		"unsupported": {
+			chdHeader(6, 124, nil),
+			nil,
+			errUnsupportedCHD,
+		},
+		"short": {
+			chdHeader(5, 124, nil)[:100],
+			nil,
+			errNotCHD,
+		},
+		"not chd": {
+			[]byte("not a chd file"),
+			nil,
+			errNotCHD,
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			h, err := ReadCHDHeader(bytes.NewReader(table.b))
+			assert.Equal(t, table.err, err)
+			assert.Equal(t, table.want, h)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/rom/synchronizer"
+	"github.com/urfave/cli/v2"
+)
+
+var cleanCommand = &cli.Command{
+	Name:        "clean",
+	Usage:       "Find files not in any dat file",
+	Description: "List anything in TARGET that doesn't match a game in the dat file, the same as when synchronising, optionally deleting it or moving it into a backup directory",
+	Action:      clean,
+	ArgsUsage:   "TARGET",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "dat",
+			Aliases: []string{"d"},
+			Usage:   "dat file synchronised with its own subdirectory of TARGET, may be repeated. Otherwise one dat file is read from stdin",
+		},
+		&cli.BoolFlag{
+			Name:  "delete",
+			Usage: "delete each file found",
+		},
+		&cli.PathFlag{
+			Name:  "backup",
+			Usage: "move each file found into a timestamped subdirectory of this directory instead",
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "increase verbosity",
+		},
+		&cli.GenericFlag{
+			Name: "log-format",
+			Value: &enumValue{
+				Enum:    logFormatNames,
+				Default: "text",
+			},
+			Usage: "format of the verbose output. (" + strings.Join(logFormatNames, ", ") + ")",
+		},
+		&cli.GenericFlag{
+			Name: "format",
+			Value: &enumValue{
+				Enum:    formatNames,
+				Default: "torrentzip",
+			},
+			Usage: "output format for each game. (" + strings.Join(formatNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "template for the filename of each game",
+		},
+		&cli.GenericFlag{
+			Name: "merging",
+			Value: &enumValue{
+				Enum:    mergingNames,
+				Default: "none",
+			},
+			Usage: "merging mode, overriding any set by the dat file. (" + strings.Join(mergingNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "1g1r",
+			Usage: "only keep the preferred game of each parent/clone set using this comma-separated region priority",
+		},
+	},
+}
+
+func clean(c *cli.Context) error {
+	if c.NArg() != 1 || (c.Bool("delete") && c.Path("backup") != "") {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	_, logOption := newLogger(c)
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	// Unless asked to, nothing is removed and the files are just listed
+	s, err := synchronizer.NewSynchronizer(logOption, synchronizer.DryRun(!c.Bool("delete") && c.Path("backup") == ""))
+	if err != nil {
+		return err
+	}
+
+	if c.String("template") != "" {
+		if err = s.SetTemplate(c.String("template")); err != nil {
+			return err
+		}
+	}
+
+	if c.Path("backup") != "" {
+		if err = s.SetBackup(c.Path("backup")); err != nil {
+			return err
+		}
+	}
+
+	datfiles, err := loadDats(c)
+	if err != nil {
+		return err
+	}
+
+	format := stringToFormat[c.Generic("format").(*enumValue).String()]
+	if !c.IsSet("format") && len(c.StringSlice("dat")) == 0 && datfiles[0].Header.Unpacked() {
+		format = synchronizer.Directory
+	}
+
+	if err = s.SetFormat(format); err != nil {
+		return err
+	}
+
+	if !c.IsSet("format") {
+		if err = s.SetUnpackedFormat(synchronizer.Directory); err != nil {
+			return err
+		}
+	}
+
+	var names []string
+	if len(c.StringSlice("dat")) > 0 {
+		names, err = s.UnknownFilesContext(ctx, c.Args().First(), datfiles...)
+	} else {
+		names, err = s.UnknownContext(ctx, c.Args().First(), datfiles[0])
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		return err
+	}
+
+	for _, name := range names {
+		fmt.Println(filepath.Join(c.Args().First(), name))
+	}
+
+	if len(c.StringSlice("dat")) > 0 {
+		err = s.DeleteFilesContext(ctx, c.Args().First(), datfiles...)
+	} else {
+		err = s.DeleteContext(ctx, c.Args().First(), datfiles[0])
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		return err
+	}
+
+	return nil
+}
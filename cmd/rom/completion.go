@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+)
+
+// completionScripts are the scripts for each shell. They all ask the
+// program itself what to complete and fall back to completing file names
+// if nothing is suggested
+var completionScripts = map[string]string{
+	"bash": `_%[1]s_complete() {
+  local cur words
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  words=("${COMP_WORDS[@]:0:$COMP_CWORD}")
+  if [[ "$cur" == -* ]]; then
+    words+=("$cur")
+  fi
+  local IFS=$'\n'
+  COMPREPLY=($(compgen -W "$("${words[@]}" --generate-bash-completion 2>/dev/null)" -- "$cur"))
+}
+
+complete -o bashdefault -o default -F _%[1]s_complete %[1]s
+`,
+	"zsh": `#compdef %[1]s
+
+_%[1]s_complete() {
+  local -a opts
+  local -a args
+  args=("${words[@]:0:$((CURRENT-1))}")
+  if [[ "${words[CURRENT]}" == -* ]]; then
+    args+=("${words[CURRENT]}")
+  fi
+  opts=("${(@f)$(SHELL=zsh "${args[@]}" --generate-bash-completion 2>/dev/null)}")
+  if [[ -n "${opts[1]}" ]]; then
+    _describe 'values' opts
+  else
+    _files
+  fi
+}
+
+compdef _%[1]s_complete %[1]s
+`,
+	"fish": `function __%[1]s_complete
+    set -l args (commandline -opc)
+    set -l cur (commandline -ct)
+    if string match -q -- '-*' $cur
+        set args $args $cur
+    end
+    set -l opts ($args --generate-bash-completion 2>/dev/null)
+    if test (count $opts) -eq 0
+        __fish_complete_path $cur
+    else
+        printf '%%s\n' $opts
+    end
+end
+
+complete -c %[1]s -f -a '(__%[1]s_complete)'
+`,
+}
+
+// completeValues returns a completion function for cmd that suggests the
+// allowed values of an enum flag and leaves any other flag taking a value
+// to the shell to complete as a file name. Anything else gets the default
+// completion of subcommands and flags
+func completeValues(cmd *cli.Command, checksums []string) cli.BashCompleteFunc {
+	complete := cli.DefaultCompleteWithFlags(cmd)
+
+	return func(c *cli.Context) {
+		// The last argument is always --generate-bash-completion
+		if len(os.Args) < 3 || !strings.HasPrefix(os.Args[len(os.Args)-2], "-") {
+			complete(c)
+			return
+		}
+		name := strings.TrimLeft(os.Args[len(os.Args)-2], "-")
+
+		for _, f := range cmd.Flags {
+			if !hasName(f, name) {
+				continue
+			}
+
+			switch f := f.(type) {
+			case *cli.GenericFlag:
+				if e, ok := f.Value.(*enumValue); ok {
+					fmt.Fprintln(c.App.Writer, strings.Join(e.Enum, "\n"))
+					return
+				}
+			case *cli.StringSliceFlag:
+				if f.Name == "algorithm" {
+					fmt.Fprintln(c.App.Writer, strings.Join(checksums, "\n"))
+					return
+				}
+			}
+
+			if v, ok := f.(cli.DocGenerationFlag); ok && v.TakesValue() {
+				return
+			}
+		}
+
+		complete(c)
+	}
+}
+
+func hasName(f cli.Flag, name string) bool {
+	for _, n := range f.Names() {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// setCompletion installs completeValues on every command and subcommand
+// that doesn't have its own completion
+func setCompletion(commands []*cli.Command, checksums []string) {
+	for _, cmd := range commands {
+		if cmd.BashComplete == nil {
+			cmd.BashComplete = completeValues(cmd, checksums)
+		}
+		setCompletion(cmd.Subcommands, checksums)
+	}
+}
+
+var completionCommand = &cli.Command{
+	Name:        "completion",
+	Usage:       "Shell completion",
+	Description: "Print a completion script for bash, fish or zsh, for example \"source <(rom completion bash)\"",
+	Action:      completion,
+	ArgsUsage:   "bash|fish|zsh",
+}
+
+func completion(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	script, ok := completionScripts[c.Args().First()]
+	if !ok {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	fmt.Printf(script, c.App.Name)
+
+	return nil
+}
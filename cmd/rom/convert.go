@@ -0,0 +1,63 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/urfave/cli/v2"
+)
+
+var convertCommand = &cli.Command{
+	Name:        "convert",
+	Usage:       "Convert a dat file",
+	Description: "Convert a Logiqx XML, clrmamepro, RomCenter, OfflineList or MAME listxml dat file read from FILE or stdin to another format, written to stdout",
+	Action:      convert,
+	ArgsUsage:   "[FILE]",
+	Flags: []cli.Flag{
+		&cli.GenericFlag{
+			Name:    "to",
+			Aliases: []string{"t"},
+			Value: &enumValue{
+				Enum:    datFormatNames,
+				Default: "logiqx",
+			},
+			Usage: "output format. (" + strings.Join(datFormatNames, ", ") + ")",
+		},
+	},
+}
+
+func convert(c *cli.Context) error {
+	if c.NArg() > 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	var (
+		b   []byte
+		err error
+	)
+	if c.NArg() == 1 {
+		b, err = os.ReadFile(c.Args().First())
+	} else {
+		b, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+
+	datfile := new(dat.File)
+	if err = unmarshal(b, datfile); err != nil {
+		return err
+	}
+
+	if b, err = stringToDatFormat[c.Generic("to").(*enumValue).String()](datfile); err != nil {
+		return err
+	}
+
+	if _, err = os.Stdout.Write(b); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+var datdiffCommand = &cli.Command{
+	Name:        "datdiff",
+	Usage:       "Compare two dat files",
+	Description: "List the games and ROMs added, removed or changed between two versions of a dat file",
+	Action:      datdiff,
+	ArgsUsage:   "OLD NEW",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the differences as JSON",
+		},
+	},
+}
+
+func datdiff(c *cli.Context) error {
+	if c.NArg() != 2 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	datfiles := make([]*dat.File, 0, 2)
+	for _, file := range c.Args().Slice() {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		datfile := new(dat.File)
+		if err := unmarshal(b, datfile); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		datfiles = append(datfiles, datfile)
+	}
+
+	d := dat.Diff(datfiles[0], datfiles[1])
+
+	if c.Bool("json") {
+		if err := json.NewEncoder(os.Stdout).Encode(d); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	if d.Empty() {
+		return nil
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetAutoWrapText(false)
+
+	table.SetHeader([]string{"", "Game", "ROM", "Size", "CRC32", "MD5", "SHA1"})
+
+	row := func(change, game string, r *dat.ROM) {
+		if r == nil {
+			table.Append([]string{change, game, "", "", "", "", ""})
+			return
+		}
+		table.Append([]string{change, game, r.Name, strconv.FormatUint(r.Size, 10), r.CRC32, r.MD5, r.SHA1})
+	}
+	rows := func(change string, g dat.Game) {
+		if len(g.ROM) == 0 {
+			row(change, g.Name, nil)
+		}
+		for i := range g.ROM {
+			row(change, g.Name, &g.ROM[i])
+		}
+	}
+
+	for _, g := range d.Added {
+		rows("+", g)
+	}
+	for _, g := range d.Removed {
+		rows("-", g)
+	}
+	for _, g := range d.Changed {
+		for i := range g.Added {
+			row("+", g.Name, &g.Added[i])
+		}
+		for i := range g.Removed {
+			row("-", g.Name, &g.Removed[i])
+		}
+		for i := range g.Changed {
+			row("-", g.Name, &g.Changed[i].Old)
+			row("+", g.Name, &g.Changed[i].New)
+		}
+	}
+
+	table.Render()
+
+	return nil
+}
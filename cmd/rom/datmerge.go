@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/urfave/cli/v2"
+)
+
+var datmergeCommand = &cli.Command{
+	Name:        "datmerge",
+	Usage:       "Merge dat files",
+	Description: "Combine the games from each dat file into one dat file written to stdout",
+	Action:      datmerge,
+	ArgsUsage:   "DAT...",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "name",
+			Usage: "name of the dat file, otherwise the header of the first dat file is used",
+		},
+		&cli.StringFlag{
+			Name:  "description",
+			Usage: "description of the dat file, defaults to the name",
+		},
+		&cli.StringFlag{
+			Name:  "version",
+			Usage: "version of the dat file",
+		},
+		&cli.StringFlag{
+			Name:  "author",
+			Usage: "author of the dat file",
+		},
+		&cli.StringFlag{
+			Name:  "homepage",
+			Usage: "homepage of the dat file",
+		},
+		&cli.GenericFlag{
+			Name: "duplicate",
+			Value: &enumValue{
+				Enum:    duplicateNames,
+				Default: "combine",
+			},
+			Usage: "how to handle a game found in more than one dat file. (" + strings.Join(duplicateNames, ", ") + ")",
+		},
+		&cli.GenericFlag{
+			Name: "conflict",
+			Value: &enumValue{
+				Enum:    conflictNames,
+				Default: "error",
+			},
+			Usage: "how to handle combined ROMs with the same name but different checksums. (" + strings.Join(conflictNames, ", ") + ")",
+		},
+		&cli.BoolFlag{
+			Name:  "sort",
+			Usage: "sort the games by name",
+		},
+		&cli.GenericFlag{
+			Name:    "to",
+			Aliases: []string{"t"},
+			Value: &enumValue{
+				Enum:    datFormatNames,
+				Default: "logiqx",
+			},
+			Usage: "output format. (" + strings.Join(datFormatNames, ", ") + ")",
+		},
+	},
+}
+
+func datmerge(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	datfiles := make([]*dat.File, 0, c.NArg())
+	for _, file := range c.Args().Slice() {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		datfile := new(dat.File)
+		if err := unmarshal(b, datfile); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		datfiles = append(datfiles, datfile)
+	}
+
+	header := datfiles[0].Header
+	if c.IsSet("name") {
+		header = dat.Header{
+			Name:        c.String("name"),
+			Description: c.String("description"),
+		}
+		if header.Description == "" {
+			header.Description = header.Name
+		}
+	} else if c.IsSet("description") {
+		header.Description = c.String("description")
+	}
+	for _, flag := range []struct {
+		name  string
+		value *string
+	}{
+		{"version", &header.Version},
+		{"author", &header.Author},
+		{"homepage", &header.Homepage},
+	} {
+		if c.IsSet(flag.name) {
+			*flag.value = c.String(flag.name)
+		}
+	}
+
+	datfile, err := dat.Merge(header, stringToDuplicate[c.Generic("duplicate").(*enumValue).String()], stringToConflict[c.Generic("conflict").(*enumValue).String()], datfiles...)
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("sort") {
+		datfile.Sort()
+	}
+
+	b, err := stringToDatFormat[c.Generic("to").(*enumValue).String()](datfile)
+	if err != nil {
+		return err
+	}
+
+	if _, err = os.Stdout.Write(b); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/bodgit/rom/synchronizer"
+	"github.com/urfave/cli/v2"
+)
+
+type duplicateSet struct {
+	Keep       string   `json:"keep"`
+	Duplicates []string `json:"duplicates"`
+	Size       int64    `json:"size"`
+}
+
+var dedupeCommand = &cli.Command{
+	Name:        "dedupe",
+	Usage:       "Find duplicate files",
+	Description: "Scan each PATH and list any files or archives that are byte-identical to another, optionally deleting or hard linking the duplicates. The first of each set by name is kept",
+	Action:      dedupe,
+	ArgsUsage:   "PATH...",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:    "workers",
+			Aliases: []string{"w"},
+			Usage:   "number of workers",
+			Value:   runtime.NumCPU(),
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "increase verbosity",
+		},
+		&cli.GenericFlag{
+			Name:    "algorithm",
+			Aliases: []string{"a"},
+			Value: &enumValue{
+				Enum:    checksumNames,
+				Default: "crc32",
+			},
+			Usage: "checksum algorithm to use. (" + strings.Join(checksumNames, ", ") + ")",
+		},
+		&cli.BoolFlag{
+			Name:    "follow-symlinks",
+			Aliases: []string{"L"},
+			Usage:   "follow symbolic links when scanning",
+		},
+		&cli.BoolFlag{
+			Name:  "delete",
+			Usage: "delete each duplicate",
+		},
+		&cli.BoolFlag{
+			Name:  "link",
+			Usage: "replace each duplicate with a hard link to the file kept",
+		},
+		&cli.BoolFlag{
+			Name:    "dry-run",
+			Aliases: []string{"n"},
+			Usage:   "only print what would be deleted or linked",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print each set of duplicates as JSON",
+		},
+	},
+}
+
+func dedupe(c *cli.Context) error {
+	if c.NArg() < 1 || (c.Bool("delete") && c.Bool("link")) {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	if c.Bool("verbose") {
+		logger.SetOutput(os.Stderr)
+	}
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	s, err := synchronizer.NewSynchronizer(synchronizer.Logger(logger), synchronizer.Workers(c.Int("workers")), synchronizer.FollowSymlinks(c.Bool("follow-symlinks")), synchronizer.Checksum(stringToChecksum[c.Generic("algorithm").(*enumValue).String()]))
+	if err != nil {
+		return err
+	}
+
+	db, err := s.ScanContext(ctx, c.Args().Slice()...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, nil)
+		}
+		return err
+	}
+
+	var (
+		sets  []duplicateSet
+		count int
+		size  int64
+	)
+
+	// Sources with the same contents might still differ, for example in
+	// how an archive is compressed, so compare the files themselves
+	for _, group := range db.DuplicateSources() {
+		if ctx.Err() != nil {
+			return interrupted(c, nil)
+		}
+
+		identical, err := identicalFiles(group)
+		if err != nil {
+			return err
+		}
+		for _, set := range identical {
+			sets = append(sets, set)
+			count += len(set.Duplicates)
+			size += set.Size * int64(len(set.Duplicates))
+		}
+	}
+
+	for _, set := range sets {
+		for _, file := range set.Duplicates {
+			if c.Bool("dry-run") {
+				continue
+			}
+
+			switch {
+			case c.Bool("delete"):
+				err = os.Remove(file)
+			case c.Bool("link"):
+				err = linkFile(set.Keep, file)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.Bool("json") {
+		if sets == nil {
+			sets = []duplicateSet{}
+		}
+		if err = json.NewEncoder(os.Stdout).Encode(sets); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	action := "duplicate of"
+	switch {
+	case c.Bool("delete"):
+		action = "deleted, duplicate of"
+	case c.Bool("link"):
+		action = "linked to"
+	}
+
+	for _, set := range sets {
+		for _, file := range set.Duplicates {
+			fmt.Println(file+":", action, set.Keep)
+		}
+	}
+
+	if count > 0 {
+		fmt.Println(count, "duplicates,", size, "bytes")
+	}
+
+	return nil
+}
+
+// identicalFiles splits names into sets of byte-identical regular files.
+// Files already linked to the file kept aren't counted as duplicates
+func identicalFiles(names []string) ([]duplicateSet, error) {
+	type file struct {
+		name string
+		info os.FileInfo
+	}
+
+	var (
+		keys  []string
+		files = make(map[string][]file)
+	)
+	for _, name := range names {
+		info, err := os.Lstat(name)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			continue
+		}
+
+		digest, err := fileDigest(name)
+		if err != nil {
+			return nil, err
+		}
+
+		key := fmt.Sprintf("%d-%x", info.Size(), digest)
+		if _, ok := files[key]; !ok {
+			keys = append(keys, key)
+		}
+		files[key] = append(files[key], file{name, info})
+	}
+
+	var sets []duplicateSet
+	for _, key := range keys {
+		keep := files[key][0]
+		set := duplicateSet{Keep: keep.name, Size: keep.info.Size()}
+		for _, f := range files[key][1:] {
+			if !os.SameFile(keep.info, f.info) {
+				set.Duplicates = append(set.Duplicates, f.name)
+			}
+		}
+		if len(set.Duplicates) > 0 {
+			sets = append(sets, set)
+		}
+	}
+
+	return sets, nil
+}
+
+func fileDigest(name string) ([]byte, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := sha1.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return nil, err
+	}
+
+	return h.Sum(nil), nil
+}
+
+// linkFile replaces file with a hard link to target
+func linkFile(target, file string) error {
+	temp := filepath.Join(filepath.Dir(file), "."+filepath.Base(file)+".link")
+	if err := os.Link(target, temp); err != nil {
+		return err
+	}
+
+	if err := os.Rename(temp, file); err != nil {
+		os.Remove(temp)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+	"github.com/urfave/cli/v2"
+)
+
+var dir2datCommand = &cli.Command{
+	Name:        "dir2dat",
+	Usage:       "Create a dat file",
+	Description: "Write a dat file to stdout describing the archives, files and subdirectories within each directory, each as a game",
+	Action:      dir2dat,
+	ArgsUsage:   "PATH...",
+	Flags: []cli.Flag{
+		&cli.StringFlag{
+			Name:  "name",
+			Usage: "name of the dat file",
+		},
+		&cli.StringFlag{
+			Name:  "description",
+			Usage: "description of the dat file, defaults to the name",
+		},
+		&cli.StringFlag{
+			Name:  "version",
+			Usage: "version of the dat file",
+		},
+		&cli.StringFlag{
+			Name:  "author",
+			Usage: "author of the dat file",
+		},
+		&cli.StringFlag{
+			Name:  "homepage",
+			Usage: "homepage of the dat file",
+		},
+	},
+}
+
+func dir2dat(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	header := dat.Header{
+		Name:        c.String("name"),
+		Description: c.String("description"),
+		Version:     c.String("version"),
+		Author:      c.String("author"),
+		Homepage:    c.String("homepage"),
+	}
+	if header.Description == "" {
+		header.Description = header.Name
+	}
+
+	datfile, err := dat.NewFile(header)
+	if err != nil {
+		return err
+	}
+
+	for _, path := range c.Args().Slice() {
+		paths, err := gamePaths(path)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range paths {
+			reader, err := rom.NewReaderContext(c.Context, p)
+			if err != nil {
+				return err
+			}
+
+			f, err := dat.NewFile(dat.Header{}, reader)
+			if err != nil {
+				return fmt.Errorf("%s: %w", p, err)
+			}
+			datfile.Game = append(datfile.Game, f.Game...)
+
+			reader.Close()
+		}
+	}
+
+	datfile.Sort()
+
+	e := dat.NewEncoder(os.Stdout)
+	e.Indent("", "\t")
+
+	if err = e.Encode(datfile); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// gamePaths returns the path of each game at path. A directory holds one
+// game per archive, file or subdirectory within it, anything else is a game
+// by itself
+func gamePaths(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	names, err := f.Readdirnames(0)
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	paths := make([]string, 0, len(names))
+	for _, name := range names {
+		// Ignore any hidden files or directories
+		if name[0] == '.' {
+			continue
+		}
+		paths = append(paths, filepath.Join(path, name))
+	}
+
+	return paths, nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bodgit/rom"
+	"github.com/urfave/cli/v2"
+)
+
+var errUnsafePath = errors.New("unsafe path")
+
+var extractCommand = &cli.Command{
+	Name:        "extract",
+	Usage:       "Extract archives",
+	Description: "Extract every file from each archive, or any other supported source, to a directory",
+	Action:      extract,
+	ArgsUsage:   "ARCHIVE...",
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Value:   ".",
+			Usage:   "directory to extract to",
+		},
+		&cli.BoolFlag{
+			Name:  "strip-headers",
+			Usage: "remove any header, such as the iNES header, from each file",
+		},
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "overwrite existing files",
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "print each file extracted",
+		},
+	},
+}
+
+func extract(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	if err := os.MkdirAll(c.Path("output"), 0o777); err != nil {
+		return err
+	}
+
+	for _, archive := range c.Args().Slice() {
+		reader, err := rom.NewReaderContext(c.Context, archive)
+		if err != nil {
+			return err
+		}
+
+		files := reader.Files()
+		sort.Strings(files)
+
+		for _, file := range files {
+			if err := extractFile(c, reader, file); err != nil {
+				return fmt.Errorf("%s: %s: %w", archive, file, err)
+			}
+
+			if c.Bool("verbose") {
+				fmt.Println(filepath.Join(c.Path("output"), filepath.FromSlash(file)))
+			}
+		}
+
+		reader.Close()
+	}
+
+	return nil
+}
+
+// extractFile copies file from reader to the output directory, creating
+// any intermediate directories in its name
+func extractFile(c *cli.Context, reader rom.Reader, file string) error {
+	name := filepath.FromSlash(file)
+	if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) || name != filepath.Clean(name) {
+		return errUnsafePath
+	}
+	target := filepath.Join(c.Path("output"), name)
+
+	if err := os.MkdirAll(filepath.Dir(target), 0o777); err != nil {
+		return err
+	}
+
+	flag := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if !c.Bool("force") {
+		flag |= os.O_EXCL
+	}
+
+	rc, err := reader.Open(file)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if c.Bool("strip-headers") {
+		if r, _, err = rom.StripHeader(file, r); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(target, flag, 0o666)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err = io.Copy(f, r); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
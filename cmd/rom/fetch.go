@@ -0,0 +1,360 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/urfave/cli/v2"
+)
+
+// datSources maps the prefix of a source to the URL it is fetched from.
+// No-Intro only offers its daily packs to a logged in browser so those
+// have to be passed as a URL or a file that has already been downloaded
+var datSources = map[string]string{
+	"libretro":     "https://raw.githubusercontent.com/libretro/libretro-database/master/metadat/%s.dat",
+	"libretro-rdb": "https://raw.githubusercontent.com/libretro/libretro-database/master/rdb/%s.rdb",
+	"redump":       "http://redump.org/datfile/%s/",
+}
+
+var (
+	errUnknownSource = errors.New("unknown source")
+	errNoDats        = errors.New("no dat files found")
+)
+
+// loadSources returns the named sources configured in filename, if any
+func loadSources(filename string) (map[string]string, error) {
+	sources := make(map[string]string)
+	if filename == "" {
+		return sources, nil
+	}
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &sources); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return sources, nil
+}
+
+// sourceLocation resolves source, which is either configured in sources,
+// a prefixed name such as "redump:psx", a URL or a local file
+func sourceLocation(source string, sources map[string]string) (string, error) {
+	if location, ok := sources[source]; ok {
+		source = location
+	}
+
+	if u, err := url.Parse(source); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		return source, nil
+	}
+
+	if i := strings.IndexByte(source, ':'); i > 0 {
+		if format, ok := datSources[source[:i]]; ok {
+			parts := strings.Split(source[i+1:], "/")
+			for j := range parts {
+				parts[j] = url.PathEscape(parts[j])
+			}
+			return fmt.Sprintf(format, strings.Join(parts, "/")), nil
+		}
+	}
+
+	if _, err := os.Stat(source); err == nil {
+		return source, nil
+	}
+
+	return "", fmt.Errorf("%w: %s", errUnknownSource, source)
+}
+
+// fetchLocation returns the contents of location along with a suggested
+// filename
+func fetchLocation(client *http.Client, location string) ([]byte, string, error) {
+	if !strings.HasPrefix(location, "http://") && !strings.HasPrefix(location, "https://") {
+		b, err := os.ReadFile(location)
+		return b, filepath.Base(location), err
+	}
+
+	req, err := http.NewRequest(http.MethodGet, location, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "rom")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", errors.New(resp.Status)
+	}
+
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", err
+	}
+
+	name := path.Base(resp.Request.URL.Path)
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		name = params["filename"]
+	}
+	if unescaped, err := url.PathUnescape(name); err == nil {
+		name = unescaped
+	}
+
+	return b, filepath.Base(name), nil
+}
+
+// convertRDB converts the RetroArch database b into a Logiqx XML dat file
+// named after the database, as it has no header
+func convertRDB(b []byte, name string) ([]byte, string, error) {
+	datfile := new(dat.File)
+	if err := dat.UnmarshalRDB(b, datfile); err != nil {
+		return nil, "", err
+	}
+
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	datfile.Header.Name = name
+	datfile.Header.Description = name
+
+	b, err := marshalLogiqx(datfile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return b, name + ".dat", nil
+}
+
+// unpackDats returns each dat file in b keyed by filename. A zip archive,
+// such as a daily pack, can contain any number of them. A RetroArch
+// database is converted to a dat file
+func unpackDats(b []byte, name string) (map[string][]byte, error) {
+	dats := make(map[string][]byte)
+
+	if bytes.HasPrefix(b, []byte(dat.RDBMagic)) {
+		b, name, err := convertRDB(b, name)
+		if err != nil {
+			return nil, err
+		}
+		dats[name] = b
+		return dats, nil
+	}
+
+	if !bytes.HasPrefix(b, []byte("PK\x03\x04")) {
+		if filepath.Ext(name) == "" {
+			name += ".dat"
+		}
+		dats[name] = b
+		return dats, nil
+	}
+
+	r, err := zip.NewReader(bytes.NewReader(b), int64(len(b)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range r.File {
+		ext := strings.ToLower(path.Ext(f.Name))
+		if f.FileInfo().IsDir() || (ext != ".dat" && ext != ".xml") {
+			continue
+		}
+
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+
+		// Packs group dat files into directories but they're all
+		// stored alongside each other
+		dats[path.Base(f.Name)] = b
+	}
+
+	if len(dats) == 0 {
+		return nil, errNoDats
+	}
+
+	return dats, nil
+}
+
+// saveDat replaces filename with b
+func saveDat(filename string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := f.Chmod(0o644); err != nil {
+		return err
+	}
+
+	if _, err := f.Write(b); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
+
+var datCommand = &cli.Command{
+	Name:  "dat",
+	Usage: "Manage dat files",
+	Subcommands: []*cli.Command{
+		{
+			Name:        "fetch",
+			Usage:       "Download dat files",
+			Description: "Download each SOURCE into a directory of dat files, replacing any older copy. A SOURCE is either a name configured with --sources, \"libretro:\" followed by a path within the libretro-database metadat directory such as \"libretro:no-intro/Nintendo - Game Boy\", \"libretro-rdb:\" followed by a RetroArch database such as \"libretro-rdb:Nintendo - Game Boy\", which is converted to a dat file, \"redump:\" followed by a Redump system such as \"redump:psx\", or any URL or file. Every dat file within a zip archive, such as a No-Intro daily pack, is extracted",
+			Action:      fetch,
+			ArgsUsage:   "SOURCE...",
+			Flags: []cli.Flag{
+				&cli.PathFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "directory to write the dat files to",
+					Value:   ".",
+				},
+				&cli.PathFlag{
+					Name:  "sources",
+					Usage: "JSON file mapping names to sources",
+				},
+				&cli.BoolFlag{
+					Name:  "all",
+					Usage: "fetch every source configured with --sources",
+				},
+				&cli.BoolFlag{
+					Name:  "list",
+					Usage: "list the sources configured with --sources",
+				},
+				&cli.DurationFlag{
+					Name:  "timeout",
+					Usage: "give up on each download after this long",
+					Value: 5 * time.Minute,
+				},
+				&cli.BoolFlag{
+					Name:    "verbose",
+					Aliases: []string{"v"},
+					Usage:   "print each dat file written",
+				},
+			},
+		},
+	},
+}
+
+func fetch(c *cli.Context) error {
+	sources, err := loadSources(c.Path("sources"))
+	if err != nil {
+		return err
+	}
+
+	if c.Bool("list") {
+		names := make([]string, 0, len(sources))
+		for name := range sources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println(name + ": " + sources[name])
+		}
+		return nil
+	}
+
+	args := c.Args().Slice()
+	if c.Bool("all") {
+		args = args[:0]
+		for name := range sources {
+			args = append(args, name)
+		}
+		sort.Strings(args)
+	}
+
+	if len(args) == 0 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	if err := os.MkdirAll(c.Path("output"), 0o777); err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: c.Duration("timeout")}
+
+	bad := false
+	for _, source := range args {
+		location, err := sourceLocation(source, sources)
+		if err != nil {
+			log.Println(err)
+			bad = true
+			continue
+		}
+
+		b, name, err := fetchLocation(client, location)
+		if err != nil {
+			log.Println(source+":", err)
+			bad = true
+			continue
+		}
+
+		dats, err := unpackDats(b, name)
+		if err != nil {
+			log.Println(source+":", err)
+			bad = true
+			continue
+		}
+
+		names := make([]string, 0, len(dats))
+		for name := range dats {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			// Catch any error page served in place of a dat file
+			if err := unmarshal(dats[name], new(dat.File)); err != nil {
+				log.Println(source+":", name+":", err)
+				bad = true
+				continue
+			}
+
+			filename := filepath.Join(c.Path("output"), name)
+			if err := saveDat(filename, dats[name]); err != nil {
+				return err
+			}
+
+			if c.Bool("verbose") {
+				fmt.Println(source, "->", filename)
+			}
+		}
+	}
+
+	if bad {
+		return cli.Exit("", exitError)
+	}
+
+	return nil
+}
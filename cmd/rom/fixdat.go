@@ -0,0 +1,240 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/bodgit/rom/synchronizer"
+	"github.com/urfave/cli/v2"
+)
+
+// readSetList reads a ClrMamePro have or miss list, which has the name of
+// one set per line
+func readSetList(file string) (map[string]struct{}, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	sets := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff")); line != "" {
+			sets[line] = struct{}{}
+		}
+	}
+
+	return sets, scanner.Err()
+}
+
+// haveSets marks every game in datfile listed in sets, by either its name
+// or description, as present and returns how many there were
+func haveSets(datfile *dat.File, sets map[string]struct{}) int {
+	n := 0
+	for i := range datfile.Game {
+		g := &datfile.Game[i]
+		_, name := sets[g.Name]
+		_, description := sets[g.Description]
+		if !name && !description {
+			continue
+		}
+		for j := range g.ROM {
+			g.ROM[j].Matched()
+		}
+		for j := range g.Disk {
+			g.Disk[j].Matched()
+		}
+		for j := range g.Sample {
+			g.Sample[j].Matched()
+		}
+		n++
+	}
+	return n
+}
+
+var fixdatCommand = &cli.Command{
+	Name:        "fixdat",
+	Usage:       "Create a fixdat",
+	Description: "Write a dat file to stdout listing only the ROMs missing from a directory without changing anything. Rather than scanning TARGET, a ClrMamePro have list can be used to say which sets are present",
+	Action:      fixdat,
+	ArgsUsage:   "[TARGET]",
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:    "dat",
+			Aliases: []string{"d"},
+			Usage:   "path to the dat file, otherwise it is read from stdin",
+		},
+		&cli.PathFlag{
+			Name:  "have-list",
+			Usage: "path to a ClrMamePro have list of the sets present, by name or description, rather than scanning TARGET",
+		},
+		&cli.PathFlag{
+			Name:  "miss",
+			Usage: "write the name of each set not complete to this file, the same as a ClrMamePro miss list",
+		},
+		&cli.IntFlag{
+			Name:    "workers",
+			Aliases: []string{"w"},
+			Usage:   "number of workers",
+			Value:   runtime.NumCPU(),
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "increase verbosity",
+		},
+		&cli.PathFlag{
+			Name:    "mia",
+			Aliases: []string{"m"},
+			Usage:   "path to file containing list of games to ignore",
+		},
+		&cli.GenericFlag{
+			Name: "format",
+			Value: &enumValue{
+				Enum:    formatNames,
+				Default: "torrentzip",
+			},
+			Usage: "output format for each game. (" + strings.Join(formatNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "template for the filename of each game",
+		},
+		&cli.GenericFlag{
+			Name: "merging",
+			Value: &enumValue{
+				Enum:    mergingNames,
+				Default: "none",
+			},
+			Usage: "merging mode, overriding any set by the dat file. (" + strings.Join(mergingNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "1g1r",
+			Usage: "only keep the preferred game of each parent/clone set using this comma-separated region priority",
+		},
+	},
+}
+
+func fixdat(c *cli.Context) error {
+	if c.NArg() > 1 || (c.NArg() == 0 && c.Path("have-list") == "") {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	if c.Bool("verbose") {
+		logger.SetOutput(os.Stderr)
+	}
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	// Nothing is changed so only the target is scanned
+	s, err := synchronizer.NewSynchronizer(synchronizer.Logger(logger), synchronizer.DryRun(true), synchronizer.Workers(c.Int("workers")))
+	if err != nil {
+		return err
+	}
+
+	if c.String("template") != "" {
+		if err = s.SetTemplate(c.String("template")); err != nil {
+			return err
+		}
+	}
+
+	if c.Path("mia") != "" {
+		f, err := os.Open(c.Path("mia"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err = s.SetMissing(f); err != nil {
+			return err
+		}
+	}
+
+	var b []byte
+	if c.Path("dat") != "" {
+		b, err = os.ReadFile(c.Path("dat"))
+	} else {
+		b, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+
+	datfile, err := loadDat(c, b)
+	if err != nil {
+		return err
+	}
+
+	if c.Path("have-list") != "" {
+		// The have list stands in for scanning the target
+		have, err := readSetList(c.Path("have-list"))
+		if err != nil {
+			return err
+		}
+		logger.Println(haveSets(datfile, have), "of", len(datfile.Game), "game(s) in the have list")
+
+		if c.Path("mia") != "" {
+			mia, err := readSetList(c.Path("mia"))
+			if err != nil {
+				return err
+			}
+			haveSets(datfile, mia)
+		}
+	} else {
+		format := stringToFormat[c.Generic("format").(*enumValue).String()]
+		if !c.IsSet("format") && datfile.Header.Unpacked() {
+			format = synchronizer.Directory
+		}
+
+		if err = s.SetFormat(format); err != nil {
+			return err
+		}
+
+		db, err := s.ScanContext(ctx, c.Args().First())
+		if err != nil {
+			if ctx.Err() != nil {
+				return interrupted(c, nil)
+			}
+			return err
+		}
+
+		if err = s.UpdateContext(ctx, c.Args().First(), datfile, db); err != nil {
+			if ctx.Err() != nil {
+				return interrupted(c, nil)
+			}
+			return err
+		}
+	}
+
+	if c.Path("miss") != "" {
+		var missing []string
+		for _, g := range datfile.Game {
+			if !g.Complete() {
+				missing = append(missing, g.Name)
+			}
+		}
+		sort.Strings(missing)
+
+		if err = writeLines(c.Path("miss"), missing); err != nil {
+			return err
+		}
+	}
+
+	e := dat.NewEncoder(os.Stdout)
+	e.Indent("", "\t")
+
+	if err = e.Encode(datfile.Fixdat()); err != nil {
+		return err
+	}
+
+	return nil
+}
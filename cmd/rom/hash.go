@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bodgit/rom"
+	"github.com/urfave/cli/v2"
+)
+
+var errUnknownChecksum = errors.New("unknown checksum algorithm")
+
+var hashCommand = &cli.Command{
+	Name:        "hash",
+	Usage:       "Print ROM checksums",
+	Description: "Print the checksums of files or the members of archives, ignoring any header",
+	Action:      hash,
+	ArgsUsage:   "FILE...",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "algorithm",
+			Aliases: []string{"a"},
+			Value:   cli.NewStringSlice("crc32", "md5", "sha1"),
+			Usage:   "checksum algorithms to print, in order. (" + strings.Join(checksumNames, ", ") + ")",
+		},
+		&cli.StringSliceFlag{
+			Name:    "member",
+			Aliases: []string{"m"},
+			Usage:   "only print this member of each archive",
+		},
+	},
+}
+
+func hash(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	var checksums []rom.Checksum
+	for _, name := range c.StringSlice("algorithm") {
+		t, ok := stringToChecksum[name]
+		if !ok {
+			return fmt.Errorf("%w: %s", errUnknownChecksum, name)
+		}
+		checksums = append(checksums, t)
+	}
+
+	for _, r := range c.Args().Slice() {
+		reader, err := rom.NewReaderContext(c.Context, r)
+		if err != nil {
+			return err
+		}
+
+		files := reader.Files()
+		sort.Strings(files)
+
+		for _, f := range files {
+			if len(c.StringSlice("member")) > 0 && !containsString(c.StringSlice("member"), f) {
+				continue
+			}
+
+			values := make([]string, 0, len(checksums)+1)
+			for _, t := range checksums {
+				b, err := reader.Checksum(f, t)
+				if err != nil {
+					return err
+				}
+				values = append(values, fmt.Sprintf("%x", b))
+			}
+
+			// Plain files are printed as given, anything else by member
+			name := r
+			if _, ok := reader.(*rom.FileReader); !ok {
+				name = r + "/" + f
+			}
+
+			fmt.Println(strings.Join(values, " ") + "  " + name)
+		}
+
+		reader.Close()
+	}
+
+	return nil
+}
+
+func containsString(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/bodgit/rom"
+	"github.com/urfave/cli/v2"
+)
+
+const headerSuffix = ".hdr"
+
+var (
+	errNoHeaderFormat = errors.New("no known header")
+	errHasHeader      = errors.New("already has a header")
+	errInvalidHeader  = errors.New("not a valid header")
+)
+
+var headerCommand = &cli.Command{
+	Name:  "header",
+	Usage: "Inspect, remove or add ROM headers",
+	Subcommands: []*cli.Command{
+		{
+			Name:        "info",
+			Usage:       "Show ROM headers",
+			Description: "Show the header, if any, at the start of each file",
+			Action:      headerInfo,
+			ArgsUsage:   "FILE...",
+		},
+		{
+			Name:        "strip",
+			Usage:       "Remove ROM headers",
+			Description: "Remove the header from each file, either in place or writing the result to another directory. Files without a header are left alone",
+			Action:      headerStrip,
+			ArgsUsage:   "FILE...",
+			Flags: []cli.Flag{
+				&cli.PathFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "write each file to this directory rather than in place",
+				},
+				&cli.BoolFlag{
+					Name:  "save",
+					Usage: "save each header alongside the file with a .hdr suffix so it can be added back",
+				},
+			},
+		},
+		{
+			Name:        "add",
+			Usage:       "Add ROM headers",
+			Description: "Add a header saved by \"header strip --save\" back to each file, either in place or writing the result to another directory",
+			Action:      headerAdd,
+			ArgsUsage:   "FILE...",
+			Flags: []cli.Flag{
+				&cli.PathFlag{
+					Name:    "output",
+					Aliases: []string{"o"},
+					Usage:   "write each file to this directory rather than in place",
+				},
+				&cli.PathFlag{
+					Name:  "header",
+					Usage: "add the header in this file rather than the .hdr file alongside each file",
+				},
+			},
+		},
+	},
+}
+
+func headerInfo(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	for _, file := range c.Args().Slice() {
+		format, ok := rom.HeaderFormat(file)
+		if !ok {
+			fmt.Println(file + ": no known header")
+			continue
+		}
+
+		size, err := readHeader(file, nil)
+		if err != nil {
+			return err
+		}
+
+		if size == 0 {
+			fmt.Println(file+":", "no", format, "header")
+			continue
+		}
+		fmt.Println(file+":", format, "header,", size, "bytes")
+	}
+
+	return nil
+}
+
+// readHeader returns the length of the header at the start of file, if
+// any, and copies the header to w if it isn't nil
+func readHeader(file string, w io.Writer) (uint64, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	_, size, err := rom.StripHeader(file, f)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", file, err)
+	}
+
+	if w != nil && size > 0 {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		if _, err := io.CopyN(w, f, int64(size)); err != nil {
+			return 0, err
+		}
+	}
+
+	return size, nil
+}
+
+// headerOutput returns where to write the new version of file, either in
+// place or within the directory passed with --output
+func headerOutput(c *cli.Context, file string) string {
+	if c.Path("output") == "" {
+		return file
+	}
+	return filepath.Join(c.Path("output"), filepath.Base(file))
+}
+
+func headerStrip(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	for _, file := range c.Args().Slice() {
+		if _, ok := rom.HeaderFormat(file); !ok {
+			return fmt.Errorf("%s: %w", file, errNoHeaderFormat)
+		}
+
+		header := new(bytes.Buffer)
+		size, err := readHeader(file, header)
+		if err != nil {
+			return err
+		}
+		if size == 0 {
+			continue
+		}
+
+		output := headerOutput(c, file)
+
+		if c.Bool("save") {
+			if err := os.WriteFile(output+headerSuffix, header.Bytes(), 0o666); err != nil {
+				return err
+			}
+		}
+
+		if err := writeFile(output, file, func(w io.Writer) error {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if _, err := f.Seek(int64(size), io.SeekStart); err != nil {
+				return err
+			}
+
+			_, err = io.Copy(w, f)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func headerAdd(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	for _, file := range c.Args().Slice() {
+		if _, ok := rom.HeaderFormat(file); !ok {
+			return fmt.Errorf("%s: %w", file, errNoHeaderFormat)
+		}
+
+		size, err := readHeader(file, nil)
+		if err != nil {
+			return err
+		}
+		if size > 0 {
+			return fmt.Errorf("%s: %w", file, errHasHeader)
+		}
+
+		source := c.Path("header")
+		if source == "" {
+			source = file + headerSuffix
+		}
+
+		header, err := os.ReadFile(source)
+		if err != nil {
+			return err
+		}
+
+		// Make sure the header is recognised as such
+		if _, size, err := rom.StripHeader(file, bytes.NewReader(header)); err != nil || size != uint64(len(header)) {
+			return fmt.Errorf("%s: %w", source, errInvalidHeader)
+		}
+
+		if err := writeFile(headerOutput(c, file), file, func(w io.Writer) error {
+			f, err := os.Open(file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			if _, err := w.Write(header); err != nil {
+				return err
+			}
+
+			_, err = io.Copy(w, f)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+	"github.com/bodgit/rom/hasheous"
+	"github.com/bodgit/rom/synchronizer"
+	"github.com/urfave/cli/v2"
+)
+
+// identifyFile returns the dat file with a ROM matching anything within
+// the archive or file called name
+func identifyFile(name string, datfiles []*dat.File) (*dat.File, bool, error) {
+	reader, err := rom.NewReader(name)
+	if err != nil {
+		return nil, false, err
+	}
+	defer reader.Close()
+
+	for _, file := range reader.Files() {
+		size, header, err := reader.Size(file)
+		if err != nil {
+			return nil, false, err
+		}
+
+		for _, t := range []rom.Checksum{rom.CRC32, rom.SHA1, rom.MD5} {
+			b, err := reader.Checksum(file, t)
+			if err != nil {
+				return nil, false, err
+			}
+			for _, datfile := range datfiles {
+				for _, m := range datfile.FindByChecksum(t, fmt.Sprintf("%x", b)) {
+					if m.ROM.Size == size-header {
+						return datfile, true, nil
+					}
+				}
+			}
+		}
+	}
+
+	return nil, false, nil
+}
+
+// newHasheous returns a client for the Hasheous server given with
+// --hasheous, if any
+func newHasheous(c *cli.Context) *hasheous.Client {
+	if c.String("hasheous") == "" {
+		return nil
+	}
+	return hasheous.NewClient(c.String("hasheous"), nil)
+}
+
+// probably returns what Hasheous thinks each file within the archive or
+// file called name is
+func probably(c *cli.Context, client *hasheous.Client, name string) ([]string, error) {
+	reader, err := rom.NewReaderContext(c.Context, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	var titles []string
+	for _, file := range reader.Files() {
+		var checksums hasheous.Checksums
+		for t, p := range map[rom.Checksum]*[]byte{rom.CRC32: &checksums.CRC32, rom.MD5: &checksums.MD5, rom.SHA1: &checksums.SHA1} {
+			if *p, err = reader.Checksum(file, t); err != nil {
+				return nil, err
+			}
+		}
+
+		m, err := client.Lookup(c.Context, checksums)
+		if err != nil {
+			return nil, err
+		}
+		if m != nil {
+			titles = append(titles, m.String())
+		}
+	}
+
+	return titles, nil
+}
+
+// importReport prints each file left in the incoming directories that
+// doesn't match any dat file and returns how many there were
+func importReport(c *cli.Context, incoming []string, datfiles []*dat.File) (int, error) {
+	unknown := 0
+	client := newHasheous(c)
+
+	for _, dir := range incoming {
+		files, _, err := looseFiles(dir)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return 0, err
+		}
+
+		for _, file := range files {
+			_, ok, err := identifyFile(file, datfiles)
+			if err != nil {
+				fmt.Println(file+":", err)
+				unknown++
+				continue
+			}
+			if !ok {
+				unknown++
+				if client == nil {
+					fmt.Println(file+":", "unidentified")
+					continue
+				}
+				titles, err := probably(c, client, file)
+				if err != nil {
+					log.Println(file+":", err)
+				}
+				if len(titles) == 0 {
+					fmt.Println(file+":", "unidentified")
+					continue
+				}
+				fmt.Println(file+":", "unidentified, probably", strings.Join(titles, ", "))
+				continue
+			}
+			if c.Bool("verbose") {
+				fmt.Println(file+":", "not used")
+			}
+		}
+	}
+
+	return unknown, nil
+}
+
+// reportUnidentified prints each file importReport finds and how many
+// there were
+func reportUnidentified(c *cli.Context, incoming []string, datfiles []*dat.File) error {
+	n, err := importReport(c, incoming, datfiles)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Println(n, "file(s) unidentified")
+	}
+	return nil
+}
+
+// importStage moves each file in the incoming directories that matches a
+// dat file into a subdirectory of dir named after its system
+func importStage(c *cli.Context, dir string, incoming []string, datfiles []*dat.File) error {
+	for _, path := range incoming {
+		files, _, err := looseFiles(path)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			datfile, ok, err := identifyFile(file, datfiles)
+			if err != nil || !ok {
+				continue
+			}
+
+			target := filepath.Join(dir, organizeDir([]string{"system"}, organizeMatch{header: datfile.Header}), filepath.Base(file))
+			if _, err := os.Lstat(target); err == nil {
+				log.Println(file+":", target, "already exists")
+				continue
+			}
+
+			if c.Bool("dry-run") || c.Bool("verbose") {
+				fmt.Println(file, "->", target)
+			}
+			if c.Bool("dry-run") {
+				continue
+			}
+
+			if err := moveFile(file, target); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+var importCommand = &cli.Command{
+	Name:        "import",
+	Usage:       "Import new dumps",
+	Description: "Identify every file in each INCOMING directory and use them to build any games in TARGET they provide ROMs for, removing them from INCOMING once everything they contain is in TARGET. With --stage each identified file is instead moved into a subdirectory of TARGET named after the system. Anything left that doesn't match any dat file is listed, along with what a Hasheous server thinks it probably is if --hasheous is given",
+	Action:      importDumps,
+	ArgsUsage:   "TARGET INCOMING...",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "dat",
+			Aliases: []string{"d"},
+			Usage:   "dat file synchronised with its own subdirectory of TARGET, may be repeated. Otherwise one dat file is read from stdin",
+		},
+		&cli.BoolFlag{
+			Name:  "stage",
+			Usage: "move each identified file into a subdirectory of TARGET for its system rather than building games",
+		},
+		&cli.BoolFlag{
+			Name:    "dry-run",
+			Aliases: []string{"n"},
+			Usage:   "don't actually do anything",
+		},
+		&cli.IntFlag{
+			Name:    "workers",
+			Aliases: []string{"w"},
+			Usage:   "number of workers",
+			Value:   runtime.NumCPU(),
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "increase verbosity",
+		},
+		&cli.GenericFlag{
+			Name: "log-format",
+			Value: &enumValue{
+				Enum:    logFormatNames,
+				Default: "text",
+			},
+			Usage: "format of the verbose output. (" + strings.Join(logFormatNames, ", ") + ")",
+		},
+		&cli.GenericFlag{
+			Name:    "algorithm",
+			Aliases: []string{"a"},
+			Value: &enumValue{
+				Enum:    checksumNames,
+				Default: "crc32",
+			},
+			Usage: "checksum algorithm to use. (" + strings.Join(checksumNames, ", ") + ")",
+		},
+		&cli.GenericFlag{
+			Name: "format",
+			Value: &enumValue{
+				Enum:    formatNames,
+				Default: "torrentzip",
+			},
+			Usage: "output format for each game. (" + strings.Join(formatNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "template for the filename of each game",
+		},
+		&cli.GenericFlag{
+			Name: "merging",
+			Value: &enumValue{
+				Enum:    mergingNames,
+				Default: "none",
+			},
+			Usage: "merging mode, overriding any set by the dat file. (" + strings.Join(mergingNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "1g1r",
+			Usage: "only keep the preferred game of each parent/clone set using this comma-separated region priority",
+		},
+		&cli.StringFlag{
+			Name:  "hasheous",
+			Usage: "URL of a Hasheous server, such as https://hasheous.org, to ask what anything unidentified probably is",
+		},
+	},
+}
+
+func importDumps(c *cli.Context) error {
+	if c.NArg() < 2 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	logger, logOption := newLogger(c)
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	datfiles, err := loadDats(c)
+	if err != nil {
+		return err
+	}
+
+	target, incoming := c.Args().First(), c.Args().Tail()
+
+	if c.Bool("stage") {
+		if err := importStage(c, target, incoming, datfiles); err != nil {
+			return err
+		}
+		return reportUnidentified(c, incoming, datfiles)
+	}
+
+	s, err := synchronizer.NewSynchronizer(logOption, synchronizer.Workers(c.Int("workers")), synchronizer.DryRun(c.Bool("dry-run")), synchronizer.ContinueOnError(true), synchronizer.Checksum(stringToChecksum[c.Generic("algorithm").(*enumValue).String()]))
+	if err != nil {
+		return err
+	}
+
+	if c.String("template") != "" {
+		if err = s.SetTemplate(c.String("template")); err != nil {
+			return err
+		}
+	}
+
+	format := stringToFormat[c.Generic("format").(*enumValue).String()]
+	if !c.IsSet("format") && len(c.StringSlice("dat")) == 0 && datfiles[0].Header.Unpacked() {
+		format = synchronizer.Directory
+	}
+
+	if err = s.SetFormat(format); err != nil {
+		return err
+	}
+
+	// Scanning the incoming files separately means only the games they
+	// provide anything for need updating
+	db, err := s.ScanContext(ctx, target)
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		return err
+	}
+
+	changed, err := s.ScanContext(ctx, incoming...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		return err
+	}
+	db.Merge(changed)
+
+	var uerr *synchronizer.UpdateError
+	for i, dir := range datTargets(c, datfiles) {
+		if err = s.UpdateChangedContext(ctx, dir, datfiles[i], db, changed); err != nil && !errors.As(err, &uerr) {
+			if ctx.Err() != nil {
+				return interrupted(c, s)
+			}
+			return err
+		}
+	}
+
+	// Anything now in the target, or already there, isn't needed
+	if uerr == nil {
+		if err = s.PruneContext(ctx, target, db, incoming...); err != nil {
+			if ctx.Err() != nil {
+				return interrupted(c, s)
+			}
+			return err
+		}
+	}
+
+	stats := s.Stats()
+	logger.Println("Created", stats.Created, "modified", stats.Modified, "and imported", stats.Pruned, "file(s)")
+
+	if err := reportUnidentified(c, incoming, datfiles); err != nil {
+		return err
+	}
+
+	return failed(uerr)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/bodgit/rom"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+type romInfo struct {
+	Name   string `json:"name"`
+	Size   uint64 `json:"size"`
+	Header uint64 `json:"header"`
+	CRC32  string `json:"crc"`
+	MD5    string `json:"md5"`
+	SHA1   string `json:"sha1"`
+	RA     string `json:"ra,omitempty"`
+}
+
+// raHash returns the RetroAchievements hash of file in reader, if it has one
+func raHash(reader rom.Reader, file string) (string, error) {
+	rc, err := reader.Open(file)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	h, err := rom.RAHash(file, rc)
+	switch {
+	case errors.Is(err, rom.ErrRAUnsupported):
+		return "", nil
+	case err != nil:
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", h), nil
+}
+
+// readInfo returns the size, header and checksums, including any
+// RetroAchievements hash, of every file in the archive or file called name,
+// sorted by name
+func readInfo(ctx context.Context, name string) ([]romInfo, error) {
+	reader, err := rom.NewReaderContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	files := reader.Files()
+	sort.Strings(files)
+
+	roms := make([]romInfo, 0, len(files))
+	for _, f := range files {
+		size, header, err := reader.Size(f)
+		if err != nil {
+			return nil, err
+		}
+
+		c, err := reader.Checksum(f, rom.CRC32)
+		if err != nil {
+			return nil, err
+		}
+
+		m, err := reader.Checksum(f, rom.MD5)
+		if err != nil {
+			return nil, err
+		}
+
+		s, err := reader.Checksum(f, rom.SHA1)
+		if err != nil {
+			return nil, err
+		}
+
+		ra, err := raHash(reader, f)
+		if err != nil {
+			return nil, err
+		}
+
+		roms = append(roms, romInfo{f, size - header, header, fmt.Sprintf("%x", c), fmt.Sprintf("%x", m), fmt.Sprintf("%x", s), ra})
+	}
+
+	return roms, nil
+}
+
+var infoCommand = &cli.Command{
+	Name:        "info",
+	Usage:       "ROM information",
+	Description: "",
+	Action:      info,
+	ArgsUsage:   "",
+	Flags: []cli.Flag{
+		&cli.GenericFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Value: &enumValue{
+				Enum:    []string{"csv", "json", "table"},
+				Default: "table",
+			},
+			Usage: "output format. (csv, json, table)",
+		},
+	},
+}
+
+func info(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	output := c.Generic("output").(*enumValue).String()
+
+	var w *csv.Writer
+	if output == "csv" {
+		w = csv.NewWriter(os.Stdout)
+		if err := w.Write([]string{"file", "rom", "size", "header", "crc32", "md5", "sha1", "ra"}); err != nil {
+			return err
+		}
+	}
+
+	for i, r := range c.Args().Slice() {
+		roms, err := readInfo(c.Context, r)
+		if err != nil {
+			return err
+		}
+
+		switch output {
+		case "json":
+			if err := json.NewEncoder(os.Stdout).Encode(struct {
+				File string    `json:"file"`
+				ROMs []romInfo `json:"roms"`
+			}{r, roms}); err != nil {
+				return err
+			}
+		case "csv":
+			for _, ri := range roms {
+				if err := w.Write([]string{r, ri.Name, strconv.FormatUint(ri.Size, 10), strconv.FormatUint(ri.Header, 10), ri.CRC32, ri.MD5, ri.SHA1, ri.RA}); err != nil {
+					return err
+				}
+			}
+		default:
+			if i > 0 {
+				fmt.Println()
+			}
+
+			fmt.Println(r)
+			fmt.Println()
+
+			table := tablewriter.NewWriter(os.Stdout)
+			table.SetBorder(false)
+			table.SetCenterSeparator("")
+			table.SetColumnSeparator("")
+			table.SetAutoWrapText(false)
+
+			table.SetHeader([]string{"ROM", "Size", "Header", "CRC32", "MD5", "SHA1", "RA"})
+
+			for _, ri := range roms {
+				table.Append([]string{ri.Name, strconv.FormatUint(ri.Size, 10), strconv.FormatUint(ri.Header, 10), ri.CRC32, ri.MD5, ri.SHA1, ri.RA})
+			}
+
+			table.Render()
+		}
+	}
+
+	if w != nil {
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+	"github.com/bodgit/rom/hasheous"
+	"github.com/bodgit/rom/openvgdb"
+	"github.com/urfave/cli/v2"
+)
+
+// hashLengths maps the length of a checksum as a hex string to its type
+var hashLengths = map[int]rom.Checksum{
+	8:  rom.CRC32,
+	32: rom.MD5,
+	40: rom.SHA1,
+}
+
+var lookupCommand = &cli.Command{
+	Name:        "lookup",
+	Usage:       "Identify ROMs",
+	Description: "Find the games and ROMs in the dat files matching each file, the members of each archive, or each CRC32, MD5 or SHA1 checksum. Anything not in the dat files can also be identified using an OpenVGDB database or a Hasheous server",
+	Action:      lookup,
+	ArgsUsage:   "FILE|CHECKSUM...",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "dat",
+			Aliases: []string{"d"},
+			Usage:   "path to a dat file to search",
+		},
+		&cli.PathFlag{
+			Name:  "openvgdb",
+			Usage: "path to an OpenVGDB database to search for anything not in the dat files",
+		},
+		&cli.StringFlag{
+			Name:  "hasheous",
+			Usage: "URL of a Hasheous server, such as https://hasheous.org, to ask about anything not in the dat files or OpenVGDB database",
+		},
+	},
+}
+
+func lookup(c *cli.Context) error {
+	if c.NArg() < 1 || (len(c.StringSlice("dat")) == 0 && c.Path("openvgdb") == "" && c.String("hasheous") == "") {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	var vgdb *openvgdb.DB
+	if c.Path("openvgdb") != "" {
+		var err error
+		if vgdb, err = openvgdb.Open(c.Path("openvgdb")); err != nil {
+			return err
+		}
+	}
+
+	datfiles := make([]*dat.File, 0, len(c.StringSlice("dat")))
+	for _, file := range c.StringSlice("dat") {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		datfile := new(dat.File)
+		if err := unmarshal(b, datfile); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		datfiles = append(datfiles, datfile)
+	}
+
+	found := func(name string, t rom.Checksum, value string, size uint64, sized bool) int {
+		n := 0
+		for i, datfile := range datfiles {
+			for _, m := range datfile.FindByChecksum(t, value) {
+				// A CRC32 alone is too easy to collide with
+				if sized && m.ROM.Size != size {
+					continue
+				}
+				fmt.Println(name+":", c.StringSlice("dat")[i]+":", m.Game.Name+":", m.ROM.Name)
+				n++
+			}
+		}
+		return n
+	}
+
+	// OpenVGDB is only consulted for anything not in the dat files
+	identified := func(name string, t rom.Checksum, value string, size uint64, sized bool) int {
+		if vgdb == nil {
+			return 0
+		}
+		n := 0
+		for _, r := range vgdb.FindByChecksum(t, value) {
+			if sized && r.Size != size {
+				continue
+			}
+			title := r.Title()
+			if r.Region != "" && !strings.Contains(title, "("+r.Region+")") {
+				title += " (" + r.Region + ")"
+			}
+			fmt.Println(name+":", "openvgdb:", r.System+":", title)
+			n++
+		}
+		return n
+	}
+
+	// Hasheous is only asked as a last resort
+	client := newHasheous(c)
+	online := func(name string, checksums hasheous.Checksums) (int, error) {
+		if client == nil {
+			return 0, nil
+		}
+		m, err := client.Lookup(c.Context, checksums)
+		if err != nil || m == nil {
+			return 0, err
+		}
+		fmt.Println(name+":", "hasheous:", m.Platform+":", m.Name)
+		return 1, nil
+	}
+
+	for _, arg := range c.Args().Slice() {
+		if _, err := os.Stat(arg); err != nil {
+			t, ok := hashLengths[len(arg)]
+			b, herr := hex.DecodeString(arg)
+			if !ok || herr != nil {
+				return err
+			}
+			var checksums hasheous.Checksums
+			switch t {
+			case rom.CRC32:
+				checksums.CRC32 = b
+			case rom.MD5:
+				checksums.MD5 = b
+			case rom.SHA1:
+				checksums.SHA1 = b
+			}
+			if found(arg, t, arg, 0, false) > 0 || identified(arg, t, arg, 0, false) > 0 {
+				continue
+			}
+			n, err := online(arg, checksums)
+			if err != nil {
+				return err
+			}
+			if n == 0 {
+				fmt.Println(arg+":", "no match")
+			}
+			continue
+		}
+
+		reader, err := rom.NewReaderContext(c.Context, arg)
+		if err != nil {
+			return err
+		}
+
+		files := reader.Files()
+		sort.Strings(files)
+
+		for _, f := range files {
+			size, header, err := reader.Size(f)
+			if err != nil {
+				return err
+			}
+
+			name := arg
+			if _, ok := reader.(*rom.FileReader); !ok {
+				name = arg + "/" + f
+			}
+
+			// Not every dat file has every checksum
+			checksums := make(map[rom.Checksum]string, 3)
+			n := 0
+			for _, t := range []rom.Checksum{rom.SHA1, rom.MD5, rom.CRC32} {
+				b, err := reader.Checksum(f, t)
+				if err != nil {
+					return err
+				}
+				checksums[t] = fmt.Sprintf("%x", b)
+
+				if n = found(name, t, checksums[t], size-header, true); n > 0 {
+					break
+				}
+			}
+			for _, t := range []rom.Checksum{rom.SHA1, rom.MD5, rom.CRC32} {
+				if n > 0 {
+					break
+				}
+				n = identified(name, t, checksums[t], size-header, true)
+			}
+			if n == 0 {
+				var sums hasheous.Checksums
+				sums.CRC32, _ = hex.DecodeString(checksums[rom.CRC32])
+				sums.MD5, _ = hex.DecodeString(checksums[rom.MD5])
+				sums.SHA1, _ = hex.DecodeString(checksums[rom.SHA1])
+				if n, err = online(name, sums); err != nil {
+					return err
+				}
+			}
+			if n == 0 {
+				fmt.Println(name+":", "no match")
+			}
+		}
+
+		reader.Close()
+	}
+
+	return nil
+}
@@ -1,21 +1,27 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"runtime"
+	"os/signal"
+	"path/filepath"
+	"reflect"
 	"sort"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/bodgit/rom"
 	"github.com/bodgit/rom/dat"
 	"github.com/bodgit/rom/synchronizer"
-	"github.com/olekukonko/tablewriter"
+	"github.com/bodgit/rom/torrent"
 	"github.com/urfave/cli/v2"
 )
 
@@ -31,6 +37,50 @@ var stringToChecksum = map[string]rom.Checksum{
 	"sha1":  rom.SHA1,
 }
 
+var stringToFormat = map[string]synchronizer.WriterFactory{
+	"directory":  synchronizer.Directory,
+	"torrentzip": synchronizer.TorrentZip,
+	"zip":        synchronizer.Zip,
+}
+
+var stringToLink = map[string]synchronizer.LinkMode{
+	"none":    synchronizer.LinkNone,
+	"hard":    synchronizer.LinkHard,
+	"reflink": synchronizer.LinkReflink,
+}
+
+var stringToDatFormat = map[string]func(*dat.File) ([]byte, error){
+	"clrmamepro": dat.MarshalClrMamePro,
+	"logiqx":     marshalLogiqx,
+	"romcenter":  dat.MarshalRomCenter,
+}
+
+var stringToDuplicate = map[string]dat.Duplicate{
+	"combine": dat.DuplicateCombine,
+	"first":   dat.DuplicateFirst,
+	"last":    dat.DuplicateLast,
+	"rename":  dat.DuplicateRename,
+	"error":   dat.DuplicateError,
+}
+
+var stringToConflict = map[string]dat.Conflict{
+	"error": dat.ConflictError,
+	"first": dat.ConflictFirst,
+	"last":  dat.ConflictLast,
+}
+
+var stringToMerging = map[string]dat.Merging{
+	"none":  dat.NonMerged,
+	"split": dat.Split,
+	"full":  dat.FullMerged,
+}
+
+var stringToTorrentVersion = map[string]torrent.Version{
+	"v1":     torrent.V1,
+	"v2":     torrent.V2,
+	"hybrid": torrent.Hybrid,
+}
+
 type enumValue struct {
 	Enum     []string
 	Default  string
@@ -55,6 +105,31 @@ func (e *enumValue) String() string {
 	return e.selected
 }
 
+// The values accepted by each enum flag, sorted for the help text
+var (
+	formatNames         = enumNames(stringToFormat)
+	linkNames           = enumNames(stringToLink)
+	mergingNames        = enumNames(stringToMerging)
+	datFormatNames      = enumNames(stringToDatFormat)
+	duplicateNames      = enumNames(stringToDuplicate)
+	conflictNames       = enumNames(stringToConflict)
+	logFormatNames      = []string{"json", "text"}
+	torrentVersionNames = enumNames(stringToTorrentVersion)
+	checksumNames       = enumNames(stringToChecksum)
+)
+
+// enumNames returns the keys of m, a map of flag values to what each
+// selects, sorted
+func enumNames(m interface{}) []string {
+	keys := reflect.ValueOf(m).MapKeys()
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		names = append(names, k.String())
+	}
+	sort.Strings(names)
+	return names
+}
+
 func init() {
 	cli.VersionFlag = &cli.BoolFlag{
 		Name:    "version",
@@ -63,141 +138,387 @@ func init() {
 	}
 }
 
-func sync(c *cli.Context) error {
-	if c.NArg() < 1 {
-		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+func humanize(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
 	}
-
-	logger := log.New(io.Discard, "", 0)
-	if c.Bool("verbose") {
-		logger.SetOutput(os.Stderr)
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
 	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
 
-	s, err := synchronizer.NewSynchronizer(synchronizer.Logger(logger), synchronizer.Workers(c.Int("workers")), synchronizer.DryRun(c.Bool("dry-run")), synchronizer.Checksum(stringToChecksum[c.Generic("algorithm").(*enumValue).String()]))
+// parseSize parses a number of bytes with an optional K, M or G suffix
+func parseSize(v string) (int64, error) {
+	mult := int64(1)
+	if i := strings.IndexAny(strings.ToUpper(v), "KMG"); i > 0 && i == len(v)-1 {
+		mult = int64(1) << (10 * (strings.IndexByte("KMG", strings.ToUpper(v)[i]) + 1))
+		v = v[:i]
+	}
+	n, err := strconv.ParseInt(v, 10, 64)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
+	return n * mult, nil
+}
 
-	if c.Path("mia") != "" {
-		f, err := os.Open(c.Path("mia"))
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer f.Close()
-
-		if err = s.SetMissing(f); err != nil {
-			log.Fatal(err)
-		}
+func bar(done, total uint64) string {
+	const width = 30
+	if total == 0 {
+		return ""
 	}
-
-	start := time.Now()
-	db, err := s.Scan(c.Args().Slice()...)
-	if err != nil {
-		log.Fatal(err)
+	n := int(done * width / total)
+	if n > width {
+		n = width
 	}
-	elapsed := time.Since(start)
+	return fmt.Sprintf("[%s%s] %3d%% ", strings.Repeat("#", n), strings.Repeat(".", width-n), done*100/total)
+}
 
-	logger.Println("Read", s.Rx(), "bytes in", elapsed)
+// isTerminal returns true if f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
 
-	s.Reset()
+// showProgress returns true if the progress bar should be drawn. Unless
+// asked for or against it is shown whenever it would be seen and isn't
+// going to be mixed up with verbose output
+func showProgress(c *cli.Context) bool {
+	if c.IsSet("progress") {
+		return c.Bool("progress")
+	}
+	return !c.Bool("verbose") && isTerminal(os.Stderr)
+}
 
-	b, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		log.Fatal(err)
+// eta estimates how long is left if done of total took elapsed
+func eta(elapsed time.Duration, done, total uint64) string {
+	if done == 0 || total <= done {
+		return ""
 	}
+	left := time.Duration(float64(elapsed) * float64(total-done) / float64(done))
+	return ", ETA " + left.Round(time.Second).String()
+}
 
-	datfile := new(dat.File)
-	if err = xml.Unmarshal(b, datfile); err != nil {
-		log.Fatal(err)
+// rate returns n bytes over elapsed as a human readable speed
+func rate(n uint64, elapsed time.Duration) string {
+	if elapsed < time.Second {
+		return ""
 	}
+	return ", " + humanize(uint64(float64(n)/elapsed.Seconds())) + "/s"
+}
 
-	start = time.Now()
-	if err = s.Update(c.Args().First(), datfile, db); err != nil {
-		log.Fatal(err)
+// progressBar returns a function that renders a ProgressEvent as a single
+// line on w, redrawing at most a few times a second. The speed and ETA are
+// measured from the start of each phase
+func progressBar(w io.Writer) func(synchronizer.ProgressEvent) {
+	var (
+		last, start time.Time
+		phase       = synchronizer.Phase(-1)
+	)
+	return func(e synchronizer.ProgressEvent) {
+		if e.Phase != phase || e.Files+e.Games <= 1 {
+			phase, start = e.Phase, time.Now()
+		}
+
+		finished := e.Phase == synchronizer.Scanning && e.Files == e.TotalFiles || e.Phase == synchronizer.Updating && e.TotalGames > 0 && e.Games == e.TotalGames
+		if !finished && time.Since(last) < 100*time.Millisecond {
+			return
+		}
+		last = time.Now()
+
+		elapsed := time.Since(start)
+
+		switch e.Phase {
+		case synchronizer.Scanning:
+			fmt.Fprintf(w, "\r\033[KScanning %s%d/%d files, %s%s%s", bar(e.Files, e.TotalFiles), e.Files, e.TotalFiles, humanize(e.Bytes), rate(e.Bytes, elapsed), eta(elapsed, e.Files, e.TotalFiles))
+		case synchronizer.Updating:
+			if e.TotalGames > 0 {
+				fmt.Fprintf(w, "\r\033[KUpdating %s%d/%d games, %s%s%s", bar(e.Games, e.TotalGames), e.Games, e.TotalGames, humanize(e.Bytes), rate(e.Bytes, elapsed), eta(elapsed, e.Games, e.TotalGames))
+			} else {
+				fmt.Fprintf(w, "\r\033[KUpdating %d games, %s%s", e.Games, humanize(e.Bytes), rate(e.Bytes, elapsed))
+			}
+		}
 	}
-	elapsed = time.Since(start)
+}
 
-	logger.Println("Read", s.Rx(), "bytes and wrote", s.Tx(), "bytes in", elapsed)
+// unmarshal parses either a Logiqx or OfflineList dat file based on the
+// name of the root element
+func unmarshal(b []byte, datfile *dat.File) error {
+	switch text := bytes.TrimLeft(bytes.TrimPrefix(b, []byte("\ufeff")), " \t\r\n"); {
+	case bytes.HasPrefix(b, []byte(dat.RDBMagic)):
+		return dat.UnmarshalRDB(b, datfile)
+	case bytes.HasPrefix(text, []byte("[")):
+		return dat.UnmarshalRomCenter(b, datfile)
+	case len(text) > 0 && text[0] != '<':
+		return dat.UnmarshalClrMamePro(b, datfile)
+	}
 
-	if err = s.Delete(c.Args().First(), datfile); err != nil {
-		log.Fatal(err)
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+		if start, ok := t.(xml.StartElement); ok {
+			switch start.Name.Local {
+			case "dat":
+				return dat.UnmarshalOfflineList(b, datfile)
+			case "mame":
+				return unmarshalListXML(b, start, datfile)
+			}
+			return dat.Unmarshal(b, datfile)
+		}
 	}
+}
 
-	if b, err = xml.MarshalIndent(datfile, "", "\t"); err != nil {
-		log.Fatal(err)
+// unmarshalListXML reads the output of "mame -listxml", which has no header
+// so one is made up from the build attribute of the root element
+func unmarshalListXML(b []byte, root xml.StartElement, datfile *dat.File) error {
+	var games []dat.Game
+	f, err := dat.Decode(bytes.NewReader(b), func(g dat.Game) error {
+		games = append(games, g)
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	if len(b) > 0 {
-		// Need to add a final newline if there is some XML
-		if _, err = os.Stdout.Write(append(b, []byte("\n")...)); err != nil {
-			log.Fatal(err)
+	f.Header.Name = "MAME"
+	f.Header.Description = "MAME"
+	for _, attr := range root.Attr {
+		if attr.Name.Local == "build" {
+			f.Header.Description += " " + attr.Value
+			f.Header.Version = attr.Value
 		}
 	}
 
+	datfile.Header = f.Header
+	datfile.Declaration = f.Declaration
+	datfile.Game = games
+
 	return nil
 }
 
-func info(c *cli.Context) error {
-	if c.NArg() < 1 {
-		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+// loadDats returns each dat file passed with --dat, otherwise the single
+// dat file read from stdin
+func loadDats(c *cli.Context) ([]*dat.File, error) {
+	if len(c.StringSlice("dat")) == 0 {
+		b, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return nil, err
+		}
+
+		datfile, err := loadDat(c, b)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*dat.File{datfile}, nil
 	}
 
-	for i, r := range c.Args().Slice() {
-		reader, err := rom.NewReader(r)
+	datfiles := make([]*dat.File, 0, len(c.StringSlice("dat")))
+	for _, file := range c.StringSlice("dat") {
+		b, err := os.ReadFile(file)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 
-		if i > 0 {
-			fmt.Println()
+		datfile, err := loadDat(c, b)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
 		}
+		datfiles = append(datfiles, datfile)
+	}
 
-		fmt.Println(r)
-		fmt.Println()
+	return datfiles, nil
+}
 
-		table := tablewriter.NewWriter(os.Stdout)
-		table.SetBorder(false)
-		table.SetCenterSeparator("")
-		table.SetColumnSeparator("")
-		table.SetAutoWrapText(false)
+func writeLines(file string, lines []string) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-		table.SetHeader([]string{"ROM", "Size", "Header", "CRC32", "MD5", "SHA1"})
+	w := bufio.NewWriter(f)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
 
-		files := reader.Files()
-		sort.Strings(files)
+	if err := w.Flush(); err != nil {
+		return err
+	}
 
-		for _, f := range files {
-			size, header, err := reader.Size(f)
-			if err != nil {
-				log.Fatal(err)
-			}
+	return f.Close()
+}
 
-			c, err := reader.Checksum(f, rom.CRC32)
-			if err != nil {
-				log.Fatal(err)
-			}
+// loadDat unmarshals a dat file and applies any merging and 1G1R filtering
+func loadDat(c *cli.Context, b []byte) (*dat.File, error) {
+	datfile := new(dat.File)
+	if err := unmarshal(b, datfile); err != nil {
+		return nil, err
+	}
 
-			m, err := reader.Checksum(f, rom.MD5)
-			if err != nil {
-				log.Fatal(err)
-			}
+	if m, ok := datfile.Header.Merging(); c.IsSet("merging") {
+		datfile.ApplyMerging(stringToMerging[c.Generic("merging").(*enumValue).String()])
+	} else if ok {
+		datfile.ApplyMerging(m)
+	}
 
-			s, err := reader.Checksum(f, rom.SHA1)
-			if err != nil {
-				log.Fatal(err)
-			}
+	if c.String("1g1r") != "" {
+		datfile = datfile.OneGameOneROM(strings.Split(c.String("1g1r"), ",")...)
+	}
 
-			table.Append([]string{f, strconv.FormatUint(size-header, 10), strconv.FormatUint(header, 10), fmt.Sprintf("%x", c), fmt.Sprintf("%x", m), fmt.Sprintf("%x", s)})
-		}
+	return datfile, nil
+}
+
+// logWriter passes each line written by a log.Logger to a LeveledLogger
+type logWriter struct {
+	l synchronizer.LeveledLogger
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.l.Log(synchronizer.LevelInfo, strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
+
+// newLogger returns the logger for the messages printed by the command and
+// the option for the synchronizer to log the same way. Both only print to
+// stderr with --verbose, either as plain text or as JSON if asked
+func newLogger(c *cli.Context) (*log.Logger, func(*synchronizer.Synchronizer) error) {
+	var w io.Writer = io.Discard
+	if c.Bool("verbose") {
+		w = os.Stderr
+	}
+
+	if c.Generic("log-format").(*enumValue).String() == "json" {
+		l := synchronizer.JSONLogger(w)
+		return log.New(logWriter{l}, "", 0), synchronizer.LevelLogger(l)
+	}
+
+	logger := log.New(w, "", 0)
+	return logger, synchronizer.Logger(logger)
+}
+
+// Exit codes used so scripts can tell what happened. Any other error,
+// including games that failed to update, uses the same exit code as
+// log.Fatal. Being interrupted uses the same exit code as the
+// shell would
+const (
+	exitError       = 1
+	exitMissing     = 2
+	exitInvalid     = 3
+	exitInterrupted = 130
+)
+
+// signalContext returns a context that is cancelled by SIGINT or SIGTERM.
+// After that the signals are no longer caught so a second one stops the
+// program straight away
+func signalContext(parent context.Context) (context.Context, context.CancelFunc) {
+	ctx, stop := signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	return ctx, stop
+}
+
+// interrupted prints what was done before a signal stopped s, or just that
+// it happened for commands without one, and returns the error to exit
+// with. Returning rather than exiting lets any deferred cleanup still happen
+func interrupted(c *cli.Context, s *synchronizer.Synchronizer) error {
+	if s == nil {
+		log.Println("Interrupted")
+		return cli.Exit("", exitInterrupted)
+	}
+
+	if showProgress(c) {
+		fmt.Fprintln(os.Stderr)
+	}
 
-		table.Render()
+	stats := s.Stats()
+	log.Println("Interrupted after creating", stats.Created, "modifying", stats.Modified, "renaming", stats.Renamed, "and pruning", stats.Pruned, "file(s)")
+	if fi, err := os.Stat(c.Path("journal")); err == nil && fi.Size() > 0 {
+		log.Println("Journal kept in", c.Path("journal"))
+	}
 
-		reader.Close()
+	return cli.Exit("", exitInterrupted)
+}
+
+// failed logs each game that failed and returns the error to exit with if
+// there were any. Returning rather than exiting lets any deferred cleanup,
+// such as saving the cache, still happen
+func failed(uerr *synchronizer.UpdateError) error {
+	if uerr == nil {
+		return nil
+	}
+	for _, err := range uerr.Errors {
+		log.Println(err)
 	}
+	log.Println(len(uerr.Errors), "game(s) failed")
+	return cli.Exit("", exitError)
+}
 
+// stillMissing returns the error to exit with if any games are still
+// missing after synchronising
+func stillMissing(stats synchronizer.Stats) error {
+	if stats.Missing > 0 {
+		return cli.Exit("", exitMissing)
+	}
 	return nil
 }
 
+// marshalLogiqx encodes datfile as a Logiqx XML dat file
+func marshalLogiqx(datfile *dat.File) ([]byte, error) {
+	b := new(bytes.Buffer)
+
+	e := dat.NewEncoder(b)
+	e.Indent("", "\t")
+
+	if err := e.Encode(datfile); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// writeFile writes name with the same permissions as source by way of a
+// temporary file in the same directory so that name can also be source
+func writeFile(name, source string, fn func(io.Writer) error) error {
+	fi, err := os.Stat(source)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := f.Chmod(fi.Mode().Perm()); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+	if err := fn(w); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), name)
+}
+
 func main() {
 	app := cli.NewApp()
 
@@ -205,62 +526,48 @@ func main() {
 	app.Usage = "ROM management utility"
 	app.Version = fmt.Sprintf("%s, commit %s, built at %s", version, commit, date)
 
-	checksums := make([]string, 0, len(stringToChecksum))
-	for k := range stringToChecksum {
-		checksums = append(checksums, k)
+	app.Commands = []*cli.Command{
+		infoCommand,
+		cleanCommand,
+		completionCommand,
+		convertCommand,
+		datCommand,
+		datdiffCommand,
+		datmergeCommand,
+		dedupeCommand,
+		dir2datCommand,
+		extractCommand,
+		fixdatCommand,
+		headerCommand,
+		hashCommand,
+		importCommand,
+		lookupCommand,
+		organizeCommand,
+		patchCommand,
+		renameCommand,
+		scanCommand,
+		torrentzipCommand,
+		verifyCommand,
+		scrubCommand,
+		serveCommand,
+		statsCommand,
+		syncCommand,
+		watchCommand,
 	}
-	sort.Strings(checksums)
 
-	app.Commands = []*cli.Command{
-		{
-			Name:        "info",
-			Usage:       "ROM information",
-			Description: "",
-			Action:      info,
-			ArgsUsage:   "",
-		},
-		{
-			Name:        "sync",
-			Usage:       "Synchronise ROMs",
-			Description: "Build a directory of Torrentzipped ROMs",
-			Action:      sync,
-			ArgsUsage:   "TARGET [SOURCE...]",
-			Flags: []cli.Flag{
-				&cli.BoolFlag{
-					Name:    "dry-run",
-					Aliases: []string{"n"},
-					Usage:   "don't actually do anything",
-				},
-				&cli.IntFlag{
-					Name:    "workers",
-					Aliases: []string{"w"},
-					Usage:   "number of workers",
-					Value:   runtime.NumCPU(),
-				},
-				&cli.BoolFlag{
-					Name:    "verbose",
-					Aliases: []string{"v"},
-					Usage:   "increase verbosity",
-				},
-				&cli.GenericFlag{
-					Name:    "algorithm",
-					Aliases: []string{"a"},
-					Value: &enumValue{
-						Enum:    checksums,
-						Default: "crc32",
-					},
-					Usage: "checksum algorithm to use. (" + strings.Join(checksums, ", ") + ")",
-				},
-				&cli.PathFlag{
-					Name:    "mia",
-					Aliases: []string{"m"},
-					Usage:   "path to file containing list of games to ignore",
-				},
-			},
-		},
-	}
-
-	if err := app.Run(os.Args); err != nil {
+	app.EnableBashCompletion = true
+	setCompletion(app.Commands, checksumNames)
+
+	// Exiting with a particular code happens straight after the action
+	// returns, so any remote connections are closed before then
+	app.ExitErrHandler = func(c *cli.Context, err error) {
+		rom.CloseConnections()
+		cli.HandleExitCoder(err)
+	}
+
+	err := app.Run(os.Args)
+	rom.CloseConnections()
+	if err != nil {
 		log.Fatal(err)
 	}
 }
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/bodgit/rom/dat"
+)
+
+// metrics accumulates what a long-running command has done across every
+// run and serves it in the Prometheus text format
+type metrics struct {
+	mutex   gosync.Mutex
+	rx      uint64
+	runs    uint64
+	errors  uint64
+	run     time.Time
+	success time.Time
+	dats    []datStats
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// read adds n bytes read by a synchronizer, which is reset or replaced
+// between runs
+func (m *metrics) read(n uint64) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.rx += n
+}
+
+// failed counts an error that didn't stop a run
+func (m *metrics) failed() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.errors++
+}
+
+// finished records the end of a run and the state of each dat file after
+// it, which are left as they were if err is not nil
+func (m *metrics) finished(datfiles []*dat.File, err error) {
+	dats := make([]datStats, 0, len(datfiles))
+	for _, datfile := range datfiles {
+		dats = append(dats, newDatStats(datfile))
+	}
+	m.finishedStats(dats, err)
+}
+
+func (m *metrics) finishedStats(dats []datStats, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.runs++
+	m.run = time.Now()
+	if err != nil {
+		m.errors++
+		return
+	}
+	m.success = m.run
+	m.dats = dats
+}
+
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+
+	b := bufio.NewWriter(w)
+	defer b.Flush()
+
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	timestamp := func(t time.Time) float64 {
+		if t.IsZero() {
+			return 0
+		}
+		return float64(t.UnixNano()) / 1e9
+	}
+
+	metric("rom_read_bytes_total", "counter", "Bytes read and hashed while scanning and updating.")
+	fmt.Fprintln(b, "rom_read_bytes_total", m.rx)
+	metric("rom_runs_total", "counter", "Scans or updates run.")
+	fmt.Fprintln(b, "rom_runs_total", m.runs)
+	metric("rom_errors_total", "counter", "Errors while scanning or updating.")
+	fmt.Fprintln(b, "rom_errors_total", m.errors)
+	metric("rom_last_run_timestamp_seconds", "gauge", "When the last scan or update finished.")
+	fmt.Fprintf(b, "rom_last_run_timestamp_seconds %.3f\n", timestamp(m.run))
+	metric("rom_last_success_timestamp_seconds", "gauge", "When the last scan or update finished without an error.")
+	fmt.Fprintf(b, "rom_last_success_timestamp_seconds %.3f\n", timestamp(m.success))
+
+	metric("rom_games", "gauge", "Games in each dat file by whether they are complete, partial or missing entirely.")
+	for _, ds := range m.dats {
+		name := labelEscaper.Replace(ds.Name)
+		fmt.Fprintf(b, "rom_games{dat=\"%s\",status=\"complete\"} %d\n", name, ds.Have)
+		fmt.Fprintf(b, "rom_games{dat=\"%s\",status=\"partial\"} %d\n", name, ds.Partial)
+		fmt.Fprintf(b, "rom_games{dat=\"%s\",status=\"missing\"} %d\n", name, ds.Missing-ds.Partial)
+	}
+	metric("rom_roms", "gauge", "ROMs in each dat file by whether they are present or missing.")
+	for _, ds := range m.dats {
+		name := labelEscaper.Replace(ds.Name)
+		fmt.Fprintf(b, "rom_roms{dat=\"%s\",status=\"have\"} %d\n", name, ds.ROMsHave)
+		fmt.Fprintf(b, "rom_roms{dat=\"%s\",status=\"missing\"} %d\n", name, ds.ROMs-ds.ROMsHave)
+	}
+}
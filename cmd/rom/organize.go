@@ -0,0 +1,363 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+	"github.com/urfave/cli/v2"
+)
+
+var errUnknownLevel = errors.New("unknown subdirectory level")
+
+// organizeMatch is the first ROM matching a loose file along with the
+// header of the dat file it came from
+type organizeMatch struct {
+	header dat.Header
+	dat.Match
+}
+
+var organizeCommand = &cli.Command{
+	Name:        "organize",
+	Usage:       "Sort loose ROMs into directories",
+	Description: "Move each loose file matching a ROM in one of the dat files into subdirectories named after the dat file and/or the regions in the game name",
+	Action:      organize,
+	ArgsUsage:   "PATH...",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "dat",
+			Aliases:  []string{"d"},
+			Usage:    "dat file to match against, may be repeated",
+			Required: true,
+		},
+		&cli.StringSliceFlag{
+			Name:  "by",
+			Usage: "comma-separated list of how to name each level of subdirectory. (system, region)",
+			Value: cli.NewStringSlice("system"),
+		},
+		&cli.PathFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "directory to create the subdirectories in, otherwise the directory containing each file",
+		},
+		&cli.BoolFlag{
+			Name:  "rename",
+			Usage: "also rename each file to match the dat file",
+		},
+		&cli.BoolFlag{
+			Name:    "dry-run",
+			Aliases: []string{"n"},
+			Usage:   "only print what would be moved",
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "print each file moved or not matched",
+		},
+	},
+}
+
+func organize(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	for _, level := range c.StringSlice("by") {
+		if level != "system" && level != "region" {
+			return fmt.Errorf("%w: %s", errUnknownLevel, level)
+		}
+	}
+
+	datfiles := make([]*dat.File, 0, len(c.StringSlice("dat")))
+	for _, file := range c.StringSlice("dat") {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		datfile := new(dat.File)
+		if err := unmarshal(b, datfile); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		datfiles = append(datfiles, datfile)
+	}
+
+	for _, path := range c.Args().Slice() {
+		files, root, err := looseFiles(path)
+		if err != nil {
+			return err
+		}
+		if c.Path("output") != "" {
+			root = c.Path("output")
+		}
+
+		for _, file := range files {
+			m, ok, err := matchLooseFile(file, datfiles)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				if c.Bool("verbose") {
+					fmt.Println(file+":", "no match")
+				}
+				continue
+			}
+
+			target := filepath.Join(root, organizeDir(c.StringSlice("by"), m))
+			if c.Bool("rename") {
+				target = filepath.Join(target, filepath.Base(filepath.FromSlash(m.ROM.Name)))
+			} else {
+				target = filepath.Join(target, filepath.Base(file))
+			}
+
+			if target == filepath.Clean(file) {
+				continue
+			}
+
+			if _, err := os.Lstat(target); err == nil {
+				log.Println(file+":", target, "already exists")
+				continue
+			}
+
+			if c.Bool("dry-run") || c.Bool("verbose") {
+				fmt.Println(file, "->", target)
+			}
+			if c.Bool("dry-run") {
+				continue
+			}
+
+			if err := moveFile(file, target); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// looseFiles returns every regular file within path, which may itself be a
+// file, along with the directory it is relative to. Hidden files and
+// directories are skipped
+func looseFiles(path string) ([]string, string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if !info.IsDir() {
+		return []string{path}, filepath.Dir(path), nil
+	}
+
+	var files []string
+	if err := filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != path && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Type().IsRegular() {
+			files = append(files, p)
+		}
+		return nil
+	}); err != nil {
+		return nil, "", err
+	}
+
+	return files, path, nil
+}
+
+// matchLooseFile returns the first ROM in datfiles matching file, trying
+// each checksum in turn as not every dat file has every checksum
+func matchLooseFile(file string, datfiles []*dat.File) (organizeMatch, bool, error) {
+	reader, err := rom.NewFileReader(file)
+	if err != nil {
+		return organizeMatch{}, false, err
+	}
+	defer reader.Close()
+
+	name := reader.Files()[0]
+
+	size, header, err := reader.Size(name)
+	if err != nil {
+		return organizeMatch{}, false, err
+	}
+
+	for _, t := range []rom.Checksum{rom.SHA1, rom.MD5, rom.CRC32} {
+		b, err := reader.Checksum(name, t)
+		if err != nil {
+			return organizeMatch{}, false, err
+		}
+
+		for _, datfile := range datfiles {
+			for _, m := range datfile.FindByChecksum(t, fmt.Sprintf("%x", b)) {
+				if m.ROM.Size == size-header {
+					return organizeMatch{datfile.Header, m}, true, nil
+				}
+			}
+		}
+	}
+
+	return organizeMatch{}, false, nil
+}
+
+// organizeDir returns the subdirectory for m with one level for each of
+// levels
+func organizeDir(levels []string, m organizeMatch) string {
+	dirs := make([]string, 0, len(levels))
+	for _, level := range levels {
+		var dir string
+		switch level {
+		case "system":
+			dir = m.header.Name
+		case "region":
+			dir = strings.Join(dat.ParseName(m.Game.Name).Regions, ", ")
+		}
+		if dir == "" {
+			dir = "Unknown"
+		}
+		dirs = append(dirs, strings.NewReplacer("/", "-", "\\", "-").Replace(dir))
+	}
+
+	return filepath.Join(dirs...)
+}
+
+// moveFile moves file to target, creating any missing directories and
+// falling back to copying if they're on different filesystems
+func moveFile(file, target string) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0o777); err != nil {
+		return err
+	}
+
+	if err := os.Rename(file, target); err == nil {
+		return nil
+	}
+
+	if err := writeFile(target, file, func(w io.Writer) error {
+		f, err := os.Open(file)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(w, f)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	return os.Remove(file)
+}
+
+// matchArchive returns the first game in datfiles with exactly the ROMs in
+// the archive read by reader, regardless of what each is called, or the
+// only game with all of them
+func matchArchive(reader rom.Reader, datfiles []*dat.File) (organizeMatch, bool, error) {
+	files := reader.Files()
+	if len(files) == 0 {
+		return organizeMatch{}, false, nil
+	}
+
+	for _, datfile := range datfiles {
+		var games map[*dat.Game]struct{}
+
+		for _, file := range files {
+			size, header, err := reader.Size(file)
+			if err != nil {
+				return organizeMatch{}, false, err
+			}
+
+			// The CRC is the cheapest to find in most archives so
+			// try that first
+			found := make(map[*dat.Game]struct{})
+			for _, t := range []rom.Checksum{rom.CRC32, rom.SHA1, rom.MD5} {
+				b, err := reader.Checksum(file, t)
+				if err != nil {
+					return organizeMatch{}, false, err
+				}
+				for _, m := range datfile.FindByChecksum(t, fmt.Sprintf("%x", b)) {
+					if m.ROM.Size == size-header {
+						found[m.Game] = struct{}{}
+					}
+				}
+				if len(found) > 0 {
+					break
+				}
+			}
+
+			if games == nil {
+				games = found
+				continue
+			}
+			for g := range games {
+				if _, ok := found[g]; !ok {
+					delete(games, g)
+				}
+			}
+		}
+
+		var candidates []*dat.Game
+		for i := range datfile.Game {
+			g := &datfile.Game[i]
+			if _, ok := games[g]; !ok {
+				continue
+			}
+
+			n := 0
+			for _, r := range g.ROM {
+				if !r.NoDump() {
+					n++
+				}
+			}
+			if n == len(files) {
+				return organizeMatch{datfile.Header, dat.Match{Game: g}}, true, nil
+			}
+			candidates = append(candidates, g)
+		}
+
+		// An incomplete archive is only named after a game if there's
+		// no other it could be
+		if len(candidates) == 1 {
+			return organizeMatch{datfile.Header, dat.Match{Game: candidates[0]}}, true, nil
+		}
+	}
+
+	return organizeMatch{}, false, nil
+}
+
+// canonicalName returns what file should be called according to datfiles.
+// An archive is named after the game it contains and a loose file after
+// the ROM it matches, keeping it in the same directory
+func canonicalName(file string, datfiles []*dat.File) (string, bool, error) {
+	reader, err := rom.NewReader(file)
+	if err != nil {
+		return "", false, err
+	}
+	defer reader.Close()
+
+	if _, ok := reader.(*rom.FileReader); ok {
+		reader.Close()
+
+		m, ok, err := matchLooseFile(file, datfiles)
+		if err != nil || !ok {
+			return "", false, err
+		}
+		return filepath.Join(filepath.Dir(file), filepath.Base(filepath.FromSlash(m.ROM.Name))), true, nil
+	}
+
+	m, ok, err := matchArchive(reader, datfiles)
+	if err != nil || !ok {
+		return "", false, err
+	}
+
+	return filepath.Join(filepath.Dir(file), strings.NewReplacer("/", "-", "\\", "-").Replace(m.Game.Name)+filepath.Ext(file)), true, nil
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+	"github.com/bodgit/rom/patch"
+	"github.com/urfave/cli/v2"
+)
+
+// matchPatched returns the first ROM in datfiles matching b
+func matchPatched(b []byte, datfiles []*dat.File) (organizeMatch, bool) {
+	checksums := []struct {
+		t     rom.Checksum
+		value string
+	}{
+		{rom.SHA1, fmt.Sprintf("%x", sha1.Sum(b))},
+		{rom.CRC32, fmt.Sprintf("%08x", crc32.ChecksumIEEE(b))},
+	}
+
+	for _, c := range checksums {
+		for _, datfile := range datfiles {
+			for _, m := range datfile.FindByChecksum(c.t, c.value) {
+				if m.ROM.Size == uint64(len(b)) {
+					return organizeMatch{datfile.Header, m}, true
+				}
+			}
+		}
+	}
+
+	return organizeMatch{}, false
+}
+
+// patchInfo prints what is known about each patch
+func patchInfo(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	for _, file := range c.Args().Slice() {
+		p, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		info, err := patch.Describe(p)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+
+		fmt.Println(file+":", info.Format)
+		if info.Format != patch.IPS {
+			fmt.Printf("  source: %d bytes, CRC32 %08x\n", info.SourceSize, info.SourceCRC32)
+			fmt.Printf("  target: %d bytes, CRC32 %08x\n", info.TargetSize, info.TargetCRC32)
+		}
+	}
+
+	return nil
+}
+
+var patchCommand = &cli.Command{
+	Name:        "patch",
+	Usage:       "Apply a patch",
+	Description: "Apply the IPS, UPS or BPS patch PATCH to the ROM SOURCE, such as a translation or ROM hack. A UPS or BPS patch is only applied if SOURCE and the result have the checksums recorded in the patch. The result is written next to PATCH with the extension of SOURCE and can be checked against dat files",
+	Action:      applyPatch,
+	ArgsUsage:   "SOURCE PATCH",
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:    "output",
+			Aliases: []string{"o"},
+			Usage:   "file to write the result to",
+		},
+		&cli.BoolFlag{
+			Name:    "force",
+			Aliases: []string{"f"},
+			Usage:   "overwrite any existing file",
+		},
+		&cli.StringSliceFlag{
+			Name:    "dat",
+			Aliases: []string{"d"},
+			Usage:   "dat file to look for the result in, may be repeated",
+		},
+		&cli.BoolFlag{
+			Name:  "info",
+			Usage: "only describe each PATCH given as an argument",
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "print the size and checksums of the result",
+		},
+	},
+}
+
+func applyPatch(c *cli.Context) error {
+	if c.Bool("info") {
+		return patchInfo(c)
+	}
+
+	if c.NArg() != 2 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	source, patchFile := c.Args().Get(0), c.Args().Get(1)
+
+	p, err := os.ReadFile(patchFile)
+	if err != nil {
+		return err
+	}
+
+	b, err := os.ReadFile(source)
+	if err != nil {
+		return err
+	}
+
+	result, err := patch.Apply(b, p)
+	if err != nil {
+		return fmt.Errorf("%s: %w", patchFile, err)
+	}
+
+	output := c.Path("output")
+	if output == "" {
+		output = strings.TrimSuffix(patchFile, filepath.Ext(patchFile)) + filepath.Ext(source)
+	}
+
+	if !c.Bool("force") {
+		if _, err := os.Lstat(output); err == nil {
+			return fmt.Errorf("%s: %w", output, os.ErrExist)
+		}
+	}
+
+	if err := writeFile(output, source, func(w io.Writer) error {
+		_, err := w.Write(result)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	if c.Bool("verbose") {
+		fmt.Printf("%s: %d bytes, CRC32 %08x, SHA1 %x\n", output, len(result), crc32.ChecksumIEEE(result), sha1.Sum(result))
+	}
+
+	if len(c.StringSlice("dat")) == 0 {
+		return nil
+	}
+
+	datfiles := make([]*dat.File, 0, len(c.StringSlice("dat")))
+	for _, file := range c.StringSlice("dat") {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		datfile := new(dat.File)
+		if err := unmarshal(b, datfile); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		datfiles = append(datfiles, datfile)
+	}
+
+	m, ok := matchPatched(result, datfiles)
+	if !ok {
+		fmt.Println(output+":", "no match")
+		return cli.Exit("", exitError)
+	}
+	fmt.Println(output+":", m.header.Name+":", m.Game.Name, "/", m.ROM.Name)
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/urfave/cli/v2"
+)
+
+var renameCommand = &cli.Command{
+	Name:        "rename",
+	Usage:       "Rename files to match dat files",
+	Description: "Rename each archive found in PATH after the game it contains and each other file after the ROM it matches, based on their checksums. Nothing is moved to another directory and archives are not rewritten so the files within them keep their names",
+	Action:      rename,
+	ArgsUsage:   "PATH...",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "dat",
+			Aliases:  []string{"d"},
+			Usage:    "dat file to match against, may be repeated",
+			Required: true,
+		},
+		&cli.BoolFlag{
+			Name:    "dry-run",
+			Aliases: []string{"n"},
+			Usage:   "only print what would be renamed",
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "increase verbosity",
+		},
+	},
+}
+
+func rename(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	datfiles := make([]*dat.File, 0, len(c.StringSlice("dat")))
+	for _, file := range c.StringSlice("dat") {
+		b, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+
+		datfile := new(dat.File)
+		if err := unmarshal(b, datfile); err != nil {
+			return fmt.Errorf("%s: %w", file, err)
+		}
+		datfiles = append(datfiles, datfile)
+	}
+
+	for _, path := range c.Args().Slice() {
+		files, _, err := looseFiles(path)
+		if err != nil {
+			return err
+		}
+
+		for _, file := range files {
+			target, ok, err := canonicalName(file, datfiles)
+			if err != nil {
+				log.Println(file+":", err)
+				continue
+			}
+			if !ok {
+				if c.Bool("verbose") {
+					fmt.Println(file+":", "no match")
+				}
+				continue
+			}
+
+			if target == filepath.Clean(file) {
+				continue
+			}
+
+			if _, err := os.Lstat(target); err == nil {
+				log.Println(file+":", target, "already exists")
+				continue
+			}
+
+			if c.Bool("dry-run") || c.Bool("verbose") {
+				fmt.Println(file, "->", target)
+			}
+			if c.Bool("dry-run") {
+				continue
+			}
+
+			if err := os.Rename(file, target); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
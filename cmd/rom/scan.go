@@ -0,0 +1,90 @@
+package main
+
+import (
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/bodgit/rom/synchronizer"
+	"github.com/urfave/cli/v2"
+)
+
+var scanCommand = &cli.Command{
+	Name:        "scan",
+	Usage:       "Scan ROMs",
+	Description: "Write the checksums of every file found to stdout for use with sync --import-db",
+	Action:      scan,
+	ArgsUsage:   "SOURCE...",
+	Flags: []cli.Flag{
+		&cli.IntFlag{
+			Name:    "workers",
+			Aliases: []string{"w"},
+			Usage:   "number of workers",
+			Value:   runtime.NumCPU(),
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "increase verbosity",
+		},
+		&cli.GenericFlag{
+			Name:    "algorithm",
+			Aliases: []string{"a"},
+			Value: &enumValue{
+				Enum:    checksumNames,
+				Default: "crc32",
+			},
+			Usage: "checksum algorithm to use. (" + strings.Join(checksumNames, ", ") + ")",
+		},
+		&cli.BoolFlag{
+			Name:    "follow-symlinks",
+			Aliases: []string{"L"},
+			Usage:   "follow symbolic links when scanning sources",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "glob pattern of source files or directories to ignore, e.g. \"*.sav\" or \"extras/**\"",
+		},
+	},
+}
+
+func scan(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	logger := log.New(io.Discard, "", 0)
+	if c.Bool("verbose") {
+		logger.SetOutput(os.Stderr)
+	}
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	s, err := synchronizer.NewSynchronizer(synchronizer.Logger(logger), synchronizer.Workers(c.Int("workers")), synchronizer.FollowSymlinks(c.Bool("follow-symlinks")), synchronizer.Checksum(stringToChecksum[c.Generic("algorithm").(*enumValue).String()]))
+	if err != nil {
+		return err
+	}
+
+	if len(c.StringSlice("exclude")) > 0 {
+		if err = s.SetExclude(c.StringSlice("exclude")...); err != nil {
+			return err
+		}
+	}
+
+	db, err := s.ScanContext(ctx, c.Args().Slice()...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, nil)
+		}
+		return err
+	}
+
+	if err = db.Export(os.Stdout); err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/bodgit/rom"
+	"github.com/urfave/cli/v2"
+)
+
+// scrubState is the file used to remember the results of each scrub,
+// hidden so it isn't treated as part of the target
+const scrubState = ".rom-scrub.json"
+
+const (
+	scrubOK       = "ok"
+	scrubNew      = "new"
+	scrubModified = "modified"
+	scrubChanged  = "changed"
+	scrubCorrupt  = "corrupt"
+)
+
+var errInvalidTorrentZip = errors.New("torrentzip comment doesn't match the central directory")
+
+type scrubRecord struct {
+	Size    int64             `json:"size"`
+	ModTime time.Time         `json:"mtime"`
+	Checked time.Time         `json:"checked"`
+	Files   map[string]string `json:"files,omitempty"`
+	Error   string            `json:"error,omitempty"`
+}
+
+type scrubResult struct {
+	File   string `json:"file"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// problem returns true if the result means the file is damaged
+func (r scrubResult) problem() bool {
+	return r.Status == scrubChanged || r.Status == scrubCorrupt
+}
+
+func (r scrubResult) String() string {
+	if r.Detail != "" {
+		return r.File + ": " + r.Status + ": " + r.Detail
+	}
+	return r.File + ": " + r.Status
+}
+
+func loadScrubState(filename string) (map[string]scrubRecord, error) {
+	records := make(map[string]scrubRecord)
+
+	b, err := os.ReadFile(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return records, nil
+		}
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &records); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return records, nil
+}
+
+func saveScrubState(filename string, records map[string]scrubRecord) error {
+	b, err := json.MarshalIndent(records, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	tmp := filename + ".tmp"
+	if err := os.WriteFile(tmp, append(b, '\n'), 0o666); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, filename)
+}
+
+// scrubFile reads every file within the archive or file called name in
+// full, which checks the CRC of each entry in a zip or 7zip archive, and
+// returns the CRC32 of each. A TorrentZip with a comment that no longer
+// matches its central directory is also an error
+func scrubFile(name string) (map[string]string, error) {
+	reader, err := rom.NewReader(name)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	if v, ok := reader.(rom.Validator); ok && !v.Valid() {
+		return nil, errInvalidTorrentZip
+	}
+
+	files := make(map[string]string, len(reader.Files()))
+
+	for _, file := range reader.Files() {
+		rc, err := reader.Open(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		h := crc32.NewIEEE()
+		_, err = io.Copy(h, rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+
+		files[file] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return files, nil
+}
+
+// compareScrub works out the status of a file by comparing a new record
+// with any previous one. If the size and modification time are the same
+// but the contents aren't then the file has rotted
+func compareScrub(name string, old scrubRecord, seen bool, record scrubRecord) scrubResult {
+	result := scrubResult{File: name, Status: scrubOK}
+
+	switch {
+	case record.Error != "":
+		result.Status, result.Detail = scrubCorrupt, record.Error
+	case !seen:
+		result.Status = scrubNew
+	case old.Size != record.Size || !old.ModTime.Equal(record.ModTime):
+		result.Status = scrubModified
+	case old.Error != "":
+		// It was already corrupt last time and is still unchanged
+		result.Status, result.Detail = scrubCorrupt, old.Error
+	default:
+		var changed []string
+		for file, crc := range record.Files {
+			if old.Files[file] != crc {
+				changed = append(changed, file)
+			}
+		}
+		for file := range old.Files {
+			if _, ok := record.Files[file]; !ok {
+				changed = append(changed, file)
+			}
+		}
+		if len(changed) > 0 {
+			sort.Strings(changed)
+			result.Status, result.Detail = scrubChanged, strings.Join(changed, ", ")
+		}
+	}
+
+	return result
+}
+
+// scrubFiles returns every regular file within dir, relative to dir,
+// ignoring hidden files and directories the same as when synchronising
+func scrubFiles(dir string) ([]string, error) {
+	var files []string
+
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.Name()[0] == '.' && file != dir {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, file)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+var scrubCommand = &cli.Command{
+	Name:        "scrub",
+	Usage:       "Check archives for bit rot",
+	Description: "Read every file within each archive or file in TARGET in full, checking the CRC of each zip or 7zip entry and the comment of each TorrentZip. The results are remembered and any file whose contents have changed without the file itself being modified since the last scrub is reported",
+	Action:      scrub,
+	ArgsUsage:   "TARGET",
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:  "state",
+			Usage: "file to remember the results in, instead of " + scrubState + " in TARGET",
+		},
+		&cli.DurationFlag{
+			Name:  "older-than",
+			Usage: "only check files not checked within this long, so a large collection can be scrubbed a bit at a time",
+		},
+		&cli.IntFlag{
+			Name:    "workers",
+			Aliases: []string{"w"},
+			Usage:   "number of workers",
+			Value:   runtime.NumCPU(),
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "also print files that are ok",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print each result as JSON",
+		},
+	},
+}
+
+func scrub(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	dir := c.Args().First()
+
+	state := c.Path("state")
+	if state == "" {
+		state = filepath.Join(dir, scrubState)
+	}
+
+	records, err := loadScrubState(state)
+	if err != nil {
+		return err
+	}
+
+	files, err := scrubFiles(dir)
+	if err != nil {
+		return err
+	}
+
+	// Anything that has gone is forgotten about
+	present := make(map[string]struct{}, len(files))
+	for _, file := range files {
+		present[file] = struct{}{}
+	}
+	for file := range records {
+		if _, ok := present[file]; !ok {
+			delete(records, file)
+		}
+	}
+
+	now := time.Now()
+
+	// Anything checked recently enough and found to be ok is left until
+	// next time
+	todo := files[:0]
+	for _, file := range files {
+		if record, ok := records[file]; ok && record.Error == "" && c.Duration("older-than") > 0 && now.Sub(record.Checked) < c.Duration("older-than") {
+			continue
+		}
+		todo = append(todo, file)
+	}
+	skipped := len(files) - len(todo)
+
+	var (
+		mutex   gosync.Mutex
+		wg      gosync.WaitGroup
+		results []scrubResult
+	)
+
+	filec := make(chan string)
+
+	for i := 0; i < c.Int("workers"); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range filec {
+				info, err := os.Stat(filepath.Join(dir, file))
+				if err != nil {
+					log.Println(err)
+					continue
+				}
+
+				record := scrubRecord{
+					Size:    info.Size(),
+					ModTime: info.ModTime(),
+					Checked: now,
+				}
+				if record.Files, err = scrubFile(filepath.Join(dir, file)); err != nil {
+					record.Error = err.Error()
+				}
+
+				mutex.Lock()
+				old, seen := records[file]
+				result := compareScrub(file, old, seen, record)
+				if result.Status == scrubChanged {
+					// Keep comparing against the original contents
+					// until the file is replaced
+					record.Files = old.Files
+				}
+				records[file] = record
+				results = append(results, result)
+				mutex.Unlock()
+			}
+		}()
+	}
+
+	func() {
+		defer close(filec)
+		for _, file := range todo {
+			select {
+			case filec <- file:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	wg.Wait()
+
+	// Save whatever was checked, even if interrupted
+	if err := saveScrubState(state, records); err != nil {
+		return err
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].File < results[j].File
+	})
+
+	bad := 0
+	for _, result := range results {
+		if result.problem() {
+			bad++
+		} else if !c.Bool("verbose") {
+			continue
+		}
+
+		if c.Bool("json") {
+			if err := json.NewEncoder(os.Stdout).Encode(result); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Println(result)
+	}
+
+	if ctx.Err() != nil {
+		return interrupted(c, nil)
+	}
+
+	if c.Bool("verbose") && skipped > 0 {
+		log.Println(skipped, "file(s) checked within the last", c.Duration("older-than"), "skipped")
+	}
+
+	if bad > 0 {
+		log.Println(len(results)-bad, "of", len(results), "file(s) good and", bad, "problem(s) found")
+		return cli.Exit("", exitError)
+	}
+
+	return nil
+}
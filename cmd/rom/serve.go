@@ -0,0 +1,496 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"time"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/bodgit/rom/synchronizer"
+	"github.com/urfave/cli/v2"
+)
+
+type romStatus struct {
+	Name  string `json:"name"`
+	Size  uint64 `json:"size"`
+	CRC32 string `json:"crc,omitempty"`
+	MD5   string `json:"md5,omitempty"`
+	SHA1  string `json:"sha1,omitempty"`
+	Have  bool   `json:"have"`
+}
+
+type fileStatus struct {
+	Name string `json:"name"`
+	Have bool   `json:"have"`
+}
+
+type gameStatus struct {
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Complete    bool         `json:"complete"`
+	ROMs        []romStatus  `json:"roms,omitempty"`
+	Disks       []fileStatus `json:"disks,omitempty"`
+	Samples     []fileStatus `json:"samples,omitempty"`
+}
+
+func newGameStatus(g dat.Game) gameStatus {
+	gs := gameStatus{
+		Name:        g.Name,
+		Description: g.Description,
+		Complete:    g.Complete(),
+	}
+	for _, r := range g.ROM {
+		gs.ROMs = append(gs.ROMs, romStatus{r.Name, r.Size, r.CRC32, r.MD5, r.SHA1, r.Complete()})
+	}
+	for _, d := range g.Disk {
+		gs.Disks = append(gs.Disks, fileStatus{d.Name, d.Complete()})
+	}
+	for _, s := range g.Sample {
+		gs.Samples = append(gs.Samples, fileStatus{s.Name, s.Complete()})
+	}
+	return gs
+}
+
+// missing returns a copy of gs with only the ROMs, disks and samples that
+// are missing
+func (gs gameStatus) missing() gameStatus {
+	m := gs
+	m.ROMs, m.Disks, m.Samples = nil, nil, nil
+	for _, r := range gs.ROMs {
+		if !r.Have {
+			m.ROMs = append(m.ROMs, r)
+		}
+	}
+	for _, d := range gs.Disks {
+		if !d.Have {
+			m.Disks = append(m.Disks, d)
+		}
+	}
+	for _, s := range gs.Samples {
+		if !s.Have {
+			m.Samples = append(m.Samples, s)
+		}
+	}
+	return m
+}
+
+type serverStatus struct {
+	Scanning bool       `json:"scanning"`
+	Scanned  *time.Time `json:"scanned,omitempty"`
+	Error    string     `json:"error,omitempty"`
+	Dats     []datStats `json:"dats"`
+}
+
+type server struct {
+	c       *cli.Context
+	trigger chan struct{}
+	metrics *metrics
+
+	mutex    gosync.Mutex
+	scanning bool
+	scanned  time.Time
+	err      error
+	dats     []datStats
+	games    []map[string]gameStatus
+	order    [][]string
+}
+
+var serveTemplate = template.Must(template.New("").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>rom</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; }
+th, td { padding: 0.2em 0.8em; text-align: left; }
+tr:nth-child(even) { background: #f0f0f0; }
+.error { color: #a00; }
+</style>
+</head>
+<body>
+<h1><a href="/">rom</a></h1>
+<form method="post" action="/api/scan">
+{{if .Status.Scanning}}<p>Scanning&hellip;</p>{{else}}<button type="submit">Scan now</button>{{end}}
+{{with .Status.Scanned}}<p>Last scanned {{.Format "2006-01-02 15:04:05"}}</p>{{end}}
+{{with .Status.Error}}<p class="error">{{.}}</p>{{end}}
+</form>
+{{if .Missing}}
+<h2>Missing from {{.Dat.Name}}</h2>
+<table>
+<tr><th>Game</th><th>Missing</th></tr>
+{{range .Missing}}<tr><td><a href="/api/dats/{{$.ID}}/games/{{.Name}}">{{.Name}}</a></td><td>{{range .ROMs}}{{.Name}} {{end}}{{range .Disks}}{{.Name}} {{end}}{{range .Samples}}{{.Name}} {{end}}</td></tr>
+{{end}}
+</table>
+{{else}}
+<table>
+<tr><th>Dat</th><th>Games</th><th>Have</th><th>Partial</th><th>Missing</th><th>%</th></tr>
+{{range $i, $d := .Status.Dats}}<tr><td>{{$d.Name}}</td><td>{{$d.Games}}</td><td>{{$d.Have}}</td><td>{{$d.Partial}}</td><td>{{if $d.Missing}}<a href="/dats/{{$i}}">{{$d.Missing}}</a>{{else}}0{{end}}</td><td>{{printf "%.1f" $d.Percent}}</td></tr>
+{{end}}
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+// refresh scans the target and sources and matches each dat file against
+// the target
+func (srv *server) refresh(ctx context.Context) error {
+	logger := log.New(io.Discard, "", 0)
+	if srv.c.Bool("verbose") {
+		logger.SetOutput(os.Stderr)
+	}
+
+	// Nothing is changed, updating just marks what is present
+	s, err := synchronizer.NewSynchronizer(synchronizer.Logger(logger), synchronizer.DryRun(true), synchronizer.Workers(srv.c.Int("workers")), synchronizer.Checksum(stringToChecksum[srv.c.Generic("algorithm").(*enumValue).String()]))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		srv.metrics.read(s.Rx())
+	}()
+
+	// Read the dat files every time so any updates are noticed
+	datfiles, err := loadDats(srv.c)
+	if err != nil {
+		return err
+	}
+
+	db, err := s.ScanContext(ctx, srv.c.Args().Slice()...)
+	if err != nil {
+		return err
+	}
+
+	dats := make([]datStats, 0, len(datfiles))
+	games := make([]map[string]gameStatus, 0, len(datfiles))
+	order := make([][]string, 0, len(datfiles))
+
+	for _, datfile := range datfiles {
+		if err = s.UpdateContext(ctx, srv.c.Args().First(), datfile, db); err != nil {
+			return err
+		}
+
+		dats = append(dats, newDatStats(datfile))
+
+		m := make(map[string]gameStatus, len(datfile.Game))
+		names := make([]string, 0, len(datfile.Game))
+		for _, g := range datfile.Game {
+			m[g.Name] = newGameStatus(g)
+			names = append(names, g.Name)
+		}
+		games = append(games, m)
+		order = append(order, names)
+	}
+
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+
+	srv.dats, srv.games, srv.order = dats, games, order
+
+	return nil
+}
+
+// run refreshes the status whenever a scan is triggered or the interval
+// passes, until ctx is cancelled
+func (srv *server) run(ctx context.Context) {
+	var tick <-chan time.Time
+	if srv.c.Duration("interval") > 0 {
+		ticker := time.NewTicker(srv.c.Duration("interval"))
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		srv.mutex.Lock()
+		srv.scanning = true
+		srv.mutex.Unlock()
+
+		err := srv.refresh(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Println(err)
+		}
+
+		srv.mutex.Lock()
+		srv.scanning, srv.err = false, err
+		if err == nil {
+			srv.scanned = time.Now()
+		}
+		dats := srv.dats
+		srv.mutex.Unlock()
+
+		if ctx.Err() == nil {
+			srv.metrics.finishedStats(dats, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-srv.trigger:
+		case <-tick:
+		}
+	}
+}
+
+func (srv *server) status() serverStatus {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+
+	st := serverStatus{
+		Scanning: srv.scanning,
+		Dats:     append([]datStats{}, srv.dats...),
+	}
+	if !srv.scanned.IsZero() {
+		scanned := srv.scanned
+		st.Scanned = &scanned
+	}
+	if srv.err != nil {
+		st.Error = srv.err.Error()
+	}
+
+	return st
+}
+
+// lookup returns the games, in order, for the dat with the passed id
+func (srv *server) lookup(id string) (map[string]gameStatus, []string, datStats, bool) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+
+	i, err := strconv.Atoi(id)
+	if err != nil || i < 0 || i >= len(srv.games) {
+		return nil, nil, datStats{}, false
+	}
+
+	return srv.games[i], srv.order[i], srv.dats[i], true
+}
+
+func (srv *server) missing(id string) ([]gameStatus, datStats, bool) {
+	games, order, ds, ok := srv.lookup(id)
+	if !ok {
+		return nil, datStats{}, false
+	}
+
+	missing := []gameStatus{}
+	for _, name := range order {
+		if g := games[name]; !g.Complete {
+			missing = append(missing, g.missing())
+		}
+	}
+
+	return missing, ds, true
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Println(err)
+	}
+}
+
+func writeHTML(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := serveTemplate.Execute(w, v); err != nil {
+		log.Println(err)
+	}
+}
+
+func (srv *server) handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		writeHTML(w, struct {
+			Status serverStatus
+			ID     string
+			Dat    datStats
+			// Missing is only set when showing a single dat file
+			Missing []gameStatus
+		}{Status: srv.status()})
+	})
+
+	mux.HandleFunc("/dats/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/dats/")
+		missing, ds, ok := srv.missing(id)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		writeHTML(w, struct {
+			Status  serverStatus
+			ID      string
+			Dat     datStats
+			Missing []gameStatus
+		}{srv.status(), id, ds, missing})
+	})
+
+	mux.Handle("/metrics", srv.metrics)
+
+	mux.HandleFunc("/api/status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, srv.status())
+	})
+
+	mux.HandleFunc("/api/scan", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		// A scan already queued will pick up any changes
+		select {
+		case srv.trigger <- struct{}{}:
+		default:
+		}
+
+		if strings.Contains(r.Header.Get("Accept"), "text/html") {
+			http.Redirect(w, r, "/", http.StatusSeeOther)
+			return
+		}
+		writeJSON(w, http.StatusAccepted, srv.status())
+	})
+
+	// /api/dats/{id}/missing and /api/dats/{id}/games/{name}
+	mux.HandleFunc("/api/dats/", func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/api/dats/"), "/", 3)
+
+		switch {
+		case len(parts) == 2 && parts[1] == "missing":
+			missing, _, ok := srv.missing(parts[0])
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, http.StatusOK, missing)
+		case len(parts) == 3 && parts[1] == "games":
+			games, _, _, ok := srv.lookup(parts[0])
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			g, ok := games[parts[2]]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, http.StatusOK, g)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	return mux
+}
+
+var serveCommand = &cli.Command{
+	Name:        "serve",
+	Usage:       "Serve collection status over HTTP",
+	Description: "Run a server reporting how many of the games in each dat file are present in TARGET, using ROMs from TARGET and any SOURCE, with a JSON API, a web page and Prometheus metrics on /metrics. TARGET is rescanned when requested or every interval",
+	Action:      serve,
+	ArgsUsage:   "TARGET [SOURCE...]",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:     "dat",
+			Aliases:  []string{"d"},
+			Usage:    "dat file to report on, may be repeated. Each is reread on every scan",
+			Required: true,
+		},
+		&cli.StringFlag{
+			Name:    "listen",
+			Aliases: []string{"l"},
+			Usage:   "address to listen on",
+			Value:   "localhost:8080",
+		},
+		&cli.DurationFlag{
+			Name:    "interval",
+			Aliases: []string{"i"},
+			Usage:   "rescan after this long, zero to only rescan when requested",
+		},
+		&cli.IntFlag{
+			Name:    "workers",
+			Aliases: []string{"w"},
+			Usage:   "number of workers",
+			Value:   runtime.NumCPU(),
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "increase verbosity",
+		},
+		&cli.GenericFlag{
+			Name:    "algorithm",
+			Aliases: []string{"a"},
+			Value: &enumValue{
+				Enum:    checksumNames,
+				Default: "crc32",
+			},
+			Usage: "checksum algorithm to use. (" + strings.Join(checksumNames, ", ") + ")",
+		},
+		&cli.GenericFlag{
+			Name: "merging",
+			Value: &enumValue{
+				Enum:    mergingNames,
+				Default: "none",
+			},
+			Usage: "merging mode, overriding any set by the dat file. (" + strings.Join(mergingNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "1g1r",
+			Usage: "only count the preferred game of each parent/clone set using this comma-separated region priority",
+		},
+	},
+}
+
+func serve(c *cli.Context) error {
+	if c.NArg() < 1 || len(c.StringSlice("dat")) == 0 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	srv := &server{
+		c:       c,
+		trigger: make(chan struct{}, 1),
+		metrics: new(metrics),
+	}
+
+	hs := &http.Server{
+		Addr:    c.String("listen"),
+		Handler: srv.handler(),
+	}
+
+	var wg gosync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		srv.run(ctx)
+	}()
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = hs.Shutdown(shutdown)
+	}()
+
+	if err := hs.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+
+	wg.Wait()
+
+	return nil
+}
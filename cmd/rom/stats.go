@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/bodgit/rom/synchronizer"
+	"github.com/olekukonko/tablewriter"
+	"github.com/urfave/cli/v2"
+)
+
+type datStats struct {
+	Name     string  `json:"name"`
+	Games    int     `json:"games"`
+	Have     int     `json:"have"`
+	Partial  int     `json:"partial"`
+	Missing  int     `json:"missing"`
+	Percent  float64 `json:"percent"`
+	ROMs     int     `json:"roms"`
+	ROMsHave int     `json:"roms_have"`
+}
+
+type collectionStats struct {
+	Dats           []datStats `json:"dats"`
+	Files          int        `json:"files"`
+	Bytes          uint64     `json:"bytes"`
+	Duplicates     int        `json:"duplicates"`
+	DuplicateBytes uint64     `json:"duplicate_bytes"`
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(n) * 100 / float64(total)
+}
+
+// newDatStats counts the games and ROMs within datfile that have been
+// matched
+func newDatStats(datfile *dat.File) datStats {
+	ds := datStats{
+		Name:  datfile.Header.Name,
+		Games: len(datfile.Game),
+	}
+	for _, g := range datfile.Game {
+		have := 0
+		for _, r := range g.ROM {
+			if r.Complete() {
+				have++
+			}
+		}
+		ds.ROMs += len(g.ROM)
+		ds.ROMsHave += have
+
+		switch {
+		case g.Complete():
+			ds.Have++
+		case have > 0:
+			ds.Partial++
+		}
+	}
+	ds.Missing = ds.Games - ds.Have
+	ds.Percent = percent(ds.Have, ds.Games)
+
+	return ds
+}
+
+var statsCommand = &cli.Command{
+	Name:        "stats",
+	Usage:       "Collection statistics",
+	Description: "Report how many of the games in each dat file are present in TARGET, along with the total size of every file found in TARGET and any other PATH and any duplicates between them",
+	Action:      stats,
+	ArgsUsage:   "TARGET [PATH...]",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "dat",
+			Aliases: []string{"d"},
+			Usage:   "dat file to report on, may be repeated. Otherwise one dat file is read from stdin",
+		},
+		&cli.IntFlag{
+			Name:    "workers",
+			Aliases: []string{"w"},
+			Usage:   "number of workers",
+			Value:   runtime.NumCPU(),
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "increase verbosity",
+		},
+		&cli.GenericFlag{
+			Name:    "algorithm",
+			Aliases: []string{"a"},
+			Value: &enumValue{
+				Enum:    checksumNames,
+				Default: "crc32",
+			},
+			Usage: "checksum algorithm to use. (" + strings.Join(checksumNames, ", ") + ")",
+		},
+		&cli.GenericFlag{
+			Name: "merging",
+			Value: &enumValue{
+				Enum:    mergingNames,
+				Default: "none",
+			},
+			Usage: "merging mode, overriding any set by the dat file. (" + strings.Join(mergingNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "1g1r",
+			Usage: "only count the preferred game of each parent/clone set using this comma-separated region priority",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "print the statistics as JSON",
+		},
+	},
+}
+
+func stats(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	logger := log.New(io.Discard, "", 0)
+	if c.Bool("verbose") {
+		logger.SetOutput(os.Stderr)
+	}
+
+	// Nothing is changed, updating just marks what is present
+	s, err := synchronizer.NewSynchronizer(synchronizer.Logger(logger), synchronizer.DryRun(true), synchronizer.Workers(c.Int("workers")), synchronizer.Checksum(stringToChecksum[c.Generic("algorithm").(*enumValue).String()]))
+	if err != nil {
+		return err
+	}
+
+	datfiles, err := loadDats(c)
+	if err != nil {
+		return err
+	}
+
+	db, err := s.ScanContext(ctx, c.Args().Slice()...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, nil)
+		}
+		return err
+	}
+
+	var cs collectionStats
+
+	for _, datfile := range datfiles {
+		if err = s.UpdateContext(ctx, c.Args().First(), datfile, db); err != nil {
+			if ctx.Err() != nil {
+				return interrupted(c, nil)
+			}
+			return err
+		}
+
+		cs.Dats = append(cs.Dats, newDatStats(datfile))
+	}
+
+	cs.Files, cs.Bytes = db.Size()
+	for _, d := range db.Duplicates() {
+		cs.Duplicates += len(d.Locations) - 1
+		cs.DuplicateBytes += d.Size * uint64(len(d.Locations)-1)
+	}
+
+	if c.Bool("json") {
+		if err = json.NewEncoder(os.Stdout).Encode(cs); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	table := tablewriter.NewWriter(os.Stdout)
+	table.SetBorder(false)
+	table.SetCenterSeparator("")
+	table.SetColumnSeparator("")
+	table.SetAutoWrapText(false)
+
+	table.SetHeader([]string{"Dat", "Games", "Have", "Partial", "Missing", "%", "ROMs", "Have", "%"})
+
+	for _, ds := range cs.Dats {
+		table.Append([]string{ds.Name, strconv.Itoa(ds.Games), strconv.Itoa(ds.Have), strconv.Itoa(ds.Partial), strconv.Itoa(ds.Missing), fmt.Sprintf("%.1f", ds.Percent), strconv.Itoa(ds.ROMs), strconv.Itoa(ds.ROMsHave), fmt.Sprintf("%.1f", percent(ds.ROMsHave, ds.ROMs))})
+	}
+
+	table.Render()
+
+	fmt.Println()
+	fmt.Println("Files:", cs.Files)
+	fmt.Println("Bytes:", cs.Bytes)
+	fmt.Println("Duplicates:", cs.Duplicates, "("+strconv.FormatUint(cs.DuplicateBytes, 10), "bytes)")
+
+	return nil
+}
@@ -0,0 +1,854 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/bodgit/rom/synchronizer"
+	"github.com/bodgit/rom/torrent"
+	"github.com/urfave/cli/v2"
+)
+
+func importDB(file string) (*synchronizer.DB, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return synchronizer.ImportDB(f)
+}
+
+func exportDB(file string, db *synchronizer.DB) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := db.Export(f); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+var syncCommand = &cli.Command{
+	Name:        "sync",
+	Usage:       "Synchronise ROMs",
+	Description: "Build a directory of Torrentzipped ROMs. A SOURCE can also be the http or https URL of an archive, only the parts needed are downloaded, or of an index page ending with a \"/\", such as a mirror, to use every file listed. s3://bucket/key URLs work the same way, using the usual AWS environment variables for credentials and AWS_ENDPOINT_URL for other S3-compatible services, sftp://user@host/path URLs are read over ssh, ftp://host/path URLs from FTP-only mirrors and smb://user@host/share/path URLs from Windows or NAS shares, with the password in SMB_PASSWORD. WebDAV servers such as Nextcloud can be used with dav:// or davs:// URLs, with the password in WEBDAV_PASSWORD, and --mirror uploads each game written to one. rclone://remote/path URLs use a running \"rclone rcd --rc-serve\" found with RCLONE_RC_ADDR to reach any storage rclone supports. Exits with 2 if any games are still missing and 1 on error or if any games failed",
+	Action:      sync,
+	ArgsUsage:   "TARGET [SOURCE...]",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:    "dry-run",
+			Aliases: []string{"n"},
+			Usage:   "don't actually do anything",
+		},
+		&cli.IntFlag{
+			Name:    "workers",
+			Aliases: []string{"w"},
+			Usage:   "number of workers",
+			Value:   runtime.NumCPU(),
+		},
+		&cli.IntFlag{
+			Name:  "scan-workers",
+			Usage: "number of workers used to checksum files, overriding --workers",
+		},
+		&cli.IntFlag{
+			Name:  "transfer-workers",
+			Usage: "number of workers used to build games, overriding --workers. Use 1 for spinning disks",
+		},
+		&cli.IntFlag{
+			Name:  "rom-workers",
+			Usage: "number of source archives read at once when building a single game, extracting each ROM into TMPDIR first if the format can only write one file at a time",
+		},
+		&cli.IntFlag{
+			Name:  "max-open-files",
+			Usage: "maximum number of source files open at once",
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "increase verbosity",
+		},
+		&cli.GenericFlag{
+			Name: "log-format",
+			Value: &enumValue{
+				Enum:    logFormatNames,
+				Default: "text",
+			},
+			Usage: "format of the verbose output. (" + strings.Join(logFormatNames, ", ") + ")",
+		},
+		&cli.BoolFlag{
+			Name:    "progress",
+			Aliases: []string{"p"},
+			Usage:   "show progress, the default if stderr is a terminal and not --verbose. Use --progress=false to hide it",
+		},
+		&cli.GenericFlag{
+			Name:    "algorithm",
+			Aliases: []string{"a"},
+			Value: &enumValue{
+				Enum:    checksumNames,
+				Default: "crc32",
+			},
+			Usage: "checksum algorithm to use. (" + strings.Join(checksumNames, ", ") + ")",
+		},
+		&cli.PathFlag{
+			Name:    "mia",
+			Aliases: []string{"m"},
+			Usage:   "path to file containing list of games to ignore",
+		},
+		&cli.PathFlag{
+			Name:  "cache",
+			Usage: "path to file used to cache the checksums of unchanged source files between runs",
+		},
+		&cli.BoolFlag{
+			Name:  "trust-target",
+			Usage: "don't validate unchanged archives that were valid on a previous run, requires --cache",
+		},
+		&cli.BoolFlag{
+			Name:  "strip-headers",
+			Usage: "remove any header such as the iNES header when copying ROMs the dat file describes without one",
+		},
+		&cli.BoolFlag{
+			Name:  "scan-sizes",
+			Usage: "only checksum files with the same size as a ROM in the dat file",
+		},
+		&cli.BoolFlag{
+			Name:  "fast-scan",
+			Usage: "only use CRC32 values read from archives when scanning and verify ROMs when copying",
+		},
+		&cli.BoolFlag{
+			Name:    "keep-going",
+			Aliases: []string{"k"},
+			Usage:   "skip any game that fails rather than stopping",
+		},
+		&cli.BoolFlag{
+			Name:    "follow-symlinks",
+			Aliases: []string{"L"},
+			Usage:   "follow symbolic links when scanning sources",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude",
+			Usage: "glob pattern of source files or directories to ignore, e.g. \"*.sav\" or \"extras/**\"",
+		},
+		&cli.StringSliceFlag{
+			Name:  "import-db",
+			Usage: "path to checksums written by scan or --export-db to use as additional sources, scanned with the same algorithm",
+		},
+		&cli.PathFlag{
+			Name:  "export-db",
+			Usage: "path to file to write the checksums of every file scanned",
+		},
+		&cli.PathFlag{
+			Name:  "have",
+			Usage: "path to file to write the list of games that are fully present",
+		},
+		&cli.PathFlag{
+			Name:  "miss",
+			Usage: "path to file to write the list of games that are absent or partially present, along with any missing ROMs",
+		},
+		&cli.BoolFlag{
+			Name:  "miss-sets",
+			Usage: "only write the name of each game to --miss, one per line, the same as a ClrMamePro set list and usable with --mia",
+		},
+		&cli.PathFlag{
+			Name:  "report",
+			Usage: "path to file to write a JSON report of the action taken for each game",
+		},
+		&cli.BoolFlag{
+			Name:  "prune-sources",
+			Usage: "after a successful sync, remove any source file whose contents are all now in the target, moving them if --backup is used",
+		},
+		&cli.PathFlag{
+			Name:  "backup",
+			Usage: "path to directory to move any deleted or replaced files into rather than removing them",
+		},
+		&cli.StringFlag{
+			Name:  "throttle",
+			Usage: "limit reading and copying files to this many bytes per second, e.g. \"10M\"",
+		},
+		&cli.PathFlag{
+			Name:  "journal",
+			Usage: "path to file used to record operations so an interrupted sync can be cleaned up and resumed",
+		},
+		&cli.PathFlag{
+			Name:  "samples",
+			Usage: "path to directory used to maintain any samples",
+		},
+		&cli.BoolFlag{
+			Name:  "gamelist",
+			Usage: "write an EmulationStation " + synchronizer.GameListFile + " to TARGET listing each game present, keeping any existing metadata",
+		},
+		&cli.PathFlag{
+			Name:  "launchbox",
+			Usage: "LaunchBox Data\\Platforms directory to write a platform XML file to listing each game present, keeping any existing metadata",
+		},
+		&cli.StringFlag{
+			Name:  "launchbox-platform",
+			Usage: "name of the LaunchBox platform, otherwise the name of the dat file",
+		},
+		&cli.PathFlag{
+			Name:  "hyperspin",
+			Usage: "HyperSpin Databases directory to write a database to listing each game present",
+		},
+		&cli.StringFlag{
+			Name:  "hyperspin-system",
+			Usage: "name of the HyperSpin system, otherwise the name of the dat file",
+		},
+		&cli.PathFlag{
+			Name:  "torrent",
+			Usage: "path to write a torrent for TARGET to once finished",
+		},
+		&cli.GenericFlag{
+			Name: "torrent-version",
+			Value: &enumValue{
+				Enum:    torrentVersionNames,
+				Default: "v1",
+			},
+			Usage: "BitTorrent version of the torrent. (" + strings.Join(torrentVersionNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "piece-length",
+			Usage: "piece length of the torrent, a power of two of at least 16K, e.g. \"1M\". Chosen from the size of TARGET if not set",
+		},
+		&cli.StringSliceFlag{
+			Name:  "tracker",
+			Usage: "announce URL to add to the torrent, may be repeated",
+		},
+		&cli.StringFlag{
+			Name:  "mirror",
+			Usage: "dav://, davs:// or rclone:// URL to upload each game to once it has been written, and delete it from if deleted",
+		},
+		&cli.GenericFlag{
+			Name: "format",
+			Value: &enumValue{
+				Enum:    formatNames,
+				Default: "torrentzip",
+			},
+			Usage: "output format for each game. (" + strings.Join(formatNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "template for the filename of each game, e.g. \"{{first .Name | upper}}/{{.Name}}{{.Extension}}\"",
+		},
+		&cli.GenericFlag{
+			Name: "link",
+			Value: &enumValue{
+				Enum:    linkNames,
+				Default: "none",
+			},
+			Usage: "link identical archives rather than storing duplicates. (" + strings.Join(linkNames, ", ") + ")",
+		},
+		&cli.GenericFlag{
+			Name: "merging",
+			Value: &enumValue{
+				Enum:    mergingNames,
+				Default: "none",
+			},
+			Usage: "merging mode, overriding any set by the dat file. (" + strings.Join(mergingNames, ", ") + ")",
+		},
+		&cli.StringSliceFlag{
+			Name:    "dat",
+			Aliases: []string{"d"},
+			Usage:   "path to a dat file to use instead of reading one from stdin. May be repeated, each is synchronized with a subdirectory of the target named after it and no dat is written to stdout, use --fixdat-dir instead",
+		},
+		&cli.PathFlag{
+			Name:  "fixdat-dir",
+			Usage: "directory to write the remaining dat of each --dat to, named after its subdirectory",
+		},
+		&cli.StringSliceFlag{
+			Name:  "region",
+			Usage: "only sync games with one of these regions in their name, e.g. \"USA,World\"",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude-region",
+			Usage: "don't sync games with any of these regions in their name",
+		},
+		&cli.StringSliceFlag{
+			Name:  "language",
+			Usage: "only sync games with one of these languages in their name, e.g. \"En,Fr\"",
+		},
+		&cli.StringSliceFlag{
+			Name:  "exclude-language",
+			Usage: "don't sync games with any of these languages in their name",
+		},
+		&cli.StringFlag{
+			Name:  "include-game",
+			Usage: "only sync games with a name matching this regular expression",
+		},
+		&cli.StringFlag{
+			Name:  "exclude-game",
+			Usage: "don't sync games with a name matching this regular expression",
+		},
+		&cli.BoolFlag{
+			Name:  "prefer-torrentzip",
+			Usage: "prefer TorrentZip sources when more than one provides a ROM",
+		},
+		&cli.StringSliceFlag{
+			Name:  "avoid-path",
+			Usage: "only use sources within this directory when no other source provides a ROM",
+		},
+		&cli.BoolFlag{
+			Name:  "sort",
+			Usage: "sort the games and ROMs in the remaining dat by name",
+		},
+		&cli.StringFlag{
+			Name:  "1g1r",
+			Usage: "only keep the preferred game of each parent/clone set using this comma-separated region priority, e.g. \"USA,Europe,Japan\"",
+		},
+	},
+}
+
+func sync(c *cli.Context) (err error) {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	logger, logOption := newLogger(c)
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	s, err := synchronizer.NewSynchronizer(logOption, synchronizer.Workers(c.Int("workers")), synchronizer.ScanWorkers(c.Int("scan-workers")), synchronizer.TransferWorkers(c.Int("transfer-workers")), synchronizer.ROMWorkers(c.Int("rom-workers")), synchronizer.MaxOpenFiles(c.Int("max-open-files")), synchronizer.DryRun(c.Bool("dry-run")), synchronizer.ContinueOnError(c.Bool("keep-going")), synchronizer.FollowSymlinks(c.Bool("follow-symlinks")), synchronizer.TrustTarget(c.Bool("trust-target")), synchronizer.StripHeaders(c.Bool("strip-headers")), synchronizer.FastScan(c.Bool("fast-scan")), synchronizer.Checksum(stringToChecksum[c.Generic("algorithm").(*enumValue).String()]))
+	if err != nil {
+		return err
+	}
+
+	if len(c.StringSlice("exclude")) > 0 {
+		if err = s.SetExclude(c.StringSlice("exclude")...); err != nil {
+			return err
+		}
+	}
+
+	if c.String("template") != "" {
+		if err = s.SetTemplate(c.String("template")); err != nil {
+			return err
+		}
+	}
+
+	for _, f := range []struct {
+		name string
+		fn   func(...string) error
+	}{
+		{"region", s.SetRegions},
+		{"exclude-region", s.SetExcludeRegions},
+		{"language", s.SetLanguages},
+		{"exclude-language", s.SetExcludeLanguages},
+	} {
+		if len(c.StringSlice(f.name)) > 0 {
+			if err = f.fn(c.StringSlice(f.name)...); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.String("include-game") != "" {
+		if err = s.SetIncludeGames(c.String("include-game")); err != nil {
+			return err
+		}
+	}
+
+	if c.String("exclude-game") != "" {
+		if err = s.SetExcludeGames(c.String("exclude-game")); err != nil {
+			return err
+		}
+	}
+
+	var ranks []synchronizer.SourceRank
+	if c.Bool("prefer-torrentzip") {
+		ranks = append(ranks, synchronizer.PreferTorrentZip())
+	}
+	if len(c.StringSlice("avoid-path")) > 0 {
+		ranks = append(ranks, synchronizer.AvoidPaths(c.StringSlice("avoid-path")...))
+	}
+	if err = s.SetPreferSources(ranks...); err != nil {
+		return err
+	}
+
+	if err = s.SetLink(stringToLink[c.Generic("link").(*enumValue).String()]); err != nil {
+		return err
+	}
+
+	if c.Path("report") != "" {
+		f, err := os.Create(c.Path("report"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err = s.SetReport(f); err != nil {
+			return err
+		}
+	}
+
+	if c.Path("backup") != "" {
+		if err = s.SetBackup(c.Path("backup")); err != nil {
+			return err
+		}
+	}
+
+	if c.String("throttle") != "" {
+		rate, err := parseSize(c.String("throttle"))
+		if err != nil {
+			return err
+		}
+
+		if err = s.SetThrottle(rate); err != nil {
+			return err
+		}
+	}
+
+	if c.Path("journal") != "" {
+		if err = s.SetJournal(c.Path("journal")); err != nil {
+			return err
+		}
+	}
+
+	if c.Path("samples") != "" {
+		if err = s.SetSamples(c.Path("samples")); err != nil {
+			return err
+		}
+	}
+
+	if c.String("mirror") != "" {
+		if err = s.SetMirror(c.String("mirror")); err != nil {
+			return err
+		}
+	}
+
+	if err = s.SetGameList(c.Bool("gamelist")); err != nil {
+		return err
+	}
+
+	if c.Path("launchbox") != "" {
+		if err = s.SetLaunchBox(c.Path("launchbox"), c.String("launchbox-platform")); err != nil {
+			return err
+		}
+	}
+
+	if c.Path("hyperspin") != "" {
+		if err = s.SetHyperSpin(c.Path("hyperspin"), c.String("hyperspin-system")); err != nil {
+			return err
+		}
+	}
+
+	if showProgress(c) {
+		if err = s.SetProgress(progressBar(os.Stderr)); err != nil {
+			return err
+		}
+	}
+
+	if c.Path("cache") != "" {
+		var cache *synchronizer.Cache
+		if cache, err = synchronizer.OpenCache(c.Path("cache")); err != nil {
+			return err
+		}
+		// The cache is saved however the update ends, but any error from
+		// that doesn't hide why it ended
+		defer func() {
+			if cerr := cache.Close(); cerr != nil && err == nil {
+				err = cerr
+			}
+		}()
+
+		if err = s.SetCache(cache); err != nil {
+			return err
+		}
+	}
+
+	if c.Path("mia") != "" {
+		f, err := os.Open(c.Path("mia"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err = s.SetMissing(f); err != nil {
+			return err
+		}
+	}
+
+	// A single Logiqx dat file can be decoded one game at a time while
+	// updating unless something needs every game up front
+	var datfiles []*dat.File
+	var header *dat.Header
+	var stream io.Reader
+	if len(c.StringSlice("dat")) == 0 && !c.IsSet("merging") && c.String("1g1r") == "" && !c.Bool("scan-sizes") {
+		if header, stream, err = peekDat(os.Stdin); err != nil {
+			return err
+		}
+		if header != nil {
+			if m, ok := header.Merging(); ok && m != dat.NonMerged {
+				header = nil
+			}
+		}
+		if header == nil {
+			b, err := io.ReadAll(stream)
+			if err != nil {
+				return err
+			}
+			datfile, err := loadDat(c, b)
+			if err != nil {
+				return err
+			}
+			datfiles, stream = []*dat.File{datfile}, nil
+		}
+	} else if datfiles, err = loadDats(c); err != nil {
+		return err
+	}
+
+	if c.Bool("scan-sizes") {
+		if err = s.SetScanSizes(datfiles...); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	db, err := s.ScanContext(ctx, c.Args().Slice()...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		return err
+	}
+	elapsed := time.Since(start)
+
+	if showProgress(c) {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	logger.Println("Read", s.Rx(), "bytes in", elapsed)
+
+	s.Reset()
+
+	for _, file := range c.StringSlice("import-db") {
+		other, err := importDB(file)
+		if err != nil {
+			return err
+		}
+		db.Merge(other)
+	}
+
+	if c.Path("export-db") != "" {
+		if err = exportDB(c.Path("export-db"), db); err != nil {
+			return err
+		}
+	}
+
+	if len(c.StringSlice("dat")) > 0 {
+		return syncFiles(ctx, c, s, db, datfiles, logger)
+	}
+
+	var datfile *dat.File
+	if stream == nil {
+		datfile = datfiles[0]
+		header = &datfile.Header
+	}
+
+	format := stringToFormat[c.Generic("format").(*enumValue).String()]
+	if !c.IsSet("format") && header.Unpacked() {
+		format = synchronizer.Directory
+	}
+
+	if err = s.SetFormat(format); err != nil {
+		return err
+	}
+
+	start = time.Now()
+	var uerr *synchronizer.UpdateError
+	if stream != nil {
+		datfile, err = s.UpdateReaderContext(ctx, c.Args().First(), stream, db)
+	} else {
+		err = s.UpdateContext(ctx, c.Args().First(), datfile, db)
+	}
+	if err != nil && !errors.As(err, &uerr) {
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		return err
+	}
+	elapsed = time.Since(start)
+
+	if showProgress(c) {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	logger.Println("Read", s.Rx(), "bytes and wrote", s.Tx(), "bytes in", elapsed)
+
+	if stream != nil {
+		err = s.DeleteSeenContext(ctx, c.Args().First())
+	} else {
+		err = s.DeleteContext(ctx, c.Args().First(), datfile)
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		return err
+	}
+
+	if c.Bool("prune-sources") && uerr == nil {
+		if err = s.PruneContext(ctx, c.Args().First(), db, c.Args().Tail()...); err != nil {
+			if ctx.Err() != nil {
+				return interrupted(c, s)
+			}
+			return err
+		}
+	}
+
+	stats := s.Stats()
+	logger.Println("Created", stats.Created, "modified", stats.Modified, "renamed", stats.Renamed, "deleted", stats.Deleted, "skipped", stats.Skipped, "pruned", stats.Pruned, "and", stats.Missing, "still missing")
+
+	if err := writeHaveMiss(c, s.HaveMiss()); err != nil {
+		return err
+	}
+
+	if err := writeTorrent(c); err != nil {
+		return err
+	}
+
+	if c.Bool("sort") {
+		datfile.Sort()
+	}
+
+	e := dat.NewEncoder(os.Stdout)
+	e.Indent("", "\t")
+
+	if err = e.Encode(datfile); err != nil {
+		return err
+	}
+
+	if err := failed(uerr); err != nil {
+		return err
+	}
+
+	return stillMissing(stats)
+}
+
+// peekDat reads just enough of the dat file from r to return its header if
+// it is a Logiqx XML dat file, otherwise nil, along with a reader that
+// starts from the beginning of the dat file again
+func peekDat(r io.Reader) (*dat.Header, io.Reader, error) {
+	buf := new(bytes.Buffer)
+	rest := io.MultiReader(buf, r)
+
+	b := make([]byte, 512)
+	n, err := io.ReadFull(r, b)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, nil, err
+	}
+	b = b[:n]
+	buf.Write(b)
+
+	if text := bytes.TrimLeft(bytes.TrimPrefix(b, []byte("\ufeff")), " \t\r\n"); bytes.HasPrefix(b, []byte(dat.RDBMagic)) || len(text) == 0 || text[0] != '<' {
+		return nil, rest, nil
+	}
+
+	// Anything the decoder reads is kept so it can be read again
+	d := xml.NewDecoder(io.MultiReader(bytes.NewReader(b), io.TeeReader(r, buf)))
+	root := false
+	for {
+		t, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				return nil, rest, nil
+			}
+			return nil, nil, err
+		}
+
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			continue
+		}
+
+		if !root {
+			if start.Name.Local != "datafile" {
+				return nil, rest, nil
+			}
+			root = true
+			continue
+		}
+
+		header := new(dat.Header)
+		if start.Name.Local == "header" {
+			if err := d.DecodeElement(header, &start); err != nil {
+				return nil, nil, err
+			}
+		}
+
+		return header, rest, nil
+	}
+}
+
+// syncFiles synchronizes each dat file passed with --dat with its own
+// subdirectory of the target
+func syncFiles(ctx context.Context, c *cli.Context, s *synchronizer.Synchronizer, db *synchronizer.DB, datfiles []*dat.File, logger *log.Logger) error {
+	if err := s.SetFormat(stringToFormat[c.Generic("format").(*enumValue).String()]); err != nil {
+		return err
+	}
+
+	if !c.IsSet("format") {
+		if err := s.SetUnpackedFormat(synchronizer.Directory); err != nil {
+			return err
+		}
+	}
+
+	start := time.Now()
+	var uerr *synchronizer.UpdateError
+	if err := s.UpdateFilesContext(ctx, c.Args().First(), db, datfiles...); err != nil && !errors.As(err, &uerr) {
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		return err
+	}
+	elapsed := time.Since(start)
+
+	if showProgress(c) {
+		fmt.Fprintln(os.Stderr)
+	}
+
+	logger.Println("Read", s.Rx(), "bytes and wrote", s.Tx(), "bytes in", elapsed)
+
+	if err := s.DeleteFilesContext(ctx, c.Args().First(), datfiles...); err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		return err
+	}
+
+	if c.Bool("prune-sources") && uerr == nil {
+		if err := s.PruneContext(ctx, c.Args().First(), db, c.Args().Tail()...); err != nil {
+			if ctx.Err() != nil {
+				return interrupted(c, s)
+			}
+			return err
+		}
+	}
+
+	stats := s.Stats()
+	logger.Println("Created", stats.Created, "modified", stats.Modified, "renamed", stats.Renamed, "deleted", stats.Deleted, "skipped", stats.Skipped, "pruned", stats.Pruned, "and", stats.Missing, "still missing")
+
+	if err := writeHaveMiss(c, s.HaveMiss()); err != nil {
+		return err
+	}
+
+	if err := writeTorrent(c); err != nil {
+		return err
+	}
+
+	if err := writeFixdats(c, datfiles); err != nil {
+		return err
+	}
+
+	if err := failed(uerr); err != nil {
+		return err
+	}
+
+	return stillMissing(stats)
+}
+
+// writeFixdats writes the remaining dat for each of datfiles to the
+// directory given with --fixdat-dir, named after its subdirectory
+func writeFixdats(c *cli.Context, datfiles []*dat.File) error {
+	if c.Path("fixdat-dir") == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(c.Path("fixdat-dir"), os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, datfile := range datfiles {
+		if c.Bool("sort") {
+			datfile.Sort()
+		}
+
+		b, err := marshalLogiqx(datfile)
+		if err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(filepath.Join(c.Path("fixdat-dir"), synchronizer.Subdirectory(datfile)+".dat"), b, 0o666); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeHaveMiss writes the have and miss lists to any files requested. The
+// have list is one game per line, the miss list is either the game or the
+// game followed by the missing ROMs if it is only partially present
+func writeHaveMiss(c *cli.Context, l synchronizer.HaveMiss) error {
+	if c.Path("have") != "" {
+		if err := writeLines(c.Path("have"), l.Have); err != nil {
+			return err
+		}
+	}
+
+	if c.Path("miss") != "" {
+		lines := append([]string(nil), l.Miss...)
+		for _, p := range l.Partial {
+			// ClrMamePro set lists have just the name of each set
+			if c.Bool("miss-sets") {
+				lines = append(lines, p.Name)
+				continue
+			}
+			lines = append(lines, p.Name+": "+strings.Join(p.Missing, ", "))
+		}
+		sort.Strings(lines)
+
+		if err := writeLines(c.Path("miss"), lines); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeTorrent writes a torrent for the whole target directory if one was
+// requested, unless nothing was actually changed
+func writeTorrent(c *cli.Context) error {
+	if c.Path("torrent") == "" || c.Bool("dry-run") {
+		return nil
+	}
+
+	opts := torrent.Options{
+		Version:  stringToTorrentVersion[c.Generic("torrent-version").(*enumValue).String()],
+		Trackers: c.StringSlice("tracker"),
+	}
+
+	if c.String("piece-length") != "" {
+		length, err := parseSize(c.String("piece-length"))
+		if err != nil {
+			return err
+		}
+		opts.PieceLength = length
+	}
+
+	f, err := os.Create(c.Path("torrent"))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := torrent.Create(f, c.Args().First(), opts); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
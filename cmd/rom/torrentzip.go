@@ -0,0 +1,254 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/sevenzip"
+	"github.com/urfave/cli/v2"
+)
+
+var (
+	errUnsupportedSource = errors.New("not a zip archive, 7zip archive or directory")
+	errSkippedFiles      = errors.New("contains hidden or nested files that would be lost")
+	errNoFiles           = errors.New("no files to convert")
+	errBadTorrentZip     = errors.New("converted archive failed verification")
+)
+
+var torrentzipCommand = &cli.Command{
+	Name:        "torrentzip",
+	Usage:       "Convert archives to TorrentZip",
+	Description: "Convert each zip archive in place to a TorrentZip, checking the result. A directory or 7zip archive is converted to a zip archive alongside it",
+	Action:      torrentzip,
+	ArgsUsage:   "PATH...",
+	Flags: []cli.Flag{
+		&cli.BoolFlag{
+			Name:  "delete",
+			Usage: "delete each directory or 7zip archive once converted",
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "print each archive converted",
+		},
+	},
+}
+
+func torrentzip(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	for _, path := range c.Args().Slice() {
+		if err := convertTorrentZip(c, path); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+
+	return nil
+}
+
+// countFiles returns the number of files within the archive or directory
+// read by reader, including any that reader can't access
+func countFiles(reader rom.Reader) (int, error) {
+	n := 0
+
+	switch reader.(type) {
+	case *rom.DirectoryReader:
+		entries, err := os.ReadDir(reader.Name())
+		if err != nil {
+			return 0, err
+		}
+		n = len(entries)
+	case *rom.SevenZipReader:
+		r, err := sevenzip.OpenReader(reader.Name())
+		if err != nil {
+			return 0, err
+		}
+		defer r.Close()
+
+		for _, f := range r.File {
+			if !f.FileInfo().IsDir() {
+				n++
+			}
+		}
+	default:
+		r, err := zip.OpenReader(reader.Name())
+		if err != nil {
+			return 0, err
+		}
+		defer r.Close()
+
+		for _, f := range r.File {
+			if !f.FileInfo().IsDir() {
+				n++
+			}
+		}
+	}
+
+	return n, nil
+}
+
+// convertTorrentZip rewrites path as a TorrentZip, replacing it if it is a
+// zip archive
+func convertTorrentZip(c *cli.Context, path string) error {
+	reader, err := rom.NewReaderContext(c.Context, path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	var target string
+	switch r := reader.(type) {
+	case *rom.TorrentZipReader:
+		if r.Valid() {
+			return nil
+		}
+		target = path
+	case *rom.ZipReader:
+		target = path
+	case *rom.SevenZipReader:
+		target = strings.TrimSuffix(path, filepath.Ext(path)) + ".zip"
+	case *rom.DirectoryReader:
+		target = filepath.Clean(path) + ".zip"
+	default:
+		return errUnsupportedSource
+	}
+
+	files := reader.Files()
+
+	n, err := countFiles(reader)
+	if err != nil {
+		return err
+	}
+	if n != len(files) {
+		return errSkippedFiles
+	}
+	if len(files) == 0 {
+		return errNoFiles
+	}
+
+	if target != path {
+		if _, err := os.Stat(target); err == nil {
+			return fmt.Errorf("%s: %w", target, os.ErrExist)
+		}
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(target), "."+filepath.Base(target))
+	if err != nil {
+		return err
+	}
+	temp := f.Name()
+	f.Close()
+	defer os.Remove(temp)
+
+	if err := writeTorrentZip(temp, reader, files); err != nil {
+		return err
+	}
+
+	if err := verifyTorrentZip(temp, reader, files); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.Chmod(temp, info.Mode().Perm()&0o666); err != nil {
+		return err
+	}
+
+	reader.Close()
+
+	if err := os.Rename(temp, target); err != nil {
+		return err
+	}
+
+	if target != path && c.Bool("delete") {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	if c.Bool("verbose") {
+		fmt.Println(target)
+	}
+
+	return nil
+}
+
+// writeTorrentZip copies files from reader to a new TorrentZip at name
+func writeTorrentZip(name string, reader rom.Reader, files []string) error {
+	w, err := rom.NewTorrentZipWriter(name)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	for _, file := range files {
+		if err := writeROMFile(w, reader, file); err != nil {
+			return err
+		}
+	}
+
+	return w.Close()
+}
+
+func writeROMFile(w rom.Writer, reader rom.Reader, file string) error {
+	rc, err := reader.Open(file)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	wc, err := w.Create(file)
+	if err != nil {
+		return err
+	}
+	defer wc.Close()
+
+	if _, err := io.Copy(wc, rc); err != nil {
+		return err
+	}
+
+	return wc.Close()
+}
+
+// verifyTorrentZip checks the TorrentZip at name is valid and contains the
+// same files as reader
+func verifyTorrentZip(name string, reader rom.Reader, files []string) error {
+	tz, err := rom.NewTorrentZipReader(name)
+	if err != nil {
+		return err
+	}
+	defer tz.Close()
+
+	if !tz.Valid() || len(tz.Files()) != len(files) {
+		return errBadTorrentZip
+	}
+
+	for _, file := range files {
+		want, err := reader.Checksum(file, rom.CRC32)
+		if err != nil {
+			return err
+		}
+
+		got, err := tz.Checksum(file, rom.CRC32)
+		if err != nil {
+			return err
+		}
+
+		if !bytes.Equal(want, got) {
+			return fmt.Errorf("%s: %w", file, errBadTorrentZip)
+		}
+	}
+
+	return nil
+}
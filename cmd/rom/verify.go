@@ -0,0 +1,179 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/bodgit/rom/synchronizer"
+	"github.com/urfave/cli/v2"
+)
+
+var verifyCommand = &cli.Command{
+	Name:        "verify",
+	Usage:       "Verify ROMs",
+	Description: "Check one or more directories of ROMs against a dat file without changing anything. Exits with 2 if games or ROMs are only missing, 3 if anything else is wrong and 1 on error",
+	Action:      verify,
+	ArgsUsage:   "TARGET...",
+	Flags: []cli.Flag{
+		&cli.PathFlag{
+			Name:    "dat",
+			Aliases: []string{"d"},
+			Usage:   "path to the dat file, otherwise it is read from stdin",
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "also list every game without any problems",
+		},
+		&cli.PathFlag{
+			Name:    "mia",
+			Aliases: []string{"m"},
+			Usage:   "path to file containing list of games to ignore",
+		},
+		&cli.GenericFlag{
+			Name: "format",
+			Value: &enumValue{
+				Enum:    formatNames,
+				Default: "torrentzip",
+			},
+			Usage: "output format for each game. (" + strings.Join(formatNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "template for the filename of each game",
+		},
+		&cli.GenericFlag{
+			Name: "merging",
+			Value: &enumValue{
+				Enum:    mergingNames,
+				Default: "none",
+			},
+			Usage: "merging mode, overriding any set by the dat file. (" + strings.Join(mergingNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "1g1r",
+			Usage: "only keep the preferred game of each parent/clone set using this comma-separated region priority",
+		},
+		&cli.BoolFlag{
+			Name:  "json",
+			Usage: "write the result as JSON",
+		},
+	},
+}
+
+func verify(c *cli.Context) error {
+	if c.NArg() < 1 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	s, err := synchronizer.NewSynchronizer()
+	if err != nil {
+		return err
+	}
+
+	if c.String("template") != "" {
+		if err = s.SetTemplate(c.String("template")); err != nil {
+			return err
+		}
+	}
+
+	if c.Path("mia") != "" {
+		f, err := os.Open(c.Path("mia"))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err = s.SetMissing(f); err != nil {
+			return err
+		}
+	}
+
+	var b []byte
+	if c.Path("dat") != "" {
+		b, err = os.ReadFile(c.Path("dat"))
+	} else {
+		b, err = io.ReadAll(os.Stdin)
+	}
+	if err != nil {
+		return err
+	}
+
+	datfile, err := loadDat(c, b)
+	if err != nil {
+		return err
+	}
+
+	format := stringToFormat[c.Generic("format").(*enumValue).String()]
+	if !c.IsSet("format") && datfile.Header.Unpacked() {
+		format = synchronizer.Directory
+	}
+
+	if err = s.SetFormat(format); err != nil {
+		return err
+	}
+
+	code := 0
+	for _, dir := range c.Args().Slice() {
+		v, err := s.VerifyContext(ctx, dir, datfile)
+		if err != nil {
+			if ctx.Err() != nil {
+				return interrupted(c, nil)
+			}
+			return err
+		}
+
+		// Only prefix the output if there's more than one directory
+		prefix := ""
+		if c.NArg() > 1 {
+			prefix = dir + ": "
+		}
+
+		if c.Bool("json") {
+			if err = json.NewEncoder(os.Stdout).Encode(struct {
+				Dir string `json:"dir"`
+				*synchronizer.Verification
+			}{dir, v}); err != nil {
+				return err
+			}
+		} else {
+			if c.Bool("verbose") {
+				for _, game := range v.OK {
+					fmt.Println(prefix + game + ": ok")
+				}
+			}
+			for _, p := range v.Problems {
+				fmt.Println(prefix + p.String())
+			}
+		}
+
+		if len(v.Problems) > 0 {
+			log.Println(prefix+fmt.Sprint(v.Good), "of", v.Games, "game(s) good and", len(v.Problems), "problem(s) found")
+		}
+
+		// Anything wrong with what is there trumps something missing
+		for _, p := range v.Problems {
+			switch p.Kind {
+			case synchronizer.ProblemMissingGame, synchronizer.ProblemMissingROM, synchronizer.ProblemMissingDisk:
+				if code == 0 {
+					code = exitMissing
+				}
+			default:
+				code = exitInvalid
+			}
+		}
+	}
+
+	if code != 0 {
+		return cli.Exit("", code)
+	}
+
+	return nil
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/bodgit/rom/synchronizer"
+	"github.com/fsnotify/fsnotify"
+	"github.com/urfave/cli/v2"
+)
+
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshot returns the size and modification time of every regular file
+// under each of dirs, skipping hidden files and directories the same as
+// when scanning
+func snapshot(dirs ...string) (map[string]fileState, error) {
+	files := make(map[string]fileState)
+
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				// Files can disappear while they are being moved around
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+
+			if info.Name()[0] == '.' && (info.IsDir() || strings.HasPrefix(info.Name(), "._")) {
+				if info.IsDir() && file != dir {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if info.Mode().IsRegular() {
+				files[file] = fileState{info.Size(), info.ModTime()}
+			}
+
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return files, nil
+}
+
+// notifyDirs adds every directory under each of dirs to fw, skipping
+// hidden directories the same as snapshot
+func notifyDirs(fw *fsnotify.Watcher, dirs ...string) error {
+	for _, dir := range dirs {
+		err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+
+			if !info.IsDir() {
+				return nil
+			}
+			if info.Name()[0] == '.' && file != dir {
+				return filepath.SkipDir
+			}
+
+			return fw.Add(file)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// newNotifier returns a watcher for every directory under each of dirs
+func newNotifier(dirs ...string) (*fsnotify.Watcher, error) {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := notifyDirs(fw, dirs...); err != nil {
+		fw.Close()
+		return nil, err
+	}
+
+	return fw, nil
+}
+
+type watcher struct {
+	known   map[string]fileState
+	pending map[string]fileState
+}
+
+// poll compares the files found now with those seen previously. A new or
+// changed file is only returned once it has stayed the same between two
+// polls, so anything still being copied is left alone. Any file that has
+// disappeared is also returned
+func (w *watcher) poll(current map[string]fileState) ([]string, []string) {
+	var ready, removed []string
+
+	for file, state := range current {
+		if known, ok := w.known[file]; ok && known == state {
+			delete(w.pending, file)
+			continue
+		}
+		if pending, ok := w.pending[file]; ok && pending == state {
+			delete(w.pending, file)
+			w.known[file] = state
+			ready = append(ready, file)
+			continue
+		}
+		w.pending[file] = state
+	}
+
+	for file := range w.known {
+		if _, ok := current[file]; !ok {
+			delete(w.known, file)
+			removed = append(removed, file)
+		}
+	}
+
+	for file := range w.pending {
+		if _, ok := current[file]; !ok {
+			delete(w.pending, file)
+		}
+	}
+
+	sort.Strings(ready)
+	sort.Strings(removed)
+
+	return ready, removed
+}
+
+// datTargets returns the directory each dat file is synchronised with,
+// which is its own subdirectory of the target if passed with --dat
+func datTargets(c *cli.Context, datfiles []*dat.File) []string {
+	dirs := make([]string, len(datfiles))
+	for i, datfile := range datfiles {
+		dirs[i] = c.Args().First()
+		if len(c.StringSlice("dat")) > 0 {
+			dirs[i] = filepath.Join(dirs[i], synchronizer.Subdirectory(datfile))
+		}
+	}
+	return dirs
+}
+
+// watchChanges scans ready, merges it into db and updates only the games
+// that contain any of the ROMs found
+func watchChanges(ctx context.Context, s *synchronizer.Synchronizer, m *metrics, db *synchronizer.DB, datfiles []*dat.File, dirs, ready []string) error {
+	// Forget anything that was there before in case it has changed
+	db.Remove(ready...)
+
+	changed, err := s.ScanContext(ctx, ready...)
+	if err != nil {
+		return err
+	}
+	db.Merge(changed)
+
+	for i, datfile := range datfiles {
+		var uerr *synchronizer.UpdateError
+		if err := s.UpdateChangedContext(ctx, dirs[i], datfile, db, changed); err != nil {
+			if !errors.As(err, &uerr) {
+				return err
+			}
+			log.Println(err)
+			m.failed()
+		}
+	}
+
+	return nil
+}
+
+// serveMetrics serves m on /metrics at addr until ctx is cancelled. Only
+// failing to listen is returned, anything after that is logged
+func serveMetrics(ctx context.Context, addr string, m *metrics) error {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m)
+
+	hs := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-ctx.Done()
+		shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = hs.Shutdown(shutdown)
+	}()
+
+	go func() {
+		if err := hs.Serve(l); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Println(err)
+		}
+	}()
+
+	return nil
+}
+
+var watchCommand = &cli.Command{
+	Name:        "watch",
+	Usage:       "Watch sources for new ROMs",
+	Description: "Synchronise TARGET once, then watch each SOURCE for new or changed files, polling instead where the filesystem can't report changes. Once a file has stopped changing it is scanned and only the games containing any ROM found in it are updated",
+	Action:      watch,
+	ArgsUsage:   "TARGET SOURCE...",
+	Flags: []cli.Flag{
+		&cli.StringSliceFlag{
+			Name:    "dat",
+			Aliases: []string{"d"},
+			Usage:   "dat file to synchronise with its own subdirectory of TARGET, may be repeated. Otherwise one dat file is read from stdin",
+		},
+		&cli.DurationFlag{
+			Name:    "interval",
+			Aliases: []string{"i"},
+			Usage:   "how often to check for new files, or how long to wait for a changed file to stop changing",
+			Value:   10 * time.Second,
+		},
+		&cli.StringFlag{
+			Name:  "metrics",
+			Usage: "address to serve Prometheus metrics on at /metrics, such as localhost:9100",
+		},
+		&cli.IntFlag{
+			Name:    "workers",
+			Aliases: []string{"w"},
+			Usage:   "number of workers",
+			Value:   runtime.NumCPU(),
+		},
+		&cli.BoolFlag{
+			Name:    "verbose",
+			Aliases: []string{"v"},
+			Usage:   "increase verbosity",
+		},
+		&cli.GenericFlag{
+			Name: "log-format",
+			Value: &enumValue{
+				Enum:    logFormatNames,
+				Default: "text",
+			},
+			Usage: "format of the verbose output. (" + strings.Join(logFormatNames, ", ") + ")",
+		},
+		&cli.GenericFlag{
+			Name:    "algorithm",
+			Aliases: []string{"a"},
+			Value: &enumValue{
+				Enum:    checksumNames,
+				Default: "crc32",
+			},
+			Usage: "checksum algorithm to use. (" + strings.Join(checksumNames, ", ") + ")",
+		},
+		&cli.GenericFlag{
+			Name: "format",
+			Value: &enumValue{
+				Enum:    formatNames,
+				Default: "torrentzip",
+			},
+			Usage: "output format for each game. (" + strings.Join(formatNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "template",
+			Usage: "template for the filename of each game",
+		},
+		&cli.GenericFlag{
+			Name: "merging",
+			Value: &enumValue{
+				Enum:    mergingNames,
+				Default: "none",
+			},
+			Usage: "merging mode, overriding any set by the dat file. (" + strings.Join(mergingNames, ", ") + ")",
+		},
+		&cli.StringFlag{
+			Name:  "1g1r",
+			Usage: "only keep the preferred game of each parent/clone set using this comma-separated region priority",
+		},
+	},
+}
+
+func watch(c *cli.Context) error {
+	if c.NArg() < 2 {
+		cli.ShowCommandHelpAndExit(c, c.Command.FullName(), 1)
+	}
+
+	logger, logOption := newLogger(c)
+
+	ctx, stop := signalContext(c.Context)
+	defer stop()
+
+	s, err := synchronizer.NewSynchronizer(logOption, synchronizer.Workers(c.Int("workers")), synchronizer.ContinueOnError(true), synchronizer.Checksum(stringToChecksum[c.Generic("algorithm").(*enumValue).String()]))
+	if err != nil {
+		return err
+	}
+
+	if c.String("template") != "" {
+		if err = s.SetTemplate(c.String("template")); err != nil {
+			return err
+		}
+	}
+
+	datfiles, err := loadDats(c)
+	if err != nil {
+		return err
+	}
+
+	format := stringToFormat[c.Generic("format").(*enumValue).String()]
+	if !c.IsSet("format") && len(c.StringSlice("dat")) == 0 && datfiles[0].Header.Unpacked() {
+		format = synchronizer.Directory
+	}
+
+	if err = s.SetFormat(format); err != nil {
+		return err
+	}
+
+	sources := c.Args().Tail()
+
+	m := new(metrics)
+	if c.String("metrics") != "" {
+		if err = serveMetrics(ctx, c.String("metrics"), m); err != nil {
+			return err
+		}
+	}
+
+	w := &watcher{
+		pending: make(map[string]fileState),
+	}
+
+	// Where the filesystem can report changes the sources are only
+	// snapshotted after one, otherwise they are polled every interval.
+	// Either way this starts before the initial scan so nothing arriving
+	// during it is missed
+	var events <-chan fsnotify.Event
+	var notifyErrors <-chan error
+	fw, err := newNotifier(sources...)
+	if err != nil {
+		log.Println("Polling as changes can't be watched:", err)
+	} else {
+		defer fw.Close()
+		events, notifyErrors = fw.Events, fw.Errors
+	}
+	changed := fw == nil
+
+	// Take the snapshot first so anything arriving during the initial
+	// scan is picked up afterwards
+	if w.known, err = snapshot(sources...); err != nil {
+		return err
+	}
+
+	db, err := s.ScanContext(ctx, c.Args().Slice()...)
+	if err != nil {
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		return err
+	}
+
+	dirs := datTargets(c, datfiles)
+
+	for i, datfile := range datfiles {
+		var uerr *synchronizer.UpdateError
+		if err = s.UpdateContext(ctx, dirs[i], datfile, db); err != nil {
+			if ctx.Err() != nil {
+				return interrupted(c, s)
+			}
+			if !errors.As(err, &uerr) {
+				return err
+			}
+			log.Println(err)
+			m.failed()
+		}
+	}
+
+	m.read(s.Rx())
+	m.finished(datfiles, nil)
+
+	logger.Println("Watching", strings.Join(sources, ", "))
+
+	ticker := time.NewTicker(c.Duration("interval"))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event := <-events:
+			changed = true
+			// Anything in a new directory needs watching too
+			if event.Op&fsnotify.Create != 0 && filepath.Base(event.Name)[0] != '.' {
+				if fi, err := os.Stat(event.Name); err == nil && fi.IsDir() {
+					if err := notifyDirs(fw, event.Name); err != nil {
+						log.Println(err)
+					}
+				}
+			}
+			continue
+		case err := <-notifyErrors:
+			// Events may have been lost
+			log.Println(err)
+			changed = true
+			continue
+		case <-ticker.C:
+		}
+
+		// A file still being copied needs checking again even if
+		// nothing else has changed
+		if !changed && len(w.pending) == 0 {
+			continue
+		}
+		changed = fw == nil
+
+		current, err := snapshot(sources...)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		ready, removed := w.poll(current)
+
+		if len(removed) > 0 {
+			logger.Println("Removed", strings.Join(removed, ", "))
+			db.Remove(removed...)
+		}
+
+		if len(ready) == 0 {
+			continue
+		}
+
+		logger.Println("Found", strings.Join(ready, ", "))
+
+		s.Reset()
+
+		err = watchChanges(ctx, s, m, db, datfiles, dirs, ready)
+		if ctx.Err() != nil {
+			return interrupted(c, s)
+		}
+		m.read(s.Rx())
+		m.finished(datfiles, err)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+
+		stats := s.Stats()
+		logger.Println("Created", stats.Created, "modified", stats.Modified, "renamed", stats.Renamed, "and", stats.Missing, "still missing")
+	}
+}
@@ -0,0 +1,151 @@
+package dat
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var unwantedFlags = []string{"Alpha", "Beta", "Demo", "Kiosk", "Pirate", "Proto", "Sample", "Unl"}
+
+type candidate struct {
+	index  int
+	region int
+	flags  bool
+	rev    string
+	clone  bool
+}
+
+func (a candidate) better(b candidate) bool {
+	if a.region != b.region {
+		return a.region < b.region
+	}
+	if a.flags != b.flags {
+		return !a.flags
+	}
+	if c := compareRevision(a.rev, b.rev); c != 0 {
+		return c > 0
+	}
+	if a.clone != b.clone {
+		return !a.clone
+	}
+	return a.index < b.index
+}
+
+func revision(n Name) string {
+	for _, f := range n.Flags {
+		if strings.HasPrefix(f, "Rev ") {
+			return strings.TrimPrefix(f, "Rev ")
+		}
+		if len(f) > 1 && f[0] == 'v' && f[1] >= '0' && f[1] <= '9' {
+			return f[1:]
+		}
+	}
+	return ""
+}
+
+func compareRevision(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) || i < len(bs); i++ {
+		var x, y string
+		if i < len(as) {
+			x = as[i]
+		}
+		if i < len(bs) {
+			y = bs[i]
+		}
+		xi, xerr := strconv.Atoi(x)
+		yi, yerr := strconv.Atoi(y)
+		switch {
+		case xerr == nil && yerr == nil:
+			if xi != yi {
+				if xi > yi {
+					return 1
+				}
+				return -1
+			}
+		case x != y:
+			if x > y {
+				return 1
+			}
+			return -1
+		}
+	}
+	return 0
+}
+
+// OneGameOneROM returns a new File using the same Header as File f
+// containing only the preferred Game from each parent and its clones. If
+// the Games have no parent/clone information then Games with the same title,
+// ignoring any flags in the name, are grouped instead. The regions are
+// listed in order of preference and any group with no Game in one of the
+// regions is dropped entirely. Ties are broken by preferring Games that are
+// not prototypes, betas, demos, etc., then the highest revision and then
+// the parent over any clone
+func (f *File) OneGameOneROM(regions ...string) *File {
+	clones := false
+	for _, g := range f.Game {
+		if g.CloneOf != "" {
+			clones = true
+			break
+		}
+	}
+
+	priority := make(map[string]int, len(regions))
+	for i, r := range regions {
+		priority[strings.ToLower(strings.TrimSpace(r))] = i
+	}
+
+	best := make(map[string]candidate)
+
+	for i, g := range f.Game {
+		n := ParseName(g.Name)
+
+		key := n.Title
+		if clones {
+			key = g.Name
+			if g.CloneOf != "" {
+				key = g.CloneOf
+			}
+		}
+
+		c := candidate{
+			index:  i,
+			region: len(regions),
+			flags:  n.HasFlag(unwantedFlags...),
+			rev:    revision(n),
+			clone:  g.CloneOf != "",
+		}
+
+		for _, r := range n.Regions {
+			if p, ok := priority[strings.ToLower(r)]; ok && p < c.region {
+				c.region = p
+			}
+		}
+
+		if c.region == len(regions) {
+			continue
+		}
+
+		if b, ok := best[key]; !ok || c.better(b) {
+			best[key] = c
+		}
+	}
+
+	indices := make([]int, 0, len(best))
+	for _, c := range best {
+		indices = append(indices, c.index)
+	}
+	sort.Ints(indices)
+
+	filtered := &File{
+		Header: f.Header,
+		Game:   make([]Game, 0, len(indices)),
+	}
+
+	for _, i := range indices {
+		filtered.Game = append(filtered.Game, f.Game[i])
+	}
+
+	return filtered
+}
@@ -0,0 +1,55 @@
+package dat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOneGameOneROM(t *testing.T) {
+	tables := map[string]struct {
+		games   []Game
+		regions []string
+		want    []string
+	}{
+		"parent clone": {
+			[]Game{
+				{Name: "Game (Japan)"},
+				{Name: "Game (USA)", CloneOf: "Game (Japan)"},
+				{Name: "Game (Europe)", CloneOf: "Game (Japan)"},
+				{Name: "Other (Europe)"},
+				{Name: "Other (Japan)", CloneOf: "Other (Europe)"},
+				{Name: "Missing (Korea)"},
+			},
+			[]string{"USA", "Europe", "Japan"},
+			[]string{"Game (USA)", "Other (Europe)"},
+		},
+		"title": {
+			[]Game{
+				{Name: "Game (USA)"},
+				{Name: "Game (USA) (Rev 1)"},
+				{Name: "Game (USA) (Rev 2) (Proto)"},
+				{Name: "Game (Europe)"},
+				{Name: "Other (Japan)"},
+				{Name: "Other (Japan) (v1.10)"},
+				{Name: "Other (Japan) (v1.9)"},
+			},
+			[]string{"usa", "japan"},
+			[]string{"Game (USA) (Rev 1)", "Other (Japan) (v1.10)"},
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			f := &File{Header: Header{Name: "test"}, Game: table.games}
+			filtered := f.OneGameOneROM(table.regions...)
+			assert.Equal(t, "test", filtered.Header.Name)
+
+			var got []string
+			for _, g := range filtered.Game {
+				got = append(got, g.Name)
+			}
+			assert.Equal(t, table.want, got)
+		})
+	}
+}
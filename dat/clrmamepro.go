@@ -0,0 +1,334 @@
+package dat
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var errClrMameProSyntax = errors.New("clrmamepro syntax error")
+
+// cmpElement is one key within a clrmamepro dat file, either with a single
+// value or a parenthesised list of child elements
+type cmpElement struct {
+	key      string
+	value    string
+	children []cmpElement
+}
+
+func (e cmpElement) get(key string) string {
+	for _, c := range e.children {
+		if c.key == key && c.children == nil {
+			return c.value
+		}
+	}
+	return ""
+}
+
+// cmpTokenize splits data into words, quoted strings and parentheses
+func cmpTokenize(data []byte) ([]string, error) {
+	var tokens []string
+	s := string(data)
+
+	for i := 0; i < len(s); {
+		switch c := s[i]; {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, string(c))
+			i++
+		case c == '"':
+			j := strings.IndexByte(s[i+1:], '"')
+			if j < 0 {
+				return nil, fmt.Errorf("%w: unterminated string", errClrMameProSyntax)
+			}
+			// Keep the quote so an empty string is still a token
+			tokens = append(tokens, s[i:i+j+1])
+			i += j + 2
+		default:
+			j := i
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && s[j] != '(' && s[j] != ')' {
+				j++
+			}
+			tokens = append(tokens, s[i:j])
+			i = j
+		}
+	}
+
+	return tokens, nil
+}
+
+// cmpParse parses tokens into elements until the closing parenthesis of the
+// current list or the end of the tokens
+func cmpParse(tokens []string) ([]cmpElement, []string, error) {
+	var elements []cmpElement
+
+	for len(tokens) > 0 {
+		if tokens[0] == ")" {
+			return elements, tokens, nil
+		}
+
+		if len(tokens) < 2 || tokens[0] == "(" {
+			return nil, nil, fmt.Errorf("%w: unexpected %q", errClrMameProSyntax, tokens[0])
+		}
+
+		e := cmpElement{key: strings.ToLower(strings.TrimPrefix(tokens[0], `"`))}
+
+		switch tokens[1] {
+		case "(":
+			children, rest, err := cmpParse(tokens[2:])
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(rest) == 0 {
+				return nil, nil, fmt.Errorf("%w: missing closing parenthesis", errClrMameProSyntax)
+			}
+			if children == nil {
+				children = []cmpElement{}
+			}
+			e.children = children
+			tokens = rest[1:]
+		case ")":
+			return nil, nil, fmt.Errorf("%w: missing value for %q", errClrMameProSyntax, e.key)
+		default:
+			e.value = strings.TrimPrefix(tokens[1], `"`)
+			tokens = tokens[2:]
+		}
+
+		elements = append(elements, e)
+	}
+
+	return elements, tokens, nil
+}
+
+// UnmarshalClrMamePro parses a clrmamepro dat file and stores the result in
+// File f. The game, machine and resource blocks are all treated as a Game
+func UnmarshalClrMamePro(data []byte, f *File) error {
+	tokens, err := cmpTokenize(data)
+	if err != nil {
+		return err
+	}
+
+	elements, rest, err := cmpParse(tokens)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 0 {
+		return fmt.Errorf("%w: unexpected %q", errClrMameProSyntax, rest[0])
+	}
+
+	f.Header = Header{}
+	f.Game = nil
+
+	for _, e := range elements {
+		if e.children == nil {
+			continue
+		}
+
+		switch e.key {
+		case "clrmamepro":
+			f.Header = Header{
+				Name:        e.get("name"),
+				Description: e.get("description"),
+				Version:     e.get("version"),
+				Date:        e.get("date"),
+				Author:      e.get("author"),
+				Homepage:    e.get("homepage"),
+				URL:         e.get("url"),
+			}
+			cmp := ClrMamePro{
+				Header:       e.get("header"),
+				ForceMerging: e.get("forcemerging"),
+				ForceNoDump:  e.get("forcenodump"),
+				ForcePacking: e.get("forcepacking"),
+			}
+			if cmp != (ClrMamePro{}) {
+				f.Header.ClrMamePro = &cmp
+			}
+		case "game", "machine", "resource":
+			game, err := cmpGame(e)
+			if err != nil {
+				return err
+			}
+			f.Game = append(f.Game, game)
+		}
+	}
+
+	return nil
+}
+
+func cmpGame(e cmpElement) (Game, error) {
+	game := Game{
+		Name:        e.get("name"),
+		CloneOf:     e.get("cloneof"),
+		RomOf:       e.get("romof"),
+		SampleOf:    e.get("sampleof"),
+		Category:    e.get("category"),
+		Description: e.get("description"),
+	}
+
+	for _, c := range e.children {
+		switch c.key {
+		case "rom":
+			if c.children == nil {
+				continue
+			}
+			r := ROM{
+				Name:   c.get("name"),
+				CRC32:  c.get("crc"),
+				MD5:    c.get("md5"),
+				SHA1:   c.get("sha1"),
+				Merge:  c.get("merge"),
+				Status: c.get("flags"),
+				Serial: c.get("serial"),
+				Date:   c.get("date"),
+				MIA:    c.get("mia"),
+			}
+			if status := c.get("status"); status != "" {
+				r.Status = status
+			}
+			if size := c.get("size"); size != "" {
+				n, err := strconv.ParseUint(size, 10, 64)
+				if err != nil {
+					return Game{}, err
+				}
+				r.Size = n
+			}
+			game.ROM = append(game.ROM, r)
+		case "disk":
+			if c.children == nil {
+				continue
+			}
+			d := Disk{
+				Name:   c.get("name"),
+				SHA1:   c.get("sha1"),
+				MD5:    c.get("md5"),
+				Merge:  c.get("merge"),
+				Status: c.get("flags"),
+			}
+			if status := c.get("status"); status != "" {
+				d.Status = status
+			}
+			game.Disk = append(game.Disk, d)
+		case "sample":
+			if c.children == nil {
+				game.Sample = append(game.Sample, Sample{Name: c.value})
+			}
+		}
+	}
+
+	return game, nil
+}
+
+// cmpQuote quotes s unless it is a single word that can be left as-is
+func cmpQuote(s string) string {
+	if s == "" || strings.ContainsAny(s, " \t\r\n()\"") {
+		return `"` + s + `"`
+	}
+	return s
+}
+
+// MarshalClrMamePro encodes File f as a clrmamepro dat file. As with
+// marshalling to XML, any ROM, Disk or Sample that has been matched and any
+// Game that is complete is left out and nothing is returned if every Game
+// is complete
+func MarshalClrMamePro(f *File) ([]byte, error) {
+	if f.isComplete() {
+		return nil, nil
+	}
+
+	b := new(bytes.Buffer)
+
+	field := func(key, value string) {
+		if value != "" {
+			fmt.Fprintf(b, "\t%s %s\n", key, cmpQuote(value))
+		}
+	}
+
+	b.WriteString("clrmamepro (\n")
+	field("name", f.Header.Name)
+	field("description", f.Header.Description)
+	field("version", f.Header.Version)
+	field("date", f.Header.Date)
+	field("author", f.Header.Author)
+	field("homepage", f.Header.Homepage)
+	field("url", f.Header.URL)
+	if cmp := f.Header.ClrMamePro; cmp != nil {
+		field("header", cmp.Header)
+		field("forcemerging", cmp.ForceMerging)
+		field("forcenodump", cmp.ForceNoDump)
+		field("forcepacking", cmp.ForcePacking)
+	}
+	b.WriteString(")\n")
+
+	for _, g := range f.Game {
+		if g.Complete() {
+			continue
+		}
+
+		b.WriteString("\ngame (\n")
+		field("name", g.Name)
+		field("description", g.Description)
+		field("category", g.Category)
+		field("cloneof", g.CloneOf)
+		field("romof", g.RomOf)
+		field("sampleof", g.SampleOf)
+
+		for _, r := range g.ROM {
+			if r.Complete() {
+				continue
+			}
+			fmt.Fprintf(b, "\trom ( name %s size %d", cmpQuote(r.Name), r.Size)
+			for _, attr := range []struct {
+				key, value string
+			}{
+				{"crc", r.CRC32},
+				{"md5", r.MD5},
+				{"sha1", r.SHA1},
+				{"merge", r.Merge},
+				{"flags", r.Status},
+				{"serial", r.Serial},
+				{"date", r.Date},
+				{"mia", r.MIA},
+			} {
+				if attr.value != "" {
+					fmt.Fprintf(b, " %s %s", attr.key, cmpQuote(attr.value))
+				}
+			}
+			b.WriteString(" )\n")
+		}
+
+		for _, d := range g.Disk {
+			if d.Complete() {
+				continue
+			}
+			fmt.Fprintf(b, "\tdisk ( name %s", cmpQuote(d.Name))
+			for _, attr := range []struct {
+				key, value string
+			}{
+				{"sha1", d.SHA1},
+				{"md5", d.MD5},
+				{"merge", d.Merge},
+				{"flags", d.Status},
+			} {
+				if attr.value != "" {
+					fmt.Fprintf(b, " %s %s", attr.key, cmpQuote(attr.value))
+				}
+			}
+			b.WriteString(" )\n")
+		}
+
+		for _, s := range g.Sample {
+			if !s.Complete() {
+				field("sample", s.Name)
+			}
+		}
+
+		b.WriteString(")\n")
+	}
+
+	return b.Bytes(), nil
+}
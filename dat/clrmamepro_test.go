@@ -0,0 +1,103 @@
+package dat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalClrMamePro(t *testing.T) {
+	data := []byte(`clrmamepro (
+	name "Nintendo - NES"
+	description "Nintendo - NES (20200101)"
+	version 20200101
+	forcemerging split
+)
+
+game (
+	name "Parent (USA)"
+	description "Parent (USA)"
+	rom ( name "Parent (USA).nes" size 40976 crc 1234abcd md5 0123456789abcdef0123456789abcdef sha1 0123456789abcdef0123456789abcdef01234567 )
+)
+
+game (
+	name "Clone (Europe)"
+	description "Clone (Europe)"
+	cloneof "Parent (USA)"
+	romof "Parent (USA)"
+	rom ( name "Clone (Europe).nes" size 4 crc b63cfbcd flags baddump )
+	rom ( name "missing.bin" size 0 flags nodump )
+	disk ( name disk sha1 0123456789abcdef0123456789abcdef01234567 )
+	sample "explode"
+)
+`)
+
+	f := new(File)
+	if err := UnmarshalClrMamePro(data, f); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, Header{
+		Name:        "Nintendo - NES",
+		Description: "Nintendo - NES (20200101)",
+		Version:     "20200101",
+		ClrMamePro: &ClrMamePro{
+			ForceMerging: "split",
+		},
+	}, f.Header)
+	assert.Equal(t, []Game{
+		{
+			Name:        "Parent (USA)",
+			Description: "Parent (USA)",
+			ROM: []ROM{
+				{Name: "Parent (USA).nes", Size: 40976, CRC32: "1234abcd", MD5: "0123456789abcdef0123456789abcdef", SHA1: "0123456789abcdef0123456789abcdef01234567"},
+			},
+		},
+		{
+			Name:        "Clone (Europe)",
+			Description: "Clone (Europe)",
+			CloneOf:     "Parent (USA)",
+			RomOf:       "Parent (USA)",
+			ROM: []ROM{
+				{Name: "Clone (Europe).nes", Size: 4, CRC32: "b63cfbcd", Status: StatusBadDump},
+				{Name: "missing.bin", Status: StatusNoDump},
+			},
+			Disk: []Disk{
+				{Name: "disk", SHA1: "0123456789abcdef0123456789abcdef01234567"},
+			},
+			Sample: []Sample{
+				{Name: "explode"},
+			},
+		},
+	}, f.Game)
+
+	b, err := MarshalClrMamePro(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g := new(File)
+	if err := UnmarshalClrMamePro(b, g); err != nil {
+		t.Fatal(err)
+	}
+
+	// The nodump ROM is never written, the same as with XML
+	f.Game[1].ROM = f.Game[1].ROM[:1]
+	assert.Equal(t, f.Header, g.Header)
+	assert.Equal(t, f.Game, g.Game)
+}
+
+func TestUnmarshalClrMameProError(t *testing.T) {
+	tables := map[string]string{
+		"unterminated": `game ( name "foo )`,
+		"unbalanced":   `game ( name foo`,
+		"extra":        `game ( name foo ) )`,
+		"value":        `game ( name )`,
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			assert.ErrorIs(t, UnmarshalClrMamePro([]byte(table), new(File)), errClrMameProSyntax)
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+)
+
+// prolog records the XML declaration and any Logiqx DOCTYPE in File f so
+// they can be written back out by an Encoder
+func prolog(t xml.Token, f *File) {
+	switch t := t.(type) {
+	case xml.ProcInst:
+		if t.Target == "xml" {
+			f.Declaration = "<?xml " + string(t.Inst) + "?>"
+		}
+	case xml.Directive:
+		if strings.HasPrefix(string(t), "DOCTYPE datafile") {
+			f.DocType = "<!" + string(t) + ">"
+		}
+	}
+}
+
+// Unmarshal is like xml.Unmarshal however it also records the XML
+// declaration and DOCTYPE that precede the root element in File f
+func Unmarshal(data []byte, f *File) error {
+	if err := xml.Unmarshal(data, f); err != nil {
+		return err
+	}
+
+	d := xml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := t.(xml.StartElement); ok {
+			return nil
+		}
+
+		prolog(t, f)
+	}
+}
+
+// Decode reads an XML dat file from r one element at a time, calling fn with
+// each Game as soon as it has been parsed rather than unmarshalling the whole
+// document in one go. This keeps memory usage low for very large dat files
+// such as the output of "mame -listxml", where <machine> elements are treated
+// the same as <game> elements. Any error returned by fn stops the decoding and
+// is returned. The returned File contains the Header, XML declaration and
+// DOCTYPE but no Games
+func Decode(r io.Reader, fn func(Game) error) (*File, error) {
+	f := new(File)
+	d := xml.NewDecoder(r)
+	root := false
+
+	for {
+		t, err := d.Token()
+		if err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, err
+		}
+
+		start, ok := t.(xml.StartElement)
+		if !ok {
+			if !root {
+				prolog(t, f)
+			}
+			continue
+		}
+		root = true
+
+		switch start.Name.Local {
+		case "header":
+			if err := d.DecodeElement(&f.Header, &start); err != nil {
+				return nil, err
+			}
+		case "game", "machine":
+			start.Name.Local = "game"
+
+			var g Game
+			if err := d.DecodeElement(&g, &start); err != nil {
+				return nil, err
+			}
+
+			if err := fn(g); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return f, nil
+}
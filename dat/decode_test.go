@@ -0,0 +1,77 @@
+package dat
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecode(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "NEC - PC Engine SuperGrafx (20191008-080644).dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	var games []string
+	f, err := Decode(file, func(g Game) error {
+		games = append(games, g.Name)
+		assert.Len(t, g.ROM, 1)
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "NEC - PC Engine SuperGrafx", f.Header.Name)
+	assert.Equal(t, `<?xml version="1.0"?>`, f.Declaration)
+	assert.Equal(t, doctype, f.DocType)
+	assert.Len(t, f.Game, 0)
+	assert.Equal(t, []string{
+		"1941 - Counter Attack (Japan)",
+		"Aldynes - The Misson Code for Rage Crisis (Japan)",
+		"Battle Ace (Japan)",
+		"Daimakaimura (Japan)",
+		"Madou King Granzort (Japan)",
+	}, games)
+}
+
+func TestDecodeMachine(t *testing.T) {
+	r := strings.NewReader(`<?xml version="1.0"?>
+<!DOCTYPE mame [
+<!ELEMENT mame (machine+)>
+]>
+<mame build="0.200">
+	<machine name="test">
+		<description>Test</description>
+		<rom name="test.bin" size="4" crc="b63cfbcd"/>
+	</machine>
+</mame>`)
+
+	var games []Game
+	f, err := Decode(r, func(g Game) error {
+		games = append(games, g)
+		return nil
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "", f.DocType)
+	assert.Len(t, games, 1)
+	assert.Equal(t, "test", games[0].Name)
+	assert.Equal(t, "Test", games[0].Description)
+	assert.Equal(t, "b63cfbcd", games[0].ROM[0].CRC32)
+}
+
+func TestDecodeError(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "NEC - PC Engine SuperGrafx (20191008-080644).dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	errTest := errors.New("test")
+	_, err = Decode(file, func(g Game) error {
+		return errTest
+	})
+	assert.Equal(t, errTest, err)
+}
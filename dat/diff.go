@@ -0,0 +1,122 @@
+package dat
+
+import (
+	"github.com/bodgit/rom"
+)
+
+// Difference holds the Games that have been added, removed or changed
+// between two versions of a File
+type Difference struct {
+	Added   []Game           `json:"added,omitempty"`
+	Removed []Game           `json:"removed,omitempty"`
+	Changed []GameDifference `json:"changed,omitempty"`
+}
+
+// GameDifference holds the ROMs that have been added, removed or changed
+// between two versions of a Game with the same name
+type GameDifference struct {
+	Name    string          `json:"name"`
+	Added   []ROM           `json:"added,omitempty"`
+	Removed []ROM           `json:"removed,omitempty"`
+	Changed []ROMDifference `json:"changed,omitempty"`
+}
+
+// ROMDifference holds the old and new versions of a ROM with the same name
+// where either the size or at least one checksum differs
+type ROMDifference struct {
+	Old ROM `json:"old"`
+	New ROM `json:"new"`
+}
+
+// Empty returns true if there are no differences
+func (d *Difference) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// Diff compares File a with File b, matching Games and ROMs by name, and
+// returns the differences needed to turn a into b
+func Diff(a, b *File) *Difference {
+	d := new(Difference)
+
+	old := make(map[string]*Game, len(a.Game))
+	for i := range a.Game {
+		old[a.Game[i].Name] = &a.Game[i]
+	}
+
+	current := make(map[string]struct{}, len(b.Game))
+	for i := range b.Game {
+		g := &b.Game[i]
+		current[g.Name] = struct{}{}
+
+		o, ok := old[g.Name]
+		if !ok {
+			d.Added = append(d.Added, *g)
+			continue
+		}
+
+		if gd := diffGame(o, g); gd != nil {
+			d.Changed = append(d.Changed, *gd)
+		}
+	}
+
+	for _, g := range a.Game {
+		if _, ok := current[g.Name]; !ok {
+			d.Removed = append(d.Removed, g)
+		}
+	}
+
+	return d
+}
+
+func diffGame(a, b *Game) *GameDifference {
+	d := &GameDifference{
+		Name: b.Name,
+	}
+
+	old := make(map[string]*ROM, len(a.ROM))
+	for i := range a.ROM {
+		old[a.ROM[i].Name] = &a.ROM[i]
+	}
+
+	current := make(map[string]struct{}, len(b.ROM))
+	for i := range b.ROM {
+		r := &b.ROM[i]
+		current[r.Name] = struct{}{}
+
+		o, ok := old[r.Name]
+		if !ok {
+			d.Added = append(d.Added, *r)
+			continue
+		}
+
+		if !sameROM(o, r) {
+			d.Changed = append(d.Changed, ROMDifference{*o, *r})
+		}
+	}
+
+	for _, r := range a.ROM {
+		if _, ok := current[r.Name]; !ok {
+			d.Removed = append(d.Removed, r)
+		}
+	}
+
+	if len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0 {
+		return nil
+	}
+
+	return d
+}
+
+func sameROM(a, b *ROM) bool {
+	if a.Size != b.Size {
+		return false
+	}
+
+	for _, c := range []rom.Checksum{rom.CRC32, rom.MD5, rom.SHA1} {
+		if a.Checksum(c) != b.Checksum(c) {
+			return false
+		}
+	}
+
+	return true
+}
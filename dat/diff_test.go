@@ -0,0 +1,79 @@
+package dat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	a := &File{
+		Game: []Game{
+			{
+				Name: "unchanged",
+				ROM: []ROM{
+					{Name: "unchanged.bin", Size: 4, CRC32: "b63cfbcd"},
+				},
+			},
+			{
+				Name: "changed",
+				ROM: []ROM{
+					{Name: "same.bin", Size: 4, CRC32: "b63cfbcd"},
+					{Name: "hash.bin", Size: 4, CRC32: "b63cfbcd"},
+					{Name: "size.bin", Size: 4, CRC32: "b63cfbcd"},
+					{Name: "removed.bin", Size: 4, CRC32: "b63cfbcd"},
+				},
+			},
+			{
+				Name: "removed",
+			},
+		},
+	}
+
+	b := &File{
+		Game: []Game{
+			{
+				Name: "unchanged",
+				ROM: []ROM{
+					{Name: "unchanged.bin", Size: 4, CRC32: "B63CFBCD"},
+				},
+			},
+			{
+				Name: "changed",
+				ROM: []ROM{
+					{Name: "same.bin", Size: 4, CRC32: "b63cfbcd"},
+					{Name: "hash.bin", Size: 4, CRC32: "cecee288"},
+					{Name: "size.bin", Size: 8, CRC32: "b63cfbcd"},
+					{Name: "added.bin", Size: 4, CRC32: "b63cfbcd"},
+				},
+			},
+			{
+				Name: "added",
+			},
+		},
+	}
+
+	d := Diff(a, b)
+	assert.Equal(t, false, d.Empty())
+	assert.Equal(t, []Game{{Name: "added"}}, d.Added)
+	assert.Equal(t, []Game{{Name: "removed"}}, d.Removed)
+	assert.Equal(t, []GameDifference{
+		{
+			Name:    "changed",
+			Added:   []ROM{{Name: "added.bin", Size: 4, CRC32: "b63cfbcd"}},
+			Removed: []ROM{{Name: "removed.bin", Size: 4, CRC32: "b63cfbcd"}},
+			Changed: []ROMDifference{
+				{
+					Old: ROM{Name: "hash.bin", Size: 4, CRC32: "b63cfbcd"},
+					New: ROM{Name: "hash.bin", Size: 4, CRC32: "cecee288"},
+				},
+				{
+					Old: ROM{Name: "size.bin", Size: 4, CRC32: "b63cfbcd"},
+					New: ROM{Name: "size.bin", Size: 8, CRC32: "b63cfbcd"},
+				},
+			},
+		},
+	}, d.Changed)
+
+	assert.Equal(t, true, Diff(a, a).Empty())
+}
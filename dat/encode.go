@@ -0,0 +1,99 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"regexp"
+)
+
+const (
+	declaration = `<?xml version="1.0"?>`
+	doctype     = `<!DOCTYPE datafile PUBLIC "-//Logiqx//DTD ROM Management Datafile//EN" "http://www.logiqx.com/Dats/datafile.dtd">`
+)
+
+var emptyElement = regexp.MustCompile(`<([^\s<>/!?]+)([^<>]*)></([^\s<>]+)>`)
+
+// Encoder writes a File as XML to an output stream. Unlike xml.Marshal, the
+// output matches the formatting commonly used by dat files; it starts with
+// the XML declaration and DOCTYPE of the original dat file, or those of a
+// Logiqx dat file if neither were recorded, empty elements such as <rom> are written
+// as self-closing tags and optional elements with no value, such as an
+// empty <category>, are omitted
+type Encoder struct {
+	w              io.Writer
+	prefix, indent string
+}
+
+// NewEncoder returns a new Encoder that writes to w
+func NewEncoder(w io.Writer) *Encoder {
+	return &Encoder{w: w}
+}
+
+// Indent sets the encoder to generate XML in which each element begins on a
+// new indented line that starts with prefix and is followed by one or more
+// copies of indent according to the nesting depth
+func (e *Encoder) Indent(prefix, indent string) {
+	e.prefix, e.indent = prefix, indent
+}
+
+// Encode writes the XML encoding of File f to the stream. As with
+// xml.Marshal, nothing is written if every Game in File f has been matched
+func (e *Encoder) Encode(f *File) error {
+	b := new(bytes.Buffer)
+
+	enc := xml.NewEncoder(b)
+	enc.Indent(e.prefix, e.indent)
+
+	if err := enc.Encode(f); err != nil {
+		return err
+	}
+
+	if b.Len() == 0 {
+		return nil
+	}
+
+	out := emptyElement.ReplaceAllFunc(b.Bytes(), func(element []byte) []byte {
+		m := emptyElement.FindSubmatch(element)
+		switch {
+		case !bytes.Equal(m[1], m[3]):
+			return element
+		case len(bytes.TrimSpace(m[2])) == 0:
+			return nil
+		default:
+			return append(append(append([]byte("<"), m[1]...), m[2]...), "/>"...)
+		}
+	})
+
+	// Removing an element leaves a blank line behind when indenting
+	if e.prefix != "" || e.indent != "" {
+		lines := bytes.Split(out, []byte("\n"))
+		out = out[:0:0]
+		for _, line := range lines {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			out = append(append(out, line...), '\n')
+		}
+	} else {
+		out = append(out, '\n')
+	}
+
+	var head string
+	for _, s := range []string{f.Declaration, f.DocType} {
+		if s != "" {
+			head += s + "\n"
+		}
+	}
+	if head == "" {
+		head = declaration + "\n" + doctype + "\n"
+	}
+
+	for _, b := range [][]byte{[]byte(head), out} {
+		if _, err := e.w.Write(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,87 @@
+package dat
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoder(t *testing.T) {
+	b, err := os.ReadFile(filepath.Join("testdata", "NEC - PC Engine SuperGrafx (20191008-080644).dat"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := new(File)
+	if err := Unmarshal(b, f); err != nil {
+		t.Fatal(err)
+	}
+
+	out := new(bytes.Buffer)
+	e := NewEncoder(out)
+	e.Indent("", "\t")
+
+	if err := e.Encode(f); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, string(bytes.ReplaceAll(b, []byte("\r\n"), []byte("\n"))), out.String())
+
+	f.Game[0].Matched()
+	f.Game[1].ROM[0].Matched()
+
+	out.Reset()
+	if err := e.Encode(f); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NotContains(t, out.String(), "1941 - Counter Attack (Japan)")
+	assert.NotContains(t, out.String(), "Aldynes")
+
+	for i := range f.Game {
+		f.Game[i].Matched()
+	}
+
+	out.Reset()
+	if err := e.Encode(f); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, 0, out.Len())
+}
+
+func TestEncoderProlog(t *testing.T) {
+	tables := map[string]struct {
+		xml    string
+		prolog string
+	}{
+		"none": {
+			`<datafile><header><name>test</name></header><game name="test"><rom name="test.bin"/></game></datafile>`,
+			`<?xml version="1.0"?>` + "\n" + doctype + "\n",
+		},
+		"declaration": {
+			`<?xml version="1.0" encoding="UTF-8"?><datafile><header><name>test</name></header><game name="test"><rom name="test.bin"/></game></datafile>`,
+			`<?xml version="1.0" encoding="UTF-8"?>` + "\n",
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			f := new(File)
+			if err := Unmarshal([]byte(table.xml), f); err != nil {
+				t.Fatal(err)
+			}
+
+			out := new(bytes.Buffer)
+			if err := NewEncoder(out).Encode(f); err != nil {
+				t.Fatal(err)
+			}
+
+			assert.True(t, strings.HasPrefix(out.String(), table.prolog+"<datafile>"), out.String())
+		})
+	}
+}
@@ -2,13 +2,17 @@
 Package dat implements parsing of XML dat files as commonly used by ROM/Disc
 preservation projects such as http://redump.org and https://no-intro.org. The
 DTD supports more elements but currently just the minimal subset used by
-these two projects are implemented.
+these two projects are implemented. The older clrmamepro and RomCenter text
+formats can also be read and written.
 
 It has the facility to mark a ROM as matched/found and so when the File is
 marshalled back to XML, any such ROMs are not included in the output. This
 means if all of the ROMs for a particular Game are matched, that Game will not
 be included at all in the output and consequently if all ROMs for all Games
-are matched, no XML will be output at all for the entire File.
+are matched, no XML will be output at all for the entire File. Using an
+Encoder rather than xml.Marshal produces output formatted the same way as the
+original dat file, including self-closing <rom> elements and, if the File was
+read with Unmarshal or Decode, the original XML declaration and DOCTYPE.
 
 An example:
 
@@ -43,16 +47,17 @@ An example:
 */
 package dat
 
-// BUG(bodgit): Due to how encoding/xml works, <rom> elements are not marshalled as self-closing
-
 import (
 	"encoding/xml"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/bodgit/rom"
 )
 
+const fixdatPrefix = "fix_"
+
 // Header represents the header section in the XML dat file
 type Header struct {
 	XMLName     xml.Name `xml:"header"`
@@ -63,6 +68,31 @@ type Header struct {
 	Author      string   `xml:"author"`
 	Homepage    string   `xml:"homepage"`
 	URL         string   `xml:"url"`
+	ClrMamePro  *ClrMamePro
+	RomCenter   *RomCenter
+}
+
+// ClrMamePro represents the optional clrmamepro element in the header which
+// carries hints for ROM managers
+type ClrMamePro struct {
+	XMLName      xml.Name `xml:"clrmamepro"`
+	Header       string   `xml:"header,attr,omitempty"`
+	ForceMerging string   `xml:"forcemerging,attr,omitempty"`
+	ForceNoDump  string   `xml:"forcenodump,attr,omitempty"`
+	ForcePacking string   `xml:"forcepacking,attr,omitempty"`
+}
+
+// RomCenter represents the optional romcenter element in the header which
+// carries hints for ROM managers
+type RomCenter struct {
+	XMLName        xml.Name `xml:"romcenter"`
+	Plugin         string   `xml:"plugin,attr,omitempty"`
+	ROMMode        string   `xml:"rommode,attr,omitempty"`
+	BIOSMode       string   `xml:"biosmode,attr,omitempty"`
+	SampleMode     string   `xml:"samplemode,attr,omitempty"`
+	LockROMMode    string   `xml:"lockrommode,attr,omitempty"`
+	LockBIOSMode   string   `xml:"lockbiosmode,attr,omitempty"`
+	LockSampleMode string   `xml:"locksamplemode,attr,omitempty"`
 }
 
 // File represents the whole XML dat file. It consists of one Header followed
@@ -71,12 +101,20 @@ type File struct {
 	XMLName xml.Name `xml:"datafile"`
 	Header  Header   `xml:"header"`
 	Game    []Game   `xml:"game"`
+
+	// Declaration and DocType hold the XML declaration and DOCTYPE found
+	// before the root element by Unmarshal or Decode
+	Declaration string `xml:"-"`
+	DocType     string `xml:"-"`
+
+	indexOnce sync.Once
+	index     map[indexKey][]Match
 }
 
 func (f *File) isComplete() bool {
 	complete := 0
 	for _, g := range f.Game {
-		if g.isComplete() {
+		if g.Complete() {
 			complete++
 		}
 	}
@@ -103,7 +141,7 @@ func (f *File) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	}
 
 	for _, g := range f.Game {
-		if g.isComplete() {
+		if g.Complete() {
 			continue
 		}
 		if err := e.EncodeElement(g, xml.StartElement{Name: xml.Name{Local: "game"}}); err != nil {
@@ -118,6 +156,51 @@ func (f *File) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 	return e.Flush()
 }
 
+// Fixdat returns a new File containing only the Games and ROMs within File f
+// that have not been matched. The Header is copied from File f with the name
+// and description prefixed to mark it as a fixdat
+func (f *File) Fixdat() *File {
+	fixdat := &File{
+		Header: f.Header,
+	}
+
+	fixdat.Header.Name = fixdatPrefix + f.Header.Name
+	fixdat.Header.Description = fixdatPrefix + f.Header.Description
+
+	for _, g := range f.Game {
+		if g.Complete() {
+			continue
+		}
+
+		game := g
+		game.ROM = make([]ROM, 0, len(g.ROM))
+		for _, r := range g.ROM {
+			if r.Complete() {
+				continue
+			}
+			game.ROM = append(game.ROM, r)
+		}
+		game.Disk = make([]Disk, 0, len(g.Disk))
+		for _, d := range g.Disk {
+			if d.Complete() {
+				continue
+			}
+			game.Disk = append(game.Disk, d)
+		}
+		game.Sample = make([]Sample, 0, len(g.Sample))
+		for _, s := range g.Sample {
+			if s.Complete() {
+				continue
+			}
+			game.Sample = append(game.Sample, s)
+		}
+
+		fixdat.Game = append(fixdat.Game, game)
+	}
+
+	return fixdat
+}
+
 // Reset returns each Game within File f back to its original state
 func (f *File) Reset() {
 	for i := range f.Game {
@@ -128,11 +211,16 @@ func (f *File) Reset() {
 // Game represents one game within an XML dat file. It contains zero or more
 // ROMs
 type Game struct {
-	XMLName     xml.Name `xml:"game"`
-	Name        string   `xml:"name,attr"`
-	Category    string   `xml:"category"`
-	Description string   `xml:"description"`
-	ROM         []ROM    `xml:"rom"`
+	XMLName     xml.Name `xml:"game" json:"-"`
+	Name        string   `xml:"name,attr" json:"name"`
+	CloneOf     string   `xml:"cloneof,attr,omitempty" json:"cloneof,omitempty"`
+	RomOf       string   `xml:"romof,attr,omitempty" json:"romof,omitempty"`
+	SampleOf    string   `xml:"sampleof,attr,omitempty" json:"sampleof,omitempty"`
+	Category    string   `xml:"category" json:"category,omitempty"`
+	Description string   `xml:"description" json:"description"`
+	ROM         []ROM    `xml:"rom" json:"rom,omitempty"`
+	Disk        []Disk   `xml:"disk" json:"disk,omitempty"`
+	Sample      []Sample `xml:"sample" json:"sample,omitempty"`
 }
 
 // Matched marks Game g as found in some external repository. By doing this
@@ -141,36 +229,74 @@ func (g *Game) Matched() {
 	for i := range g.ROM {
 		g.ROM[i].Matched()
 	}
+	for i := range g.Disk {
+		g.Disk[i].Matched()
+	}
+	for i := range g.Sample {
+		g.Sample[i].Matched()
+	}
 }
 
-func (g *Game) isComplete() bool {
+// Complete returns true if every ROM, Disk and Sample used by Game g has
+// been matched
+func (g *Game) Complete() bool {
 	complete := 0
 	for _, r := range g.ROM {
-		if r.isComplete() {
+		if r.Complete() {
 			complete++
 		}
 	}
-	return complete == len(g.ROM)
+	for _, d := range g.Disk {
+		if d.Complete() {
+			complete++
+		}
+	}
+	for _, s := range g.Sample {
+		if s.Complete() {
+			complete++
+		}
+	}
+	return complete == len(g.ROM)+len(g.Disk)+len(g.Sample)
 }
 
-// Reset returns each ROM used by Game g back to its original state
+// Reset returns each ROM, Disk and Sample used by Game g back to its
+// original state
 func (g *Game) Reset() {
 	for i := range g.ROM {
 		g.ROM[i].Reset()
 	}
+	for i := range g.Disk {
+		g.Disk[i].Reset()
+	}
+	for i := range g.Sample {
+		g.Sample[i].Reset()
+	}
 }
 
 // ROM represents one ROM within an XML dat file
 type ROM struct {
-	XMLName xml.Name `xml:"rom"`
-	Name    string   `xml:"name,attr"`
-	Size    uint64   `xml:"size,attr"`
-	CRC32   string   `xml:"crc,attr"`
-	MD5     string   `xml:"md5,attr"`
-	SHA1    string   `xml:"sha1,attr"`
+	XMLName xml.Name `xml:"rom" json:"-"`
+	Name    string   `xml:"name,attr" json:"name"`
+	Size    uint64   `xml:"size,attr" json:"size"`
+	CRC32   string   `xml:"crc,attr" json:"crc,omitempty"`
+	MD5     string   `xml:"md5,attr" json:"md5,omitempty"`
+	SHA1    string   `xml:"sha1,attr" json:"sha1,omitempty"`
+	Merge   string   `xml:"merge,attr" json:"merge,omitempty"`
+	Status  string   `xml:"status,attr" json:"status,omitempty"`
+	Serial  string   `xml:"serial,attr" json:"serial,omitempty"`
+	Date    string   `xml:"date,attr" json:"date,omitempty"`
+	MIA     string   `xml:"mia,attr" json:"mia,omitempty"`
 	matched bool
 }
 
+// Possible values for the ROM status attribute
+const (
+	StatusBadDump  = "baddump"
+	StatusNoDump   = "nodump"
+	StatusGood     = "good"
+	StatusVerified = "verified"
+)
+
 // Checksum returns the correct checksum value based on the requested
 // checksum type
 func (r *ROM) Checksum(t rom.Checksum) string {
@@ -189,7 +315,7 @@ func (r *ROM) Checksum(t rom.Checksum) string {
 // MarshalXML is required by the xml.Marshaler interface. It encodes the ROM
 // as XML if the ROM has not been matched
 func (r *ROM) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
-	if r.isComplete() {
+	if r.Complete() {
 		return nil
 	}
 
@@ -202,18 +328,22 @@ func (r *ROM) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
 			Name:  xml.Name{Local: "size"},
 			Value: strconv.FormatUint(r.Size, 10),
 		},
-		{
-			Name:  xml.Name{Local: "crc"},
-			Value: r.CRC32,
-		},
-		{
-			Name:  xml.Name{Local: "md5"},
-			Value: r.MD5,
-		},
-		{
-			Name:  xml.Name{Local: "sha1"},
-			Value: r.SHA1,
-		},
+	}
+	for _, attr := range []struct {
+		name, value string
+	}{
+		{"crc", r.CRC32},
+		{"md5", r.MD5},
+		{"sha1", r.SHA1},
+		{"merge", r.Merge},
+		{"status", r.Status},
+		{"serial", r.Serial},
+		{"date", r.Date},
+		{"mia", r.MIA},
+	} {
+		if attr.value != "" {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr.name}, Value: attr.value})
+		}
 	}
 	tokens := []xml.Token{start}
 
@@ -236,8 +366,27 @@ func (r *ROM) Matched() {
 	r.matched = true
 }
 
-func (r *ROM) isComplete() bool {
-	return r.matched
+// BadDump returns true if ROM r is known to be a bad dump
+func (r *ROM) BadDump() bool {
+	return r.Status == StatusBadDump
+}
+
+// Missing returns true if ROM r is flagged as missing in action, i.e. it is
+// known to exist but no copy is currently available
+func (r *ROM) Missing() bool {
+	return strings.EqualFold(r.MIA, "yes")
+}
+
+// NoDump returns true if ROM r is known to exist but has never been dumped
+// and so has no checksums. Such a ROM is always considered matched
+func (r *ROM) NoDump() bool {
+	return r.Status == StatusNoDump
+}
+
+// Complete returns true if ROM r has been matched or is known to have never
+// been dumped
+func (r *ROM) Complete() bool {
+	return r.matched || r.NoDump()
 }
 
 // Reset returns ROM r to its original state such that it will be marshalled
@@ -245,3 +394,140 @@ func (r *ROM) isComplete() bool {
 func (r *ROM) Reset() {
 	r.matched = false
 }
+
+// Disk represents one disk, typically a MAME CHD file, within an XML dat
+// file. Unlike a ROM it has no size or CRC and the checksums are those
+// recorded within the CHD header rather than of the file itself
+type Disk struct {
+	XMLName xml.Name `xml:"disk" json:"-"`
+	Name    string   `xml:"name,attr" json:"name"`
+	SHA1    string   `xml:"sha1,attr" json:"sha1,omitempty"`
+	MD5     string   `xml:"md5,attr" json:"md5,omitempty"`
+	Merge   string   `xml:"merge,attr" json:"merge,omitempty"`
+	Status  string   `xml:"status,attr" json:"status,omitempty"`
+	matched bool
+}
+
+// Checksum returns the correct checksum value based on the requested
+// checksum type. Only MD5 and SHA1 are supported
+func (d *Disk) Checksum(t rom.Checksum) string {
+	var v string
+	switch t {
+	case rom.MD5:
+		v = strings.ToLower(d.MD5)
+	case rom.SHA1:
+		v = strings.ToLower(d.SHA1)
+	}
+	return v
+}
+
+// MarshalXML is required by the xml.Marshaler interface. It encodes the Disk
+// as XML if the Disk has not been matched
+func (d *Disk) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if d.Complete() {
+		return nil
+	}
+
+	start.Attr = []xml.Attr{
+		{
+			Name:  xml.Name{Local: "name"},
+			Value: d.Name,
+		},
+	}
+	for _, attr := range []struct {
+		name, value string
+	}{
+		{"sha1", d.SHA1},
+		{"md5", d.MD5},
+		{"merge", d.Merge},
+		{"status", d.Status},
+	} {
+		if attr.value != "" {
+			start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attr.name}, Value: attr.value})
+		}
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if err := e.EncodeToken(start.End()); err != nil {
+		return err
+	}
+
+	return e.Flush()
+}
+
+// Matched marks Disk d as found in some external repository. By doing this
+// it will not be marshalled back into XML
+func (d *Disk) Matched() {
+	d.matched = true
+}
+
+// NoDump returns true if Disk d is known to exist but has never been dumped
+func (d *Disk) NoDump() bool {
+	return d.Status == StatusNoDump
+}
+
+// Complete returns true if Disk d has been matched or is known to have
+// never been dumped
+func (d *Disk) Complete() bool {
+	return d.matched || d.NoDump()
+}
+
+// Reset returns Disk d to its original state such that it will be
+// marshalled back into XML
+func (d *Disk) Reset() {
+	d.matched = false
+}
+
+// Sample represents one audio sample used by a Game. Samples are stored as
+// WAV files in a zip archive named after either the Game or the Game it
+// shares samples with and have no checksums
+type Sample struct {
+	XMLName xml.Name `xml:"sample" json:"-"`
+	Name    string   `xml:"name,attr" json:"name"`
+	matched bool
+}
+
+// MarshalXML is required by the xml.Marshaler interface. It encodes the
+// Sample as XML if the Sample has not been matched
+func (s *Sample) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	if s.Complete() {
+		return nil
+	}
+
+	start.Attr = []xml.Attr{
+		{
+			Name:  xml.Name{Local: "name"},
+			Value: s.Name,
+		},
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if err := e.EncodeToken(start.End()); err != nil {
+		return err
+	}
+
+	return e.Flush()
+}
+
+// Matched marks Sample s as found in some external repository. By doing
+// this it will not be marshalled back into XML
+func (s *Sample) Matched() {
+	s.matched = true
+}
+
+// Complete returns true if Sample s has been matched
+func (s *Sample) Complete() bool {
+	return s.matched
+}
+
+// Reset returns Sample s to its original state such that it will be
+// marshalled back into XML
+func (s *Sample) Reset() {
+	s.matched = false
+}
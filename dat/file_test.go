@@ -5,6 +5,9 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func ExampleUnmarshal() {
@@ -85,3 +88,113 @@ func ExampleMarshal() {
 	//	</game>
 	//</datafile>
 }
+
+func TestFixdat(t *testing.T) {
+	f := File{
+		Header: Header{
+			Name:        "test",
+			Description: "description",
+		},
+		Game: []Game{
+			{
+				Name: "complete",
+				ROM: []ROM{
+					{Name: "complete.bin"},
+				},
+			},
+			{
+				Name: "partial",
+				ROM: []ROM{
+					{Name: "found.bin"},
+					{Name: "missing.bin"},
+				},
+			},
+			{
+				Name: "missing",
+				ROM: []ROM{
+					{Name: "missing.bin"},
+				},
+			},
+		},
+	}
+
+	f.Game[0].Matched()
+	f.Game[1].ROM[0].Matched()
+
+	fixdat := f.Fixdat()
+	assert.Equal(t, "fix_test", fixdat.Header.Name)
+	assert.Equal(t, "fix_description", fixdat.Header.Description)
+	assert.Len(t, fixdat.Game, 2)
+	assert.Equal(t, "partial", fixdat.Game[0].Name)
+	assert.Equal(t, []ROM{{Name: "missing.bin"}}, fixdat.Game[0].ROM)
+	assert.Equal(t, "missing", fixdat.Game[1].Name)
+
+	// The original should be untouched
+	assert.Len(t, f.Game, 3)
+	assert.Len(t, f.Game[1].ROM, 2)
+}
+
+func TestStatus(t *testing.T) {
+	b := []byte(`<datafile>
+	<game name="test">
+		<rom name="good.bin" size="4" crc="b63cfbcd"/>
+		<rom name="bad.bin" size="4" crc="cecee288" status="baddump"/>
+		<rom name="missing.bin" size="4" status="nodump"/>
+	</game>
+</datafile>`)
+
+	f := new(File)
+	if err := xml.Unmarshal(b, f); err != nil {
+		t.Fatal(err)
+	}
+
+	g := &f.Game[0]
+	assert.Equal(t, false, g.ROM[0].BadDump())
+	assert.Equal(t, true, g.ROM[1].BadDump())
+	assert.Equal(t, true, g.ROM[2].NoDump())
+
+	g.ROM[0].Matched()
+	g.ROM[1].Matched()
+	assert.Equal(t, true, g.Complete())
+
+	g.ROM[1].Reset()
+	b, err := xml.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `<game name="test"><category></category><description></description><rom name="bad.bin" size="4" crc="cecee288" status="baddump"></rom></game>`, string(b))
+}
+
+func TestROMAttributes(t *testing.T) {
+	b := []byte(`<game name="test"><rom name="test.bin" size="4" crc="b63cfbcd" md5="08d6c05a21512a79a1dfeb9d2a8f262f" sha1="12dada1fff4d4787ade3333147202c3b443e376f" status="verified" serial="ABC-1234" date="2020-01-01" mia="yes"></rom></game>`)
+
+	g := new(Game)
+	if err := xml.Unmarshal(b, g); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "ABC-1234", g.ROM[0].Serial)
+	assert.Equal(t, "2020-01-01", g.ROM[0].Date)
+	assert.Equal(t, true, g.ROM[0].Missing())
+
+	out, err := xml.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, `<game name="test"><category></category><description></description><rom name="test.bin" size="4" crc="b63cfbcd" md5="08d6c05a21512a79a1dfeb9d2a8f262f" sha1="12dada1fff4d4787ade3333147202c3b443e376f" status="verified" serial="ABC-1234" date="2020-01-01" mia="yes"></rom></game>`, string(out))
+}
+
+func TestROMMissingChecksums(t *testing.T) {
+	b := []byte(`<game name="test"><category></category><description></description><rom name="a.bin" size="20" crc="deadbeef"></rom><rom name="b.bin" size="4" sha1="12dada1fff4d4787ade3333147202c3b443e376f"></rom></game>`)
+
+	g := new(Game)
+	if err := xml.Unmarshal(b, g); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := xml.Marshal(g)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, string(b), string(out))
+}
@@ -0,0 +1,89 @@
+package dat
+
+import (
+	"strings"
+)
+
+// Filter returns a new File using the same Header as File f containing only
+// those Games for which fn returns true
+func (f *File) Filter(fn func(Game) bool) *File {
+	filtered := &File{
+		Header: f.Header,
+	}
+
+	for _, g := range f.Game {
+		if fn(g) {
+			filtered.Game = append(filtered.Game, g)
+		}
+	}
+
+	return filtered
+}
+
+// Not returns a filter that inverts the result of fn
+func Not(fn func(Game) bool) func(Game) bool {
+	return func(g Game) bool {
+		return !fn(g)
+	}
+}
+
+// All returns a filter that is true only if every filter is true
+func All(fns ...func(Game) bool) func(Game) bool {
+	return func(g Game) bool {
+		for _, fn := range fns {
+			if !fn(g) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// Any returns a filter that is true if at least one filter is true
+func Any(fns ...func(Game) bool) func(Game) bool {
+	return func(g Game) bool {
+		for _, fn := range fns {
+			if fn(g) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Region returns a filter that is true for any Game with a name containing
+// at least one of the passed regions, such as "USA" or "Europe"
+func Region(regions ...string) func(Game) bool {
+	return func(g Game) bool {
+		return ParseName(g.Name).HasRegion(regions...)
+	}
+}
+
+// Language returns a filter that is true for any Game with a name
+// containing at least one of the passed languages, such as "En" or "Fr"
+func Language(languages ...string) func(Game) bool {
+	return func(g Game) bool {
+		return ParseName(g.Name).HasLanguage(languages...)
+	}
+}
+
+// Flag returns a filter that is true for any Game with a name containing at
+// least one of the passed flags, such as "Proto" or "Demo"
+func Flag(flags ...string) func(Game) bool {
+	return func(g Game) bool {
+		return ParseName(g.Name).HasFlag(flags...)
+	}
+}
+
+// Category returns a filter that is true for any Game with one of the
+// passed categories
+func Category(categories ...string) func(Game) bool {
+	return func(g Game) bool {
+		for _, c := range categories {
+			if strings.EqualFold(g.Category, c) {
+				return true
+			}
+		}
+		return false
+	}
+}
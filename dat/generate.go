@@ -0,0 +1,83 @@
+package dat
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bodgit/rom"
+)
+
+// NewFile returns a new File using the passed Header and populated with one
+// Game for each Reader. The Game is named after the underlying file or
+// directory minus any extension and contains one ROM for each file
+// accessible by the Reader. Sizes and checksums exclude any header that
+// might be present
+func NewFile(header Header, readers ...rom.Reader) (*File, error) {
+	f := &File{
+		Header: header,
+		Game:   make([]Game, 0, len(readers)),
+	}
+
+	for _, reader := range readers {
+		game, err := newGame(reader)
+		if err != nil {
+			return nil, err
+		}
+		f.Game = append(f.Game, *game)
+	}
+
+	return f, nil
+}
+
+func gameName(reader rom.Reader) string {
+	name := filepath.Base(reader.Name())
+	if _, ok := reader.(*rom.DirectoryReader); ok {
+		return name
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name))
+}
+
+func newGame(reader rom.Reader) (*Game, error) {
+	name := gameName(reader)
+
+	g := &Game{
+		Name:        name,
+		Description: name,
+	}
+
+	files := reader.Files()
+	sort.Strings(files)
+
+	for _, file := range files {
+		size, header, err := reader.Size(file)
+		if err != nil {
+			return nil, err
+		}
+
+		r := ROM{
+			Name: file,
+			Size: size - header,
+		}
+
+		for _, c := range []struct {
+			checksum rom.Checksum
+			value    *string
+		}{
+			{rom.CRC32, &r.CRC32},
+			{rom.MD5, &r.MD5},
+			{rom.SHA1, &r.SHA1},
+		} {
+			b, err := reader.Checksum(file, c.checksum)
+			if err != nil {
+				return nil, err
+			}
+			*c.value = fmt.Sprintf("%x", b)
+		}
+
+		g.ROM = append(g.ROM, r)
+	}
+
+	return g, nil
+}
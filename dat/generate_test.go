@@ -0,0 +1,57 @@
+package dat
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/bodgit/rom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFile(t *testing.T) {
+	tables := map[string]struct {
+		path string
+		name string
+	}{
+		"zip": {
+			filepath.Join("..", "testdata", "test.zip"),
+			"test",
+		},
+		"directory": {
+			filepath.Join("..", "testdata", "test"),
+			"test",
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			r, err := rom.NewReader(table.path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer r.Close()
+
+			f, err := NewFile(Header{Name: "test"}, r)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, "test", f.Header.Name)
+			assert.Len(t, f.Game, 1)
+			assert.Equal(t, table.name, f.Game[0].Name)
+			assert.Equal(t, []ROM{
+				{
+					Name:  "test.bin",
+					Size:  20,
+					CRC32: "d580a153",
+					MD5:   "f202a9e83272626f0353a305e1147dc9",
+					SHA1:  "4ebc20b46ea4d010ed9ac1fde4c251cf231a661f",
+				},
+				{
+					Name:  "test.nes",
+					Size:  4,
+					CRC32: "4473ef85",
+					MD5:   "6c9997754fec0660056fb1eddbe7a400",
+					SHA1:  "c45ef3c8dcb569a58feba8d5aee1f47e93ac5cdd",
+				},
+			}, f.Game[0].ROM)
+		})
+	}
+}
@@ -0,0 +1,43 @@
+package dat
+
+import (
+	"strings"
+
+	"github.com/bodgit/rom"
+)
+
+type indexKey struct {
+	checksum rom.Checksum
+	value    string
+}
+
+// Match is a ROM found by FindByChecksum along with the Game that uses it
+type Match struct {
+	Game *Game
+	ROM  *ROM
+}
+
+func (f *File) buildIndex() {
+	f.index = make(map[indexKey][]Match)
+	for i := range f.Game {
+		g := &f.Game[i]
+		for j := range g.ROM {
+			r := &g.ROM[j]
+			for _, t := range []rom.Checksum{rom.CRC32, rom.MD5, rom.SHA1} {
+				if v := r.Checksum(t); v != "" {
+					k := indexKey{t, v}
+					f.index[k] = append(f.index[k], Match{g, r})
+				}
+			}
+		}
+	}
+}
+
+// FindByChecksum returns every ROM within File f that has the checksum value
+// of the requested checksum type. An index of all checksums is built on the
+// first call so any later changes to the Games within File f are not
+// reflected in the results
+func (f *File) FindByChecksum(t rom.Checksum, value string) []Match {
+	f.indexOnce.Do(f.buildIndex)
+	return f.index[indexKey{t, strings.ToLower(value)}]
+}
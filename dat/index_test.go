@@ -0,0 +1,43 @@
+package dat
+
+import (
+	"testing"
+
+	"github.com/bodgit/rom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindByChecksum(t *testing.T) {
+	f := &File{
+		Game: []Game{
+			{
+				Name: "first",
+				ROM: []ROM{
+					{Name: "a.bin", CRC32: "8C4588E2", SHA1: "66873b0e0cc699aa75daf06a3f74c0508037e605"},
+					{Name: "b.bin", CRC32: "4c2126b0"},
+				},
+			},
+			{
+				Name: "second",
+				ROM: []ROM{
+					{Name: "a.bin", CRC32: "8c4588e2"},
+				},
+			},
+		},
+	}
+
+	matches := f.FindByChecksum(rom.CRC32, "8c4588e2")
+	if assert.Len(t, matches, 2) {
+		assert.Equal(t, "first", matches[0].Game.Name)
+		assert.Same(t, &f.Game[0].ROM[0], matches[0].ROM)
+		assert.Equal(t, "second", matches[1].Game.Name)
+	}
+
+	matches = f.FindByChecksum(rom.SHA1, "66873B0E0CC699AA75DAF06A3F74C0508037E605")
+	if assert.Len(t, matches, 1) {
+		assert.Equal(t, "a.bin", matches[0].ROM.Name)
+	}
+
+	assert.Empty(t, f.FindByChecksum(rom.MD5, "30686dba4795521174658de2492e0046"))
+	assert.Empty(t, f.FindByChecksum(rom.SHA1, "4c2126b0"))
+}
@@ -0,0 +1,154 @@
+package dat
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Duplicate determines how Merge handles a Game name found in more than one
+// File
+type Duplicate int
+
+// Supported ways of handling duplicate Games
+const (
+	// DuplicateCombine merges the ROMs of each Game into one Game, with any
+	// ROMs that share a name resolved according to the Conflict policy
+	DuplicateCombine Duplicate = iota
+	// DuplicateFirst keeps the first Game seen
+	DuplicateFirst
+	// DuplicateLast keeps the last Game seen
+	DuplicateLast
+	// DuplicateRename keeps every Game, with those seen after the first
+	// having the name of the File they came from appended
+	DuplicateRename
+	// DuplicateError causes Merge to return an error
+	DuplicateError
+)
+
+// Conflict determines how Merge handles ROMs with the same name in the same
+// Game but with different sizes or checksums
+type Conflict int
+
+// Supported ways of handling conflicting ROMs
+const (
+	// ConflictError causes Merge to return an error
+	ConflictError Conflict = iota
+	// ConflictFirst keeps the first ROM seen
+	ConflictFirst
+	// ConflictLast keeps the last ROM seen
+	ConflictLast
+)
+
+var (
+	// ErrDuplicate is returned by Merge if a Game name is found in more
+	// than one File and DuplicateError is used
+	ErrDuplicate = errors.New("duplicate game")
+	// ErrConflict is returned by Merge if two ROMs with the same name
+	// differ and ConflictError is used
+	ErrConflict = errors.New("conflicting rom")
+)
+
+// Merge combines the Games from each File into one new File using the passed
+// Header. Games found in more than one File that contain exactly the same
+// ROMs are only included once, otherwise they are handled according to the
+// Duplicate and Conflict policies. The order of Games is preserved
+func Merge(header Header, duplicate Duplicate, conflict Conflict, files ...*File) (*File, error) {
+	merged := &File{
+		Header: header,
+	}
+
+	games := make(map[string]int)
+
+	for _, f := range files {
+		for _, g := range f.Game {
+			g.ROM = append([]ROM(nil), g.ROM...)
+
+			i, ok := games[g.Name]
+			if !ok {
+				games[g.Name] = len(merged.Game)
+				merged.Game = append(merged.Game, g)
+				continue
+			}
+
+			existing := &merged.Game[i]
+			if sameGame(existing, &g) {
+				continue
+			}
+
+			switch duplicate {
+			case DuplicateCombine:
+				if err := combineGame(existing, &g, conflict); err != nil {
+					return nil, err
+				}
+			case DuplicateFirst:
+			case DuplicateLast:
+				merged.Game[i] = g
+			case DuplicateRename:
+				name := fmt.Sprintf("%s [%s]", g.Name, f.Header.Name)
+				for n := 2; ; n++ {
+					if _, ok := games[name]; !ok {
+						break
+					}
+					name = fmt.Sprintf("%s [%s] (%d)", g.Name, f.Header.Name, n)
+				}
+				g.Name = name
+				games[g.Name] = len(merged.Game)
+				merged.Game = append(merged.Game, g)
+			default:
+				return nil, fmt.Errorf("%w: %s", ErrDuplicate, g.Name)
+			}
+		}
+	}
+
+	return merged, nil
+}
+
+func sameGame(a, b *Game) bool {
+	if len(a.ROM) != len(b.ROM) {
+		return false
+	}
+
+	roms := make(map[string]*ROM, len(a.ROM))
+	for i := range a.ROM {
+		roms[a.ROM[i].Name] = &a.ROM[i]
+	}
+
+	for i := range b.ROM {
+		r, ok := roms[b.ROM[i].Name]
+		if !ok || !sameROM(r, &b.ROM[i]) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func combineGame(dst, src *Game, conflict Conflict) error {
+	roms := make(map[string]int, len(dst.ROM))
+	for i, r := range dst.ROM {
+		roms[r.Name] = i
+	}
+
+	for _, r := range src.ROM {
+		i, ok := roms[r.Name]
+		if !ok {
+			roms[r.Name] = len(dst.ROM)
+			dst.ROM = append(dst.ROM, r)
+			continue
+		}
+
+		if sameROM(&dst.ROM[i], &r) {
+			continue
+		}
+
+		switch conflict {
+		case ConflictFirst:
+		case ConflictLast:
+			dst.ROM[i] = r
+		default:
+			return fmt.Errorf("%w: %s in %s", ErrConflict, r.Name, src.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package dat
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMerge(t *testing.T) {
+	a := &File{
+		Header: Header{Name: "a"},
+		Game: []Game{
+			{
+				Name: "same",
+				ROM:  []ROM{{Name: "same.bin", Size: 4, CRC32: "b63cfbcd"}},
+			},
+			{
+				Name: "duplicate",
+				ROM: []ROM{
+					{Name: "first.bin", Size: 4, CRC32: "b63cfbcd"},
+					{Name: "conflict.bin", Size: 4, CRC32: "b63cfbcd"},
+				},
+			},
+		},
+	}
+
+	b := &File{
+		Header: Header{Name: "b"},
+		Game: []Game{
+			{
+				Name: "same",
+				ROM:  []ROM{{Name: "same.bin", Size: 4, CRC32: "B63CFBCD"}},
+			},
+			{
+				Name: "duplicate",
+				ROM: []ROM{
+					{Name: "last.bin", Size: 4, CRC32: "b63cfbcd"},
+					{Name: "conflict.bin", Size: 4, CRC32: "cecee288"},
+				},
+			},
+			{
+				Name: "unique",
+			},
+		},
+	}
+
+	tables := map[string]struct {
+		duplicate Duplicate
+		conflict  Conflict
+		games     []string
+		roms      []ROM
+		err       error
+	}{
+		"combine first": {
+			DuplicateCombine,
+			ConflictFirst,
+			[]string{"same", "duplicate", "unique"},
+			[]ROM{
+				{Name: "first.bin", Size: 4, CRC32: "b63cfbcd"},
+				{Name: "conflict.bin", Size: 4, CRC32: "b63cfbcd"},
+				{Name: "last.bin", Size: 4, CRC32: "b63cfbcd"},
+			},
+			nil,
+		},
+		"combine last": {
+			DuplicateCombine,
+			ConflictLast,
+			[]string{"same", "duplicate", "unique"},
+			[]ROM{
+				{Name: "first.bin", Size: 4, CRC32: "b63cfbcd"},
+				{Name: "conflict.bin", Size: 4, CRC32: "cecee288"},
+				{Name: "last.bin", Size: 4, CRC32: "b63cfbcd"},
+			},
+			nil,
+		},
+		"combine error": {
+			DuplicateCombine,
+			ConflictError,
+			nil,
+			nil,
+			ErrConflict,
+		},
+		"first": {
+			DuplicateFirst,
+			ConflictError,
+			[]string{"same", "duplicate", "unique"},
+			a.Game[1].ROM,
+			nil,
+		},
+		"last": {
+			DuplicateLast,
+			ConflictError,
+			[]string{"same", "duplicate", "unique"},
+			b.Game[1].ROM,
+			nil,
+		},
+		"rename": {
+			DuplicateRename,
+			ConflictError,
+			[]string{"same", "duplicate", "duplicate [b]", "unique"},
+			a.Game[1].ROM,
+			nil,
+		},
+		"error": {
+			DuplicateError,
+			ConflictError,
+			nil,
+			nil,
+			ErrDuplicate,
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			f, err := Merge(Header{Name: "merged"}, table.duplicate, table.conflict, a, b)
+			if table.err != nil {
+				assert.Equal(t, true, errors.Is(err, table.err))
+				return
+			}
+			assert.Equal(t, nil, err)
+			assert.Equal(t, "merged", f.Header.Name)
+
+			var games []string
+			for _, g := range f.Game {
+				games = append(games, g.Name)
+			}
+			assert.Equal(t, table.games, games)
+			assert.Equal(t, table.roms, f.Game[1].ROM)
+		})
+	}
+
+	// The originals should be untouched
+	assert.Len(t, a.Game[1].ROM, 2)
+	assert.Equal(t, "b63cfbcd", a.Game[1].ROM[1].CRC32)
+}
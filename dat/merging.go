@@ -0,0 +1,174 @@
+package dat
+
+import (
+	"strings"
+)
+
+// Merging describes how the ROMs of a parent Game and its clones are
+// arranged into sets
+type Merging int
+
+// Supported merging modes
+const (
+	// NonMerged means every Game contains all of the ROMs it needs,
+	// including those shared with its parent
+	NonMerged Merging = iota
+	// Split means each clone only contains the ROMs that are not found in
+	// its parent
+	Split
+	// FullMerged means each clone is folded into its parent so the parent
+	// contains every ROM for the whole family
+	FullMerged
+)
+
+// Merging returns the merging mode requested by either the clrmamepro or
+// romcenter elements of Header h and whether one was found
+func (h *Header) Merging() (Merging, bool) {
+	if h.ClrMamePro != nil {
+		switch strings.ToLower(h.ClrMamePro.ForceMerging) {
+		case "none":
+			return NonMerged, true
+		case "split":
+			return Split, true
+		case "full":
+			return FullMerged, true
+		}
+	}
+
+	if h.RomCenter != nil {
+		switch strings.ToLower(h.RomCenter.ROMMode) {
+		case "unmerged":
+			return NonMerged, true
+		case "split":
+			return Split, true
+		case "merged":
+			return FullMerged, true
+		}
+	}
+
+	return NonMerged, false
+}
+
+// Unpacked returns true if the clrmamepro element of Header h requests that
+// sets are stored as directories rather than archives
+func (h *Header) Unpacked() bool {
+	return h.ClrMamePro != nil && strings.EqualFold(h.ClrMamePro.ForcePacking, "unzip")
+}
+
+// ApplyMerging rearranges the Games within File f in place to match the
+// requested merging mode. It is assumed the Games are currently non-merged.
+// A ROM or Disk in a clone is considered to come from the parent if it has a
+// merge attribute or the parent has a ROM with the same name, size and
+// checksums. When fully merging, a clone ROM that has the same name as a
+// different ROM is stored as "clone/name"
+func (f *File) ApplyMerging(m Merging) {
+	if m == NonMerged {
+		return
+	}
+
+	parents := make(map[string]int, len(f.Game))
+	for i, g := range f.Game {
+		if g.CloneOf == "" {
+			parents[g.Name] = i
+		}
+	}
+
+	merged := make(map[int]struct{})
+
+	for j := range f.Game {
+		g := &f.Game[j]
+
+		i, ok := parents[g.CloneOf]
+		if g.CloneOf == "" || !ok {
+			continue
+		}
+
+		parent := &f.Game[i]
+
+		switch m {
+		case Split:
+			roms := make([]ROM, 0, len(g.ROM))
+			for _, r := range g.ROM {
+				if !fromParent(parent, r) {
+					roms = append(roms, r)
+				}
+			}
+			g.ROM = roms
+
+			disks := make([]Disk, 0, len(g.Disk))
+			for _, d := range g.Disk {
+				if d.Merge == "" {
+					disks = append(disks, d)
+				}
+			}
+			g.Disk = disks
+		case FullMerged:
+			for _, r := range g.ROM {
+				if fromParent(parent, r) {
+					continue
+				}
+				// A different ROM with the same name as one already in
+				// the set is kept under the name of the clone, the same
+				// as clrmamepro
+				if hasROM(parent, r.Name) {
+					r.Name = g.Name + "/" + r.Name
+					if fromParent(parent, r) {
+						continue
+					}
+				}
+				r.Merge = ""
+				parent.ROM = append(parent.ROM, r)
+			}
+
+			disks := make(map[string]struct{}, len(parent.Disk))
+			for _, d := range parent.Disk {
+				disks[d.Name] = struct{}{}
+			}
+			for _, d := range g.Disk {
+				if _, ok := disks[d.Name]; ok || d.Merge != "" {
+					continue
+				}
+				disks[d.Name] = struct{}{}
+				parent.Disk = append(parent.Disk, d)
+			}
+
+			merged[j] = struct{}{}
+		}
+	}
+
+	if len(merged) == 0 {
+		return
+	}
+
+	games := make([]Game, 0, len(f.Game)-len(merged))
+	for j, g := range f.Game {
+		if _, ok := merged[j]; !ok {
+			games = append(games, g)
+		}
+	}
+
+	f.Game = games
+}
+
+func hasROM(g *Game, name string) bool {
+	for _, r := range g.ROM {
+		if r.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func fromParent(parent *Game, r ROM) bool {
+	if r.Merge != "" {
+		return true
+	}
+
+	for i := range parent.ROM {
+		if parent.ROM[i].Name == r.Name && sameROM(&parent.ROM[i], &r) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,102 @@
+package dat
+
+import (
+	"encoding/xml"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mergingFile() *File {
+	return &File{
+		Game: []Game{
+			{
+				Name:    "clone",
+				CloneOf: "parent",
+				ROM: []ROM{
+					{Name: "shared.bin", Size: 4, CRC32: "b63cfbcd", Merge: "shared.bin"},
+					{Name: "same.bin", Size: 4, CRC32: "cecee288"},
+					{Name: "clone.bin", Size: 4, CRC32: "12345678"},
+					{Name: "parent.bin", Size: 4, CRC32: "abcdef01"},
+				},
+			},
+			{
+				Name: "parent",
+				ROM: []ROM{
+					{Name: "shared.bin", Size: 4, CRC32: "b63cfbcd"},
+					{Name: "same.bin", Size: 4, CRC32: "cecee288"},
+					{Name: "parent.bin", Size: 4, CRC32: "87654321"},
+				},
+			},
+		},
+	}
+}
+
+func TestApplyMerging(t *testing.T) {
+	f := mergingFile()
+	f.ApplyMerging(NonMerged)
+	assert.Equal(t, mergingFile(), f)
+
+	f = mergingFile()
+	f.ApplyMerging(Split)
+	assert.Len(t, f.Game, 2)
+	assert.Equal(t, []ROM{
+		{Name: "clone.bin", Size: 4, CRC32: "12345678"},
+		{Name: "parent.bin", Size: 4, CRC32: "abcdef01"},
+	}, f.Game[0].ROM)
+	assert.Len(t, f.Game[1].ROM, 3)
+
+	f = mergingFile()
+	f.ApplyMerging(FullMerged)
+	assert.Len(t, f.Game, 1)
+	assert.Equal(t, "parent", f.Game[0].Name)
+	assert.Equal(t, []ROM{
+		{Name: "shared.bin", Size: 4, CRC32: "b63cfbcd"},
+		{Name: "same.bin", Size: 4, CRC32: "cecee288"},
+		{Name: "parent.bin", Size: 4, CRC32: "87654321"},
+		{Name: "clone.bin", Size: 4, CRC32: "12345678"},
+		{Name: "clone/parent.bin", Size: 4, CRC32: "abcdef01"},
+	}, f.Game[0].ROM)
+}
+
+func TestHeaderMerging(t *testing.T) {
+	tables := map[string]struct {
+		xml      string
+		merging  Merging
+		ok       bool
+		unpacked bool
+	}{
+		"none": {
+			`<header><name>test</name></header>`,
+			NonMerged,
+			false,
+			false,
+		},
+		"clrmamepro": {
+			`<header><name>test</name><clrmamepro forcemerging="split" forcepacking="unzip"/></header>`,
+			Split,
+			true,
+			true,
+		},
+		"romcenter": {
+			`<header><name>test</name><romcenter rommode="merged"/></header>`,
+			FullMerged,
+			true,
+			false,
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			h := new(Header)
+			if err := xml.Unmarshal([]byte(table.xml), h); err != nil {
+				t.Fatal(err)
+			}
+
+			m, ok := h.Merging()
+			assert.Equal(t, table.merging, m)
+			assert.Equal(t, table.ok, ok)
+			assert.Equal(t, table.unpacked, h.Unpacked())
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package dat
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Name holds the components of a game name following the No-Intro naming
+// convention, for example "Title (USA, Europe) (En,Fr,De) (Rev 1) (Proto)"
+type Name struct {
+	// Title is the name with all of the parenthesised and bracketed flags
+	// removed
+	Title string
+	// Regions lists each region, such as "USA" or "Europe"
+	Regions []string
+	// Languages lists each language, such as "En" or "Fr". If the name
+	// has no explicit languages but exactly one region with an obvious
+	// language, that is used instead
+	Languages []string
+	// Flags lists any other parenthesised flags, such as "Rev 1", "Proto"
+	// or "Demo"
+	Flags []string
+}
+
+var knownRegions = map[string]string{
+	"Argentina":     "Es",
+	"Asia":          "",
+	"Australia":     "En",
+	"Austria":       "De",
+	"Belgium":       "",
+	"Brazil":        "Pt",
+	"Canada":        "",
+	"China":         "Zh",
+	"Croatia":       "Hr",
+	"Czech":         "Cs",
+	"Denmark":       "Da",
+	"Europe":        "",
+	"Finland":       "Fi",
+	"France":        "Fr",
+	"Germany":       "De",
+	"Greece":        "El",
+	"Hong Kong":     "Zh",
+	"India":         "",
+	"Ireland":       "En",
+	"Israel":        "He",
+	"Italy":         "It",
+	"Japan":         "Ja",
+	"Korea":         "Ko",
+	"Latin America": "Es",
+	"Mexico":        "Es",
+	"Netherlands":   "Nl",
+	"New Zealand":   "En",
+	"Norway":        "No",
+	"Poland":        "Pl",
+	"Portugal":      "Pt",
+	"Russia":        "Ru",
+	"Scandinavia":   "",
+	"Singapore":     "",
+	"South Africa":  "",
+	"Spain":         "Es",
+	"Sweden":        "Sv",
+	"Switzerland":   "",
+	"Taiwan":        "Zh",
+	"Turkey":        "Tr",
+	"UK":            "En",
+	"USA":           "En",
+	"Unknown":       "",
+	"World":         "",
+}
+
+var (
+	nameGroup     = regexp.MustCompile(`\s*[(\[]([^)\]]*)[)\]]`)
+	languageGroup = regexp.MustCompile(`^[A-Z][a-z](-[A-Za-z]+)?(,\s*[A-Z][a-z](-[A-Za-z]+)?)*$`)
+)
+
+// ParseName splits a game name into its component parts
+func ParseName(name string) Name {
+	n := Name{
+		Title: strings.TrimSpace(nameGroup.ReplaceAllString(name, "")),
+	}
+
+	for _, m := range nameGroup.FindAllStringSubmatch(name, -1) {
+		group := m[1]
+
+		// Bracketed flags such as [b] are only ever flags
+		if strings.HasPrefix(strings.TrimSpace(m[0]), "[") {
+			n.Flags = append(n.Flags, group)
+			continue
+		}
+
+		if n.Regions == nil && isRegions(group) {
+			n.Regions = splitGroup(group)
+			continue
+		}
+
+		if n.Languages == nil && languageGroup.MatchString(group) {
+			n.Languages = splitGroup(group)
+			continue
+		}
+
+		n.Flags = append(n.Flags, group)
+	}
+
+	if n.Languages == nil && len(n.Regions) == 1 {
+		if l := knownRegions[n.Regions[0]]; l != "" {
+			n.Languages = []string{l}
+		}
+	}
+
+	return n
+}
+
+func splitGroup(group string) []string {
+	parts := strings.Split(group, ",")
+	for i := range parts {
+		parts[i] = strings.TrimSpace(parts[i])
+	}
+	return parts
+}
+
+func isRegions(group string) bool {
+	for _, region := range splitGroup(group) {
+		if _, ok := knownRegions[region]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// HasRegion returns true if Name n contains any of the passed regions
+func (n Name) HasRegion(regions ...string) bool {
+	return containsAny(n.Regions, regions, strings.EqualFold)
+}
+
+// HasLanguage returns true if Name n contains any of the passed languages
+func (n Name) HasLanguage(languages ...string) bool {
+	return containsAny(n.Languages, languages, strings.EqualFold)
+}
+
+// HasFlag returns true if Name n contains any of the passed flags. A flag
+// also matches if it is followed by further words so "Beta" matches both
+// "Beta" and "Beta 2"
+func (n Name) HasFlag(flags ...string) bool {
+	return containsAny(n.Flags, flags, func(have, want string) bool {
+		return strings.EqualFold(have, want) || len(have) > len(want) && strings.EqualFold(have[:len(want)+1], want+" ")
+	})
+}
+
+func containsAny(have, want []string, equal func(string, string) bool) bool {
+	for _, h := range have {
+		for _, w := range want {
+			if equal(h, w) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package dat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseName(t *testing.T) {
+	tables := map[string]struct {
+		name string
+		want Name
+	}{
+		"simple": {
+			"Battle Ace (Japan)",
+			Name{
+				Title:     "Battle Ace",
+				Regions:   []string{"Japan"},
+				Languages: []string{"Ja"},
+			},
+		},
+		"multiple regions": {
+			"Tetris (USA, Europe)",
+			Name{
+				Title:   "Tetris",
+				Regions: []string{"USA", "Europe"},
+			},
+		},
+		"languages and flags": {
+			"Title (Europe) (En,Fr,De) (Rev 1) (Proto) [b]",
+			Name{
+				Title:     "Title",
+				Regions:   []string{"Europe"},
+				Languages: []string{"En", "Fr", "De"},
+				Flags:     []string{"Rev 1", "Proto", "b"},
+			},
+		},
+		"no flags": {
+			"Title",
+			Name{
+				Title: "Title",
+			},
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.want, ParseName(table.name))
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	f := &File{
+		Header: Header{Name: "test"},
+		Game: []Game{
+			{Name: "Game (USA)"},
+			{Name: "Game (Europe) (En,Fr)"},
+			{Name: "Game (Japan)"},
+			{Name: "Game (USA) (Proto)"},
+			{Name: "Game (USA) (Beta 2)"},
+			{Name: "Game (World) (Demo)", Category: "Demos"},
+		},
+	}
+
+	names := func(f *File) []string {
+		var s []string
+		for _, g := range f.Game {
+			s = append(s, g.Name)
+		}
+		return s
+	}
+
+	tables := map[string]struct {
+		fn   func(Game) bool
+		want []string
+	}{
+		"region": {
+			Region("USA", "World"),
+			[]string{"Game (USA)", "Game (USA) (Proto)", "Game (USA) (Beta 2)", "Game (World) (Demo)"},
+		},
+		"language": {
+			Language("fr"),
+			[]string{"Game (Europe) (En,Fr)"},
+		},
+		"not flag": {
+			Not(Flag("Proto", "Beta", "Demo")),
+			[]string{"Game (USA)", "Game (Europe) (En,Fr)", "Game (Japan)"},
+		},
+		"all": {
+			All(Region("USA"), Not(Flag("Proto", "Beta"))),
+			[]string{"Game (USA)"},
+		},
+		"any": {
+			Any(Region("Japan"), Category("demos")),
+			[]string{"Game (Japan)", "Game (World) (Demo)"},
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			filtered := f.Filter(table.fn)
+			assert.Equal(t, "test", filtered.Header.Name)
+			assert.Equal(t, table.want, names(filtered))
+		})
+	}
+}
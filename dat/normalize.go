@@ -0,0 +1,110 @@
+package dat
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// ErrChecksumShort is used when a checksum has fewer digits than
+	// expected, usually because any leading zeros have been dropped
+	ErrChecksumShort = errors.New("checksum too short")
+	// ErrChecksumLong is used when a checksum has more digits than expected
+	ErrChecksumLong = errors.New("checksum too long")
+	// ErrChecksumInvalid is used when a checksum is not hexadecimal
+	ErrChecksumInvalid = errors.New("checksum not hexadecimal")
+)
+
+// Warning describes a problem found with a checksum attribute of either a
+// ROM or a Disk
+type Warning struct {
+	Game      string
+	Name      string
+	Attribute string
+	Value     string
+	Err       error
+}
+
+func (w Warning) Error() string {
+	return fmt.Sprintf("%s: %s: %s %q: %v", w.Game, w.Name, w.Attribute, w.Value, w.Err)
+}
+
+func (w Warning) Unwrap() error {
+	return w.Err
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !strings.ContainsRune("0123456789abcdef", c) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalize lowercases the checksum value v and pads it with leading zeros
+// to the expected number of digits. A value that isn't hexadecimal or is too
+// long can never match anything so it is cleared
+func normalize(v *string, digits int) error {
+	if *v == "" {
+		return nil
+	}
+
+	s := strings.ToLower(strings.TrimSpace(*v))
+
+	switch {
+	case !isHex(s):
+		*v = ""
+		return ErrChecksumInvalid
+	case len(s) > digits:
+		*v = ""
+		return ErrChecksumLong
+	case len(s) < digits:
+		*v = strings.Repeat("0", digits-len(s)) + s
+		return ErrChecksumShort
+	}
+
+	*v = s
+
+	return nil
+}
+
+// Normalize lowercases and pads every checksum used by Game g so that it
+// can be compared directly. Any malformed checksum is cleared and returned
+// as a Warning
+func (g *Game) Normalize() []Warning {
+	var warnings []Warning
+
+	check := func(name, attribute string, v *string, digits int) {
+		value := *v
+		if err := normalize(v, digits); err != nil {
+			warnings = append(warnings, Warning{g.Name, name, attribute, value, err})
+		}
+	}
+
+	for i := range g.ROM {
+		r := &g.ROM[i]
+		check(r.Name, "crc", &r.CRC32, 8)
+		check(r.Name, "md5", &r.MD5, 32)
+		check(r.Name, "sha1", &r.SHA1, 40)
+	}
+
+	for i := range g.Disk {
+		d := &g.Disk[i]
+		check(d.Name, "md5", &d.MD5, 32)
+		check(d.Name, "sha1", &d.SHA1, 40)
+	}
+
+	return warnings
+}
+
+// Normalize normalizes the checksums for every Game in File f, returning
+// any Warnings
+func (f *File) Normalize() []Warning {
+	var warnings []Warning
+	for i := range f.Game {
+		warnings = append(warnings, f.Game[i].Normalize()...)
+	}
+	return warnings
+}
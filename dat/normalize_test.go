@@ -0,0 +1,67 @@
+package dat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalize(t *testing.T) {
+	f := &File{
+		Game: []Game{
+			{
+				Name: "test",
+				ROM: []ROM{
+					{
+						Name:  "upper.bin",
+						CRC32: "8C4588E2",
+						MD5:   "30686DBA4795521174658DE2492E0046",
+						SHA1:  "66873B0E0CC699AA75DAF06A3F74C0508037E605",
+					},
+					{
+						Name:  "short.bin",
+						CRC32: "c4588e2",
+						SHA1:  "0x66873b0e",
+					},
+					{
+						Name: "long.bin",
+						MD5:  "30686dba4795521174658de2492e00460",
+					},
+				},
+				Disk: []Disk{
+					{
+						Name: "disk",
+						SHA1: "66873b0e0cc699aa75daf06a3f74c0508037e605",
+					},
+				},
+			},
+		},
+	}
+
+	warnings := f.Normalize()
+
+	assert.Equal(t, []ROM{
+		{
+			Name:  "upper.bin",
+			CRC32: "8c4588e2",
+			MD5:   "30686dba4795521174658de2492e0046",
+			SHA1:  "66873b0e0cc699aa75daf06a3f74c0508037e605",
+		},
+		{
+			Name:  "short.bin",
+			CRC32: "0c4588e2",
+		},
+		{
+			Name: "long.bin",
+		},
+	}, f.Game[0].ROM)
+	assert.Equal(t, "66873b0e0cc699aa75daf06a3f74c0508037e605", f.Game[0].Disk[0].SHA1)
+
+	assert.Equal(t, []Warning{
+		{"test", "short.bin", "crc", "c4588e2", ErrChecksumShort},
+		{"test", "short.bin", "sha1", "0x66873b0e", ErrChecksumInvalid},
+		{"test", "long.bin", "md5", "30686dba4795521174658de2492e00460", ErrChecksumLong},
+	}, warnings)
+	assert.ErrorIs(t, warnings[0], ErrChecksumShort)
+	assert.Equal(t, `test: short.bin: crc "c4588e2": checksum too short`, warnings[0].Error())
+}
@@ -0,0 +1,99 @@
+package dat
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// offlineListLocations maps the OfflineList location codes to the region
+// names used by the No-Intro naming convention
+var offlineListLocations = map[int]string{
+	0:  "Europe",
+	1:  "USA",
+	2:  "Germany",
+	3:  "China",
+	4:  "Spain",
+	5:  "France",
+	6:  "Italy",
+	7:  "Japan",
+	8:  "Netherlands",
+	9:  "UK",
+	10: "Denmark",
+	11: "Finland",
+	12: "Norway",
+	13: "Poland",
+	14: "Portugal",
+	15: "Sweden",
+	19: "Australia",
+	21: "Brazil",
+	22: "Korea",
+}
+
+type offlineList struct {
+	XMLName       xml.Name `xml:"dat"`
+	Configuration struct {
+		DatName    string `xml:"datName"`
+		DatVersion string `xml:"datVersion"`
+	} `xml:"configuration"`
+	Games []struct {
+		ReleaseNumber string `xml:"releaseNumber"`
+		Title         string `xml:"title"`
+		ROMSize       uint64 `xml:"romSize"`
+		Location      string `xml:"location"`
+		Comment       string `xml:"comment"`
+		Files         []struct {
+			Extension string `xml:"extension,attr"`
+			CRC32     string `xml:",chardata"`
+		} `xml:"files>romCRC"`
+	} `xml:"games>game"`
+}
+
+// UnmarshalOfflineList parses an OfflineList XML dat file, as commonly used
+// for GBA and DS collections, and stores the result in File f. Each game is
+// named following the "NNNN - Title (Region)" convention used by such
+// collections and each ROM is named after the game plus the extension
+// listed in the dat file. OfflineList only records a CRC32 for each ROM
+func UnmarshalOfflineList(data []byte, f *File) error {
+	var ol offlineList
+	if err := xml.Unmarshal(data, &ol); err != nil {
+		return err
+	}
+
+	f.Header = Header{
+		Name:        ol.Configuration.DatName,
+		Description: ol.Configuration.DatName,
+		Version:     ol.Configuration.DatVersion,
+	}
+	f.Game = make([]Game, 0, len(ol.Games))
+
+	for _, g := range ol.Games {
+		name := strings.TrimSpace(g.Title)
+		if n, err := strconv.Atoi(strings.TrimSpace(g.ReleaseNumber)); err == nil && n > 0 {
+			name = fmt.Sprintf("%04d - %s", n, name)
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(g.Location)); err == nil {
+			if region, ok := offlineListLocations[n]; ok {
+				name += " (" + region + ")"
+			}
+		}
+
+		game := Game{
+			Name:        name,
+			Description: name,
+		}
+
+		for _, file := range g.Files {
+			game.ROM = append(game.ROM, ROM{
+				Name:  name + file.Extension,
+				Size:  g.ROMSize,
+				CRC32: strings.TrimSpace(file.CRC32),
+			})
+		}
+
+		f.Game = append(f.Game, game)
+	}
+
+	return nil
+}
@@ -0,0 +1,64 @@
+package dat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalOfflineList(t *testing.T) {
+	data := []byte(`<?xml version="1.0"?>
+<dat xsi:schemaLocation="http://www.logiqx.com/Dats/datafile.xsd" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">
+	<configuration>
+		<datName>ADVANsCEne Nintendo DS Collection</datName>
+		<datVersion>1234</datVersion>
+	</configuration>
+	<games>
+		<game>
+			<imageNumber>1</imageNumber>
+			<releaseNumber>1</releaseNumber>
+			<title>Test Game</title>
+			<romSize>16777216</romSize>
+			<location>7</location>
+			<files>
+				<romCRC extension=".nds">8C4588E2</romCRC>
+			</files>
+			<im1CRC>00000000</im1CRC>
+		</game>
+		<game>
+			<releaseNumber>0</releaseNumber>
+			<title>Another Game</title>
+			<romSize>4</romSize>
+			<location>99</location>
+			<files>
+				<romCRC extension=".gba">b63cfbcd</romCRC>
+			</files>
+		</game>
+	</games>
+	<gui/>
+</dat>`)
+
+	f := new(File)
+	if err := UnmarshalOfflineList(data, f); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "ADVANsCEne Nintendo DS Collection", f.Header.Name)
+	assert.Equal(t, "1234", f.Header.Version)
+	assert.Equal(t, []Game{
+		{
+			Name:        "0001 - Test Game (Japan)",
+			Description: "0001 - Test Game (Japan)",
+			ROM: []ROM{
+				{Name: "0001 - Test Game (Japan).nds", Size: 16777216, CRC32: "8C4588E2"},
+			},
+		},
+		{
+			Name:        "Another Game",
+			Description: "Another Game",
+			ROM: []ROM{
+				{Name: "Another Game.gba", Size: 4, CRC32: "b63cfbcd"},
+			},
+		},
+	}, f.Game)
+}
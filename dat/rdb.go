@@ -0,0 +1,260 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"path"
+	"strconv"
+)
+
+// RDBMagic is at the start of every RetroArch database
+const RDBMagic = "RARCHDB\x00"
+
+// Each record follows a header of the magic and the offset of the metadata
+// at the end, see libretro-db in the RetroArch source
+const rdbHeaderSize = 16
+
+var errRDBSyntax = errors.New("rdb syntax error")
+
+// msgpackDecoder decodes just the MessagePack types used by RetroArch
+// databases
+type msgpackDecoder struct {
+	b []byte
+}
+
+func (d *msgpackDecoder) next(n int) ([]byte, error) {
+	if n < 0 || n > len(d.b) {
+		return nil, fmt.Errorf("%w: truncated", errRDBSyntax)
+	}
+	b := d.b[:n]
+	d.b = d.b[n:]
+	return b, nil
+}
+
+func (d *msgpackDecoder) uint(n int) (uint64, error) {
+	b, err := d.next(n)
+	if err != nil {
+		return 0, err
+	}
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v, nil
+}
+
+// decode returns the next value, with maps as map[string]interface{},
+// arrays as []interface{}, strings as string, binary as []byte, integers as
+// int64 or uint64 and nil as nil
+func (d *msgpackDecoder) decode() (interface{}, error) {
+	t, err := d.uint(1)
+	if err != nil {
+		return nil, err
+	}
+
+	var length uint64
+	switch {
+	case t <= 0x7f:
+		return t, nil
+	case t >= 0xe0:
+		return int64(int8(t)), nil
+	case t >= 0x80 && t <= 0x8f:
+		return d.decodeMap(int(t & 0x0f))
+	case t >= 0x90 && t <= 0x9f:
+		return d.decodeArray(int(t & 0x0f))
+	case t >= 0xa0 && t <= 0xbf:
+		b, err := d.next(int(t & 0x1f))
+		return string(b), err
+	}
+
+	switch t {
+	case 0xc0:
+		return nil, nil
+	case 0xc2, 0xc3:
+		return t == 0xc3, nil
+	case 0xc4, 0xc5, 0xc6, 0xd9, 0xda, 0xdb:
+		n := map[uint64]int{0xc4: 1, 0xc5: 2, 0xc6: 4, 0xd9: 1, 0xda: 2, 0xdb: 4}[t]
+		if length, err = d.uint(n); err != nil {
+			return nil, err
+		}
+		b, err := d.next(int(length))
+		if err != nil {
+			return nil, err
+		}
+		if t >= 0xd9 {
+			return string(b), nil
+		}
+		return append([]byte{}, b...), nil
+	case 0xca:
+		v, err := d.uint(4)
+		return float64(math.Float32frombits(uint32(v))), err
+	case 0xcb:
+		v, err := d.uint(8)
+		return math.Float64frombits(v), err
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		return d.uint(1 << (t - 0xcc))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		n := 1 << (t - 0xd0)
+		v, err := d.uint(n)
+		// Sign-extend from the width of the integer
+		shift := 64 - 8*n
+		return int64(v<<shift) >> shift, err
+	case 0xdc, 0xdd:
+		if length, err = d.uint(map[uint64]int{0xdc: 2, 0xdd: 4}[t]); err != nil {
+			return nil, err
+		}
+		return d.decodeArray(int(length))
+	case 0xde, 0xdf:
+		if length, err = d.uint(map[uint64]int{0xde: 2, 0xdf: 4}[t]); err != nil {
+			return nil, err
+		}
+		return d.decodeMap(int(length))
+	}
+
+	return nil, fmt.Errorf("%w: unsupported type 0x%02x", errRDBSyntax, t)
+}
+
+func (d *msgpackDecoder) decodeArray(n int) ([]interface{}, error) {
+	a := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		v, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, v)
+	}
+	return a, nil
+}
+
+func (d *msgpackDecoder) decodeMap(n int) (map[string]interface{}, error) {
+	m := make(map[string]interface{}, n)
+	for i := 0; i < n; i++ {
+		k, err := d.decode()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := k.(string)
+		if !ok {
+			return nil, fmt.Errorf("%w: map key is not a string", errRDBSyntax)
+		}
+		if m[key], err = d.decode(); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// rdbString returns a text field, which might be stored as binary
+func rdbString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
+
+// rdbChecksum returns a checksum field, which is stored as binary
+func rdbChecksum(v interface{}) string {
+	switch v := v.(type) {
+	case []byte:
+		return hex.EncodeToString(v)
+	case string:
+		return v
+	}
+	return ""
+}
+
+func rdbUint(v interface{}) uint64 {
+	switch v := v.(type) {
+	case uint64:
+		return v
+	case int64:
+		if v > 0 {
+			return uint64(v)
+		}
+	case string:
+		n, _ := strconv.ParseUint(v, 10, 64)
+		return n
+	}
+	return 0
+}
+
+// UnmarshalRDB decodes the RetroArch database in data into File f. Each
+// entry becomes a ROM within a Game of the same name. There is no header in
+// the database so it is left empty
+func UnmarshalRDB(data []byte, f *File) error {
+	if !bytes.HasPrefix(data, []byte(RDBMagic)) || len(data) < rdbHeaderSize {
+		return fmt.Errorf("%w: not a RetroArch database", errRDBSyntax)
+	}
+
+	end := binary.BigEndian.Uint64(data[len(RDBMagic):])
+	if end < rdbHeaderSize || end > uint64(len(data)) {
+		return fmt.Errorf("%w: bad metadata offset", errRDBSyntax)
+	}
+
+	f.Header = Header{}
+	f.Game = nil
+
+	games := make(map[string]int)
+	d := &msgpackDecoder{data[rdbHeaderSize:end]}
+	for len(d.b) > 0 {
+		v, err := d.decode()
+		if err != nil {
+			return err
+		}
+		// The records end with nil
+		if v == nil {
+			break
+		}
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%w: record is not a map", errRDBSyntax)
+		}
+
+		r := ROM{
+			Name:   rdbString(m["rom_name"]),
+			Size:   rdbUint(m["size"]),
+			CRC32:  rdbChecksum(m["crc"]),
+			MD5:    rdbChecksum(m["md5"]),
+			SHA1:   rdbChecksum(m["sha1"]),
+			Serial: rdbString(m["serial"]),
+		}
+
+		name := rdbString(m["name"])
+		if name == "" {
+			name = r.Name[:len(r.Name)-len(path.Ext(r.Name))]
+		}
+		if r.Name == "" {
+			r.Name = name
+		}
+		if name == "" {
+			continue
+		}
+
+		if i, ok := games[name]; ok {
+			f.Game[i].ROM = append(f.Game[i].ROM, r)
+			continue
+		}
+
+		description := rdbString(m["description"])
+		if description == "" {
+			description = name
+		}
+
+		games[name] = len(f.Game)
+		f.Game = append(f.Game, Game{
+			Name:        name,
+			Description: description,
+			Category:    rdbString(m["genre"]),
+			ROM:         []ROM{r},
+		})
+	}
+
+	return nil
+}
@@ -0,0 +1,117 @@
+package dat
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func msgpackString(s string) []byte {
+	return append([]byte{0xa0 | byte(len(s))}, s...)
+}
+
+func msgpackBinary(b ...byte) []byte {
+	return append([]byte{0xc4, byte(len(b))}, b...)
+}
+
+func rdb(records ...[]byte) []byte {
+	body := bytes.Join(records, nil)
+	body = append(body, 0xc0)
+
+	b := new(bytes.Buffer)
+	b.WriteString(RDBMagic)
+	_ = binary.Write(b, binary.BigEndian, uint64(rdbHeaderSize+len(body)))
+	b.Write(body)
+	// The metadata map with the count of records
+	b.WriteByte(0x81)
+	b.Write(msgpackString("count"))
+	b.WriteByte(byte(len(records)))
+
+	return b.Bytes()
+}
+
+func TestUnmarshalRDB(t *testing.T) {
+	data := rdb(
+		bytes.Join([][]byte{
+			{0x86},
+			msgpackString("name"), msgpackString("Game (USA)"),
+			msgpackString("rom_name"), msgpackString("Game (USA).nes"),
+			msgpackString("size"), {0xcd, 0x01, 0x00},
+			msgpackString("crc"), msgpackBinary(0xb6, 0x3c, 0xfb, 0xcd),
+			msgpackString("serial"), msgpackBinary('N', 'E', 'S'),
+			msgpackString("releaseyear"), {0xcd, 0x07, 0xc6},
+		}, nil),
+		bytes.Join([][]byte{
+			{0x83},
+			msgpackString("rom_name"), msgpackString("Other (Europe).nes"),
+			msgpackString("size"), {0x04},
+			msgpackString("md5"), msgpackBinary(0x08, 0xd6, 0xc0, 0x5a, 0x21, 0x51, 0x2a, 0x79, 0xa1, 0xdf, 0xeb, 0x9d, 0x2a, 0x8f, 0x26, 0x2f),
+		}, nil),
+		bytes.Join([][]byte{
+			{0x82},
+			msgpackString("name"), msgpackString("Game (USA)"),
+			msgpackString("rom_name"), msgpackString("Game (USA) (Alt).nes"),
+		}, nil),
+	)
+
+	f := new(File)
+	if err := UnmarshalRDB(data, f); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, Header{}, f.Header)
+	assert.Equal(t, []Game{
+		{
+			Name:        "Game (USA)",
+			Description: "Game (USA)",
+			ROM: []ROM{
+				{Name: "Game (USA).nes", Size: 256, CRC32: "b63cfbcd", Serial: "NES"},
+				{Name: "Game (USA) (Alt).nes"},
+			},
+		},
+		{
+			Name:        "Other (Europe)",
+			Description: "Other (Europe)",
+			ROM: []ROM{
+				{Name: "Other (Europe).nes", Size: 4, MD5: "08d6c05a21512a79a1dfeb9d2a8f262f"},
+			},
+		},
+	}, f.Game)
+
+	tables := map[string][]byte{
+		"magic":     []byte("RARCHDX\x00\x00\x00\x00\x00\x00\x00\x00\x10"),
+		"offset":    []byte(RDBMagic + "\x00\x00\x00\x00\x00\x00\x10\x00"),
+		"truncated": append([]byte(RDBMagic+"\x00\x00\x00\x00\x00\x00\x00\x13"), 0x81, 0xa4, 'n'),
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			assert.NotEqual(t, nil, UnmarshalRDB(table, new(File)))
+		})
+	}
+}
+
+func TestMsgpackDecode(t *testing.T) {
+	tables := map[string]struct {
+		b []byte
+		v interface{}
+	}{
+		"negative fixint": {[]byte{0xff}, int64(-1)},
+		"int16":           {[]byte{0xd1, 0xff, 0xfe}, int64(-2)},
+		"uint32":          {[]byte{0xce, 0x00, 0x01, 0x00, 0x00}, uint64(65536)},
+		"str8":            {[]byte{0xd9, 0x02, 'h', 'i'}, "hi"},
+		"array":           {[]byte{0x92, 0x01, 0xc3}, []interface{}{uint64(1), true}},
+		"nil":             {[]byte{0xc0}, nil},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			d := &msgpackDecoder{table.b}
+			v, err := d.decode()
+			assert.Equal(t, nil, err)
+			assert.Equal(t, table.v, v)
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package dat
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// romCenterSeparator delimits the fields of each ROM in a RomCenter dat file
+const romCenterSeparator = "¬"
+
+var errRomCenterSyntax = errors.New("romcenter syntax error")
+
+// UnmarshalRomCenter parses a RomCenter 2.x dat file and stores the result in
+// File f. Each line in the [GAMES] section describes one ROM and consecutive
+// lines for the same game are collected into one Game. RomCenter only
+// records a CRC32 for each ROM. Dat files that aren't valid UTF-8 are read
+// as Latin-1
+func UnmarshalRomCenter(data []byte, f *File) error {
+	if !utf8.Valid(data) {
+		runes := make([]rune, len(data))
+		for i, c := range data {
+			runes[i] = rune(c)
+		}
+		data = []byte(string(runes))
+	}
+
+	f.Header = Header{}
+	f.Game = nil
+
+	var (
+		section      string
+		split, merge bool
+		plugin       string
+		index        = make(map[string]int)
+	)
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, 1<<20)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "\ufeff"))
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToUpper(line[1 : len(line)-1])
+			continue
+		}
+
+		if section == "GAMES" {
+			if err := romCenterROM(line, f, index); err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			continue
+		}
+
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			continue
+		}
+		key, value := strings.ToLower(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+
+		switch section {
+		case "CREDITS":
+			switch key {
+			case "author":
+				f.Header.Author = value
+			case "version":
+				f.Header.Version = value
+			case "date":
+				f.Header.Date = value
+			case "homepage":
+				f.Header.Homepage = value
+			case "url":
+				f.Header.URL = value
+			}
+		case "DAT":
+			switch key {
+			case "plugin":
+				plugin = value
+			case "split":
+				split = value == "1"
+			case "merge":
+				merge = value == "1"
+			}
+		case "EMULATOR":
+			switch key {
+			case "refname":
+				f.Header.Name = value
+			case "version":
+				f.Header.Description = value
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+
+	rc := RomCenter{Plugin: plugin}
+	switch {
+	case merge:
+		rc.ROMMode = "merged"
+	case split:
+		rc.ROMMode = "split"
+	}
+	if rc != (RomCenter{}) {
+		f.Header.RomCenter = &rc
+	}
+
+	return nil
+}
+
+// romCenterROM adds the ROM described by line to the Game it belongs to in
+// File f, creating the Game if needed
+func romCenterROM(line string, f *File, index map[string]int) error {
+	fields := strings.Split(strings.Trim(line, romCenterSeparator), romCenterSeparator)
+	if len(fields) < 7 {
+		return fmt.Errorf("%w: expected at least 7 fields, found %d", errRomCenterSyntax, len(fields))
+	}
+	for len(fields) < 9 {
+		fields = append(fields, "")
+	}
+
+	parent, name, description := fields[0], fields[2], fields[3]
+
+	i, ok := index[name]
+	if !ok {
+		game := Game{
+			Name:        name,
+			Description: description,
+			RomOf:       fields[7],
+		}
+		if parent != "" && parent != name {
+			game.CloneOf = parent
+		}
+		f.Game = append(f.Game, game)
+		i = len(f.Game) - 1
+		index[name] = i
+	}
+
+	r := ROM{
+		Name:  fields[4],
+		CRC32: fields[5],
+		Merge: fields[8],
+	}
+	if fields[6] != "" {
+		size, err := strconv.ParseUint(fields[6], 10, 64)
+		if err != nil {
+			return err
+		}
+		r.Size = size
+	}
+	f.Game[i].ROM = append(f.Game[i].ROM, r)
+
+	return nil
+}
+
+// MarshalRomCenter encodes File f as a RomCenter 2.50 dat file. Disks and
+// Samples along with any MD5 or SHA1 checksums can't be represented and are
+// dropped. As with marshalling to XML, any ROM that has been matched is left
+// out and nothing is returned if every Game is complete
+func MarshalRomCenter(f *File) ([]byte, error) {
+	if f.isComplete() {
+		return nil, nil
+	}
+
+	b := new(bytes.Buffer)
+
+	b.WriteString("[CREDITS]\r\n")
+	fmt.Fprintf(b, "author=%s\r\n", f.Header.Author)
+	fmt.Fprintf(b, "version=%s\r\n", f.Header.Version)
+	fmt.Fprintf(b, "date=%s\r\n", f.Header.Date)
+	fmt.Fprintf(b, "homepage=%s\r\n", f.Header.Homepage)
+	fmt.Fprintf(b, "url=%s\r\n", f.Header.URL)
+
+	split, merge, plugin := "0", "0", "arcade.dll"
+	m, _ := f.Header.Merging()
+	switch m {
+	case Split:
+		split = "1"
+	case FullMerged:
+		split, merge = "1", "1"
+	}
+	if rc := f.Header.RomCenter; rc != nil && rc.Plugin != "" {
+		plugin = rc.Plugin
+	}
+
+	b.WriteString("[DAT]\r\n")
+	b.WriteString("version=2.50\r\n")
+	fmt.Fprintf(b, "plugin=%s\r\n", plugin)
+	fmt.Fprintf(b, "split=%s\r\n", split)
+	fmt.Fprintf(b, "merge=%s\r\n", merge)
+
+	b.WriteString("[EMULATOR]\r\n")
+	fmt.Fprintf(b, "refname=%s\r\n", f.Header.Name)
+	fmt.Fprintf(b, "version=%s\r\n", f.Header.Description)
+
+	description := make(map[string]string, len(f.Game))
+	for _, g := range f.Game {
+		description[g.Name] = g.Description
+	}
+
+	b.WriteString("[GAMES]\r\n")
+	for _, g := range f.Game {
+		if g.Complete() {
+			continue
+		}
+
+		parent := g.Name
+		if g.CloneOf != "" {
+			parent = g.CloneOf
+		}
+
+		for _, r := range g.ROM {
+			if r.Complete() {
+				continue
+			}
+			fields := []string{
+				parent,
+				description[parent],
+				g.Name,
+				g.Description,
+				r.Name,
+				strings.ToLower(r.CRC32),
+				strconv.FormatUint(r.Size, 10),
+				g.RomOf,
+				r.Merge,
+			}
+			b.WriteString(romCenterSeparator + strings.Join(fields, romCenterSeparator) + romCenterSeparator + "\r\n")
+		}
+	}
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,88 @@
+package dat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnmarshalRomCenter(t *testing.T) {
+	data := []byte("[CREDITS]\r\n" +
+		"author=Someone\r\n" +
+		"version=0.1\r\n" +
+		"[DAT]\r\n" +
+		"version=2.50\r\n" +
+		"plugin=arcade.dll\r\n" +
+		"split=1\r\n" +
+		"merge=0\r\n" +
+		"[EMULATOR]\r\n" +
+		"refname=Test\r\n" +
+		"version=Test Dat\r\n" +
+		"[GAMES]\r\n" +
+		"¬parent¬Parent¬parent¬Parent¬a.bin¬1234abcd¬16¬¬¬\r\n" +
+		"¬parent¬Parent¬parent¬Parent¬b.bin¬b63cfbcd¬4¬¬¬\r\n" +
+		"¬parent¬Parent¬clone¬Clone¬a.bin¬1234abcd¬16¬parent¬a.bin¬\r\n")
+
+	f := new(File)
+	if err := UnmarshalRomCenter(data, f); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, Header{
+		Name:        "Test",
+		Description: "Test Dat",
+		Version:     "0.1",
+		Author:      "Someone",
+		RomCenter: &RomCenter{
+			Plugin:  "arcade.dll",
+			ROMMode: "split",
+		},
+	}, f.Header)
+	assert.Equal(t, []Game{
+		{
+			Name:        "parent",
+			Description: "Parent",
+			ROM: []ROM{
+				{Name: "a.bin", Size: 16, CRC32: "1234abcd"},
+				{Name: "b.bin", Size: 4, CRC32: "b63cfbcd"},
+			},
+		},
+		{
+			Name:        "clone",
+			Description: "Clone",
+			CloneOf:     "parent",
+			RomOf:       "parent",
+			ROM: []ROM{
+				{Name: "a.bin", Size: 16, CRC32: "1234abcd", Merge: "a.bin"},
+			},
+		},
+	}, f.Game)
+
+	b, err := MarshalRomCenter(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Contains(t, string(b), "split=1\r\nmerge=0\r\n")
+	assert.Contains(t, string(b), "¬parent¬Parent¬clone¬Clone¬a.bin¬1234abcd¬16¬parent¬a.bin¬\r\n")
+
+	g := new(File)
+	if err := UnmarshalRomCenter(b, g); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, f.Game, g.Game)
+}
+
+func TestUnmarshalRomCenterLatin1(t *testing.T) {
+	data := []byte("[GAMES]\r\n\xacgame\xacGame\xacgame\xacGame \xe9\xaca.bin\xac1234abcd\xac16\xac\xac\xac\r\n")
+
+	f := new(File)
+	if err := UnmarshalRomCenter(data, f); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Equal(t, "Game é", f.Game[0].Description)
+}
+
+func TestUnmarshalRomCenterError(t *testing.T) {
+	assert.ErrorIs(t, UnmarshalRomCenter([]byte("[GAMES]\r\n¬foo¬bar¬\r\n"), new(File)), errRomCenterSyntax)
+}
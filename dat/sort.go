@@ -0,0 +1,38 @@
+package dat
+
+import "sort"
+
+// lower returns s with only the ASCII letters lowercased, which is how
+// TorrentZip orders the files within an archive
+func lower(s string) string {
+	b := []byte(s)
+	for i, c := range b {
+		if c >= 'A' && c <= 'Z' {
+			b[i] = c + 'a' - 'A'
+		}
+	}
+	return string(b)
+}
+
+// Sort orders the Games within File f by name and the ROMs, Disks and
+// Samples within each Game likewise. Names are compared case-insensitively
+// following the same rules as TorrentZip so the output is stable regardless
+// of the original order
+func (f *File) Sort() {
+	sort.SliceStable(f.Game, func(i, j int) bool {
+		return lower(f.Game[i].Name) < lower(f.Game[j].Name)
+	})
+
+	for i := range f.Game {
+		g := &f.Game[i]
+		sort.SliceStable(g.ROM, func(i, j int) bool {
+			return lower(g.ROM[i].Name) < lower(g.ROM[j].Name)
+		})
+		sort.SliceStable(g.Disk, func(i, j int) bool {
+			return lower(g.Disk[i].Name) < lower(g.Disk[j].Name)
+		})
+		sort.SliceStable(g.Sample, func(i, j int) bool {
+			return lower(g.Sample[i].Name) < lower(g.Sample[j].Name)
+		})
+	}
+}
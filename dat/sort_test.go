@@ -0,0 +1,41 @@
+package dat
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSort(t *testing.T) {
+	f := &File{
+		Game: []Game{
+			{
+				Name: "b",
+				ROM: []ROM{
+					{Name: "b.bin"},
+					{Name: "A.bin"},
+					{Name: "_.bin"},
+				},
+			},
+			{
+				Name: "A",
+			},
+			{
+				Name: "a",
+			},
+			{
+				Name: "[BIOS]",
+			},
+		},
+	}
+
+	f.Sort()
+
+	names := make([]string, 0, len(f.Game))
+	for _, g := range f.Game {
+		names = append(names, g.Name)
+	}
+
+	assert.Equal(t, []string{"[BIOS]", "A", "a", "b"}, names)
+	assert.Equal(t, []ROM{{Name: "_.bin"}, {Name: "A.bin"}, {Name: "b.bin"}}, f.Game[3].ROM)
+}
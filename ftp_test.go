@@ -0,0 +1,228 @@
+package rom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// ftpServer is just enough of an FTP server to serve files in dir. If mlsd
+// is false then only LIST is supported
+func ftpServer(t *testing.T, dir string, mlsd bool) net.Listener {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, err)
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				c := textproto.NewConn(conn)
+				_ = c.PrintfLine("220 test")
+
+				var data net.Listener
+				var rest int64
+				for {
+					line, err := c.ReadLine()
+					if err != nil {
+						return
+					}
+					cmd, arg := line, ""
+					if i := strings.IndexByte(line, ' '); i >= 0 {
+						cmd, arg = line[:i], line[i+1:]
+					}
+					name := filepath.Join(dir, filepath.FromSlash(arg))
+
+					switch cmd {
+					case "USER":
+						_ = c.PrintfLine("331 password please")
+					case "PASS":
+						if arg != "secret" {
+							_ = c.PrintfLine("530 login incorrect")
+							continue
+						}
+						_ = c.PrintfLine("230 logged in")
+					case "TYPE":
+						_ = c.PrintfLine("200 ok")
+					case "EPSV":
+						data, _ = net.Listen("tcp", "127.0.0.1:0")
+						_ = c.PrintfLine("229 Entering Extended Passive Mode (|||%d|)", data.Addr().(*net.TCPAddr).Port)
+					case "SIZE":
+						fi, err := os.Stat(name)
+						if err != nil {
+							_ = c.PrintfLine("550 no such file")
+							continue
+						}
+						_ = c.PrintfLine("213 %d", fi.Size())
+					case "REST":
+						rest, _ = strconv.ParseInt(arg, 10, 64)
+						_ = c.PrintfLine("350 ok")
+					case "RETR", "MLSD", "LIST":
+						if cmd == "MLSD" && !mlsd {
+							_ = c.PrintfLine("500 unknown command")
+							data.Close()
+							continue
+						}
+
+						dc, err := data.Accept()
+						data.Close()
+						if err != nil {
+							return
+						}
+
+						var r io.Reader
+						if cmd == "RETR" {
+							f, err := os.Open(name)
+							if err != nil {
+								_ = c.PrintfLine("550 no such file")
+								dc.Close()
+								continue
+							}
+							_, _ = f.Seek(rest, io.SeekStart)
+							b, _ := io.ReadAll(f)
+							f.Close()
+							r = strings.NewReader(string(b))
+						} else {
+							entries, _ := os.ReadDir(name)
+							var b strings.Builder
+							for _, e := range entries {
+								if cmd == "MLSD" {
+									if e.IsDir() {
+										fmt.Fprintf(&b, "type=dir; %s\r\n", e.Name())
+									} else {
+										fmt.Fprintf(&b, "type=file;size=1; %s\r\n", e.Name())
+									}
+									continue
+								}
+								mode := "-rw-r--r--"
+								if e.IsDir() {
+									mode = "drwxr-xr-x"
+								}
+								fmt.Fprintf(&b, "%s   1 ftp  ftp        0 Jan  1 00:00 %s\r\n", mode, e.Name())
+							}
+							r = strings.NewReader(b.String())
+						}
+						rest = 0
+
+						_ = c.PrintfLine("150 opening data connection")
+						if _, err := io.Copy(dc, r); err != nil {
+							dc.Close()
+							_ = c.PrintfLine("426 transfer aborted")
+							continue
+						}
+						dc.Close()
+						_ = c.PrintfLine("226 transfer complete")
+					default:
+						_ = c.PrintfLine("502 not implemented")
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return l
+}
+
+func TestParseLIST(t *testing.T) {
+	tables := map[string]struct {
+		line  string
+		entry ftpEntry
+		ok    bool
+	}{
+		"file":   {"-rw-r--r--   1 ftp  ftp     1024 Jan  1 00:00 test.zip", ftpEntry{"test.zip", false}, true},
+		"spaces": {"-rw-r--r--   1 ftp  ftp     1024 Jan  1  2020 Game (USA).zip", ftpEntry{"Game (USA).zip", false}, true},
+		"dir":    {"drwxr-xr-x   2 ftp  ftp     4096 Jan  1 00:00 test", ftpEntry{"test", true}, true},
+		"total":  {"total 8", ftpEntry{}, false},
+		"link":   {"lrwxrwxrwx   1 ftp  ftp        4 Jan  1 00:00 a -> b", ftpEntry{}, false},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			entry, ok := parseLIST(table.line)
+			assert.Equal(t, table.ok, ok)
+			assert.Equal(t, table.entry, entry)
+		})
+	}
+}
+
+func TestFTP(t *testing.T) {
+	for _, mlsd := range []bool{true, false} {
+		t.Run(fmt.Sprintf("mlsd=%v", mlsd), func(t *testing.T) {
+			l := ftpServer(t, "testdata", mlsd)
+			defer l.Close()
+
+			base := "ftp://user:secret@" + l.Addr().String()
+
+			files, err := List(base + "/test")
+			assert.Equal(t, nil, err)
+			listed := "ftp://user@" + l.Addr().String()
+			assert.Equal(t, []string{listed + "/test/test.bin", listed + "/test/test.nes"}, files)
+
+			// The password is still known when reconnecting
+			closeFTP()
+			f, err := OpenFTP(context.Background(), files[0])
+			assert.Equal(t, nil, err)
+			assert.Equal(t, nil, f.Close())
+
+			_, err = OpenFTP(context.Background(), base+"/missing.zip")
+			var terr *textproto.Error
+			assert.True(t, errors.As(err, &terr))
+
+			f, err = OpenFTP(context.Background(), base+"/test/test.bin")
+			assert.Equal(t, nil, err)
+			b, err := io.ReadAll(io.NewSectionReader(f, 0, f.Size()))
+			assert.Equal(t, nil, err)
+			expected, _ := os.ReadFile(filepath.Join("testdata", "test", "test.bin"))
+			assert.Equal(t, expected, b)
+			assert.Equal(t, nil, f.Close())
+
+			r, err := NewReader(base + "/test.zip")
+			assert.Equal(t, nil, err)
+			assert.Equal(t, "*rom.ZipReader", fmt.Sprintf("%T", r))
+
+			checksum, err := r.Checksum("test.bin", SHA1)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, []byte{0x4e, 0xbc, 0x20, 0xb4, 0x6e, 0xa4, 0xd0, 0x10, 0xed, 0x9a, 0xc1, 0xfd, 0xe4, 0xc2, 0x51, 0xcf, 0x23, 0x1a, 0x66, 0x1f}, checksum)
+			assert.Equal(t, nil, r.Close())
+		})
+	}
+}
+
+func TestFTPCancel(t *testing.T) {
+	// A server that accepts the connection but never says anything
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Equal(t, nil, err)
+	defer l.Close()
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_, _ = io.Copy(io.Discard, conn)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err = OpenFTP(ctx, "ftp://"+l.Addr().String()+"/test.zip")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,122 @@
+/*
+Package hasheous implements a client for Hasheous, an online service that
+identifies ROMs by their checksums, which can be used to find what a file
+that isn't in any dat file probably is.
+*/
+package hasheous
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const lookupPath = "/api/v1/Lookup/ByHash"
+
+var errNoChecksums = errors.New("no checksums to look up")
+
+// Match is what a file was identified as
+type Match struct {
+	Name     string `json:"name"`
+	Platform string `json:"platform"`
+}
+
+func (m Match) String() string {
+	if m.Platform == "" {
+		return m.Name
+	}
+	return m.Name + " (" + m.Platform + ")"
+}
+
+// Checksums are the checksums of a file to look up, any of which can be
+// nil
+type Checksums struct {
+	CRC32 []byte
+	MD5   []byte
+	SHA1  []byte
+}
+
+type lookupRequest struct {
+	MD5   string `json:"md5,omitempty"`
+	SHA1  string `json:"sha1,omitempty"`
+	CRC32 string `json:"crc,omitempty"`
+}
+
+type lookupResponse struct {
+	Name     string `json:"name"`
+	Platform struct {
+		Name string `json:"name"`
+	} `json:"platform"`
+}
+
+// Client queries a Hasheous server
+type Client struct {
+	url    string
+	client *http.Client
+}
+
+// NewClient returns a Client for the server at url, such as
+// "https://hasheous.org", using client to make requests
+func NewClient(url string, client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Client{
+		url:    strings.TrimSuffix(url, "/"),
+		client: client,
+	}
+}
+
+// Lookup returns what the file with checksums c was identified as, or nil
+// if the server doesn't recognise it
+func (c *Client) Lookup(ctx context.Context, checksums Checksums) (*Match, error) {
+	if checksums.CRC32 == nil && checksums.MD5 == nil && checksums.SHA1 == nil {
+		return nil, errNoChecksums
+	}
+
+	b, err := json.Marshal(lookupRequest{
+		MD5:   hex.EncodeToString(checksums.MD5),
+		SHA1:  hex.EncodeToString(checksums.SHA1),
+		CRC32: hex.EncodeToString(checksums.CRC32),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url+lookupPath, bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "rom")
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("%s: %s", c.url, resp.Status)
+	}
+
+	var r lookupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+	if r.Name == "" {
+		return nil, nil
+	}
+
+	return &Match{r.Name, r.Platform.Name}, nil
+}
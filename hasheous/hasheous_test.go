@@ -0,0 +1,56 @@
+package hasheous
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookup(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != lookupPath {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		var req lookupRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		switch req.SHA1 {
+		case "4ebc20b46ea4d010ed9ac1fde4c251cf231a661f":
+			_, _ = w.Write([]byte(`{"id":1,"name":"Test Game","platform":{"id":2,"name":"Nintendo Game Boy"}}`))
+		case "ffffffffffffffffffffffffffffffffffffffff":
+			http.Error(w, "broken", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	c := NewClient(ts.URL+"/", nil)
+
+	m, err := c.Lookup(context.Background(), Checksums{
+		CRC32: []byte{0xd5, 0x80, 0xa1, 0x53},
+		SHA1:  []byte{0x4e, 0xbc, 0x20, 0xb4, 0x6e, 0xa4, 0xd0, 0x10, 0xed, 0x9a, 0xc1, 0xfd, 0xe4, 0xc2, 0x51, 0xcf, 0x23, 0x1a, 0x66, 0x1f},
+	})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, &Match{"Test Game", "Nintendo Game Boy"}, m)
+	assert.Equal(t, "Test Game (Nintendo Game Boy)", m.String())
+
+	m, err = c.Lookup(context.Background(), Checksums{SHA1: make([]byte, 20)})
+	assert.Equal(t, nil, err)
+	assert.Nil(t, m)
+
+	_, err = c.Lookup(context.Background(), Checksums{SHA1: []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}})
+	assert.NotEqual(t, nil, err)
+
+	_, err = c.Lookup(context.Background(), Checksums{})
+	assert.Equal(t, errNoChecksums, err)
+}
@@ -5,39 +5,57 @@ import (
 	"path/filepath"
 )
 
-func headerSize(_ io.Reader) (uint64, error) {
-	return 0, nil
+type headerFormat struct {
+	name   string
+	reader func(io.Reader) (io.Reader, uint64, error)
 }
 
-var extensionToHeaderSize = map[string]func(io.Reader) (uint64, error){
-	lynxExtension: func(r io.Reader) (uint64, error) {
-		_, hs, err := lynxReader(r)
-		if err != nil {
-			return 0, err
-		}
-
-		return hs, nil
-	},
-	nesExtension: func(r io.Reader) (uint64, error) {
-		_, hs, err := nesReader(r)
-		if err != nil {
-			return 0, err
-		}
+var extensionToHeader = map[string]headerFormat{
+	lynxExtension: {"Lynx", lynxReader},
+	nesExtension:  {"iNES", nesReader},
+}
 
-		return hs, nil
-	},
+func headerSize(_ io.Reader) (uint64, error) {
+	return 0, nil
 }
 
 func hasHeader(filename string) bool {
-	if _, ok := extensionToHeaderSize[filepath.Ext(filename)]; ok {
+	if _, ok := extensionToHeader[filepath.Ext(filename)]; ok {
 		return true
 	}
 	return false
 }
 
 func headerSizeFunction(filename string) func(io.Reader) (uint64, error) {
-	if f, ok := extensionToHeaderSize[filepath.Ext(filename)]; ok {
-		return f
+	if h, ok := extensionToHeader[filepath.Ext(filename)]; ok {
+		return func(r io.Reader) (uint64, error) {
+			_, hs, err := h.reader(r)
+			if err != nil {
+				return 0, err
+			}
+
+			return hs, nil
+		}
 	}
 	return headerSize
 }
+
+// HeaderFormat returns the name of the header, such as "iNES", that a ROM
+// called filename may start with based on its extension. It returns false
+// if no header is known for such a ROM
+func HeaderFormat(filename string) (string, bool) {
+	if h, ok := extensionToHeader[filepath.Ext(filename)]; ok {
+		return h.name, true
+	}
+	return "", false
+}
+
+// StripHeader detects any header at the start of r, which is read as a ROM
+// called filename, and returns a reader for the remaining ROM data along
+// with the length of the header. The length is zero if there was no header
+func StripHeader(filename string, r io.Reader) (io.Reader, uint64, error) {
+	if h, ok := extensionToHeader[filepath.Ext(filename)]; ok {
+		return h.reader(r)
+	}
+	return r, 0, nil
+}
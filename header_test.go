@@ -0,0 +1,63 @@
+package rom
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeaderFormat(t *testing.T) {
+	name, ok := HeaderFormat("test.nes")
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "iNES", name)
+
+	_, ok = HeaderFormat("test.bin")
+	assert.Equal(t, false, ok)
+}
+
+func TestStripHeader(t *testing.T) {
+	tables := map[string]struct {
+		filename string
+		got      []byte
+		want     []byte
+		size     uint64
+	}{
+		"plain": {
+			"test.bin",
+			[]byte{0x01, 0x02, 0x03, 0x04},
+			[]byte{0x01, 0x02, 0x03, 0x04},
+			0,
+		},
+		"NES no header": {
+			"test.nes",
+			[]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
+			[]byte{0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f},
+			0,
+		},
+		"NES header": {
+			"test.nes",
+			[]byte{'N', 'E', 'S', 0x1a, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01, 0x02, 0x03, 0x04},
+			[]byte{0x01, 0x02, 0x03, 0x04},
+			nesHeaderSize,
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			r, size, err := StripHeader(table.filename, bytes.NewReader(table.got))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			b, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			assert.Equal(t, table.size, size)
+			assert.Equal(t, table.want, b)
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package rom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errNoRanges = errors.New("server doesn't support range requests")
+
+// httpClient is used for every HTTP request so a server that stops
+// responding is given up on rather than holding up a worker forever.
+// There's no overall limit as uploading a large file can take a long time
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		IdleConnTimeout:       90 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	},
+}
+
+// hrefPattern finds each link in an index page. This is enough for the
+// pages generated by common web servers without needing to parse HTML
+var hrefPattern = regexp.MustCompile(`(?i)<a\s[^>]*href\s*=\s*["']([^"']+)["']`)
+
+func init() {
+	RegisterScheme("http", OpenHTTP)
+	RegisterScheme("https", OpenHTTP)
+	RegisterLister("http", ListHTTP)
+	RegisterLister("https", ListHTTP)
+}
+
+type httpFile struct {
+	blockFile
+	ctx    context.Context
+	client *http.Client
+	url    string
+	sign   func(*http.Request)
+}
+
+// OpenHTTP returns a RemoteFile for the http or https URL using range
+// requests, so only the parts of an archive that are needed are downloaded.
+// The server must support range requests
+func OpenHTTP(ctx context.Context, name string) (RemoteFile, error) {
+	return openHTTP(ctx, name, nil)
+}
+
+// openHTTP is like OpenHTTP but calls sign, if set, with each request first
+func openHTTP(ctx context.Context, name string, sign func(*http.Request)) (*httpFile, error) {
+	f := &httpFile{
+		ctx:    ctx,
+		client: httpClient,
+		url:    name,
+		sign:   sign,
+	}
+	f.blockFile.read = f.read
+
+	ctx, cancel := context.WithTimeout(ctx, blockTimeout)
+	defer cancel()
+
+	resp, err := f.get(ctx, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	// Content-Range is "bytes 0-0/size"
+	cr := resp.Header.Get("Content-Range")
+	i := strings.LastIndexByte(cr, '/')
+	if i < 0 {
+		return nil, fmt.Errorf("%s: invalid Content-Range %q", name, cr)
+	}
+	if f.size, err = strconv.ParseInt(cr[i+1:], 10, 64); err != nil {
+		return nil, fmt.Errorf("%s: invalid Content-Range %q", name, cr)
+	}
+
+	return f, nil
+}
+
+// get requests length bytes from offset
+func (f *httpFile) get(ctx context.Context, offset, length int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	if f.sign != nil {
+		f.sign(req)
+	}
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp, nil
+	case http.StatusOK:
+		resp.Body.Close()
+		return nil, fmt.Errorf("%s: %w", f.url, errNoRanges)
+	}
+	resp.Body.Close()
+
+	return nil, fmt.Errorf("%s: %s", f.url, resp.Status)
+}
+
+func (f *httpFile) read(b []byte, offset int64) error {
+	ctx, cancel := context.WithTimeout(f.ctx, blockTimeout)
+	defer cancel()
+
+	resp, err := f.get(ctx, offset, int64(len(b)))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	_, err = io.ReadFull(resp.Body, b)
+
+	return err
+}
+
+func (f *httpFile) Close() error {
+	f.block = nil
+	return nil
+}
+
+// ListHTTP returns the URL of every file linked to from the index page at
+// name, such as the directory listing of a web server or mirror. Any link
+// to a page beneath name ending with a "/" is followed as a subdirectory
+// and anything outside of name is ignored
+func ListHTTP(ctx context.Context, name string) ([]string, error) {
+	base, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+	}
+
+	seen := make(map[string]struct{})
+
+	var files []string
+	var list func(*url.URL) error
+	list = func(u *url.URL) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("%s: %s", u, resp.Status)
+		}
+
+		b, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range hrefPattern.FindAllSubmatch(b, -1) {
+			ref, err := url.Parse(html.UnescapeString(string(m[1])))
+			if err != nil || ref.RawQuery != "" || ref.Fragment != "" {
+				continue
+			}
+
+			link := u.ResolveReference(ref)
+			if link.Host != base.Host || !strings.HasPrefix(link.Path, u.Path) || link.Path == u.Path {
+				continue
+			}
+
+			// Skip hidden files and directories
+			if n := strings.TrimSuffix(link.Path[len(u.Path):], "/"); n == "" || n[0] == '.' {
+				continue
+			}
+
+			if _, ok := seen[link.Path]; ok {
+				continue
+			}
+			seen[link.Path] = struct{}{}
+
+			if strings.HasSuffix(link.Path, "/") {
+				if err := list(link); err != nil {
+					return err
+				}
+				continue
+			}
+
+			files = append(files, link.String())
+		}
+
+		return nil
+	}
+
+	if err := list(base); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
@@ -0,0 +1,158 @@
+package rom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewReaderHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer ts.Close()
+
+	tables := map[string]struct {
+		path   string
+		err    error
+		reader string
+		files  []string
+	}{
+		"file": {
+			"/test/test.bin",
+			nil,
+			"*rom.FileReader",
+			[]string{"test.bin"},
+		},
+		"zip": {
+			"/test.zip",
+			nil,
+			"*rom.ZipReader",
+			[]string{"test.bin", "test.nes"},
+		},
+		"torrentzip": {
+			"/torrent.zip",
+			nil,
+			"*rom.TorrentZipReader",
+			[]string{"test.bin", "test.nes"},
+		},
+		"7z": {
+			"/test.7z",
+			nil,
+			"*rom.SevenZipReader",
+			[]string{"test.bin", "test.nes"},
+		},
+		"rar": {
+			"/test.rar",
+			errRemoteRar,
+			"",
+			nil,
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			r, err := NewReader(ts.URL + table.path)
+			assert.Equal(t, table.err, err)
+			if err != nil {
+				return
+			}
+
+			assert.Equal(t, table.reader, fmt.Sprintf("%T", r))
+			assert.Equal(t, ts.URL+table.path, r.Name())
+			files := r.Files()
+			sort.Strings(files)
+			assert.Equal(t, table.files, files)
+
+			checksum, err := r.Checksum("test.bin", SHA1)
+			assert.Equal(t, nil, err)
+			assert.Equal(t, []byte{0x4e, 0xbc, 0x20, 0xb4, 0x6e, 0xa4, 0xd0, 0x10, 0xed, 0x9a, 0xc1, 0xfd, 0xe4, 0xc2, 0x51, 0xcf, 0x23, 0x1a, 0x66, 0x1f}, checksum)
+
+			if v, ok := r.(Validator); ok {
+				assert.Equal(t, true, v.Valid())
+			}
+
+			assert.Equal(t, nil, r.Close())
+			assert.Greater(t, r.Rx(), uint64(0))
+		})
+	}
+}
+
+func TestOpenHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/norange":
+			_, _ = w.Write([]byte("0123456789"))
+		default:
+			http.ServeContent(w, r, "", time.Time{}, strings.NewReader("0123456789"))
+		}
+	}))
+	defer ts.Close()
+
+	_, err := OpenHTTP(context.Background(), ts.URL+"/norange")
+	assert.True(t, errors.Is(err, errNoRanges))
+
+	f, err := OpenHTTP(context.Background(), ts.URL+"/file")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, int64(10), f.Size())
+
+	b := make([]byte, 4)
+	n, err := f.ReadAt(b, 3)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "3456", string(b[:n]))
+
+	n, err = f.ReadAt(b, 8)
+	assert.Equal(t, io.EOF, err)
+	assert.Equal(t, "89", string(b[:n]))
+
+	_, err = f.ReadAt(b, 10)
+	assert.Equal(t, io.EOF, err)
+
+	assert.Equal(t, nil, f.Close())
+}
+
+func TestListHTTP(t *testing.T) {
+	ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer ts.Close()
+
+	files, err := ListHTTP(context.Background(), ts.URL+"/")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{
+		ts.URL + "/test.7z",
+		ts.URL + "/test.rar",
+		ts.URL + "/test.zip",
+		ts.URL + "/test/test.bin",
+		ts.URL + "/test/test.nes",
+		ts.URL + "/torrent.zip",
+	}, files)
+
+	files, err = List(ts.URL + "/test")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{ts.URL + "/test/test.bin", ts.URL + "/test/test.nes"}, files)
+
+	_, err = List("nothing://test/")
+	assert.True(t, errors.Is(err, errNoLister))
+}
+
+func TestOpenHTTPCancel(t *testing.T) {
+	stalled := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-stalled
+	}))
+	defer ts.Close()
+	defer close(stalled)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err := NewReaderContext(ctx, ts.URL+"/test.zip")
+	assert.True(t, errors.Is(err, context.Canceled))
+}
@@ -0,0 +1,173 @@
+/*
+Package openvgdb implements looking up ROMs by checksum in an OpenVGDB
+database, as used by OpenEmu, to find the title, system, region and other
+metadata for files that aren't in any dat file.
+
+The database is a SQLite file which is read directly so no SQLite library
+is needed.
+*/
+package openvgdb
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/bodgit/rom"
+)
+
+// Release is a release of a ROM
+type Release struct {
+	Title       string `json:"title"`
+	Region      string `json:"region,omitempty"`
+	Developer   string `json:"developer,omitempty"`
+	Publisher   string `json:"publisher,omitempty"`
+	Genre       string `json:"genre,omitempty"`
+	Date        string `json:"date,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// ROM is a ROM known to OpenVGDB
+type ROM struct {
+	FileName string    `json:"filename"`
+	Size     uint64    `json:"size"`
+	CRC32    string    `json:"crc,omitempty"`
+	MD5      string    `json:"md5,omitempty"`
+	SHA1     string    `json:"sha1,omitempty"`
+	System   string    `json:"system"`
+	Region   string    `json:"region,omitempty"`
+	Releases []Release `json:"releases,omitempty"`
+}
+
+// Title returns the title of the first release of the ROM, falling back to
+// its filename without extension
+func (r *ROM) Title() string {
+	if len(r.Releases) > 0 && r.Releases[0].Title != "" {
+		return r.Releases[0].Title
+	}
+	if i := strings.LastIndexByte(r.FileName, '.'); i > 0 {
+		return r.FileName[:i]
+	}
+	return r.FileName
+}
+
+// DB is an OpenVGDB database loaded into memory
+type DB struct {
+	roms      []*ROM
+	checksums map[rom.Checksum]map[string][]*ROM
+}
+
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case []byte:
+		return string(v)
+	}
+	return ""
+}
+
+func integer(v interface{}) int64 {
+	switch v := v.(type) {
+	case int64:
+		return v
+	case string:
+		i, _ := strconv.ParseInt(strings.TrimSpace(v), 10, 64)
+		return i
+	}
+	return 0
+}
+
+// names reads a table mapping an ID to a name
+func names(db *sqliteDB, table, id, name string) (map[int64]string, error) {
+	m := make(map[int64]string)
+	err := db.table(table, func(row map[string]interface{}) error {
+		m[integer(row[id])] = text(row[name])
+		return nil
+	})
+	return m, err
+}
+
+// Open reads the OpenVGDB database in the file called name
+func Open(name string) (*DB, error) {
+	sqlite, err := openSQLite(name)
+	if err != nil {
+		return nil, err
+	}
+	defer sqlite.Close()
+
+	systems, err := names(sqlite, "SYSTEMS", "systemID", "systemName")
+	if err != nil {
+		return nil, err
+	}
+
+	regions, err := names(sqlite, "REGIONS", "regionID", "regionName")
+	if err != nil {
+		return nil, err
+	}
+
+	db := &DB{
+		checksums: map[rom.Checksum]map[string][]*ROM{
+			rom.CRC32: make(map[string][]*ROM),
+			rom.MD5:   make(map[string][]*ROM),
+			rom.SHA1:  make(map[string][]*ROM),
+		},
+	}
+
+	ids := make(map[int64]*ROM)
+	if err := sqlite.table("ROMs", func(row map[string]interface{}) error {
+		r := &ROM{
+			FileName: text(row["romFileName"]),
+			Size:     uint64(integer(row["romSize"])),
+			CRC32:    strings.ToLower(text(row["romHashCRC"])),
+			MD5:      strings.ToLower(text(row["romHashMD5"])),
+			SHA1:     strings.ToLower(text(row["romHashSHA1"])),
+			System:   systems[integer(row["systemID"])],
+			Region:   regions[integer(row["regionID"])],
+		}
+		ids[integer(row["romID"])] = r
+		db.roms = append(db.roms, r)
+
+		for c, v := range map[rom.Checksum]string{rom.CRC32: r.CRC32, rom.MD5: r.MD5, rom.SHA1: r.SHA1} {
+			if v != "" {
+				db.checksums[c][v] = append(db.checksums[c][v], r)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if err := sqlite.table("RELEASES", func(row map[string]interface{}) error {
+		r, ok := ids[integer(row["romID"])]
+		if !ok {
+			return nil
+		}
+		r.Releases = append(r.Releases, Release{
+			Title:       text(row["releaseTitleName"]),
+			Region:      text(row["TEMPregionLocalizedName"]),
+			Developer:   text(row["releaseDeveloper"]),
+			Publisher:   text(row["releasePublisher"]),
+			Genre:       text(row["releaseGenre"]),
+			Date:        text(row["releaseDate"]),
+			Description: text(row["releaseDescription"]),
+		})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// Len returns the number of ROMs in db
+func (db *DB) Len() int {
+	return len(db.roms)
+}
+
+// FindByChecksum returns every ROM in db with the checksum value of type t
+func (db *DB) FindByChecksum(t rom.Checksum, value string) []*ROM {
+	return db.checksums[t][strings.ToLower(value)]
+}
@@ -0,0 +1,76 @@
+package openvgdb
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/bodgit/rom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVarint(t *testing.T) {
+	tables := map[string]struct {
+		b      []byte
+		v      int64
+		length int
+	}{
+		"one byte":   {[]byte{0x7f}, 0x7f, 1},
+		"two bytes":  {[]byte{0x81, 0x00}, 0x80, 2},
+		"nine bytes": {[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, -1, 9},
+		"truncated":  {[]byte{0x81}, 0, 0},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			v, length := varint(table.b)
+			assert.Equal(t, table.v, v)
+			assert.Equal(t, table.length, length)
+		})
+	}
+}
+
+func TestColumns(t *testing.T) {
+	names, rowid := columns(`CREATE TABLE "ROMs" (romID INTEGER PRIMARY KEY AUTOINCREMENT, "systemID" INTEGER, romSize INTEGER DEFAULT (0), [romFileName] TEXT, UNIQUE (romID, systemID))`)
+	assert.Equal(t, []string{"romID", "systemID", "romSize", "romFileName"}, names)
+	assert.Equal(t, 0, rowid)
+
+	names, rowid = columns("CREATE TABLE t (a TEXT, b)")
+	assert.Equal(t, []string{"a", "b"}, names)
+	assert.Equal(t, -1, rowid)
+}
+
+func TestOpen(t *testing.T) {
+	db, err := Open(filepath.Join("testdata", "openvgdb.sqlite"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, 150, db.Len())
+
+	roms := db.FindByChecksum(rom.SHA1, "D11C5046DF86ACD07E132A3A35B3FFC42617A089")
+	assert.Equal(t, 1, len(roms))
+	r := roms[0]
+	assert.Equal(t, "Game 1 (USA).nes", r.FileName)
+	assert.Equal(t, uint64(5), r.Size)
+	assert.Equal(t, "a220df0c", r.CRC32)
+	assert.Equal(t, "Game Boy", r.System)
+	assert.Equal(t, "Europe", r.Region)
+	assert.Equal(t, "Game 1", r.Title())
+	assert.Equal(t, 1, len(r.Releases))
+	assert.Equal(t, "Action", r.Releases[0].Genre)
+	// Long enough to need overflow pages
+	assert.Equal(t, strings.TrimSpace(strings.Repeat("A game. ", 300)), r.Releases[0].Description)
+
+	assert.Equal(t, roms, db.FindByChecksum(rom.MD5, "09fc0431eea5dfa0df635f5af39af0a8"))
+	assert.Equal(t, roms, db.FindByChecksum(rom.CRC32, "A220DF0C"))
+
+	// No release so the title comes from the filename
+	roms = db.FindByChecksum(rom.CRC32, "d527ef9a")
+	if assert.Equal(t, 1, len(roms)) {
+		assert.Equal(t, "Game 0 (USA)", roms[0].Title())
+		assert.Equal(t, "Nintendo Entertainment System", roms[0].System)
+	}
+
+	assert.Equal(t, 0, len(db.FindByChecksum(rom.SHA1, "0000000000000000000000000000000000000000")))
+
+	_, err = Open("openvgdb.go")
+	assert.Equal(t, errNotSQLite, err)
+}
@@ -0,0 +1,381 @@
+package openvgdb
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+)
+
+// Just enough of the SQLite file format to read every row of a table, see
+// https://www.sqlite.org/fileformat.html
+
+const (
+	sqliteMagic      = "SQLite format 3\x00"
+	sqliteHeaderSize = 100
+
+	pageInteriorTable = 0x05
+	pageLeafTable     = 0x0d
+)
+
+var (
+	errNotSQLite   = errors.New("not a SQLite database")
+	errEncoding    = errors.New("only UTF-8 SQLite databases are supported")
+	errCorrupt     = errors.New("corrupt SQLite database")
+	errNoSuchTable = errors.New("no such table")
+)
+
+type sqliteDB struct {
+	f        *os.File
+	pageSize int
+	usable   int
+	pages    uint32
+}
+
+func openSQLite(name string) (*sqliteDB, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	b := make([]byte, sqliteHeaderSize)
+	if _, err := io.ReadFull(f, b); err != nil {
+		f.Close()
+		return nil, errNotSQLite
+	}
+	if !bytes.HasPrefix(b, []byte(sqliteMagic)) {
+		f.Close()
+		return nil, errNotSQLite
+	}
+	if binary.BigEndian.Uint32(b[56:]) > 1 {
+		f.Close()
+		return nil, errEncoding
+	}
+
+	db := &sqliteDB{
+		f:        f,
+		pageSize: int(binary.BigEndian.Uint16(b[16:])),
+		pages:    binary.BigEndian.Uint32(b[28:]),
+	}
+	if db.pageSize == 1 {
+		db.pageSize = 1 << 16
+	}
+	db.usable = db.pageSize - int(b[20])
+
+	// The page count in the header is only valid if written by a recent
+	// enough version, otherwise use the file size
+	if fi, err := f.Stat(); err == nil && (db.pages == 0 || binary.BigEndian.Uint32(b[24:]) != binary.BigEndian.Uint32(b[92:])) {
+		db.pages = uint32(fi.Size() / int64(db.pageSize))
+	}
+
+	return db, nil
+}
+
+func (db *sqliteDB) Close() error {
+	return db.f.Close()
+}
+
+func (db *sqliteDB) page(n uint32) ([]byte, error) {
+	if n == 0 || n > db.pages {
+		return nil, errCorrupt
+	}
+	b := make([]byte, db.pageSize)
+	if _, err := db.f.ReadAt(b, int64(n-1)*int64(db.pageSize)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// varint decodes the variable length integer at the start of b, returning
+// it and its length
+func varint(b []byte) (int64, int) {
+	var v uint64
+	for i := 0; i < 9 && i < len(b); i++ {
+		if i == 8 {
+			return int64(v<<8 | uint64(b[i])), 9
+		}
+		v = v<<7 | uint64(b[i]&0x7f)
+		if b[i] < 0x80 {
+			return int64(v), i + 1
+		}
+	}
+	return 0, 0
+}
+
+// walk calls fn with the rowid and payload of every row in the table
+// b-tree rooted at page root
+func (db *sqliteDB) walk(root uint32, fn func(int64, []byte) error) error {
+	b, err := db.page(root)
+	if err != nil {
+		return err
+	}
+
+	// Page 1 also has the database header
+	offset := 0
+	if root == 1 {
+		offset = sqliteHeaderSize
+	}
+	header := b[offset:]
+
+	cells := int(binary.BigEndian.Uint16(header[3:]))
+	pointers := header[8:]
+	if header[0] == pageInteriorTable {
+		pointers = header[12:]
+	}
+	if len(pointers) < cells*2 {
+		return errCorrupt
+	}
+
+	for i := 0; i < cells; i++ {
+		cell := int(binary.BigEndian.Uint16(pointers[i*2:]))
+		if cell >= len(b) {
+			return errCorrupt
+		}
+
+		switch header[0] {
+		case pageInteriorTable:
+			if cell+4 > len(b) {
+				return errCorrupt
+			}
+			if err := db.walk(binary.BigEndian.Uint32(b[cell:]), fn); err != nil {
+				return err
+			}
+		case pageLeafTable:
+			rowid, payload, err := db.cell(b[cell:])
+			if err != nil {
+				return err
+			}
+			if err := fn(rowid, payload); err != nil {
+				return err
+			}
+		default:
+			return errCorrupt
+		}
+	}
+
+	if header[0] == pageInteriorTable {
+		return db.walk(binary.BigEndian.Uint32(header[8:]), fn)
+	}
+
+	return nil
+}
+
+// cell returns the rowid and complete payload of a table leaf cell,
+// following any overflow pages
+func (db *sqliteDB) cell(b []byte) (int64, []byte, error) {
+	size, n := varint(b)
+	if n == 0 || size < 0 {
+		return 0, nil, errCorrupt
+	}
+	b = b[n:]
+
+	rowid, n := varint(b)
+	if n == 0 {
+		return 0, nil, errCorrupt
+	}
+	b = b[n:]
+
+	local := int(size)
+	if maxLocal := db.usable - 35; local > maxLocal {
+		minLocal := (db.usable-12)*32/255 - 23
+		local = minLocal + (int(size)-minLocal)%(db.usable-4)
+		if local > maxLocal {
+			local = minLocal
+		}
+	}
+	if local > len(b) {
+		return 0, nil, errCorrupt
+	}
+
+	payload := make([]byte, 0, size)
+	payload = append(payload, b[:local]...)
+	if local == int(size) {
+		return rowid, payload, nil
+	}
+
+	if len(b) < local+4 {
+		return 0, nil, errCorrupt
+	}
+	for next := binary.BigEndian.Uint32(b[local:]); next != 0 && len(payload) < int(size); {
+		page, err := db.page(next)
+		if err != nil {
+			return 0, nil, err
+		}
+		next = binary.BigEndian.Uint32(page)
+
+		remaining := int(size) - len(payload)
+		if remaining > db.usable-4 {
+			remaining = db.usable - 4
+		}
+		payload = append(payload, page[4:4+remaining]...)
+	}
+	if len(payload) != int(size) {
+		return 0, nil, errCorrupt
+	}
+
+	return rowid, payload, nil
+}
+
+// record decodes the columns of a record, leaving integers as int64, reals
+// as float64, text as string, blobs as []byte and NULL as nil
+func record(b []byte) ([]interface{}, error) {
+	size, n := varint(b)
+	if n == 0 || size > int64(len(b)) || size < int64(n) {
+		return nil, errCorrupt
+	}
+
+	header, body := b[n:size], b[size:]
+
+	var values []interface{}
+	for len(header) > 0 {
+		t, n := varint(header)
+		if n == 0 {
+			return nil, errCorrupt
+		}
+		header = header[n:]
+
+		var length int
+		switch {
+		case t >= 12:
+			length = int(t-12) / 2
+		case t >= 1 && t <= 4:
+			length = int(t)
+		case t == 5:
+			length = 6
+		case t == 6 || t == 7:
+			length = 8
+		}
+		if length > len(body) {
+			return nil, errCorrupt
+		}
+		v := body[:length]
+		body = body[length:]
+
+		switch {
+		case t == 0:
+			values = append(values, nil)
+		case t >= 1 && t <= 6:
+			// Sign-extend the big-endian integer
+			i := int64(int8(v[0]))
+			for _, c := range v[1:] {
+				i = i<<8 | int64(c)
+			}
+			values = append(values, i)
+		case t == 7:
+			values = append(values, math.Float64frombits(binary.BigEndian.Uint64(v)))
+		case t == 8 || t == 9:
+			values = append(values, t-8)
+		case t >= 12 && t%2 == 0:
+			values = append(values, append([]byte{}, v...))
+		case t >= 13:
+			values = append(values, string(v))
+		default:
+			return nil, errCorrupt
+		}
+	}
+
+	return values, nil
+}
+
+// columns returns the names of the columns in a CREATE TABLE statement
+// and which one, if any, is an alias for the rowid
+func columns(sql string) ([]string, int) {
+	start, end := strings.IndexByte(sql, '('), strings.LastIndexByte(sql, ')')
+	if start < 0 || end < start {
+		return nil, -1
+	}
+
+	var defs []string
+	depth, last := 0, start+1
+	for i := start + 1; i < end; i++ {
+		switch sql[i] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				defs = append(defs, sql[last:i])
+				last = i + 1
+			}
+		}
+	}
+	defs = append(defs, sql[last:end])
+
+	var names []string
+	rowid := -1
+	for _, def := range defs {
+		fields := strings.Fields(def)
+		if len(fields) == 0 {
+			continue
+		}
+		switch strings.ToUpper(fields[0]) {
+		case "CONSTRAINT", "PRIMARY", "UNIQUE", "CHECK", "FOREIGN":
+			continue
+		}
+		if strings.Contains(strings.ToUpper(strings.Join(fields[1:], " ")), "INTEGER PRIMARY KEY") {
+			rowid = len(names)
+		}
+		names = append(names, strings.Trim(fields[0], "\"`[]'"))
+	}
+
+	return names, rowid
+}
+
+// table calls fn with every row in the named table, keyed by column name
+func (db *sqliteDB) table(name string, fn func(map[string]interface{}) error) error {
+	var root int64
+	var sql string
+	err := db.walk(1, func(_ int64, payload []byte) error {
+		values, err := record(payload)
+		if err != nil {
+			return err
+		}
+		if len(values) < 5 {
+			return errCorrupt
+		}
+		if t, _ := values[0].(string); t != "table" {
+			return nil
+		}
+		if n, _ := values[1].(string); !strings.EqualFold(n, name) {
+			return nil
+		}
+		root, _ = values[3].(int64)
+		sql, _ = values[4].(string)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if root == 0 {
+		return fmt.Errorf("%s: %w", name, errNoSuchTable)
+	}
+
+	names, rowid := columns(sql)
+
+	return db.walk(uint32(root), func(id int64, payload []byte) error {
+		values, err := record(payload)
+		if err != nil {
+			return err
+		}
+
+		row := make(map[string]interface{}, len(names))
+		for i, n := range names {
+			switch {
+			case i == rowid:
+				row[n] = id
+			case i < len(values):
+				row[n] = values[i]
+			default:
+				// Columns added later are missing from older rows
+				row[n] = nil
+			}
+		}
+
+		return fn(row)
+	})
+}
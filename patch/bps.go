@@ -0,0 +1,123 @@
+package patch
+
+import (
+	"bytes"
+	"hash/crc32"
+)
+
+const (
+	bpsSourceRead = iota
+	bpsTargetRead
+	bpsSourceCopy
+	bpsTargetCopy
+)
+
+// ApplyBPS applies the BPS patch to source and returns the result. Both
+// source and the result must match the checksums recorded in the patch
+func ApplyBPS(source, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, bpsMagic) {
+		return nil, ErrUnknownFormat
+	}
+
+	if err := checkFooter(patch); err != nil {
+		return nil, err
+	}
+
+	sourceCRC, targetCRC, _ := footer(patch)
+	if crc32.ChecksumIEEE(source) != sourceCRC {
+		return nil, ErrSourceChecksum
+	}
+
+	r := reader{b: patch, offset: len(bpsMagic), end: len(patch) - footerLength}
+
+	sourceSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if sourceSize != uint64(len(source)) {
+		return nil, ErrSourceChecksum
+	}
+
+	// Any metadata is ignored
+	metadataSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.bytes(metadataSize); err != nil {
+		return nil, err
+	}
+
+	target := make([]byte, 0, targetSize)
+
+	var sourceOffset, targetOffset int64
+	for !r.done() {
+		data, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		length := data>>2 + 1
+
+		if uint64(len(target))+length > targetSize {
+			return nil, ErrCorrupt
+		}
+
+		switch data & 3 {
+		case bpsSourceRead:
+			start := uint64(len(target))
+			if start+length > sourceSize {
+				return nil, ErrCorrupt
+			}
+			target = append(target, source[start:start+length]...)
+		case bpsTargetRead:
+			b, err := r.bytes(length)
+			if err != nil {
+				return nil, err
+			}
+			target = append(target, b...)
+		case bpsSourceCopy, bpsTargetCopy:
+			d, err := r.varint()
+			if err != nil {
+				return nil, err
+			}
+			relative := int64(d >> 1)
+			if d&1 != 0 {
+				relative = -relative
+			}
+
+			if data&3 == bpsSourceCopy {
+				sourceOffset += relative
+				if sourceOffset < 0 || uint64(sourceOffset)+length > sourceSize {
+					return nil, ErrCorrupt
+				}
+				target = append(target, source[sourceOffset:sourceOffset+int64(length)]...)
+				sourceOffset += int64(length)
+				continue
+			}
+
+			// The copy can overlap what it is writing so it has to
+			// be done one byte at a time
+			targetOffset += relative
+			if targetOffset < 0 || targetOffset >= int64(len(target)) {
+				return nil, ErrCorrupt
+			}
+			for i := uint64(0); i < length; i++ {
+				target = append(target, target[targetOffset])
+				targetOffset++
+			}
+		}
+	}
+
+	if uint64(len(target)) != targetSize {
+		return nil, ErrCorrupt
+	}
+
+	if crc32.ChecksumIEEE(target) != targetCRC {
+		return nil, ErrTargetChecksum
+	}
+
+	return target, nil
+}
@@ -0,0 +1,68 @@
+package patch
+
+import "bytes"
+
+var ipsEOF = []byte("EOF")
+
+// ApplyIPS applies the IPS patch to source and returns the result. The
+// result is extended if the patch writes past the end of source and is
+// truncated if the patch records a final length
+func ApplyIPS(source, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, ipsMagic) {
+		return nil, ErrUnknownFormat
+	}
+
+	target := append([]byte{}, source...)
+
+	write := func(offset int, b []byte) {
+		if n := offset + len(b); n > len(target) {
+			target = append(target, make([]byte, n-len(target))...)
+		}
+		copy(target[offset:], b)
+	}
+
+	for i := len(ipsMagic); ; {
+		if i+3 > len(patch) {
+			return nil, ErrCorrupt
+		}
+
+		// An offset of "EOF" is the last record, as long as it isn't
+		// followed by a size
+		if bytes.Equal(patch[i:i+3], ipsEOF) {
+			switch len(patch) - i - 3 {
+			case 0:
+				return target, nil
+			case 3:
+				n := int(patch[i+3])<<16 | int(patch[i+4])<<8 | int(patch[i+5])
+				if n < len(target) {
+					target = target[:n]
+				}
+				return target, nil
+			}
+		}
+
+		if i+5 > len(patch) {
+			return nil, ErrCorrupt
+		}
+		offset := int(patch[i])<<16 | int(patch[i+1])<<8 | int(patch[i+2])
+		size := int(patch[i+3])<<8 | int(patch[i+4])
+		i += 5
+
+		if size == 0 {
+			// A run of the same byte
+			if i+3 > len(patch) {
+				return nil, ErrCorrupt
+			}
+			size = int(patch[i])<<8 | int(patch[i+1])
+			write(offset, bytes.Repeat(patch[i+2:i+3], size))
+			i += 3
+			continue
+		}
+
+		if i+size > len(patch) {
+			return nil, ErrCorrupt
+		}
+		write(offset, patch[i:i+size])
+		i += size
+	}
+}
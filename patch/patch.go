@@ -0,0 +1,213 @@
+/*
+Package patch implements applying the IPS, UPS and BPS patch formats
+commonly used to distribute translations and ROM hacks.
+
+UPS and BPS patches record the CRC32 of both the ROM they apply to and the
+ROM they produce, so applying one also checks the source is the expected
+dump and the result is correct. IPS patches have no such checksums.
+*/
+package patch
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+)
+
+// Format is the type of a patch
+type Format int
+
+const (
+	// IPS is the International Patching System format
+	IPS Format = iota + 1
+	// UPS is the Universal Patching System format
+	UPS
+	// BPS is the Binary Patching System format
+	BPS
+)
+
+func (f Format) String() string {
+	switch f {
+	case IPS:
+		return "IPS"
+	case UPS:
+		return "UPS"
+	case BPS:
+		return "BPS"
+	}
+	return "unknown"
+}
+
+var (
+	// ErrUnknownFormat is returned if the patch isn't in a supported
+	// format
+	ErrUnknownFormat = errors.New("unknown patch format")
+	// ErrCorrupt is returned if the patch is truncated or malformed
+	ErrCorrupt = errors.New("corrupt patch")
+	// ErrPatchChecksum is returned if the checksum of the patch itself
+	// doesn't match
+	ErrPatchChecksum = errors.New("patch checksum mismatch")
+	// ErrSourceChecksum is returned if the ROM being patched isn't the
+	// one the patch was made for
+	ErrSourceChecksum = errors.New("source checksum mismatch")
+	// ErrTargetChecksum is returned if the patched ROM doesn't have the
+	// expected checksum
+	ErrTargetChecksum = errors.New("target checksum mismatch")
+)
+
+var (
+	ipsMagic = []byte("PATCH")
+	upsMagic = []byte("UPS1")
+	bpsMagic = []byte("BPS1")
+)
+
+// footerLength is the length of the source, target and patch CRC32 values
+// at the end of a UPS or BPS patch
+const footerLength = 12
+
+// Info describes a patch
+type Info struct {
+	Format Format
+	// SourceSize and TargetSize are the sizes of the ROM the patch
+	// applies to and the ROM it produces. They are zero for IPS
+	// patches
+	SourceSize uint64
+	TargetSize uint64
+	// SourceCRC32 and TargetCRC32 are the checksums of the ROM the
+	// patch applies to and the ROM it produces. They are zero for IPS
+	// patches
+	SourceCRC32 uint32
+	TargetCRC32 uint32
+}
+
+// Detect returns the format of patch
+func Detect(patch []byte) (Format, error) {
+	switch {
+	case bytes.HasPrefix(patch, ipsMagic):
+		return IPS, nil
+	case bytes.HasPrefix(patch, upsMagic):
+		return UPS, nil
+	case bytes.HasPrefix(patch, bpsMagic):
+		return BPS, nil
+	}
+	return 0, ErrUnknownFormat
+}
+
+// Describe returns the Info for patch. The checksum of a UPS or BPS patch
+// is verified
+func Describe(patch []byte) (Info, error) {
+	format, err := Detect(patch)
+	if err != nil {
+		return Info{}, err
+	}
+
+	info := Info{Format: format}
+	if format == IPS {
+		return info, nil
+	}
+
+	if err := checkFooter(patch); err != nil {
+		return Info{}, err
+	}
+
+	r := reader{b: patch, offset: len(upsMagic), end: len(patch) - footerLength}
+	if info.SourceSize, err = r.varint(); err != nil {
+		return Info{}, err
+	}
+	if info.TargetSize, err = r.varint(); err != nil {
+		return Info{}, err
+	}
+
+	info.SourceCRC32, info.TargetCRC32, _ = footer(patch)
+
+	return info, nil
+}
+
+// Apply applies patch to source and returns the result, detecting the
+// format of the patch
+func Apply(source, patch []byte) ([]byte, error) {
+	format, err := Detect(patch)
+	if err != nil {
+		return nil, err
+	}
+
+	switch format {
+	case IPS:
+		return ApplyIPS(source, patch)
+	case UPS:
+		return ApplyUPS(source, patch)
+	default:
+		return ApplyBPS(source, patch)
+	}
+}
+
+// footer returns the source, target and patch CRC32 values from the end of
+// a UPS or BPS patch
+func footer(patch []byte) (uint32, uint32, uint32) {
+	f := patch[len(patch)-footerLength:]
+	return binary.LittleEndian.Uint32(f), binary.LittleEndian.Uint32(f[4:]), binary.LittleEndian.Uint32(f[8:])
+}
+
+// checkFooter makes sure a UPS or BPS patch is long enough and its own
+// checksum matches
+func checkFooter(patch []byte) error {
+	if len(patch) < len(upsMagic)+footerLength {
+		return ErrCorrupt
+	}
+
+	if _, _, c := footer(patch); crc32.ChecksumIEEE(patch[:len(patch)-4]) != c {
+		return ErrPatchChecksum
+	}
+
+	return nil
+}
+
+// reader reads the variable length integers and bytes used by UPS and BPS
+// patches
+type reader struct {
+	b      []byte
+	offset int
+	end    int
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.offset >= r.end {
+		return 0, ErrCorrupt
+	}
+	b := r.b[r.offset]
+	r.offset++
+	return b, nil
+}
+
+func (r *reader) varint() (uint64, error) {
+	var data, shift uint64 = 0, 1
+	for {
+		x, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		data += uint64(x&0x7f) * shift
+		if x&0x80 != 0 {
+			return data, nil
+		}
+		shift <<= 7
+		data += shift
+		if shift > 1<<56 {
+			return 0, ErrCorrupt
+		}
+	}
+}
+
+func (r *reader) bytes(n uint64) ([]byte, error) {
+	if uint64(r.end-r.offset) < n {
+		return nil, ErrCorrupt
+	}
+	b := r.b[r.offset : r.offset+int(n)]
+	r.offset += int(n)
+	return b, nil
+}
+
+func (r *reader) done() bool {
+	return r.offset >= r.end
+}
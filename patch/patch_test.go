@@ -0,0 +1,211 @@
+package patch
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func encodeVarint(v uint64) []byte {
+	var b []byte
+	for {
+		x := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, 0x80|x)
+		}
+		b = append(b, x)
+		v--
+	}
+}
+
+// withFooter appends the source, target and patch checksums to patch
+func withFooter(patch, source, target []byte) []byte {
+	patch = append(patch, make([]byte, 8)...)
+	binary.LittleEndian.PutUint32(patch[len(patch)-8:], crc32.ChecksumIEEE(source))
+	binary.LittleEndian.PutUint32(patch[len(patch)-4:], crc32.ChecksumIEEE(target))
+	crc := make([]byte, 4)
+	binary.LittleEndian.PutUint32(crc, crc32.ChecksumIEEE(patch))
+	return append(patch, crc...)
+}
+
+func join(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+var (
+	testSource = []byte("abcdefgh")
+	testTarget = []byte("abXdefghij")
+)
+
+func testUPS() []byte {
+	// Skip 2, XOR 'c' to 'X' then end the hunk, skip to the end of the
+	// source and write the two new bytes
+	return withFooter(join(
+		upsMagic,
+		encodeVarint(uint64(len(testSource))),
+		encodeVarint(uint64(len(testTarget))),
+		encodeVarint(2), []byte{'c' ^ 'X', 0},
+		encodeVarint(4), []byte{'i', 'j', 0},
+	), testSource, testTarget)
+}
+
+func testBPS() []byte {
+	return withFooter(join(
+		bpsMagic,
+		encodeVarint(uint64(len(testSource))),
+		encodeVarint(uint64(len(testTarget))),
+		encodeVarint(4), []byte("meta"),
+		encodeVarint((2-1)<<2|bpsSourceRead),
+		encodeVarint((1-1)<<2|bpsTargetRead), []byte("X"),
+		encodeVarint((5-1)<<2|bpsSourceCopy), encodeVarint(3<<1),
+		encodeVarint((2-1)<<2|bpsTargetRead), []byte("ij"),
+	), testSource, testTarget)
+}
+
+func TestVarint(t *testing.T) {
+	for _, v := range []uint64{0, 1, 127, 128, 255, 16383, 16384, 1 << 32} {
+		r := reader{b: encodeVarint(v)}
+		r.end = len(r.b)
+		got, err := r.varint()
+		assert.Nil(t, err)
+		assert.Equal(t, v, got)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tables := map[string]struct {
+		patch  []byte
+		format Format
+		err    error
+	}{
+		"IPS":     {[]byte("PATCHEOF"), IPS, nil},
+		"UPS":     {testUPS(), UPS, nil},
+		"BPS":     {testBPS(), BPS, nil},
+		"unknown": {[]byte("nothing"), 0, ErrUnknownFormat},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			format, err := Detect(table.patch)
+			assert.Equal(t, table.err, err)
+			assert.Equal(t, table.format, format)
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	info, err := Describe(testBPS())
+	assert.Nil(t, err)
+	assert.Equal(t, Info{BPS, 8, 10, crc32.ChecksumIEEE(testSource), crc32.ChecksumIEEE(testTarget)}, info)
+
+	info, err = Describe([]byte("PATCHEOF"))
+	assert.Nil(t, err)
+	assert.Equal(t, Info{Format: IPS}, info)
+}
+
+func TestApply(t *testing.T) {
+	corrupt := testBPS()
+	corrupt[len(corrupt)-1] ^= 0xff
+
+	tables := map[string]struct {
+		source []byte
+		patch  []byte
+		want   []byte
+		err    error
+	}{
+		"IPS": {
+			testSource,
+			join(ipsMagic, []byte{0, 0, 2, 0, 1}, []byte("X"), []byte{0, 0, 8, 0, 2}, []byte("ij"), ipsEOF),
+			testTarget,
+			nil,
+		},
+		"IPS RLE": {
+			testSource,
+			join(ipsMagic, []byte{0, 0, 1, 0, 0, 0, 3, 'z'}, ipsEOF),
+			[]byte("azzzefgh"),
+			nil,
+		},
+		"IPS truncate": {
+			testSource,
+			join(ipsMagic, ipsEOF, []byte{0, 0, 4}),
+			[]byte("abcd"),
+			nil,
+		},
+		"IPS truncated patch": {
+			testSource,
+			join(ipsMagic, []byte{0, 0, 2, 0, 4}, []byte("X")),
+			nil,
+			ErrCorrupt,
+		},
+		"UPS": {
+			testSource,
+			testUPS(),
+			testTarget,
+			nil,
+		},
+		"UPS wrong source": {
+			[]byte("abcdefgz"),
+			testUPS(),
+			nil,
+			ErrSourceChecksum,
+		},
+		"BPS": {
+			testSource,
+			testBPS(),
+			testTarget,
+			nil,
+		},
+		"BPS wrong source": {
+			[]byte("abcdefgz"),
+			testBPS(),
+			nil,
+			ErrSourceChecksum,
+		},
+		"BPS patch checksum": {
+			testSource,
+			corrupt,
+			nil,
+			ErrPatchChecksum,
+		},
+		"BPS wrong target": {
+			testSource,
+			withFooter(join(
+				bpsMagic,
+				encodeVarint(8),
+				encodeVarint(2),
+				encodeVarint(0),
+				encodeVarint((2-1)<<2|bpsTargetRead), []byte("ab"),
+			), testSource, []byte("xx")),
+			nil,
+			ErrTargetChecksum,
+		},
+		"BPS target copy": {
+			testSource,
+			withFooter(join(
+				bpsMagic,
+				encodeVarint(8),
+				encodeVarint(6),
+				encodeVarint(0),
+				encodeVarint((1-1)<<2|bpsTargetRead), []byte("a"),
+				encodeVarint((5-1)<<2|bpsTargetCopy), encodeVarint(0),
+			), testSource, []byte("aaaaaa")),
+			[]byte("aaaaaa"),
+			nil,
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			got, err := Apply(table.source, table.patch)
+			assert.Equal(t, table.err, err)
+			assert.Equal(t, table.want, got)
+		})
+	}
+}
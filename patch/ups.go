@@ -0,0 +1,75 @@
+package patch
+
+import (
+	"bytes"
+	"hash/crc32"
+)
+
+// ApplyUPS applies the UPS patch to source and returns the result. Both
+// source and the result must match the checksums recorded in the patch
+func ApplyUPS(source, patch []byte) ([]byte, error) {
+	if !bytes.HasPrefix(patch, upsMagic) {
+		return nil, ErrUnknownFormat
+	}
+
+	if err := checkFooter(patch); err != nil {
+		return nil, err
+	}
+
+	sourceCRC, targetCRC, _ := footer(patch)
+	if crc32.ChecksumIEEE(source) != sourceCRC {
+		return nil, ErrSourceChecksum
+	}
+
+	r := reader{b: patch, offset: len(upsMagic), end: len(patch) - footerLength}
+
+	sourceSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	targetSize, err := r.varint()
+	if err != nil {
+		return nil, err
+	}
+	if sourceSize != uint64(len(source)) {
+		return nil, ErrSourceChecksum
+	}
+
+	target := make([]byte, targetSize)
+	copy(target, source)
+
+	// Each hunk skips some unchanged bytes and then XORs bytes until a
+	// zero byte, which marks the end of the hunk
+	var offset uint64
+	for !r.done() {
+		skip, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		offset += skip
+
+		for {
+			x, err := r.byte()
+			if err != nil {
+				return nil, err
+			}
+			if offset < targetSize {
+				var b byte
+				if offset < sourceSize {
+					b = source[offset]
+				}
+				target[offset] = b ^ x
+			}
+			offset++
+			if x == 0 {
+				break
+			}
+		}
+	}
+
+	if crc32.ChecksumIEEE(target) != targetCRC {
+		return nil, ErrTargetChecksum
+	}
+
+	return target, nil
+}
@@ -0,0 +1,244 @@
+package rom
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	rcloneScheme      = "rclone://"
+	rcloneDefaultAddr = "http://localhost:5572"
+)
+
+var errRcloneRemote = errors.New("no remote in rclone URL")
+
+func init() {
+	RegisterScheme("rclone", OpenRclone)
+	RegisterLister("rclone", ListRclone)
+	RegisterPutter("rclone", PutRclone, DeleteRclone)
+}
+
+// rcloneConfig is where to find the rclone remote control API, using the
+// same environment variables as rclone's own flags
+type rcloneConfig struct {
+	addr     string
+	user     string
+	password string
+}
+
+func rcloneConfigFromEnv() rcloneConfig {
+	c := rcloneConfig{
+		addr:     getenv("RCLONE_RC_ADDR"),
+		user:     getenv("RCLONE_RC_USER"),
+		password: getenv("RCLONE_RC_PASS"),
+	}
+	if c.addr == "" {
+		c.addr = rcloneDefaultAddr
+	}
+	if !strings.Contains(c.addr, "://") {
+		c.addr = "http://" + c.addr
+	}
+	c.addr = strings.TrimSuffix(c.addr, "/")
+	return c
+}
+
+func (c rcloneConfig) sign(req *http.Request) {
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+}
+
+// splitRclone splits an rclone://remote/path URL into the name of the
+// rclone remote and the unescaped path within it
+func splitRclone(name string) (string, string, error) {
+	if !strings.HasPrefix(strings.ToLower(name), rcloneScheme) {
+		return "", "", fmt.Errorf("%s: not an rclone URL", name)
+	}
+	remote, p := name[len(rcloneScheme):], ""
+	if i := strings.IndexByte(remote, '/'); i >= 0 {
+		remote, p = remote[:i], remote[i+1:]
+	}
+	if remote == "" {
+		return "", "", fmt.Errorf("%s: %w", name, errRcloneRemote)
+	}
+	p, err := url.PathUnescape(p)
+	if err != nil {
+		return "", "", fmt.Errorf("%s: %w", name, err)
+	}
+	return remote, p, nil
+}
+
+// call makes a request to the remote control API, decoding any response
+// into out
+func (c rcloneConfig) call(ctx context.Context, method string, in, out interface{}) error {
+	b, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.addr+"/"+method, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.sign(req)
+
+	return c.do(req, out)
+}
+
+func (c rcloneConfig) do(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var e struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&e); err != nil || e.Error == "" {
+			e.Error = resp.Status
+		}
+		if resp.StatusCode == http.StatusNotFound {
+			return fmt.Errorf("rclone: %s: %w", e.Error, os.ErrNotExist)
+		}
+		return fmt.Errorf("rclone: %s", e.Error)
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// OpenRclone returns a RemoteFile for an rclone://remote/path URL, where
+// remote is configured in a running "rclone rcd --rc-serve". Its address
+// and credentials are taken from RCLONE_RC_ADDR, RCLONE_RC_USER and
+// RCLONE_RC_PASS, which gives access to any storage rclone supports
+func OpenRclone(ctx context.Context, name string) (RemoteFile, error) {
+	remote, p, err := splitRclone(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c := rcloneConfigFromEnv()
+
+	return openHTTP(ctx, c.addr+"/["+url.PathEscape(remote)+":]/"+s3Escape(p, true), c.sign)
+}
+
+// ListRclone returns the rclone:// URL of every file beneath the path in
+// name, skipping anything hidden
+func ListRclone(ctx context.Context, name string) ([]string, error) {
+	remote, p, err := splitRclone(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var out struct {
+		List []struct {
+			Path  string
+			IsDir bool
+		} `json:"list"`
+	}
+	if err := rcloneConfigFromEnv().call(ctx, "operations/list", map[string]interface{}{
+		"fs":     remote + ":",
+		"remote": strings.TrimSuffix(p, "/"),
+		"opt": map[string]interface{}{
+			"recurse":   true,
+			"filesOnly": true,
+		},
+	}, &out); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	files := make([]string, 0, len(out.List))
+	for _, item := range out.List {
+		if item.IsDir || strings.HasPrefix(item.Path, ".") || strings.Contains(item.Path, "/.") {
+			continue
+		}
+		u := url.URL{Path: path.Join(p, item.Path)}
+		files = append(files, rcloneScheme+remote+"/"+u.EscapedPath())
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// PutRclone uploads size bytes from r to the rclone://remote/path URL
+// name, creating any directories needed
+func PutRclone(ctx context.Context, name string, r io.Reader, size int64) error {
+	remote, p, err := splitRclone(name)
+	if err != nil {
+		return err
+	}
+
+	c := rcloneConfigFromEnv()
+
+	dir := path.Dir(p)
+	if dir == "." {
+		dir = ""
+	}
+
+	query := url.Values{}
+	query.Set("fs", remote+":")
+	query.Set("remote", dir)
+
+	pr, pw := io.Pipe()
+	mw := multipart.NewWriter(pw)
+	go func() {
+		part, err := mw.CreateFormFile("file", path.Base(p))
+		if err == nil {
+			_, err = io.CopyN(part, r, size)
+		}
+		if err == nil {
+			err = mw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.addr+"/operations/uploadfile?"+query.Encode(), pr)
+	if err != nil {
+		pr.Close()
+		return err
+	}
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	c.sign(req)
+
+	if err := c.do(req, nil); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
+
+// DeleteRclone removes the file at the rclone://remote/path URL name
+func DeleteRclone(ctx context.Context, name string) error {
+	remote, p, err := splitRclone(name)
+	if err != nil {
+		return err
+	}
+
+	if err := rcloneConfigFromEnv().call(ctx, "operations/deletefile", map[string]string{
+		"fs":     remote + ":",
+		"remote": p,
+	}, nil); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package rom
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitRclone(t *testing.T) {
+	tables := map[string]struct {
+		name   string
+		remote string
+		path   string
+		err    bool
+	}{
+		"path":      {"rclone://remote/dir/file.zip", "remote", "dir/file.zip", false},
+		"remote":    {"rclone://remote", "remote", "", false},
+		"escaped":   {"rclone://remote/Game%20%28USA%29.zip", "remote", "Game (USA).zip", false},
+		"no remote": {"rclone:///file.zip", "", "", true},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			remote, p, err := splitRclone(table.name)
+			assert.Equal(t, table.err, err != nil)
+			assert.Equal(t, table.remote, remote)
+			assert.Equal(t, table.path, p)
+		})
+	}
+}
+
+func TestRclone(t *testing.T) {
+	fs := http.FileServer(http.Dir("testdata"))
+	uploaded := make(map[string]string)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/operations/list":
+			var in struct {
+				FS     string `json:"fs"`
+				Remote string `json:"remote"`
+			}
+			assert.Equal(t, nil, json.NewDecoder(r.Body).Decode(&in))
+			assert.Equal(t, "test:", in.FS)
+			assert.Equal(t, "test", in.Remote)
+			fmt.Fprint(w, `{"list":[{"Path":"test.nes","IsDir":false},{"Path":".hidden","IsDir":false},{"Path":"test.bin","IsDir":false}]}`)
+		case "/operations/uploadfile":
+			f, fh, err := r.FormFile("file")
+			assert.Equal(t, nil, err)
+			b, _ := io.ReadAll(f)
+			uploaded[path.Join(r.URL.Query().Get("remote"), fh.Filename)] = string(b)
+			fmt.Fprint(w, `{}`)
+		case "/operations/deletefile":
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"error":"object not found","status":404}`)
+		default:
+			r.URL.Path = strings.TrimPrefix(r.URL.Path, "/[test:]")
+			fs.ServeHTTP(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	t.Setenv("RCLONE_RC_ADDR", strings.TrimPrefix(ts.URL, "http://"))
+	t.Setenv("RCLONE_RC_USER", "user")
+	t.Setenv("RCLONE_RC_PASS", "secret")
+
+	files, err := List("rclone://test/test/")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"rclone://test/test/test.bin", "rclone://test/test/test.nes"}, files)
+
+	r, err := NewReader("rclone://test/test.zip")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "*rom.ZipReader", fmt.Sprintf("%T", r))
+
+	checksum, err := r.Checksum("test.bin", SHA1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte{0x4e, 0xbc, 0x20, 0xb4, 0x6e, 0xa4, 0xd0, 0x10, 0xed, 0x9a, 0xc1, 0xfd, 0xe4, 0xc2, 0x51, 0xcf, 0x23, 0x1a, 0x66, 0x1f}, checksum)
+	assert.Equal(t, nil, r.Close())
+
+	assert.Equal(t, nil, Put("rclone://test/dir/test.bin", strings.NewReader("test"), 4))
+	assert.Equal(t, map[string]string{"dir/test.bin": "test"}, uploaded)
+
+	assert.True(t, errors.Is(Delete("rclone://test/dir/missing.bin"), os.ErrNotExist))
+}
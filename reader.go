@@ -2,6 +2,7 @@ package rom
 
 import (
 	"archive/zip"
+	"context"
 	"errors"
 	"fmt"
 	"hash/crc32"
@@ -57,6 +58,16 @@ var (
 // NewReader uses heuristics to work out the type of file passed and uses
 // the most appropriate Reader to access it
 func NewReader(path string) (Reader, error) {
+	return NewReaderContext(context.Background(), path)
+}
+
+// NewReaderContext is like NewReader but a remote file is opened and read
+// using ctx, so cancelling it stops any request in progress
+func NewReaderContext(ctx context.Context, path string) (Reader, error) {
+	if open, ok := remoteOpener(path); ok {
+		return newRemoteReader(ctx, path, open)
+	}
+
 	info, err := os.Stat(path)
 	if err != nil {
 		return nil, err
@@ -94,6 +105,7 @@ type FileReader struct {
 	directory string
 	filename  string
 	size      uint64
+	remote    archiveFile
 	rx        plumbing.WriteCounter
 }
 
@@ -143,6 +155,9 @@ func (r *FileReader) Checksum(filename string, checksum Checksum) ([]byte, error
 // Close closes access to the underlying file. Any other methods are not
 // guaranteed to work after this has been called
 func (r *FileReader) Close() error {
+	if r.remote != nil {
+		return r.remote.Close()
+	}
 	return nil
 }
 
@@ -153,6 +168,9 @@ func (r *FileReader) Files() []string {
 
 // Name returns the full path to the underlying file
 func (r *FileReader) Name() string {
+	if r.remote != nil {
+		return r.remote.Name()
+	}
 	return filepath.Join(r.directory, r.filename)
 }
 
@@ -161,6 +179,9 @@ func (r *FileReader) Open(filename string) (io.ReadCloser, error) {
 	if filename != r.filename {
 		return nil, errFileNotFound
 	}
+	if r.remote != nil {
+		return plumbing.TeeReadCloser(io.NopCloser(io.NewSectionReader(r.remote, 0, int64(r.size))), &r.rx), nil
+	}
 	file, err := os.Open(filepath.Join(r.directory, filename))
 	if err != nil {
 		return nil, err
@@ -337,41 +358,65 @@ func (r *DirectoryReader) Size(filename string) (uint64, uint64, error) {
 	return size, hs, nil
 }
 
+// archiveFile is the underlying file of an archive, either an *os.File or a
+// RemoteFile
+type archiveFile interface {
+	io.ReaderAt
+	io.Closer
+	Name() string
+}
+
 // ZipReader reads a zip archive and provides access to any regular files
 // contained within. Hidden files, directories and any files not in the
 // top level are inaccessible
 type ZipReader struct {
 	checksums map[string][][]byte
-	file      *os.File
+	file      archiveFile
 	reader    *zip.Reader
 	files     map[string]*zip.File
 	rx        plumbing.WriteCounter
 }
 
 // NewZipReader returns a new ZipReader for the passed zip archive
-func NewZipReader(filename string) (r *ZipReader, err error) {
-	r = &ZipReader{
-		checksums: make(map[string][][]byte),
-		files:     make(map[string]*zip.File),
+func NewZipReader(filename string) (*ZipReader, error) {
+	f, size, err := openFile(filename)
+	if err != nil {
+		return nil, err
 	}
 
-	r.file, err = os.Open(filename)
+	r, err := newZipReader(f, size)
 	if err != nil {
-		return
+		f.Close()
+		return nil, err
 	}
-	defer func() {
-		if err != nil {
-			r.file.Close()
-		}
-	}()
 
-	var info os.FileInfo
-	info, err = r.file.Stat()
+	return r, nil
+}
+
+// openFile opens filename and returns it along with its size
+func openFile(filename string) (*os.File, int64, error) {
+	f, err := os.Open(filename)
 	if err != nil {
-		return
+		return nil, 0, err
 	}
 
-	r.reader, err = zip.NewReader(plumbing.TeeReaderAt(r.file, &r.rx), info.Size())
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, 0, err
+	}
+
+	return f, info.Size(), nil
+}
+
+func newZipReader(f archiveFile, size int64) (r *ZipReader, err error) {
+	r = &ZipReader{
+		checksums: make(map[string][][]byte),
+		file:      f,
+		files:     make(map[string]*zip.File),
+	}
+
+	r.reader, err = zip.NewReader(plumbing.TeeReaderAt(r.file, &r.rx), size)
 	if err != nil {
 		return
 	}
@@ -499,12 +544,18 @@ const (
 // archive. It extends NewZipReader to check that the zip archive has the
 // correctly formatted comment and validates that the CRC of the central
 // directory matches the comment value
-func NewTorrentZipReader(filename string) (r *TorrentZipReader, err error) {
-	r = new(TorrentZipReader)
-
-	r.ZipReader, err = NewZipReader(filename)
+func NewTorrentZipReader(filename string) (*TorrentZipReader, error) {
+	zr, err := NewZipReader(filename)
 	if err != nil {
-		return
+		return nil, err
+	}
+
+	return newTorrentZipReader(zr)
+}
+
+func newTorrentZipReader(zr *ZipReader) (r *TorrentZipReader, err error) {
+	r = &TorrentZipReader{
+		ZipReader: zr,
 	}
 	reader := r.ZipReader.reader
 
@@ -542,36 +593,36 @@ func (r *TorrentZipReader) Valid() bool {
 // the top level are inaccessible
 type SevenZipReader struct {
 	checksums map[string][][]byte
-	file      *os.File
+	file      archiveFile
 	reader    *sevenzip.Reader
 	files     map[string]*sevenzip.File
 	rx        plumbing.WriteCounter
 }
 
 // NewSevenZipReader returns a new SevenZipReader for the passed 7zip archive
-func NewSevenZipReader(filename string) (r *SevenZipReader, err error) {
-	r = &SevenZipReader{
-		checksums: make(map[string][][]byte),
-		files:     make(map[string]*sevenzip.File),
+func NewSevenZipReader(filename string) (*SevenZipReader, error) {
+	f, size, err := openFile(filename)
+	if err != nil {
+		return nil, err
 	}
 
-	r.file, err = os.Open(filename)
+	r, err := newSevenZipReader(f, size)
 	if err != nil {
-		return
+		f.Close()
+		return nil, err
 	}
-	defer func() {
-		if err != nil {
-			r.file.Close()
-		}
-	}()
 
-	var info os.FileInfo
-	info, err = r.file.Stat()
-	if err != nil {
-		return
+	return r, nil
+}
+
+func newSevenZipReader(f archiveFile, size int64) (r *SevenZipReader, err error) {
+	r = &SevenZipReader{
+		checksums: make(map[string][][]byte),
+		file:      f,
+		files:     make(map[string]*sevenzip.File),
 	}
 
-	r.reader, err = sevenzip.NewReader(plumbing.TeeReaderAt(r.file, &r.rx), info.Size())
+	r.reader, err = sevenzip.NewReader(plumbing.TeeReaderAt(r.file, &r.rx), size)
 	if err != nil {
 		return
 	}
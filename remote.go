@@ -0,0 +1,365 @@
+package rom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// RemoteFile is a file somewhere other than the local filesystem that can
+// be read at any offset
+type RemoteFile interface {
+	io.ReaderAt
+	io.Closer
+	// Size returns the size of the file
+	Size() int64
+}
+
+// An Opener returns the RemoteFile for the passed URL. The context applies
+// to opening the file and to every read from it afterwards
+type Opener func(context.Context, string) (RemoteFile, error)
+
+var (
+	errRemoteRar = errors.New("rar archives can't be read remotely")
+
+	errNoLister = errors.New("listing not supported")
+	errNoPutter = errors.New("writing not supported")
+
+	openersMutex sync.RWMutex
+	openers      = make(map[string]Opener)
+	listers      = make(map[string]Lister)
+	putters      = make(map[string]Putter)
+	deleters     = make(map[string]Deleter)
+)
+
+// A Lister returns the URLs of every file beneath the passed URL, which is
+// a directory of some sort
+type Lister func(context.Context, string) ([]string, error)
+
+// RegisterScheme makes NewReader use open for any path that is a URL with
+// the passed scheme, such as "https". Any Opener already registered for the
+// scheme is replaced
+func RegisterScheme(scheme string, open Opener) {
+	openersMutex.Lock()
+	defer openersMutex.Unlock()
+
+	openers[strings.ToLower(scheme)] = open
+}
+
+// RegisterLister makes List use list for any URL with the passed scheme.
+// Any Lister already registered for the scheme is replaced
+func RegisterLister(scheme string, list Lister) {
+	openersMutex.Lock()
+	defer openersMutex.Unlock()
+
+	listers[strings.ToLower(scheme)] = list
+}
+
+// A Putter writes the passed number of bytes from the reader to the passed
+// URL, replacing any file already there
+type Putter func(context.Context, string, io.Reader, int64) error
+
+// A Deleter removes the file at the passed URL
+type Deleter func(context.Context, string) error
+
+// RegisterPutter makes Put and Delete use put and del for any URL with the
+// passed scheme. Anything already registered for the scheme is replaced
+func RegisterPutter(scheme string, put Putter, del Deleter) {
+	openersMutex.Lock()
+	defer openersMutex.Unlock()
+
+	putters[strings.ToLower(scheme)] = put
+	deleters[strings.ToLower(scheme)] = del
+}
+
+func scheme(name string) string {
+	i := strings.Index(name, "://")
+	if i < 1 {
+		return ""
+	}
+	return strings.ToLower(name[:i])
+}
+
+// redact returns name with any password replaced, so it can be shown
+func redact(name string) string {
+	u, err := url.Parse(name)
+	if err != nil {
+		return name
+	}
+	return u.Redacted()
+}
+
+func remoteOpener(name string) (Opener, bool) {
+	openersMutex.RLock()
+	defer openersMutex.RUnlock()
+
+	open, ok := openers[scheme(name)]
+
+	return open, ok
+}
+
+// List returns the URLs of every file beneath the URL name using the Lister
+// registered for its scheme
+func List(name string) ([]string, error) {
+	return ListContext(context.Background(), name)
+}
+
+// ListContext is like List but stops early if ctx is cancelled
+func ListContext(ctx context.Context, name string) ([]string, error) {
+	openersMutex.RLock()
+	list, ok := listers[scheme(name)]
+	openersMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("%s: %w", redact(name), errNoLister)
+	}
+
+	return list(ctx, name)
+}
+
+// Put writes size bytes from r to the URL name using the Putter registered
+// for its scheme
+func Put(name string, r io.Reader, size int64) error {
+	return PutContext(context.Background(), name, r, size)
+}
+
+// PutContext is like Put but stops early if ctx is cancelled
+func PutContext(ctx context.Context, name string, r io.Reader, size int64) error {
+	openersMutex.RLock()
+	put, ok := putters[scheme(name)]
+	openersMutex.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%s: %w", redact(name), errNoPutter)
+	}
+
+	return put(ctx, name, r, size)
+}
+
+// Delete removes the file at the URL name using the Deleter registered for
+// its scheme
+func Delete(name string) error {
+	return DeleteContext(context.Background(), name)
+}
+
+// DeleteContext is like Delete but stops early if ctx is cancelled
+func DeleteContext(ctx context.Context, name string) error {
+	openersMutex.RLock()
+	del, ok := deleters[scheme(name)]
+	openersMutex.RUnlock()
+
+	if !ok {
+		return fmt.Errorf("%s: %w", redact(name), errNoPutter)
+	}
+
+	return del(ctx, name)
+}
+
+// IsWritable returns true if name is a URL with a scheme registered with
+// RegisterPutter
+func IsWritable(name string) bool {
+	openersMutex.RLock()
+	defer openersMutex.RUnlock()
+
+	_, ok := putters[scheme(name)]
+	return ok
+}
+
+// IsRemote returns true if name is a URL with a scheme registered with
+// RegisterScheme
+func IsRemote(name string) bool {
+	_, ok := remoteOpener(name)
+	return ok
+}
+
+type namedFile struct {
+	RemoteFile
+	name string
+}
+
+func (f namedFile) Name() string {
+	return f.name
+}
+
+// newRemoteReader works out what type of file name is from its first few
+// bytes in the same way as for a local file. Directories aren't supported
+func newRemoteReader(ctx context.Context, name string, open Opener) (Reader, error) {
+	rf, err := open(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	f := namedFile{rf, name}
+
+	r, err := func() (Reader, error) {
+		b := make([]byte, 3072)
+		n, err := f.ReadAt(b, 0)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return nil, err
+		}
+
+		switch mimetype.Detect(b[:n]).Extension() {
+		case ".7z":
+			return newSevenZipReader(f, f.Size())
+		case ".rar":
+			return nil, errRemoteRar
+		case ".zip":
+			zr, err := newZipReader(f, f.Size())
+			if err != nil {
+				return nil, err
+			}
+			tzr, err := newTorrentZipReader(zr)
+			if err != ErrNotTorrentZip {
+				return tzr, err
+			}
+			return zr, nil
+		}
+
+		filename := path.Base(name)
+		if u, err := url.Parse(name); err == nil {
+			filename = path.Base(u.Path)
+		}
+
+		return &FileReader{
+			filename: filename,
+			size:     uint64(f.Size()),
+			remote:   f,
+		}, nil
+	}()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// CloseConnections closes any FTP connections and SFTP or SMB sessions kept
+// open for reuse, waiting for the ssh processes used by SFTP to exit. This
+// should be called before exiting
+func CloseConnections() {
+	closeFTP()
+	closeSFTP()
+	closeSMB()
+}
+
+// blockSize is the minimum amount read from a remote file at once so
+// reading sequentially through an archive doesn't need a request for every
+// small read
+const blockSize = 1 << 20
+
+// blockTimeout limits how long reading each block of a remote file can
+// take, including any round trips needed to do so
+const blockTimeout = 5 * time.Minute
+
+// dialTimeout limits how long connecting to a server can take
+const dialTimeout = 30 * time.Second
+
+// dialContext connects to the TCP address, giving up if ctx is cancelled
+func dialContext(ctx context.Context, address string) (net.Conn, error) {
+	return (&net.Dialer{Timeout: dialTimeout}).DialContext(ctx, "tcp", address)
+}
+
+// deadliner is a connection that can have a deadline set for its I/O
+type deadliner interface {
+	SetDeadline(time.Time) error
+}
+
+// watchContext makes I/O on each of conns fail if it hasn't finished
+// within blockTimeout or ctx is cancelled first. The returned function
+// must be called once the I/O is done, it removes the deadline and returns
+// the error to use given err, which is the context's error if that was
+// why. A connection that was interrupted can't be used again
+func watchContext(ctx context.Context, conns ...deadliner) func(error) error {
+	for _, conn := range conns {
+		_ = conn.SetDeadline(time.Now().Add(blockTimeout))
+	}
+
+	stop, done := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(done)
+		select {
+		case <-ctx.Done():
+			for _, conn := range conns {
+				_ = conn.SetDeadline(time.Unix(1, 0))
+			}
+		case <-stop:
+		}
+	}()
+
+	return func(err error) error {
+		close(stop)
+		<-done
+		for _, conn := range conns {
+			_ = conn.SetDeadline(time.Time{})
+		}
+		if err != nil && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+}
+
+// blockFile implements ReadAt and Size for a remote file using read to
+// fetch at least blockSize bytes at a time
+type blockFile struct {
+	read func([]byte, int64) error
+	size int64
+
+	mutex  sync.Mutex
+	offset int64
+	block  []byte
+}
+
+// ReadAt reads len(b) bytes from offset. Anything read is kept so further
+// reads nearby don't need another request
+func (f *blockFile) ReadAt(b []byte, offset int64) (int, error) {
+	if offset >= f.size {
+		return 0, io.EOF
+	}
+
+	n := len(b)
+	if remaining := f.size - offset; int64(n) > remaining {
+		n = int(remaining)
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if offset < f.offset || offset+int64(n) > f.offset+int64(len(f.block)) {
+		size := int64(n)
+		if size < blockSize {
+			size = blockSize
+		}
+		if remaining := f.size - offset; size > remaining {
+			size = remaining
+		}
+
+		block := make([]byte, size)
+		if err := f.read(block, offset); err != nil {
+			return 0, err
+		}
+		f.offset, f.block = offset, block
+	}
+
+	copy(b, f.block[offset-f.offset:])
+
+	if n < len(b) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// Size returns the size of the remote file
+func (f *blockFile) Size() int64 {
+	return f.size
+}
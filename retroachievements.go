@@ -0,0 +1,147 @@
+package rom
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// ErrRAUnsupported is returned by RAHash for any file that isn't for a
+// supported system
+var ErrRAUnsupported = errors.New("no RetroAchievements hash for file")
+
+// See the following for reference:
+//
+// * https://docs.retroachievements.org/developer-docs/game-identification.html
+
+func skipHeader(b []byte, size int, magic []byte, offset int) []byte {
+	if len(b) > size && bytes.HasPrefix(b[offset:], magic) {
+		return b[size:]
+	}
+	return b
+}
+
+// skipCopierHeader strips the header some copiers added if the remainder is
+// a whole number of banks
+func skipCopierHeader(b []byte, bank int) []byte {
+	if len(b)%bank == 512 {
+		return b[512:]
+	}
+	return b
+}
+
+// n64BigEndian converts b to the native big-endian .z64 byte order
+func n64BigEndian(b []byte) []byte {
+	if len(b) < 4 {
+		return b
+	}
+
+	var swap func([]byte)
+	switch {
+	case bytes.HasPrefix(b, []byte{0x37, 0x80, 0x40, 0x12}):
+		// .v64 swaps each pair of bytes
+		swap = func(w []byte) {
+			w[0], w[1], w[2], w[3] = w[1], w[0], w[3], w[2]
+		}
+	case bytes.HasPrefix(b, []byte{0x40, 0x12, 0x37, 0x80}):
+		// .n64 is little-endian
+		swap = func(w []byte) {
+			w[0], w[1], w[2], w[3] = w[3], w[2], w[1], w[0]
+		}
+	default:
+		return b
+	}
+
+	out := make([]byte, len(b))
+	copy(out, b)
+	for i := 0; i+4 <= len(out); i += 4 {
+		swap(out[i : i+4])
+	}
+
+	return out
+}
+
+func plainRA(b []byte) []byte {
+	return b
+}
+
+var extensionToRA = map[string]func([]byte) []byte{
+	// Atari 2600, 5200, Jaguar and Lynx
+	".a26": plainRA,
+	".a52": plainRA,
+	".j64": plainRA,
+	".lnx": func(b []byte) []byte {
+		return skipHeader(b, lynxHeaderSize, []byte("LYNX"), 0)
+	},
+	// Atari 7800
+	".a78": func(b []byte) []byte {
+		return skipHeader(b, 128, []byte("ATARI7800"), 1)
+	},
+	// ColecoVision, Intellivision and Vectrex
+	".col": plainRA,
+	".int": plainRA,
+	".vec": plainRA,
+	// Nintendo systems
+	".nes": func(b []byte) []byte {
+		return skipHeader(b, nesHeaderSize, []byte{'N', 'E', 'S', 0x1a}, 0)
+	},
+	".fds": func(b []byte) []byte {
+		return skipHeader(b, 16, []byte{'F', 'D', 'S', 0x1a}, 0)
+	},
+	".sfc": func(b []byte) []byte {
+		return skipCopierHeader(b, 8<<10)
+	},
+	".smc": func(b []byte) []byte {
+		return skipCopierHeader(b, 8<<10)
+	},
+	".gb":  plainRA,
+	".gbc": plainRA,
+	".gba": plainRA,
+	".vb":  plainRA,
+	".min": plainRA,
+	".n64": n64BigEndian,
+	".v64": n64BigEndian,
+	".z64": n64BigEndian,
+	// NEC PC Engine and SuperGrafx
+	".pce": func(b []byte) []byte {
+		return skipCopierHeader(b, 128<<10)
+	},
+	".sgx": func(b []byte) []byte {
+		return skipCopierHeader(b, 128<<10)
+	},
+	// Sega systems
+	".sg":  plainRA,
+	".sms": plainRA,
+	".gg":  plainRA,
+	".md":  plainRA,
+	".gen": plainRA,
+	".32x": plainRA,
+	// SNK Neo Geo Pocket and Bandai WonderSwan
+	".ngp": plainRA,
+	".ngc": plainRA,
+	".ws":  plainRA,
+	".wsc": plainRA,
+}
+
+// RAHash returns the hash RetroAchievements uses to identify the file called
+// filename read from r. Which system the file is for is taken from the
+// extension and any header that system's rules ignore is skipped before
+// hashing. Disc images and arcade sets aren't supported
+func RAHash(filename string, r io.Reader) ([]byte, error) {
+	f, ok := extensionToRA[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return nil, ErrRAUnsupported
+	}
+
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := md5.Sum(f(b))
+
+	return sum[:], nil
+}
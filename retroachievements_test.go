@@ -0,0 +1,102 @@
+package rom
+
+import (
+	"bytes"
+	"crypto/md5"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func md5Sum(b []byte) []byte {
+	sum := md5.Sum(b)
+	return sum[:]
+}
+
+func TestRAHash(t *testing.T) {
+	data := []byte{0x01, 0x02, 0x03, 0x04}
+	bank := bytes.Repeat([]byte{0x55}, 8<<10)
+	z64 := []byte{0x80, 0x37, 0x12, 0x40, 0x01, 0x02, 0x03, 0x04}
+
+	tables := map[string]struct {
+		filename string
+		b        []byte
+		want     []byte
+		err      error
+	}{
+		"plain": {
+			"test.gb",
+			data,
+			md5Sum(data),
+			nil,
+		},
+		"uppercase": {
+			"TEST.GBA",
+			data,
+			md5Sum(data),
+			nil,
+		},
+		"NES header": {
+			"test.nes",
+			append([]byte{'N', 'E', 'S', 0x1a, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, data...),
+			md5Sum(data),
+			nil,
+		},
+		"NES no header": {
+			"test.nes",
+			data,
+			md5Sum(data),
+			nil,
+		},
+		"7800 header": {
+			"test.a78",
+			append(append([]byte{0x01}, append([]byte("ATARI7800"), make([]byte, 118)...)...), data...),
+			md5Sum(data),
+			nil,
+		},
+		"SNES copier header": {
+			"test.sfc",
+			append(make([]byte, 512), bank...),
+			md5Sum(bank),
+			nil,
+		},
+		"SNES no header": {
+			"test.smc",
+			bank,
+			md5Sum(bank),
+			nil,
+		},
+		"N64 big-endian": {
+			"test.z64",
+			z64,
+			md5Sum(z64),
+			nil,
+		},
+		"N64 byteswapped": {
+			"test.v64",
+			[]byte{0x37, 0x80, 0x40, 0x12, 0x02, 0x01, 0x04, 0x03},
+			md5Sum(z64),
+			nil,
+		},
+		"N64 little-endian": {
+			"test.n64",
+			[]byte{0x40, 0x12, 0x37, 0x80, 0x04, 0x03, 0x02, 0x01},
+			md5Sum(z64),
+			nil,
+		},
+		"unsupported": {
+			"test.iso",
+			data,
+			nil,
+			ErrRAUnsupported,
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			got, err := RAHash(table.filename, bytes.NewReader(table.b))
+			assert.Equal(t, table.err, err)
+			assert.Equal(t, table.want, got)
+		})
+	}
+}
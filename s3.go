@@ -0,0 +1,271 @@
+package rom
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	s3Scheme      = "s3://"
+	s3Algorithm   = "AWS4-HMAC-SHA256"
+	s3EmptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+var errS3Bucket = errors.New("no bucket in s3 URL")
+
+func init() {
+	RegisterScheme("s3", OpenS3)
+	RegisterLister("s3", ListS3)
+}
+
+// s3Config is the endpoint and credentials used to access a bucket
+type s3Config struct {
+	endpoint     string
+	region       string
+	accessKey    string
+	secretKey    string
+	sessionToken string
+}
+
+func getenv(keys ...string) string {
+	for _, key := range keys {
+		if v := os.Getenv(key); v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// s3ConfigFromEnv uses the same environment variables as the AWS tools.
+// Setting AWS_ENDPOINT_URL allows any S3-compatible service such as MinIO
+// to be used. Without any credentials requests are anonymous
+func s3ConfigFromEnv() s3Config {
+	c := s3Config{
+		endpoint:     getenv("AWS_ENDPOINT_URL_S3", "AWS_ENDPOINT_URL"),
+		region:       getenv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		accessKey:    getenv("AWS_ACCESS_KEY_ID"),
+		secretKey:    getenv("AWS_SECRET_ACCESS_KEY"),
+		sessionToken: getenv("AWS_SESSION_TOKEN"),
+	}
+	if c.region == "" {
+		c.region = "us-east-1"
+	}
+	if c.endpoint == "" {
+		c.endpoint = "https://s3." + c.region + ".amazonaws.com"
+	}
+	c.endpoint = strings.TrimSuffix(c.endpoint, "/")
+	return c
+}
+
+// splitS3 splits an s3://bucket/key URL into the bucket and key
+func splitS3(name string) (string, string, error) {
+	if !strings.HasPrefix(strings.ToLower(name), s3Scheme) {
+		return "", "", fmt.Errorf("%s: not an s3 URL", name)
+	}
+	bucket, key := name[len(s3Scheme):], ""
+	if i := strings.IndexByte(bucket, '/'); i >= 0 {
+		bucket, key = bucket[:i], bucket[i+1:]
+	}
+	if bucket == "" {
+		return "", "", fmt.Errorf("%s: %w", name, errS3Bucket)
+	}
+	return bucket, key, nil
+}
+
+// s3Escape encodes s as required for signing, which is everything other
+// than the unreserved characters. Any "/" is left alone if path is true
+func s3Escape(s string, path bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '.' || c == '_' || c == '~' || path && c == '/' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// url returns the path-style URL for key in bucket, which works with both
+// AWS and other services
+func (c s3Config) url(bucket, key string, query url.Values) string {
+	u := c.endpoint + "/" + s3Escape(bucket, false) + "/" + s3Escape(key, true)
+
+	if len(query) > 0 {
+		keys := make([]string, 0, len(query))
+		for k := range query {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		parts := make([]string, 0, len(keys))
+		for _, k := range keys {
+			parts = append(parts, s3Escape(k, false)+"="+s3Escape(query.Get(k), false))
+		}
+		u += "?" + strings.Join(parts, "&")
+	}
+
+	return u
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// sign adds an AWS Signature Version 4 to req, made at t. The request
+// must not have a body
+func (c s3Config) sign(req *http.Request, t time.Time) {
+	if c.accessKey == "" {
+		return
+	}
+
+	date := t.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Amz-Date", date)
+	req.Header.Set("X-Amz-Content-Sha256", s3EmptySHA256)
+	if c.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.sessionToken)
+	}
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for k := range req.Header {
+		if lk := strings.ToLower(k); lk == "range" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(req.Header.Get(k))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonical strings.Builder
+	for _, k := range names {
+		canonical.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signed := strings.Join(names, ";")
+
+	request := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonical.String(),
+		signed,
+		s3EmptySHA256,
+	}, "\n")
+
+	scope := date[:8] + "/" + c.region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(request))
+	toSign := strings.Join([]string{s3Algorithm, date, scope, hex.EncodeToString(hash[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.secretKey), date[:8])
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", s3Algorithm, c.accessKey, scope, signed, hex.EncodeToString(hmacSHA256(key, toSign))))
+}
+
+// OpenS3 returns a RemoteFile for an s3://bucket/key URL using range
+// requests. The endpoint, region and credentials are taken from the usual
+// AWS environment variables and AWS_ENDPOINT_URL can point at any
+// S3-compatible service
+func OpenS3(ctx context.Context, name string) (RemoteFile, error) {
+	bucket, key, err := splitS3(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c := s3ConfigFromEnv()
+
+	return openHTTP(ctx, c.url(bucket, key, nil), func(req *http.Request) {
+		c.sign(req, time.Now())
+	})
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key string
+	}
+	IsTruncated           bool
+	NextContinuationToken string
+}
+
+// ListS3 returns the s3:// URL of every object in the bucket with a key
+// beginning with that in name
+func ListS3(ctx context.Context, name string) ([]string, error) {
+	bucket, prefix, err := splitS3(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c := s3ConfigFromEnv()
+
+	var files []string
+	token := ""
+	for {
+		query := url.Values{}
+		query.Set("list-type", "2")
+		if prefix != "" {
+			query.Set("prefix", prefix)
+		}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url(bucket, "", query), nil)
+		if err != nil {
+			return nil, err
+		}
+		c.sign(req, time.Now())
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListResult
+		if resp.StatusCode == http.StatusOK {
+			err = xml.NewDecoder(resp.Body).Decode(&result)
+		} else {
+			err = fmt.Errorf("%s: %s", name, resp.Status)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			// Skip any "directory" placeholders
+			if strings.HasSuffix(object.Key, "/") {
+				continue
+			}
+			files = append(files, s3Scheme+bucket+"/"+object.Key)
+		}
+
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
@@ -0,0 +1,660 @@
+package rom
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SFTP version 3 packet types, as used by OpenSSH
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpRead     = 5
+	sftpFstat    = 8
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpData     = 103
+	sftpName     = 104
+	sftpAttrs    = 105
+	sftpReadFlag = 1
+
+	sftpAttrSize        = 0x1
+	sftpAttrUIDGID      = 0x2
+	sftpAttrPermissions = 0x4
+	sftpAttrACModTime   = 0x8
+	sftpAttrExtended    = 0x80000000
+
+	sftpStatusEOF        = 1
+	sftpStatusNoSuchFile = 2
+
+	// sftpChunkSize is the most requested with each read, which all
+	// servers should support
+	sftpChunkSize = 32768
+
+	// sftpMaxPacket is the longest packet accepted, the same limit as
+	// OpenSSH uses
+	sftpMaxPacket = 256 * 1024
+)
+
+var (
+	errSFTPProtocol = errors.New("sftp protocol error")
+	errSFTPHost     = errors.New("invalid sftp host")
+)
+
+func init() {
+	RegisterScheme("sftp", OpenSFTP)
+	RegisterLister("sftp", ListSFTP)
+}
+
+type sftpError struct {
+	code uint32
+	msg  string
+}
+
+func (e *sftpError) Error() string {
+	return fmt.Sprintf("sftp: %s (%d)", e.msg, e.code)
+}
+
+func (e *sftpError) Is(target error) bool {
+	return e.code == sftpStatusNoSuchFile && target == os.ErrNotExist
+}
+
+// sftpBuffer builds and parses the fields of a packet
+type sftpBuffer struct {
+	b   []byte
+	err error
+}
+
+func (b *sftpBuffer) putUint32(v uint32) {
+	b.b = append(b.b, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(b.b[len(b.b)-4:], v)
+}
+
+func (b *sftpBuffer) putUint64(v uint64) {
+	b.b = append(b.b, 0, 0, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint64(b.b[len(b.b)-8:], v)
+}
+
+func (b *sftpBuffer) putString(s string) {
+	b.putUint32(uint32(len(s)))
+	b.b = append(b.b, s...)
+}
+
+// next returns the next n bytes. If there aren't that many left then the
+// error is set and nil is returned
+func (b *sftpBuffer) next(n int) []byte {
+	if b.err != nil || n < 0 || n > len(b.b) {
+		b.err = errSFTPProtocol
+		return nil
+	}
+	v := b.b[:n]
+	b.b = b.b[n:]
+	return v
+}
+
+func (b *sftpBuffer) uint32() uint32 {
+	if v := b.next(4); v != nil {
+		return binary.BigEndian.Uint32(v)
+	}
+	return 0
+}
+
+func (b *sftpBuffer) uint64() uint64 {
+	if v := b.next(8); v != nil {
+		return binary.BigEndian.Uint64(v)
+	}
+	return 0
+}
+
+func (b *sftpBuffer) string() string {
+	return string(b.next(int(b.uint32())))
+}
+
+// attrs parses file attributes and returns the size and permissions
+func (b *sftpBuffer) attrs() (uint64, uint32) {
+	var size uint64
+	var perm uint32
+
+	flags := b.uint32()
+	if flags&sftpAttrSize != 0 {
+		size = b.uint64()
+	}
+	if flags&sftpAttrUIDGID != 0 {
+		b.next(8)
+	}
+	if flags&sftpAttrPermissions != 0 {
+		perm = b.uint32()
+	}
+	if flags&sftpAttrACModTime != 0 {
+		b.next(8)
+	}
+	if flags&sftpAttrExtended != 0 {
+		for i := b.uint32(); i > 0 && b.err == nil; i-- {
+			b.string()
+			b.string()
+		}
+	}
+
+	return size, perm
+}
+
+// status returns the error for a status packet, or nil if it's OK
+func (b *sftpBuffer) status() error {
+	code := b.uint32()
+	msg := b.string()
+	if b.err != nil {
+		return b.err
+	}
+	if code == 0 {
+		return nil
+	}
+	return &sftpError{code, msg}
+}
+
+type sftpResponse struct {
+	typ  byte
+	data *sftpBuffer
+}
+
+// sftpConn is a connection to an SFTP server. Requests can be sent while
+// waiting for others so reading isn't held up by the round trip time
+type sftpConn struct {
+	mutex sync.Mutex
+	r     *bufio.Reader
+	w     io.Writer
+	conns []deadliner
+	id    uint32
+
+	key  string
+	cmd  *exec.Cmd
+	once sync.Once
+}
+
+var sftpConns = struct {
+	sync.Mutex
+	m map[string]*sftpConn
+}{
+	m: make(map[string]*sftpConn),
+}
+
+// newSFTPConn starts an SFTP session with the server reached by r and w
+func newSFTPConn(ctx context.Context, r io.Reader, w io.Writer) (*sftpConn, error) {
+	c := &sftpConn{
+		r: bufio.NewReader(r),
+		w: w,
+	}
+
+	// Pipes to ssh support deadlines, so a server that stops responding
+	// or a cancelled context can interrupt a request
+	for _, v := range []interface{}{r, w} {
+		if d, ok := v.(deadliner); ok {
+			c.conns = append(c.conns, d)
+		}
+	}
+
+	stop := watchContext(ctx, c.conns...)
+	b := new(sftpBuffer)
+	b.putUint32(3)
+	err := c.write(sftpInit, b.b)
+	var typ byte
+	if err == nil {
+		typ, _, err = c.readPacket()
+	}
+	if err = stop(err); err != nil {
+		return nil, err
+	}
+	if typ != sftpVersion {
+		return nil, errSFTPProtocol
+	}
+
+	return c, nil
+}
+
+// dialSFTP returns the connection for the host in u, running ssh to start
+// one if there isn't one already. Connections are kept for reuse
+func dialSFTP(ctx context.Context, u *url.URL) (*sftpConn, error) {
+	// Anything that looks like an option would be taken as one by ssh
+	if u.Hostname() == "" || strings.HasPrefix(u.Hostname(), "-") {
+		return nil, fmt.Errorf("%w: %q", errSFTPHost, u.Hostname())
+	}
+
+	key := u.User.Username() + "@" + u.Host
+
+	sftpConns.Lock()
+	defer sftpConns.Unlock()
+
+	if c, ok := sftpConns.m[key]; ok {
+		return c, nil
+	}
+
+	var args []string
+	if port := u.Port(); port != "" {
+		args = append(args, "-p", port)
+	}
+	if user := u.User.Username(); user != "" {
+		args = append(args, "-l", user)
+	}
+	args = append(args, "-s", "--", u.Hostname(), "sftp")
+
+	cmd := exec.Command("ssh", args...)
+	cmd.Stderr = os.Stderr
+
+	w, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	r, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	c, err := newSFTPConn(ctx, r, w)
+	if err != nil {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+		return nil, fmt.Errorf("%s: %w", u.Host, err)
+	}
+	c.key, c.cmd = key, cmd
+	sftpConns.m[key] = c
+
+	return c, nil
+}
+
+// stop kills the ssh process, if there is one, and waits for it to exit
+func (c *sftpConn) stop() {
+	c.once.Do(func() {
+		if c.cmd != nil {
+			_ = c.cmd.Process.Kill()
+			_ = c.cmd.Wait()
+		}
+	})
+}
+
+// drop stops c and forgets it so the next request starts a new session
+func (c *sftpConn) drop() {
+	sftpConns.Lock()
+	if sftpConns.m[c.key] == c {
+		delete(sftpConns.m, c.key)
+	}
+	sftpConns.Unlock()
+
+	c.stop()
+}
+
+// closeSFTP stops every session that's kept for reuse
+func closeSFTP() {
+	sftpConns.Lock()
+	conns := sftpConns.m
+	sftpConns.m = make(map[string]*sftpConn)
+	sftpConns.Unlock()
+
+	for _, c := range conns {
+		c.stop()
+	}
+}
+
+func (c *sftpConn) write(typ byte, payload []byte) error {
+	b := make([]byte, 5, 5+len(payload))
+	binary.BigEndian.PutUint32(b, uint32(1+len(payload)))
+	b[4] = typ
+	_, err := c.w.Write(append(b, payload...))
+	return err
+}
+
+func (c *sftpConn) readPacket() (byte, *sftpBuffer, error) {
+	var length [4]byte
+	if _, err := io.ReadFull(c.r, length[:]); err != nil {
+		return 0, nil, err
+	}
+
+	n := binary.BigEndian.Uint32(length[:])
+	if n < 1 || n > sftpMaxPacket {
+		return 0, nil, errSFTPProtocol
+	}
+
+	b := make([]byte, n)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return 0, nil, err
+	}
+
+	return b[0], &sftpBuffer{b: b[1:]}, nil
+}
+
+// requests sends each payload of type typ and returns the responses in
+// the same order
+func (c *sftpConn) requests(ctx context.Context, typ byte, payloads ...[]byte) ([]sftpResponse, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	// Any error here leaves the session out of step with the server
+	stop := watchContext(ctx, c.conns...)
+	responses, err := c.roundTrip(typ, payloads)
+	if err = stop(err); err != nil {
+		c.drop()
+		return nil, err
+	}
+
+	return responses, nil
+}
+
+// roundTrip sends each payload and waits for all of the responses
+func (c *sftpConn) roundTrip(typ byte, payloads [][]byte) ([]sftpResponse, error) {
+	ids := make(map[uint32]int, len(payloads))
+	for i, payload := range payloads {
+		c.id++
+		ids[c.id] = i
+
+		b := new(sftpBuffer)
+		b.putUint32(c.id)
+		if err := c.write(typ, append(b.b, payload...)); err != nil {
+			return nil, err
+		}
+	}
+
+	responses := make([]sftpResponse, len(payloads))
+	for range payloads {
+		rtyp, data, err := c.readPacket()
+		if err != nil {
+			return nil, err
+		}
+
+		i, ok := ids[data.uint32()]
+		if !ok || data.err != nil {
+			return nil, errSFTPProtocol
+		}
+		responses[i] = sftpResponse{rtyp, data}
+	}
+
+	return responses, nil
+}
+
+func (c *sftpConn) request(ctx context.Context, typ byte, payload []byte) (sftpResponse, error) {
+	responses, err := c.requests(ctx, typ, payload)
+	if err != nil {
+		return sftpResponse{}, err
+	}
+	return responses[0], nil
+}
+
+// handle sends a request expecting a handle back
+func (c *sftpConn) handle(ctx context.Context, typ byte, payload []byte) (string, error) {
+	r, err := c.request(ctx, typ, payload)
+	if err != nil {
+		return "", err
+	}
+
+	switch r.typ {
+	case sftpHandle:
+		h := r.data.string()
+		return h, r.data.err
+	case sftpStatus:
+		if err := r.data.status(); err != nil {
+			return "", err
+		}
+	}
+
+	return "", errSFTPProtocol
+}
+
+func (c *sftpConn) close(ctx context.Context, handle string) error {
+	b := new(sftpBuffer)
+	b.putString(handle)
+
+	r, err := c.request(ctx, sftpClose, b.b)
+	if err != nil {
+		return err
+	}
+	if r.typ != sftpStatus {
+		return errSFTPProtocol
+	}
+
+	return r.data.status()
+}
+
+type sftpEntry struct {
+	name string
+	dir  bool
+}
+
+// readdir returns the entries in the directory p
+func (c *sftpConn) readdir(ctx context.Context, p string) ([]sftpEntry, error) {
+	b := new(sftpBuffer)
+	b.putString(p)
+
+	handle, err := c.handle(ctx, sftpOpendir, b.b)
+	if err != nil {
+		return nil, err
+	}
+	defer c.close(context.Background(), handle)
+
+	b = new(sftpBuffer)
+	b.putString(handle)
+
+	var entries []sftpEntry
+	for {
+		r, err := c.request(ctx, sftpReaddir, b.b)
+		if err != nil {
+			return nil, err
+		}
+
+		switch r.typ {
+		case sftpName:
+			for i := r.data.uint32(); i > 0 && r.data.err == nil; i-- {
+				name := r.data.string()
+				r.data.string()
+				_, perm := r.data.attrs()
+				entries = append(entries, sftpEntry{name, perm&0o170000 == 0o040000})
+			}
+			if r.data.err != nil {
+				return nil, r.data.err
+			}
+		case sftpStatus:
+			if err := r.data.status(); err != nil {
+				var serr *sftpError
+				if errors.As(err, &serr) && serr.code == sftpStatusEOF {
+					return entries, nil
+				}
+				return nil, err
+			}
+			return nil, errSFTPProtocol
+		default:
+			return nil, errSFTPProtocol
+		}
+	}
+}
+
+type sftpFile struct {
+	blockFile
+	ctx    context.Context
+	conn   *sftpConn
+	handle string
+}
+
+// OpenSFTP returns a RemoteFile for an sftp://user@host:port/path URL. The
+// connection is made by running ssh so any configuration, keys or agent
+// it uses apply
+func OpenSFTP(ctx context.Context, name string) (RemoteFile, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := dialSFTP(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	b := new(sftpBuffer)
+	b.putString(u.Path)
+	b.putUint32(sftpReadFlag)
+	b.putUint32(0)
+
+	f := &sftpFile{ctx: ctx, conn: c}
+	if f.handle, err = c.handle(ctx, sftpOpen, b.b); err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	f.blockFile.read = f.read
+
+	b = new(sftpBuffer)
+	b.putString(f.handle)
+
+	r, err := c.request(ctx, sftpFstat, b.b)
+	if err == nil {
+		switch r.typ {
+		case sftpAttrs:
+			var size uint64
+			size, _ = r.data.attrs()
+			f.size, err = int64(size), r.data.err
+		case sftpStatus:
+			if err = r.data.status(); err == nil {
+				err = errSFTPProtocol
+			}
+		default:
+			err = errSFTPProtocol
+		}
+	}
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+
+	return f, nil
+}
+
+// read fills b from offset, sending every request needed at once
+func (f *sftpFile) read(b []byte, offset int64) error {
+	var payloads [][]byte
+	for i := 0; i < len(b); i += sftpChunkSize {
+		n := len(b) - i
+		if n > sftpChunkSize {
+			n = sftpChunkSize
+		}
+
+		p := new(sftpBuffer)
+		p.putString(f.handle)
+		p.putUint64(uint64(offset) + uint64(i))
+		p.putUint32(uint32(n))
+		payloads = append(payloads, p.b)
+	}
+
+	responses, err := f.conn.requests(f.ctx, sftpRead, payloads...)
+	if err != nil {
+		return err
+	}
+
+	for i, r := range responses {
+		switch r.typ {
+		case sftpData:
+			data := r.data.string()
+			if r.data.err != nil {
+				return r.data.err
+			}
+			start := i * sftpChunkSize
+			end := start + sftpChunkSize
+			if end > len(b) {
+				end = len(b)
+			}
+			n := copy(b[start:end], data)
+
+			// Servers are allowed to return less than was asked for
+			if start+n < end {
+				if n == 0 {
+					return errSFTPProtocol
+				}
+				if err := f.read(b[start+n:end], offset+int64(start+n)); err != nil {
+					return err
+				}
+			}
+		case sftpStatus:
+			if err := r.data.status(); err != nil {
+				var serr *sftpError
+				if errors.As(err, &serr) && serr.code == sftpStatusEOF {
+					return io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			return errSFTPProtocol
+		default:
+			return errSFTPProtocol
+		}
+	}
+
+	return nil
+}
+
+// Close closes the handle even if the context used to open the file has
+// been cancelled since
+func (f *sftpFile) Close() error {
+	f.block = nil
+	return f.conn.close(context.Background(), f.handle)
+}
+
+// ListSFTP returns the URL of every file beneath the directory in the
+// sftp:// URL name, skipping anything hidden
+func ListSFTP(ctx context.Context, name string) ([]string, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := dialSFTP(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	var list func(string) error
+	list = func(dir string) error {
+		entries, err := c.readdir(ctx, dir)
+		if err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+
+		for _, e := range entries {
+			if strings.HasPrefix(e.name, ".") {
+				continue
+			}
+
+			p := path.Join(dir, e.name)
+			if e.dir {
+				if err := list(p); err != nil {
+					return err
+				}
+				continue
+			}
+
+			file := *u
+			file.Path = p
+			files = append(files, file.String())
+		}
+
+		return nil
+	}
+
+	if err := list(u.Path); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
@@ -0,0 +1,237 @@
+package rom
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sftpServer is just enough of an SFTP server to serve files in dir
+func sftpServer(t *testing.T, dir string, r io.Reader, w io.WriteCloser) {
+	out := make(chan []byte, 100)
+
+	go func() {
+		for b := range out {
+			var length [4]byte
+			binary.BigEndian.PutUint32(length[:], uint32(len(b)))
+			if _, err := w.Write(append(length[:], b...)); err != nil {
+				return
+			}
+		}
+		w.Close()
+	}()
+
+	status := func(id, code uint32) []byte {
+		b := new(sftpBuffer)
+		b.putUint32(id)
+		b.putUint32(code)
+		b.putString("")
+		b.putString("")
+		return append([]byte{sftpStatus}, b.b...)
+	}
+
+	files := make(map[string]*os.File)
+	dirs := make(map[string][]os.DirEntry)
+
+	go func() {
+		defer close(out)
+
+		for handles := 0; ; handles++ {
+			var length [4]byte
+			if _, err := io.ReadFull(r, length[:]); err != nil {
+				return
+			}
+			p := make([]byte, binary.BigEndian.Uint32(length[:]))
+			if _, err := io.ReadFull(r, p); err != nil {
+				return
+			}
+
+			req := &sftpBuffer{b: p[1:]}
+			if p[0] == sftpInit {
+				b := new(sftpBuffer)
+				b.putUint32(3)
+				out <- append([]byte{sftpVersion}, b.b...)
+				continue
+			}
+
+			id := req.uint32()
+			resp := new(sftpBuffer)
+			resp.putUint32(id)
+			typ := byte(sftpStatus)
+
+			switch p[0] {
+			case sftpOpen, sftpOpendir:
+				name := filepath.Join(dir, filepath.FromSlash(req.string()))
+				handle := fmt.Sprint(handles)
+				if p[0] == sftpOpen {
+					f, err := os.Open(name)
+					if err != nil {
+						out <- status(id, sftpStatusNoSuchFile)
+						continue
+					}
+					files[handle] = f
+				} else {
+					entries, err := os.ReadDir(name)
+					if err != nil {
+						out <- status(id, sftpStatusNoSuchFile)
+						continue
+					}
+					dirs[handle] = entries
+				}
+				typ = sftpHandle
+				resp.putString(handle)
+			case sftpFstat:
+				fi, err := files[req.string()].Stat()
+				assert.Equal(t, nil, err)
+				typ = sftpAttrs
+				resp.putUint32(sftpAttrSize)
+				resp.putUint64(uint64(fi.Size()))
+			case sftpRead:
+				f := files[req.string()]
+				offset, n := req.uint64(), req.uint32()
+				b := make([]byte, n)
+				n2, err := f.ReadAt(b, int64(offset))
+				if n2 == 0 && err == io.EOF {
+					out <- status(id, sftpStatusEOF)
+					continue
+				}
+				typ = sftpData
+				resp.putString(string(b[:n2]))
+			case sftpReaddir:
+				handle := req.string()
+				entries := dirs[handle]
+				if len(entries) == 0 {
+					out <- status(id, sftpStatusEOF)
+					continue
+				}
+				dirs[handle] = nil
+				typ = sftpName
+				resp.putUint32(uint32(len(entries)))
+				for _, e := range entries {
+					resp.putString(e.Name())
+					resp.putString(e.Name())
+					resp.putUint32(sftpAttrPermissions)
+					if e.IsDir() {
+						resp.putUint32(0o040755)
+					} else {
+						resp.putUint32(0o100644)
+					}
+				}
+			case sftpClose:
+				handle := req.string()
+				if f, ok := files[handle]; ok {
+					f.Close()
+				}
+				delete(files, handle)
+				delete(dirs, handle)
+				resp.putUint32(0)
+				resp.putString("")
+				resp.putString("")
+			default:
+				out <- status(id, 8)
+				continue
+			}
+
+			out <- append([]byte{typ}, resp.b...)
+		}
+	}()
+}
+
+func TestSFTP(t *testing.T) {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	sftpServer(t, "testdata", sr, sw)
+
+	c, err := newSFTPConn(context.Background(), cr, cw)
+	assert.Equal(t, nil, err)
+
+	sftpConns.Lock()
+	sftpConns.m["user@test"] = c
+	sftpConns.Unlock()
+
+	files, err := List("sftp://user@test/test")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{"sftp://user@test/test/test.bin", "sftp://user@test/test/test.nes"}, files)
+
+	_, err = OpenSFTP(context.Background(), "sftp://user@test/missing.zip")
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	r, err := NewReader("sftp://user@test/test.zip")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "*rom.ZipReader", fmt.Sprintf("%T", r))
+	assert.Equal(t, "sftp://user@test/test.zip", r.Name())
+
+	checksum, err := r.Checksum("test.bin", SHA1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte{0x4e, 0xbc, 0x20, 0xb4, 0x6e, 0xa4, 0xd0, 0x10, 0xed, 0x9a, 0xc1, 0xfd, 0xe4, 0xc2, 0x51, 0xcf, 0x23, 0x1a, 0x66, 0x1f}, checksum)
+	assert.Equal(t, nil, r.Close())
+}
+
+func TestSFTPHost(t *testing.T) {
+	_, err := OpenSFTP(context.Background(), "sftp://-oProxyCommand=false/test.zip")
+	assert.True(t, errors.Is(err, errSFTPHost))
+}
+
+func TestSFTPCancel(t *testing.T) {
+	// A server that never responds
+	cr, sw, err := os.Pipe()
+	assert.Equal(t, nil, err)
+	defer cr.Close()
+	defer sw.Close()
+	sr, cw, err := os.Pipe()
+	assert.Equal(t, nil, err)
+	defer sr.Close()
+	defer cw.Close()
+
+	go func() {
+		_, _ = io.Copy(io.Discard, sr)
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	_, err = newSFTPConn(ctx, cr, cw)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestSFTPBuffer(t *testing.T) {
+	// A length far beyond what's in the packet
+	b := &sftpBuffer{b: []byte{0xff, 0xff, 0xff, 0xff, 'a'}}
+	assert.Equal(t, "", b.string())
+	assert.Equal(t, errSFTPProtocol, b.err)
+	assert.Equal(t, uint64(0), b.uint64())
+}
+
+func TestSFTPDrop(t *testing.T) {
+	cr, sw := io.Pipe()
+	sr, cw := io.Pipe()
+	sftpServer(t, "testdata", sr, sw)
+
+	c, err := newSFTPConn(context.Background(), cr, cw)
+	assert.Equal(t, nil, err)
+	c.key = "user@broken"
+
+	sftpConns.Lock()
+	sftpConns.m[c.key] = c
+	sftpConns.Unlock()
+
+	// The server going away means the session is no use any more
+	sr.Close()
+
+	_, err = OpenSFTP(context.Background(), "sftp://user@broken/test.zip")
+	assert.NotEqual(t, nil, err)
+
+	sftpConns.Lock()
+	_, ok := sftpConns.m[c.key]
+	sftpConns.Unlock()
+	assert.False(t, ok)
+}
@@ -0,0 +1,386 @@
+package rom
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hirochachacha/go-smb2"
+)
+
+const (
+	smbDefaultPort = "445"
+	smbPasswordEnv = "SMB_PASSWORD"
+	smbUserEnv     = "SMB_USER"
+	smbDomainEnv   = "SMB_DOMAIN"
+
+	// smbGuest is the user logged in as when there isn't one, as go-smb2
+	// doesn't support anonymous logins
+	smbGuest = "guest"
+)
+
+var errSMBShare = errors.New("no share in smb URL")
+
+func init() {
+	RegisterScheme("smb", OpenSMB)
+	RegisterLister("smb", ListSMB)
+}
+
+// smbConn is a logged in session with an SMB server and the shares
+// connected to so far
+type smbConn struct {
+	mutex   sync.Mutex
+	conn    net.Conn
+	session *smb2.Session
+	host    string
+	shares  map[string]*smb2.Share
+	key     string
+}
+
+var smbConns = struct {
+	sync.Mutex
+	m         map[string]*smbConn
+	passwords map[string]string
+}{
+	m:         make(map[string]*smbConn),
+	passwords: make(map[string]string),
+}
+
+// newSMBConn logs in to the server reached by conn
+func newSMBConn(ctx context.Context, conn net.Conn, host, user, password, domain string) (*smbConn, error) {
+	if user == "" {
+		user = smbGuest
+	}
+
+	d := &smb2.Dialer{
+		Initiator: &smb2.NTLMInitiator{
+			User:     user,
+			Password: password,
+			Domain:   domain,
+		},
+	}
+
+	session, err := d.DialContext(ctx, conn)
+	if err != nil {
+		return nil, smbContextError(ctx, err)
+	}
+
+	return &smbConn{
+		conn:    conn,
+		session: session,
+		host:    host,
+		shares:  make(map[string]*smb2.Share),
+	}, nil
+}
+
+// smbCredentials returns the user, password and domain to use for u,
+// taken from the URL first and then the environment. A user can be given
+// as "domain;user"
+func smbCredentials(u *url.URL) (string, string, string) {
+	user, password, domain := getenv(smbUserEnv), getenv(smbPasswordEnv), getenv(smbDomainEnv)
+	if u.User != nil {
+		user = u.User.Username()
+		if p, ok := u.User.Password(); ok {
+			password = p
+		}
+	}
+	if i := strings.IndexByte(user, ';'); i >= 0 {
+		domain, user = user[:i], user[i+1:]
+	}
+	return user, password, domain
+}
+
+// dialSMB returns the session for the host in u, connecting and logging in
+// if there isn't one already. Sessions are kept for reuse
+func dialSMB(ctx context.Context, u *url.URL) (*smbConn, error) {
+	user, password, domain := smbCredentials(u)
+	key := user + "@" + u.Host
+
+	smbConns.Lock()
+	defer smbConns.Unlock()
+
+	// The URLs returned by ListSMB leave out the password so it's
+	// remembered for when they're opened
+	if _, ok := u.User.Password(); ok {
+		smbConns.passwords[key] = password
+	} else if p, ok := smbConns.passwords[key]; ok {
+		password = p
+	}
+
+	if c, ok := smbConns.m[key]; ok {
+		return c, nil
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = smbDefaultPort
+	}
+
+	conn, err := dialContext(ctx, net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, blockTimeout)
+	defer cancel()
+
+	c, err := newSMBConn(ctx, conn, u.Hostname(), user, password, domain)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("%s: %w", u.Host, err)
+	}
+	c.key = key
+	smbConns.m[key] = c
+
+	return c, nil
+}
+
+// closeSMB logs off every session that's kept for reuse
+func closeSMB() {
+	smbConns.Lock()
+	conns := smbConns.m
+	smbConns.m = make(map[string]*smbConn)
+	smbConns.Unlock()
+
+	for _, c := range conns {
+		c.close()
+	}
+}
+
+func (c *smbConn) close() {
+	ctx, cancel := context.WithTimeout(context.Background(), dialTimeout)
+	defer cancel()
+
+	_ = c.session.WithContext(ctx).Logoff()
+	c.conn.Close()
+}
+
+// check returns err, forgetting c first if it means the connection has
+// failed so the next request connects again
+func (c *smbConn) check(ctx context.Context, err error) error {
+	var terr *smb2.TransportError
+	if errors.As(err, &terr) {
+		smbConns.Lock()
+		if smbConns.m[c.key] == c {
+			delete(smbConns.m, c.key)
+		}
+		smbConns.Unlock()
+		c.conn.Close()
+	}
+	return smbContextError(ctx, err)
+}
+
+// smbContextError returns the error from ctx if that's why err happened,
+// as go-smb2 doesn't wrap it
+func smbContextError(ctx context.Context, err error) error {
+	var cerr *smb2.ContextError
+	if errors.As(err, &cerr) {
+		return ctx.Err()
+	}
+	return err
+}
+
+// share returns the named share, connecting to it the first time
+func (c *smbConn) share(ctx context.Context, name string) (*smb2.Share, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if share, ok := c.shares[name]; ok {
+		return share.WithContext(ctx), nil
+	}
+
+	share, err := c.session.WithContext(ctx).Mount(`\\` + c.host + `\` + name)
+	if err != nil {
+		return nil, c.check(ctx, err)
+	}
+	c.shares[name] = share
+
+	return share.WithContext(ctx), nil
+}
+
+// splitSMB splits an smb://host/share/path URL into the share and path
+func splitSMB(u *url.URL) (string, string, error) {
+	share := strings.TrimPrefix(u.Path, "/")
+	p := ""
+	if i := strings.IndexByte(share, '/'); i >= 0 {
+		share, p = share[:i], share[i:]
+	}
+	if share == "" {
+		return "", "", fmt.Errorf("%s: %w", u.Redacted(), errSMBShare)
+	}
+	return share, p, nil
+}
+
+// smbPath returns p as go-smb2 expects it, relative to the share
+func smbPath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+p), "/")
+}
+
+type smbFile struct {
+	blockFile
+	ctx    context.Context
+	cancel context.CancelFunc
+	conn   *smbConn
+	file   *smb2.File
+}
+
+// OpenSMB returns a RemoteFile for an smb://user@host/share/path URL. The
+// password and domain can be given in the URL or with the SMB_PASSWORD and
+// SMB_DOMAIN environment variables, without a user the guest account is
+// used
+func OpenSMB(ctx context.Context, name string) (RemoteFile, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	share, p, err := splitSMB(u)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := dialSMB(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	// The file keeps the context it was opened with, so cancelling this
+	// one is how a read that takes too long is given up on
+	f := &smbFile{conn: c}
+	f.ctx, f.cancel = context.WithCancel(ctx)
+	f.blockFile.read = f.read
+
+	err = f.limit(func() error {
+		s, err := c.share(f.ctx, share)
+		if err != nil {
+			return err
+		}
+
+		if f.file, err = s.Open(smbPath(p)); err != nil {
+			return c.check(f.ctx, err)
+		}
+
+		fi, err := f.file.Stat()
+		if err != nil {
+			f.file.Close()
+			return c.check(f.ctx, err)
+		}
+		f.size = fi.Size()
+
+		return nil
+	})
+	if err != nil {
+		f.cancel()
+		return nil, fmt.Errorf("%s: %w", u.Redacted(), err)
+	}
+
+	return f, nil
+}
+
+// limit calls fn, cancelling the file's context if it doesn't return
+// within blockTimeout
+func (f *smbFile) limit(fn func() error) error {
+	timer := time.AfterFunc(blockTimeout, f.cancel)
+	err := fn()
+	if !timer.Stop() && err != nil {
+		return os.ErrDeadlineExceeded
+	}
+	return err
+}
+
+func (f *smbFile) read(b []byte, offset int64) error {
+	return f.limit(func() error {
+		n, err := f.file.ReadAt(b, offset)
+		if err != nil && !errors.Is(err, io.EOF) {
+			return f.conn.check(f.ctx, err)
+		}
+		if n < len(b) {
+			return io.ErrUnexpectedEOF
+		}
+		return nil
+	})
+}
+
+func (f *smbFile) Close() error {
+	defer f.cancel()
+
+	f.block = nil
+	return f.file.Close()
+}
+
+// ListSMB returns the URL of every file beneath the directory in the
+// smb:// URL name, skipping anything hidden. Any password in name is left
+// out of them so it doesn't end up in logs or reports
+func ListSMB(ctx context.Context, name string) ([]string, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	share, p, err := splitSMB(u)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := dialSMB(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := c.share(ctx, share)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	var list func(string) error
+	list = func(dir string) error {
+		ctx, cancel := context.WithTimeout(ctx, blockTimeout)
+		defer cancel()
+
+		entries, err := s.WithContext(ctx).ReadDir(smbPath(dir))
+		if err != nil {
+			return fmt.Errorf("%s: %w", dir, c.check(ctx, err))
+		}
+
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".") {
+				continue
+			}
+
+			p := path.Join(dir, e.Name())
+			if e.IsDir() {
+				if err := list(p); err != nil {
+					return err
+				}
+				continue
+			}
+
+			file := *u
+			if u.User != nil {
+				file.User = url.User(u.User.Username())
+			}
+			file.Path = "/" + share + p
+			files = append(files, file.String())
+		}
+
+		return nil
+	}
+
+	if err := list(path.Join("/", p)); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
@@ -0,0 +1,121 @@
+package synchronizer
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const backupLayout = "20060102-150405"
+
+// Backup configures a directory that any file which would otherwise be
+// deleted or replaced is moved into instead. Each Synchronizer uses its own
+// timestamped directory beneath dir and files keep their path relative to
+// the directory being synchronized
+func Backup(dir string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.backupDir = dir
+		return nil
+	}
+}
+
+// SetBackup configures the directory used by s to keep any deleted or
+// replaced files
+func (s *Synchronizer) SetBackup(dir string) error {
+	return s.setOption(Backup(dir))
+}
+
+// backup moves file, which is within dir, into the backup directory if one
+// is configured and file exists
+func (s *Synchronizer) backup(dir, file string) error {
+	if s.backupDir == "" {
+		return nil
+	}
+
+	if _, err := os.Lstat(file); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+
+	rel, err := filepath.Rel(dir, file)
+	if err != nil {
+		return err
+	}
+
+	s.backupOnce.Do(func() {
+		s.backupStamp = time.Now().Format(backupLayout)
+	})
+
+	dst := filepath.Join(s.backupDir, s.backupStamp, rel)
+
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+
+	s.log(LevelInfo, []Field{{FieldFile, file}, {FieldAction, "backup"}}, "Backing up", file, "to", dst)
+
+	return move(file, dst)
+}
+
+// remove deletes file, which is within dir, or moves it into the backup
+// directory if one is configured
+func (s *Synchronizer) remove(dir, file string) error {
+	if s.backupDir != "" {
+		return s.backup(dir, file)
+	}
+	return os.RemoveAll(file)
+}
+
+// move renames src to dst, falling back to copying if they are on
+// different filesystems
+func move(src, dst string) error {
+	if err := os.Rename(src, dst); err == nil {
+		return nil
+	}
+
+	if err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		if info.IsDir() {
+			return os.MkdirAll(target, info.Mode().Perm())
+		}
+
+		return copyFile(path, target, info.Mode().Perm())
+	}); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(src)
+}
+
+func copyFile(src, dst string, perm os.FileMode) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if _, err := io.Copy(w, r); err != nil {
+		return err
+	}
+
+	return w.Close()
+}
@@ -0,0 +1,201 @@
+package synchronizer
+
+import (
+	"encoding/gob"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bodgit/rom"
+)
+
+type scannedFile struct {
+	File     string
+	Checksum checksum
+}
+
+type cacheEntry struct {
+	Size      int64
+	ModTime   int64
+	Checksums map[rom.Checksum][]scannedFile
+	Valid     bool
+}
+
+// Cache is a persistent store of the checksums of every file found while
+// scanning, keyed by the path, size and modification time of each file. A
+// file that has not changed since it was last scanned does not need to be
+// read again. Any entry for a file that has changed is replaced and entries
+// for files that no longer exist are removed when the Cache is closed
+type Cache struct {
+	filename string
+	mutex    sync.Mutex
+	entries  map[string]cacheEntry
+	dirty    bool
+}
+
+// OpenCache returns a new Cache stored in filename, loading any existing
+// entries
+func OpenCache(filename string) (*Cache, error) {
+	c := &Cache{
+		filename: filename,
+		entries:  make(map[string]cacheEntry),
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return c, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := gob.NewDecoder(f).Decode(&c.entries); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Close saves the Cache back to disk if anything has changed
+func (c *Cache) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for name := range c.entries {
+		if _, err := os.Stat(name); errors.Is(err, os.ErrNotExist) {
+			delete(c.entries, name)
+			c.dirty = true
+		}
+	}
+
+	if !c.dirty {
+		return nil
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(c.filename), filepath.Base(c.filename))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	if err := gob.NewEncoder(f).Encode(c.entries); err != nil {
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(f.Name(), c.filename); err != nil {
+		return err
+	}
+
+	c.dirty = false
+
+	return nil
+}
+
+func cacheKey(file string) (string, os.FileInfo, error) {
+	name, err := filepath.Abs(file)
+	if err != nil {
+		return "", nil, err
+	}
+
+	info, err := os.Stat(name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return name, info, nil
+}
+
+func (c *Cache) get(file string, t rom.Checksum) ([]scannedFile, bool) {
+	name, info, err := cacheKey(file)
+	if err != nil {
+		return nil, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[name]
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		return nil, false
+	}
+
+	files, ok := entry.Checksums[t]
+
+	return files, ok
+}
+
+func (c *Cache) put(file string, t rom.Checksum, files []scannedFile) error {
+	name, info, err := cacheKey(file)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[name]
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		entry = cacheEntry{
+			Size:      info.Size(),
+			ModTime:   info.ModTime().UnixNano(),
+			Checksums: make(map[rom.Checksum][]scannedFile),
+		}
+	}
+
+	entry.Checksums[t] = files
+	c.entries[name] = entry
+	c.dirty = true
+
+	return nil
+}
+
+// valid returns true if file was recorded as a valid archive and hasn't
+// changed since
+func (c *Cache) valid(file string) bool {
+	name, info, err := cacheKey(file)
+	if err != nil {
+		return false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[name]
+
+	return ok && entry.Valid && entry.Size == info.Size() && entry.ModTime == info.ModTime().UnixNano()
+}
+
+// setValid records that file is a valid archive
+func (c *Cache) setValid(file string) error {
+	name, info, err := cacheKey(file)
+	if err != nil {
+		return err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[name]
+	if !ok || entry.Size != info.Size() || entry.ModTime != info.ModTime().UnixNano() {
+		entry = cacheEntry{
+			Size:      info.Size(),
+			ModTime:   info.ModTime().UnixNano(),
+			Checksums: make(map[rom.Checksum][]scannedFile),
+		}
+	}
+
+	if !entry.Valid {
+		entry.Valid = true
+		c.entries[name] = entry
+		c.dirty = true
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package synchronizer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bodgit/rom"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	tmp := t.TempDir()
+	filename := filepath.Join(tmp, "cache.gob")
+	file := filepath.Join(tmp, "test.bin")
+	gone := filepath.Join(tmp, "gone.bin")
+
+	for _, name := range []string{file, gone} {
+		if err := os.WriteFile(name, []byte("test"), 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	files := []scannedFile{
+		{"test.bin", checksum{rom.CRC32, "d87f7e0c", 4}},
+	}
+
+	c, err := OpenCache(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok := c.get(file, rom.CRC32)
+	assert.False(t, ok)
+
+	assert.Equal(t, nil, c.put(file, rom.CRC32, files))
+	assert.Equal(t, nil, c.put(gone, rom.CRC32, files))
+	assert.Equal(t, nil, c.setValid(file))
+	assert.Equal(t, nil, c.Close())
+
+	// Anything that no longer exists is dropped when the cache is closed
+	if err := os.Remove(gone); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = OpenCache(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cached, ok := c.get(file, rom.CRC32)
+	assert.True(t, ok)
+	assert.Equal(t, files, cached)
+	assert.True(t, c.valid(file))
+
+	_, ok = c.get(file, rom.SHA1)
+	assert.False(t, ok)
+
+	assert.Equal(t, nil, c.Close())
+
+	c, err = OpenCache(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, c.entries, 1)
+
+	// A file that has changed isn't trusted any more
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(file, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	_, ok = c.get(file, rom.CRC32)
+	assert.False(t, ok)
+	assert.False(t, c.valid(file))
+
+	assert.Equal(t, nil, c.Close())
+}
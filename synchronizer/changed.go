@@ -0,0 +1,63 @@
+package synchronizer
+
+import (
+	"context"
+
+	"github.com/bodgit/rom/dat"
+)
+
+// Remove removes every source provided by each of names, including any
+// files within it if it is a directory, such as when a file has been
+// deleted or changed since it was scanned
+func (db *DB) Remove(names ...string) {
+	for _, name := range names {
+		db.invalidate(name)
+	}
+}
+
+// UpdateChanged is like Update except only games with a ROM or disk found
+// in changed are updated, where changed is usually the result of scanning
+// just the files that have appeared since db was scanned. It should be
+// merged into db first. Every other game in datfile is still known so its
+// archive is never renamed to build another game
+func (s *Synchronizer) UpdateChanged(dir string, datfile *dat.File, db, changed *DB) error {
+	return s.UpdateChangedContext(context.Background(), dir, datfile, db, changed)
+}
+
+// UpdateChangedContext is like UpdateChanged but stops early if ctx is
+// cancelled
+func (s *Synchronizer) UpdateChangedContext(ctx context.Context, dir string, datfile *dat.File, db, changed *DB) error {
+	games := make(map[string]struct{}, len(datfile.Game))
+	affected := &dat.File{Header: datfile.Header}
+
+	for _, game := range datfile.Game {
+		if s.filtered(game) {
+			continue
+		}
+		games[s.gameFilename(game)] = struct{}{}
+		if s.affected(game, changed) {
+			affected.Game = append(affected.Game, game)
+		}
+	}
+
+	if len(affected.Game) == 0 {
+		return nil
+	}
+
+	return s.update(ctx, dir, fileGames(affected), uint64(len(affected.Game)), db, games, func(dat.Game) {})
+}
+
+// affected returns true if any ROM or disk in game is provided by db
+func (s *Synchronizer) affected(game dat.Game, db *DB) bool {
+	for _, r := range game.ROM {
+		if wanted(r) && len(db.find(romChecksum(r, s.dbChecksum()))) > 0 {
+			return true
+		}
+	}
+	for _, d := range game.Disk {
+		if c := diskChecksum(d); c.Value != "" && len(db.find(c)) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -1,6 +1,8 @@
 package synchronizer
 
 import (
+	"path/filepath"
+	"strings"
 	"sync"
 
 	"github.com/bodgit/rom"
@@ -14,56 +16,107 @@ type source struct {
 // DB holds a collection of ROM checksums and the file(s) that provides them
 type DB struct {
 	checksums map[checksum][]source
+	names     map[string][]source
+	partial   map[string]struct{}
 	mutex     sync.Mutex
 }
 
 func newDB() (*DB, error) {
 	return &DB{
 		checksums: make(map[checksum][]source),
+		names:     make(map[string][]source),
+		partial:   make(map[string]struct{}),
 	}, nil
 }
 
-func (db *DB) scan(reader rom.Reader, t rom.Checksum) error {
-	db.mutex.Lock()
-	defer db.mutex.Unlock()
+// scanReader checksums every file within reader. If wanted is not nil then
+// only files it returns true for are checksummed
+func scanReader(reader rom.Reader, t rom.Checksum, wanted func(string, uint64) bool) ([]scannedFile, error) {
+	files := make([]scannedFile, 0, len(reader.Files()))
 
 	for _, file := range reader.Files() {
 		size, header, err := reader.Size(file)
 		if err != nil {
-			return err
+			return nil, err
+		}
+
+		if wanted != nil && !wanted(file, size-header) {
+			continue
 		}
 
 		c, err := reader.Checksum(file, t)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		checksum := checksum{
-			Type:  t,
-			Value: checksumToString(c),
-			Size:  size - header,
-		}
+		files = append(files, scannedFile{
+			File: file,
+			Checksum: checksum{
+				Type:  t,
+				Value: checksumToString(c),
+				Size:  size - header,
+			},
+		})
+	}
 
-		db.checksums[checksum] = append(db.checksums[checksum], source{reader.Name(), file})
+	return files, nil
+}
+
+func (db *DB) scan(reader rom.Reader, t rom.Checksum) error {
+	files, err := scanReader(reader, t, nil)
+	if err != nil {
+		return err
 	}
 
+	db.add(reader.Name(), files)
+
 	return nil
 }
 
+func (db *DB) add(name string, files []scannedFile) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for _, f := range files {
+		db.checksums[f.Checksum] = append(db.checksums[f.Checksum], source{name, f.File})
+		db.names[f.File] = append(db.names[f.File], source{name, f.File})
+	}
+}
+
+// skipped records that some files within name weren't scanned
+func (db *DB) skipped(name string) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	db.partial[name] = struct{}{}
+}
+
 func (db *DB) find(checksum checksum) []source {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 	return db.checksums[checksum]
 }
 
+func (db *DB) findName(file string) []source {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+	return db.names[file]
+}
+
+// invalidate removes every source provided by name, including any files
+// within it if it is a directory
 func (db *DB) invalidate(name string) {
 	db.mutex.Lock()
 	defer db.mutex.Unlock()
 
+	prefix := name + string(filepath.Separator)
+	provides := func(s source) bool {
+		return name == s.Name || strings.HasPrefix(s.Name, prefix)
+	}
+
 	for k, v := range db.checksums {
 		tmp := v[:0]
 		for _, s := range v {
-			if name != s.Name {
+			if !provides(s) {
 				tmp = append(tmp, s)
 			}
 		}
@@ -73,4 +126,42 @@ func (db *DB) invalidate(name string) {
 		}
 		db.checksums[k] = tmp
 	}
+
+	for k, v := range db.names {
+		tmp := v[:0]
+		for _, s := range v {
+			if !provides(s) {
+				tmp = append(tmp, s)
+			}
+		}
+		if len(tmp) == 0 {
+			delete(db.names, k)
+			continue
+		}
+		db.names[k] = tmp
+	}
+
+	for k := range db.partial {
+		if provides(source{Name: k}) {
+			delete(db.partial, k)
+		}
+	}
+}
+
+func (db *DB) scanDisk(name string, h *rom.CHDHeader) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for t, c := range map[rom.Checksum][]byte{rom.MD5: h.MD5, rom.SHA1: h.SHA1} {
+		if c == nil {
+			continue
+		}
+
+		checksum := checksum{
+			Type:  t,
+			Value: checksumToString(c),
+		}
+
+		db.checksums[checksum] = append(db.checksums[checksum], source{name, filepath.Base(name)})
+	}
 }
@@ -0,0 +1,59 @@
+package synchronizer
+
+import (
+	"sort"
+
+	"github.com/bodgit/rom/dat"
+)
+
+// changes works out how each ROM of game within archive, currently holding
+// files, would change using sources. When running in dry-run mode each
+// change is also logged as nothing is actually copied
+func (s *Synchronizer) changes(game dat.Game, archive string, files []string, sources map[string][]source) []ROMChange {
+	s.reduceSources(sources)
+
+	var changes []ROMChange
+	used := make(map[string]struct{})
+
+	names := make([]string, 0, len(sources))
+	for name := range sources {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		src := sources[name][0]
+		switch {
+		case inArchive(src, archive, name):
+			used[name] = struct{}{}
+		case src.Name == archive:
+			used[src.File] = struct{}{}
+			changes = append(changes, ROMChange{ROM: name, Change: ChangeRenamed, File: src.File})
+		default:
+			changes = append(changes, ROMChange{ROM: name, Change: ChangeAdded, Source: src.Name, File: src.File})
+		}
+	}
+
+	// Anything not kept or renamed is dropped, apart from any disks
+	for _, file := range files {
+		if _, ok := used[file]; ok || containsString(diskFiles(game), file) {
+			continue
+		}
+		changes = append(changes, ROMChange{ROM: file, Change: ChangeRemoved})
+	}
+
+	if s.dryRun {
+		for _, c := range changes {
+			switch c.Change {
+			case ChangeAdded:
+				s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldFile, c.ROM}, {FieldSource, c.Source}, {FieldAction, c.Change}}, "Would copy", c.File, "from", c.Source, "to", archive, "as", c.ROM)
+			case ChangeRenamed:
+				s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldFile, c.ROM}, {FieldAction, c.Change}}, "Would rename", c.File, "to", c.ROM, "in", archive)
+			case ChangeRemoved:
+				s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldFile, c.ROM}, {FieldAction, c.Change}}, "Would remove", c.ROM, "from", archive)
+			}
+		}
+	}
+
+	return changes
+}
@@ -0,0 +1,187 @@
+package synchronizer
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/bodgit/plumbing"
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+)
+
+const chdExtension = ".chd"
+
+func readCHDHeader(file string) (*rom.CHDHeader, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return rom.ReadCHDHeader(f)
+}
+
+// scanDisk adds the checksums from the header of a CHD file. If the file
+// doesn't look like a CHD false is returned so it can be scanned as a
+// regular file instead
+func (s *Synchronizer) scanDisk(db *DB, file string) (bool, error) {
+	h, err := readCHDHeader(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, err
+		}
+		return false, nil
+	}
+
+	s.log(LevelDebug, []Field{{FieldFile, file}}, "Scanning", file)
+
+	db.scanDisk(file, h)
+
+	return true, nil
+}
+
+// rescanDisk replaces what db knows about file. Invalidating filters the
+// slices other games may be reading in place so nothing else can be
+// running at the same time
+func (s *Synchronizer) rescanDisk(db *DB, file string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	db.invalidate(file)
+	_, err := s.scanDisk(db, file)
+	return err
+}
+
+func validDisk(file string, c checksum) (bool, error) {
+	h, err := readCHDHeader(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	want, err := hex.DecodeString(c.Value)
+	if err != nil {
+		return false, err
+	}
+
+	switch c.Type {
+	case rom.MD5:
+		return bytes.Equal(h.MD5, want), nil
+	case rom.SHA1:
+		return bytes.Equal(h.SHA1, want), nil
+	}
+
+	return false, nil
+}
+
+func (s *Synchronizer) copyDisk(src, dst string) error {
+	if err := os.MkdirAll(filepath.Dir(dst), os.ModePerm); err != nil {
+		return err
+	}
+
+	s.acquireFile()
+	defer s.releaseFile()
+
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.CreateTemp(filepath.Dir(dst), "")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(w.Name())
+	defer w.Close()
+
+	id, err := s.begin(opDisk, dst, w.Name())
+	if err != nil {
+		return err
+	}
+
+	var rx, tx plumbing.WriteCounter
+
+	if _, err := io.Copy(io.MultiWriter(w, &tx), io.TeeReader(s.throttled(r), &rx)); err != nil {
+		return err
+	}
+
+	atomic.AddUint64(&s.rx, rx.Count())
+	atomic.AddUint64(&s.tx, tx.Count())
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(w.Name(), dst); err != nil {
+		return err
+	}
+
+	return s.end(id)
+}
+
+func (s *Synchronizer) disks(game dat.Game, dir string, db *DB) error {
+	for i, d := range game.Disk {
+		if d.NoDump() {
+			continue
+		}
+
+		c := diskChecksum(d)
+		if c.Value == "" {
+			continue
+		}
+
+		filename := filepath.Join(dir, diskFilename(game, d))
+
+		ok, err := validDisk(filename, c)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			game.Disk[i].Matched()
+			continue
+		}
+
+		// The slice is shared with the database so take a copy of the
+		// source while nothing else can change it
+		s.mutex.RLock()
+		srcs := db.find(c)
+		var src source
+		if len(srcs) > 0 {
+			src = srcs[0]
+		}
+		s.mutex.RUnlock()
+		if src.Name == "" {
+			continue
+		}
+
+		s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldFile, filename}, {FieldSource, src.Name}, {FieldAction, "copy"}}, "Copying", src.Name, "to", filename)
+
+		if s.dryRun {
+			continue
+		}
+
+		if err := s.backup(dir, filename); err != nil {
+			return err
+		}
+
+		if err := s.copyDisk(src.Name, filename); err != nil {
+			return err
+		}
+
+		if err := s.rescanDisk(db, filename); err != nil {
+			return err
+		}
+
+		game.Disk[i].Matched()
+	}
+
+	return nil
+}
@@ -0,0 +1,150 @@
+package synchronizer
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bodgit/rom"
+)
+
+// Location is a file found by a scan within a source such as an archive or
+// directory
+type Location struct {
+	Source string `json:"source"`
+	File   string `json:"file"`
+}
+
+// Duplicate lists every Location of files with the same checksum and size
+type Duplicate struct {
+	Checksum  string     `json:"checksum"`
+	Size      uint64     `json:"size"`
+	Locations []Location `json:"locations"`
+}
+
+// regular returns every source that is a regular file rather than a disk.
+// The caller must hold the lock
+func (db *DB) regular() map[source]struct{} {
+	regular := make(map[source]struct{})
+	for _, v := range db.names {
+		for _, s := range v {
+			regular[s] = struct{}{}
+		}
+	}
+	return regular
+}
+
+// locations calls fn with the checksum and each unique Location of every
+// file in db, only using the SHA1 of a disk. The caller must hold the lock
+func (db *DB) locations(fn func(checksum, []Location)) {
+	regular := db.regular()
+
+	for c, v := range db.checksums {
+		seen := make(map[source]struct{}, len(v))
+		locations := make([]Location, 0, len(v))
+		for _, s := range v {
+			if _, ok := regular[s]; !ok && c.Type != rom.SHA1 {
+				continue
+			}
+			if _, ok := seen[s]; ok {
+				continue
+			}
+			seen[s] = struct{}{}
+			locations = append(locations, Location{s.Name, s.File})
+		}
+		if len(locations) > 0 {
+			fn(c, locations)
+		}
+	}
+}
+
+// Duplicates returns every file found more than once by the scans that
+// populated db, sorted by checksum. The Locations of each are sorted by
+// source and then file
+func (db *DB) Duplicates() []Duplicate {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var duplicates []Duplicate
+	db.locations(func(c checksum, locations []Location) {
+		if len(locations) < 2 {
+			return
+		}
+		sort.Slice(locations, func(i, j int) bool {
+			if locations[i].Source == locations[j].Source {
+				return locations[i].File < locations[j].File
+			}
+			return locations[i].Source < locations[j].Source
+		})
+		duplicates = append(duplicates, Duplicate{c.Value, c.Size, locations})
+	})
+
+	sort.Slice(duplicates, func(i, j int) bool {
+		if duplicates[i].Checksum == duplicates[j].Checksum {
+			return duplicates[i].Size < duplicates[j].Size
+		}
+		return duplicates[i].Checksum < duplicates[j].Checksum
+	})
+
+	return duplicates
+}
+
+// Size returns the number of files found by the scans that populated db
+// and their total size, excluding any header. Disks count towards the
+// number of files but not the size
+func (db *DB) Size() (int, uint64) {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	var (
+		files int
+		size  uint64
+	)
+	db.locations(func(c checksum, locations []Location) {
+		files += len(locations)
+		size += c.Size * uint64(len(locations))
+	})
+
+	return files, size
+}
+
+// DuplicateSources returns each group of two or more sources, such as
+// archives, that contain exactly the same files with the same checksums.
+// Each group and the groups themselves are sorted by name. Any source that
+// was only partly scanned is ignored
+func (db *DB) DuplicateSources() [][]string {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	contents := make(map[string][]string)
+	db.locations(func(c checksum, locations []Location) {
+		for _, l := range locations {
+			contents[l.Source] = append(contents[l.Source], fmt.Sprintf("%s\x00%d\x00%s\x00%d", l.File, c.Type, c.Value, c.Size))
+		}
+	})
+
+	groups := make(map[string][]string)
+	for name, files := range contents {
+		if _, ok := db.partial[name]; ok {
+			continue
+		}
+		sort.Strings(files)
+		key := strings.Join(files, "\x00")
+		groups[key] = append(groups[key], name)
+	}
+
+	var duplicates [][]string
+	for _, names := range groups {
+		if len(names) < 2 {
+			continue
+		}
+		sort.Strings(names)
+		duplicates = append(duplicates, names)
+	}
+
+	sort.Slice(duplicates, func(i, j int) bool {
+		return duplicates[i][0] < duplicates[j][0]
+	})
+
+	return duplicates
+}
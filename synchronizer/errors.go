@@ -0,0 +1,77 @@
+package synchronizer
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/bodgit/rom/dat"
+)
+
+// GameError records the error encountered while processing a game
+type GameError struct {
+	Game string
+	Err  error
+}
+
+func (e *GameError) Error() string {
+	return fmt.Sprintf("%s: %v", e.Game, e.Err)
+}
+
+func (e *GameError) Unwrap() error {
+	return e.Err
+}
+
+// UpdateError is returned when one or more games failed to be processed
+// while continuing on error
+type UpdateError struct {
+	Errors []*GameError
+}
+
+func (e *UpdateError) Error() string {
+	if len(e.Errors) == 1 {
+		return e.Errors[0].Error()
+	}
+	return fmt.Sprintf("%d games failed, first error: %v", len(e.Errors), e.Errors[0])
+}
+
+type failures struct {
+	mutex  sync.Mutex
+	errors []*GameError
+}
+
+func (f *failures) add(game dat.Game, err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.errors = append(f.errors, &GameError{game.Name, err})
+}
+
+func (f *failures) take() error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if len(f.errors) == 0 {
+		return nil
+	}
+
+	err := &UpdateError{f.errors}
+	f.errors = nil
+
+	return err
+}
+
+// ContinueOnError configures whether an error while processing a game is
+// logged and the game skipped rather than stopping the update. Any such
+// errors are returned together as an UpdateError once every game has been
+// processed
+func ContinueOnError(v bool) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.continueOnError = v
+		return nil
+	}
+}
+
+// SetContinueOnError configures whether s continues after an error while
+// processing a game
+func (s *Synchronizer) SetContinueOnError(v bool) error {
+	return s.setOption(ContinueOnError(v))
+}
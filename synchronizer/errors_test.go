@@ -0,0 +1,57 @@
+package synchronizer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContinueOnError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "broken", http.StatusInternalServerError)
+	}))
+	defer ts.Close()
+
+	mirror := "dav://" + strings.TrimPrefix(ts.URL, "http://")
+
+	tables := map[string]struct {
+		continueOnError bool
+	}{
+		"stop": {
+			false,
+		},
+		"continue": {
+			true,
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			tmp := t.TempDir()
+			journal := filepath.Join(tmp, "journal")
+
+			target, err := testUpdate(t, tmp, Journal(journal), Mirror(mirror), ContinueOnError(table.continueOnError))
+
+			var uerr *UpdateError
+			if assert.NotEqual(t, nil, err) {
+				assert.Equal(t, table.continueOnError, errors.As(err, &uerr))
+			}
+			if uerr != nil && assert.Len(t, uerr.Errors, 1) {
+				assert.Equal(t, "test", uerr.Errors[0].Game)
+			}
+
+			// The journal is kept so the next update can clean up
+			_, err = os.Stat(journal)
+			assert.Equal(t, nil, err)
+
+			_, err = os.Stat(filepath.Join(target, "test.zip"))
+			assert.Equal(t, nil, err)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package synchronizer
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Exclude configures one or more glob patterns for files and directories
+// that should be ignored when scanning. A pattern without a "/" is matched
+// against the base name anywhere, such as "*.sav", otherwise it is matched
+// against the path relative to the directory being scanned where "**"
+// matches any number of directories, such as "extras/**"
+func Exclude(patterns ...string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		for _, pattern := range patterns {
+			for _, segment := range strings.Split(pattern, "/") {
+				if _, err := path.Match(segment, ""); err != nil {
+					return err
+				}
+			}
+		}
+		s.exclude = append(s.exclude, patterns...)
+		return nil
+	}
+}
+
+// SetExclude configures the glob patterns of files and directories ignored
+// by s when scanning
+func (s *Synchronizer) SetExclude(patterns ...string) error {
+	return s.setOption(Exclude(patterns...))
+}
+
+// excluded returns true if the path rel, relative to the directory being
+// scanned, matches any of the configured patterns
+func (s *Synchronizer) excluded(rel string) bool {
+	if rel == "." {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+
+	for _, pattern := range s.exclude {
+		if !strings.Contains(pattern, "/") {
+			if ok, _ := path.Match(pattern, path.Base(rel)); ok {
+				return true
+			}
+			continue
+		}
+		if matchSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(rel, "/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func matchSegments(pattern, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,162 @@
+package synchronizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/bodgit/rom"
+)
+
+const exportVersion = 1
+
+var checksumNames = map[rom.Checksum]string{
+	rom.CRC32: "crc32",
+	rom.MD5:   "md5",
+	rom.SHA1:  "sha1",
+}
+
+type exportedFile struct {
+	File  string `json:"file"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+	Size  uint64 `json:"size,omitempty"`
+	Disk  bool   `json:"disk,omitempty"`
+}
+
+type exportedSource struct {
+	Name    string         `json:"name"`
+	Files   []exportedFile `json:"files"`
+	Partial bool           `json:"partial,omitempty"`
+}
+
+type exportedDB struct {
+	Version int              `json:"version"`
+	Sources []exportedSource `json:"sources"`
+}
+
+// Export writes the contents of db to w as JSON so that it can be loaded
+// again with ImportDB, for example to reuse a scan of offline media. Each
+// file keeps the path it was found at so any media must be available at
+// the same location when it is used as a source
+func (db *DB) Export(w io.Writer) error {
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	regular := db.regular()
+
+	sources := make(map[string][]exportedFile)
+	for c, v := range db.checksums {
+		for _, s := range v {
+			_, ok := regular[s]
+			sources[s.Name] = append(sources[s.Name], exportedFile{
+				File:  s.File,
+				Type:  checksumNames[c.Type],
+				Value: c.Value,
+				Size:  c.Size,
+				Disk:  !ok,
+			})
+		}
+	}
+
+	e := exportedDB{
+		Version: exportVersion,
+		Sources: make([]exportedSource, 0, len(sources)),
+	}
+	for name, files := range sources {
+		sort.Slice(files, func(i, j int) bool {
+			if files[i].File == files[j].File {
+				return files[i].Type < files[j].Type
+			}
+			return files[i].File < files[j].File
+		})
+		_, partial := db.partial[name]
+		e.Sources = append(e.Sources, exportedSource{name, files, partial})
+	}
+	sort.Slice(e.Sources, func(i, j int) bool {
+		return e.Sources[i].Name < e.Sources[j].Name
+	})
+
+	return json.NewEncoder(w).Encode(e)
+}
+
+// ImportDB returns a new DB populated from r, which should contain the
+// output of Export
+func ImportDB(r io.Reader) (*DB, error) {
+	var e exportedDB
+	if err := json.NewDecoder(r).Decode(&e); err != nil {
+		return nil, err
+	}
+
+	if e.Version != exportVersion {
+		return nil, fmt.Errorf("unsupported DB version %d", e.Version)
+	}
+
+	types := make(map[string]rom.Checksum, len(checksumNames))
+	for k, v := range checksumNames {
+		types[v] = k
+	}
+
+	db, err := newDB()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range e.Sources {
+		if s.Partial {
+			db.partial[s.Name] = struct{}{}
+		}
+		for _, f := range s.Files {
+			t, ok := types[f.Type]
+			if !ok {
+				return nil, fmt.Errorf("unknown checksum type %q", f.Type)
+			}
+			c := checksum{Type: t, Value: f.Value, Size: f.Size}
+			db.checksums[c] = append(db.checksums[c], source{s.Name, f.File})
+			if !f.Disk {
+				db.names[f.File] = append(db.names[f.File], source{s.Name, f.File})
+			}
+		}
+	}
+
+	return db, nil
+}
+
+// Merge adds every file found in other to db, ignoring any that db already
+// has
+func (db *DB) Merge(other *DB) {
+	other.mutex.Lock()
+	defer other.mutex.Unlock()
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	for c, v := range other.checksums {
+		db.checksums[c] = mergeSources(db.checksums[c], v)
+	}
+
+	for k, v := range other.names {
+		db.names[k] = mergeSources(db.names[k], v)
+	}
+
+	for k := range other.partial {
+		db.partial[k] = struct{}{}
+	}
+}
+
+func mergeSources(dst, src []source) []source {
+	seen := make(map[source]struct{}, len(dst))
+	for _, s := range dst {
+		seen[s] = struct{}{}
+	}
+
+	for _, s := range src {
+		if _, ok := seen[s]; !ok {
+			dst = append(dst, s)
+			seen[s] = struct{}{}
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,78 @@
+package synchronizer
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"errors"
+	"hash"
+	"hash/crc32"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+)
+
+var errChecksumMismatch = errors.New("checksum mismatch")
+
+// FastScan configures whether scanning only uses CRC32 values regardless of
+// the checksum algorithm. For zip and 7z archives these are read from the
+// archive metadata without decompressing anything. Each ROM is instead
+// checked against the configured checksum algorithm as it is copied, any
+// hard or symbolic links are not checked
+func FastScan(v bool) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.fastScan = v
+		return nil
+	}
+}
+
+// SetFastScan configures whether s only uses CRC32 values when scanning
+func (s *Synchronizer) SetFastScan(v bool) error {
+	return s.setOption(FastScan(v))
+}
+
+// dbChecksum returns the checksum algorithm used to populate and search
+// the DB
+func (s *Synchronizer) dbChecksum() rom.Checksum {
+	if s.fastScan {
+		return rom.CRC32
+	}
+	return s.checksum
+}
+
+// verifier hashes everything written to it except for an initial header
+type verifier struct {
+	hash.Hash
+	skip uint64
+}
+
+func (v *verifier) Write(p []byte) (int, error) {
+	n := len(p)
+	if v.skip >= uint64(n) {
+		v.skip -= uint64(n)
+		return n, nil
+	}
+	p, v.skip = p[v.skip:], 0
+	_, _ = v.Hash.Write(p)
+	return n, nil
+}
+
+// newVerifier returns a verifier for r if copying it needs checking against
+// the configured checksum algorithm, otherwise nil. header is the length
+// of any header in the copy that isn't part of the checksum
+func (s *Synchronizer) newVerifier(r dat.ROM, header uint64) *verifier {
+	if !s.fastScan || s.checksum == rom.CRC32 || r.Checksum(s.checksum) == "" {
+		return nil
+	}
+
+	var h hash.Hash
+	switch s.checksum {
+	case rom.MD5:
+		h = md5.New()
+	case rom.SHA1:
+		h = sha1.New()
+	default:
+		h = crc32.NewIEEE()
+	}
+
+	return &verifier{h, header}
+}
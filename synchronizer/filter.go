@@ -0,0 +1,127 @@
+package synchronizer
+
+import (
+	"regexp"
+
+	"github.com/bodgit/rom/dat"
+)
+
+// Regions configures the regions, such as "USA" and "World", used to
+// filter games by the flags in their No-Intro style names. Only games with
+// at least one of the regions are synchronized, any other games are
+// treated as if they weren't in the dat file
+func Regions(regions ...string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.filters = append(s.filters, dat.Region(regions...))
+		return nil
+	}
+}
+
+// SetRegions configures the regions used by s to filter games
+func (s *Synchronizer) SetRegions(regions ...string) error {
+	return s.setOption(Regions(regions...))
+}
+
+// ExcludeRegions is like Regions however games with any of the regions
+// are not synchronized
+func ExcludeRegions(regions ...string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.filters = append(s.filters, dat.Not(dat.Region(regions...)))
+		return nil
+	}
+}
+
+// SetExcludeRegions configures the regions used by s to exclude games
+func (s *Synchronizer) SetExcludeRegions(regions ...string) error {
+	return s.setOption(ExcludeRegions(regions...))
+}
+
+// Languages is like Regions but uses languages, such as "En" or "Fr"
+func Languages(languages ...string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.filters = append(s.filters, dat.Language(languages...))
+		return nil
+	}
+}
+
+// SetLanguages configures the languages used by s to filter games
+func (s *Synchronizer) SetLanguages(languages ...string) error {
+	return s.setOption(Languages(languages...))
+}
+
+// ExcludeLanguages is like ExcludeRegions but uses languages
+func ExcludeLanguages(languages ...string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.filters = append(s.filters, dat.Not(dat.Language(languages...)))
+		return nil
+	}
+}
+
+// SetExcludeLanguages configures the languages used by s to exclude games
+func (s *Synchronizer) SetExcludeLanguages(languages ...string) error {
+	return s.setOption(ExcludeLanguages(languages...))
+}
+
+func matchGames(patterns []string) (func(dat.Game) bool, error) {
+	res := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, re)
+	}
+
+	return func(g dat.Game) bool {
+		for _, re := range res {
+			if re.MatchString(g.Name) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+// IncludeGames configures regular expressions matched against the name of
+// each game. Only games matching at least one are synchronized, any other
+// games are treated as if they weren't in the dat file
+func IncludeGames(patterns ...string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		fn, err := matchGames(patterns)
+		if err != nil {
+			return err
+		}
+		s.filters = append(s.filters, fn)
+		return nil
+	}
+}
+
+// SetIncludeGames configures the regular expressions used by s to filter
+// games by name
+func (s *Synchronizer) SetIncludeGames(patterns ...string) error {
+	return s.setOption(IncludeGames(patterns...))
+}
+
+// ExcludeGames is like IncludeGames however games matching any of the
+// regular expressions are not synchronized
+func ExcludeGames(patterns ...string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		fn, err := matchGames(patterns)
+		if err != nil {
+			return err
+		}
+		s.filters = append(s.filters, dat.Not(fn))
+		return nil
+	}
+}
+
+// SetExcludeGames configures the regular expressions used by s to exclude
+// games by name
+func (s *Synchronizer) SetExcludeGames(patterns ...string) error {
+	return s.setOption(ExcludeGames(patterns...))
+}
+
+// filtered returns true if game should be ignored
+func (s *Synchronizer) filtered(game dat.Game) bool {
+	return len(s.filters) > 0 && !dat.All(s.filters...)(game)
+}
@@ -0,0 +1,148 @@
+package synchronizer
+
+import (
+	"github.com/bodgit/rom"
+)
+
+// WriterFactory describes the format used to store the ROMs of each game
+type WriterFactory struct {
+	// Extension is appended to the game name to form the filename
+	Extension string
+	// NewWriter returns a new rom.Writer used to create the named file
+	NewWriter func(string) (rom.Writer, error)
+	// NewReader returns a new rom.Reader used to read an existing file
+	NewReader func(string) (rom.Reader, error)
+	// Valid returns true if the already opened rom.Reader is correctly
+	// formatted, otherwise the file is rebuilt
+	Valid func(rom.Reader) bool
+	// NewTrustedReader, if set, opens an archive that was previously found
+	// to be valid without validating it again
+	NewTrustedReader func(string) (rom.Reader, error)
+	// Reproducible is true if files created from identical ROMs are
+	// byte-identical, which allows them to be shared by linking
+	Reproducible bool
+}
+
+var (
+	// TorrentZip creates TorrentZip archives. Any existing zip archive that
+	// isn't a valid TorrentZip is rebuilt. This is the default
+	TorrentZip = WriterFactory{
+		Extension: ".zip",
+		NewWriter: func(filename string) (rom.Writer, error) {
+			return rom.NewTorrentZipWriter(filename)
+		},
+		NewReader: func(filename string) (rom.Reader, error) {
+			reader, err := rom.NewTorrentZipReader(filename)
+			if err == rom.ErrNotTorrentZip {
+				return rom.NewZipReader(filename)
+			}
+			return reader, err
+		},
+		Valid: func(reader rom.Reader) bool {
+			v, ok := reader.(rom.Validator)
+			return ok && v.Valid()
+		},
+		NewTrustedReader: func(filename string) (rom.Reader, error) {
+			return rom.NewZipReader(filename)
+		},
+		Reproducible: true,
+	}
+
+	// Zip creates plain zip archives
+	Zip = WriterFactory{
+		Extension: ".zip",
+		NewWriter: func(filename string) (rom.Writer, error) {
+			return rom.NewZipWriter(filename)
+		},
+		NewReader: func(filename string) (rom.Reader, error) {
+			return rom.NewZipReader(filename)
+		},
+		Valid: func(rom.Reader) bool {
+			return true
+		},
+	}
+
+	// Directory extracts the ROMs of each game into a directory named after
+	// the game. Each file is verified against the checksums in the dat file
+	// on subsequent runs, provided the target directory is also scanned
+	Directory = WriterFactory{
+		NewWriter: func(filename string) (rom.Writer, error) {
+			return rom.NewDirectoryWriter(filename)
+		},
+		NewReader: func(filename string) (rom.Reader, error) {
+			return rom.NewDirectoryReader(filename)
+		},
+		Valid: func(rom.Reader) bool {
+			return true
+		},
+	}
+)
+
+// Format configures the format used to store the ROMs of each game
+func Format(f WriterFactory) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.format = f
+		return nil
+	}
+}
+
+// SetFormat configures the format used by s to store the ROMs of each game
+func (s *Synchronizer) SetFormat(f WriterFactory) error {
+	return s.setOption(Format(f))
+}
+
+// UnpackedFormat configures the format used instead of the one set with
+// Format for any dat file synchronized with UpdateFiles whose header says
+// its games are unpacked, usually Directory
+func UnpackedFormat(f WriterFactory) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.unpacked = f
+		return nil
+	}
+}
+
+// SetUnpackedFormat configures the format used by s for unpacked dat files
+func (s *Synchronizer) SetUnpackedFormat(f WriterFactory) error {
+	return s.setOption(UnpackedFormat(f))
+}
+
+// TrustTarget configures whether an existing archive in the target
+// directory that was valid on a previous run is trusted, provided its size
+// and modification time haven't changed, rather than being validated
+// again. This requires a Cache to remember which archives were valid
+func TrustTarget(v bool) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.trustTarget = v
+		return nil
+	}
+}
+
+// SetTrustTarget configures whether s trusts previously valid archives
+func (s *Synchronizer) SetTrustTarget(v bool) error {
+	return s.setOption(TrustTarget(v))
+}
+
+// openArchive opens the existing archive filename for a game, returning
+// whether it is valid for the configured format
+func (s *Synchronizer) openArchive(filename string) (rom.Reader, bool, error) {
+	trust := s.trustTarget && s.cache != nil && s.format.NewTrustedReader != nil
+	if trust && s.cache.valid(filename) {
+		reader, err := s.format.NewTrustedReader(filename)
+		return reader, err == nil, err
+	}
+
+	reader, err := s.format.NewReader(filename)
+	if err != nil {
+		return nil, false, err
+	}
+
+	valid := s.format.Valid(reader)
+	if valid && trust {
+		if err := s.cache.setValid(filename); err != nil {
+			reader.Close()
+			return nil, false, err
+		}
+	}
+
+	return reader, valid, nil
+}
@@ -0,0 +1,218 @@
+package synchronizer
+
+import (
+	"encoding/xml"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/bodgit/rom/dat"
+)
+
+// GameListFile is the name of the EmulationStation game list written to
+// the target directory
+const GameListFile = "gamelist.xml"
+
+// gameListPlaceholders are the metadata elements added empty to each new
+// entry, ready for a scraper or editing by hand
+var gameListPlaceholders = []string{"desc", "image", "releasedate", "developer", "publisher", "genre", "players"}
+
+// xmlElement keeps any element as it was found
+type xmlElement struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Inner   string     `xml:",innerxml"`
+}
+
+type gameListEntry struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr   `xml:",any,attr"`
+	Path    string       `xml:"path"`
+	Name    string       `xml:"name,omitempty"`
+	Other   []xmlElement `xml:",any"`
+}
+
+// textElement returns an element containing just value
+func textElement(name, value string) xmlElement {
+	b := new(strings.Builder)
+	_ = xml.EscapeText(b, []byte(value))
+	return xmlElement{XMLName: xml.Name{Local: name}, Inner: b.String()}
+}
+
+// finishedGames records the filename of each game Update finished with so
+// any game lists can be written afterwards
+type finishedGames struct {
+	mutex sync.Mutex
+	games map[string]dat.Game
+}
+
+func (f *finishedGames) reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.games = make(map[string]dat.Game)
+}
+
+func (f *finishedGames) add(name string, game dat.Game) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.games[name] = game
+}
+
+// listsGames returns true if any game lists need writing once Update has
+// finished
+func (s *Synchronizer) listsGames() bool {
+	return s.gameList || s.launchBox.dir != "" || s.hyperSpin.dir != ""
+}
+
+// writeLists writes any game lists that use datfile for the games found in
+// dir
+func (s *Synchronizer) writeLists(dir string, datfile *dat.File) error {
+	if err := s.writeLaunchBox(dir, datfile); err != nil {
+		return err
+	}
+	return s.writeHyperSpin(dir, datfile)
+}
+
+type gameList struct {
+	XMLName xml.Name        `xml:"gameList"`
+	Entries []gameListEntry `xml:",any"`
+}
+
+// GameList configures whether Update writes an EmulationStation
+// gamelist.xml to the target directory listing every game present in it,
+// so front-ends such as Batocera show each one by its description without a
+// separate scraper pass. Any entries already in the file, along with any
+// metadata they have, are kept as long as their file still exists
+func GameList(v bool) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.gameList = v
+		return nil
+	}
+}
+
+// SetGameList configures whether s writes a gamelist.xml to the target
+// directory
+func (s *Synchronizer) SetGameList(v bool) error {
+	return s.setOption(GameList(v))
+}
+
+// gameListPath returns path as relative to dir using "/" as separator
+func gameListPath(dir, path string) string {
+	path = filepath.FromSlash(path)
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(path))
+}
+
+func newGameListEntry(name string, game dat.Game) gameListEntry {
+	e := gameListEntry{
+		XMLName: xml.Name{Local: "game"},
+		Path:    "./" + filepath.ToSlash(name),
+		Name:    game.Description,
+	}
+	if e.Name == "" {
+		e.Name = game.Name
+	}
+	for _, p := range gameListPlaceholders {
+		var value string
+		if p == "genre" {
+			value = game.Category
+		}
+		e.Other = append(e.Other, textElement(p, value))
+	}
+	return e
+}
+
+// writeGameList merges the games found in dir into its game list
+func (s *Synchronizer) writeGameList(dir string) error {
+	if !s.gameList || s.dryRun {
+		return nil
+	}
+
+	s.finished.mutex.Lock()
+	defer s.finished.mutex.Unlock()
+
+	filename := filepath.Join(dir, GameListFile)
+
+	var list gameList
+	b, err := os.ReadFile(filename)
+	switch {
+	case err == nil:
+		if err := xml.Unmarshal(b, &list); err != nil {
+			return err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	// Keep any existing entry, including folders, whose file still exists
+	entries := list.Entries[:0]
+	listed := make(map[string]struct{}, len(list.Entries))
+	for _, e := range list.Entries {
+		path := gameListPath(dir, e.Path)
+		if _, err := os.Stat(filepath.Join(dir, path)); err != nil {
+			continue
+		}
+		listed[path] = struct{}{}
+		entries = append(entries, e)
+	}
+
+	names := make([]string, 0, len(s.finished.games))
+	for name := range s.finished.games {
+		if _, ok := listed[filepath.ToSlash(name)]; ok {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		entries = append(entries, newGameListEntry(name, s.finished.games[name]))
+	}
+	list.Entries = entries
+
+	return writeXML(filename, list, "\t")
+}
+
+// writeXML replaces filename with v marshalled as XML
+func writeXML(filename string, v interface{}, indent string) error {
+	b, err := xml.MarshalIndent(v, "", indent)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(filename), "."+filepath.Base(filename)+"-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	// Temporary files are only readable by the owner
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+
+	if _, err := f.WriteString(xml.Header); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), filename)
+}
@@ -0,0 +1,110 @@
+package synchronizer
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/bodgit/rom/dat"
+)
+
+// PartialGame is a game that is only partially present
+type PartialGame struct {
+	Name string `json:"name"`
+	// Missing lists the names of the ROMs, disks and samples not found
+	Missing []string `json:"missing"`
+}
+
+// HaveMiss lists the status of every game processed by Update, each list
+// sorted by name. Games synchronized with UpdateFiles are prefixed with the
+// subdirectory of their dat file
+type HaveMiss struct {
+	// Have lists the games that are fully present
+	Have []string `json:"have"`
+	// Partial lists the games that are only partially present
+	Partial []PartialGame `json:"partial"`
+	// Miss lists the games that are entirely absent, including any that
+	// were skipped
+	Miss []string `json:"miss"`
+}
+
+type haveMiss struct {
+	mutex sync.Mutex
+	lists HaveMiss
+}
+
+// record adds game, which should have been fully processed, to the
+// appropriate list, under dir if it is in a subdirectory
+func (h *haveMiss) record(dir string, game dat.Game) {
+	var missing []string
+	have := 0
+	for _, r := range game.ROM {
+		switch {
+		case r.NoDump():
+		case r.Complete() && !r.Missing():
+			have++
+		default:
+			missing = append(missing, r.Name)
+		}
+	}
+	for _, d := range game.Disk {
+		switch {
+		case d.NoDump():
+		case d.Complete():
+			have++
+		default:
+			missing = append(missing, d.Name)
+		}
+	}
+	for _, s := range game.Sample {
+		if s.Complete() {
+			have++
+			continue
+		}
+		missing = append(missing, s.Name)
+	}
+
+	name := game.Name
+	if dir != "" {
+		name = filepath.Join(dir, name)
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	switch {
+	case len(missing) == 0:
+		h.lists.Have = append(h.lists.Have, name)
+	case have == 0:
+		h.lists.Miss = append(h.lists.Miss, name)
+	default:
+		h.lists.Partial = append(h.lists.Partial, PartialGame{name, missing})
+	}
+}
+
+func (h *haveMiss) reset() {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	h.lists = HaveMiss{}
+}
+
+// HaveMiss returns the have and miss lists for every game processed by s
+// since it was last reset
+func (s *Synchronizer) HaveMiss() HaveMiss {
+	s.haveMiss.mutex.Lock()
+	defer s.haveMiss.mutex.Unlock()
+
+	l := HaveMiss{
+		Have:    append([]string(nil), s.haveMiss.lists.Have...),
+		Partial: append([]PartialGame(nil), s.haveMiss.lists.Partial...),
+		Miss:    append([]string(nil), s.haveMiss.lists.Miss...),
+	}
+
+	sort.Strings(l.Have)
+	sort.Slice(l.Partial, func(i, j int) bool {
+		return l.Partial[i].Name < l.Partial[j].Name
+	})
+	sort.Strings(l.Miss)
+
+	return l
+}
@@ -0,0 +1,41 @@
+package synchronizer
+
+import (
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+)
+
+// StripHeaders configures whether any header detected on a copy of a ROM,
+// such as the 16 byte iNES header, is removed when the dat file describes
+// the ROM without it. Existing archives containing headered copies are
+// rebuilt
+func StripHeaders(v bool) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.stripHeaders = v
+		return nil
+	}
+}
+
+// SetStripHeaders configures whether s removes headers when copying ROMs
+func (s *Synchronizer) SetStripHeaders(v bool) error {
+	return s.setOption(StripHeaders(v))
+}
+
+// stripHeader returns the length of the header to remove from file within
+// reader when copying it as r, if any
+func (s *Synchronizer) stripHeader(reader rom.Reader, file string, r dat.ROM) (uint64, error) {
+	if !s.stripHeaders {
+		return 0, nil
+	}
+
+	size, header, err := reader.Size(file)
+	if err != nil {
+		return 0, err
+	}
+
+	if header > 0 && size-header == r.Size {
+		return header, nil
+	}
+
+	return 0, nil
+}
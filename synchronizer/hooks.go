@@ -0,0 +1,118 @@
+package synchronizer
+
+type hooks struct {
+	gameCreated  []func(GameReport)
+	gameModified []func(GameReport)
+	gameDeleted  []func(GameReport)
+	missingROM   []func(string, MissingROM)
+	err          []func(string, error)
+}
+
+// addHook runs add once s is ready to record what happens to each game
+func addHook(add func(*hooks)) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		if s.actions == nil {
+			s.actions = make(map[string]GameReport)
+		}
+		add(&s.hooks)
+		return nil
+	}
+}
+
+// OnGameCreated configures fn to be called with the report for every game
+// created by Update. Any hook may be called concurrently and none are
+// called for changes that would be made in dry-run mode
+func OnGameCreated(fn func(GameReport)) func(*Synchronizer) error {
+	return addHook(func(h *hooks) {
+		h.gameCreated = append(h.gameCreated, fn)
+	})
+}
+
+// SetOnGameCreated adds fn to the hooks called by s when a game is created
+func (s *Synchronizer) SetOnGameCreated(fn func(GameReport)) error {
+	return s.setOption(OnGameCreated(fn))
+}
+
+// OnGameModified is like OnGameCreated for every game modified or rebuilt
+func OnGameModified(fn func(GameReport)) func(*Synchronizer) error {
+	return addHook(func(h *hooks) {
+		h.gameModified = append(h.gameModified, fn)
+	})
+}
+
+// SetOnGameModified adds fn to the hooks called by s when a game is
+// modified
+func (s *Synchronizer) SetOnGameModified(fn func(GameReport)) error {
+	return s.setOption(OnGameModified(fn))
+}
+
+// OnGameDeleted is like OnGameCreated for every game deleted because none
+// of its ROMs could be found
+func OnGameDeleted(fn func(GameReport)) func(*Synchronizer) error {
+	return addHook(func(h *hooks) {
+		h.gameDeleted = append(h.gameDeleted, fn)
+	})
+}
+
+// SetOnGameDeleted adds fn to the hooks called by s when a game is deleted
+func (s *Synchronizer) SetOnGameDeleted(fn func(GameReport)) error {
+	return s.setOption(OnGameDeleted(fn))
+}
+
+// OnMissingROM configures fn to be called with the name of the game and
+// each of its ROMs still missing once Update has processed it
+func OnMissingROM(fn func(string, MissingROM)) func(*Synchronizer) error {
+	return addHook(func(h *hooks) {
+		h.missingROM = append(h.missingROM, fn)
+	})
+}
+
+// SetOnMissingROM adds fn to the hooks called by s for each missing ROM
+func (s *Synchronizer) SetOnMissingROM(fn func(string, MissingROM)) error {
+	return s.setOption(OnMissingROM(fn))
+}
+
+// OnError configures fn to be called with the name of the game and the
+// error for every game that fails while continuing on error
+func OnError(fn func(string, error)) func(*Synchronizer) error {
+	return addHook(func(h *hooks) {
+		h.err = append(h.err, fn)
+	})
+}
+
+// SetOnError adds fn to the hooks called by s when a game fails
+func (s *Synchronizer) SetOnError(fn func(string, error)) error {
+	return s.setOption(OnError(fn))
+}
+
+// callHooks calls any hooks relevant to r, the final report for a game
+func (s *Synchronizer) callHooks(r GameReport, err error) {
+	if err != nil {
+		for _, fn := range s.hooks.err {
+			fn(r.Name, err)
+		}
+	}
+
+	for _, m := range r.Missing {
+		for _, fn := range s.hooks.missingROM {
+			fn(r.Name, m)
+		}
+	}
+
+	if s.dryRun {
+		return
+	}
+
+	var fns []func(GameReport)
+	switch r.Action {
+	case ActionCreated:
+		fns = s.hooks.gameCreated
+	case ActionModified:
+		fns = s.hooks.gameModified
+	case ActionDeleted:
+		fns = s.hooks.gameDeleted
+	}
+	for _, fn := range fns {
+		fn(r)
+	}
+}
@@ -0,0 +1,129 @@
+package synchronizer
+
+import (
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bodgit/rom/dat"
+)
+
+type hyperSpin struct {
+	dir    string
+	system string
+}
+
+type hyperSpinHeader struct {
+	ListName        string `xml:"listname"`
+	LastListUpdate  string `xml:"lastlistupdate"`
+	ListVersion     string `xml:"listversion"`
+	ExporterVersion string `xml:"exporterversion"`
+}
+
+type hyperSpinGame struct {
+	Name         string `xml:"name,attr"`
+	Index        string `xml:"index,attr"`
+	Image        string `xml:"image,attr"`
+	Description  string `xml:"description"`
+	CloneOf      string `xml:"cloneof"`
+	CRC          string `xml:"crc"`
+	Manufacturer string `xml:"manufacturer"`
+	Year         string `xml:"year"`
+	Genre        string `xml:"genre"`
+	Rating       string `xml:"rating"`
+	Enabled      string `xml:"enabled"`
+}
+
+type hyperSpinMenu struct {
+	XMLName xml.Name        `xml:"menu"`
+	Header  hyperSpinHeader `xml:"header"`
+	Games   []hyperSpinGame `xml:"game"`
+}
+
+// HyperSpin configures a directory, normally the Databases directory of a
+// HyperSpin installation, that Update writes a database to for the system
+// listing exactly the games present in the target directory. Games that are
+// only partially present are listed but not enabled. The database is
+// written to a subdirectory named after the system, which is named after
+// the dat file, as with Subdirectory, unless system is set
+func HyperSpin(dir, system string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.hyperSpin = hyperSpin{dir, system}
+		return nil
+	}
+}
+
+// SetHyperSpin configures the directory s writes a HyperSpin database to,
+// along with an optional system name
+func (s *Synchronizer) SetHyperSpin(dir, system string) error {
+	return s.setOption(HyperSpin(dir, system))
+}
+
+func newHyperSpinGame(game dat.Game) hyperSpinGame {
+	g := hyperSpinGame{
+		Name:        game.Name,
+		Description: game.Description,
+		CloneOf:     game.CloneOf,
+		Genre:       game.Category,
+		Enabled:     "Yes",
+	}
+	if g.Description == "" {
+		g.Description = game.Name
+	}
+	// Only single ROM games have a meaningful CRC
+	if len(game.ROM) == 1 {
+		g.CRC = game.ROM[0].CRC32
+	}
+	if !game.Complete() {
+		g.Enabled = "No"
+	}
+	return g
+}
+
+// writeHyperSpin writes the database for datfile listing the games found
+// in dir
+func (s *Synchronizer) writeHyperSpin(dir string, datfile *dat.File) error {
+	if s.hyperSpin.dir == "" || s.dryRun {
+		return nil
+	}
+
+	system := s.hyperSpin.system
+	if system == "" {
+		system = Subdirectory(datfile)
+	}
+	system = safeFilename(system)
+	if system == "" {
+		return errNoSubdirectory
+	}
+
+	s.finished.mutex.Lock()
+	defer s.finished.mutex.Unlock()
+
+	menu := hyperSpinMenu{
+		Header: hyperSpinHeader{
+			ListName:        system,
+			LastListUpdate:  datfile.Header.Date,
+			ListVersion:     datfile.Header.Version,
+			ExporterVersion: "rom",
+		},
+		Games: make([]hyperSpinGame, 0, len(s.finished.games)),
+	}
+
+	for name, game := range s.finished.games {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			continue
+		}
+		menu.Games = append(menu.Games, newHyperSpinGame(game))
+	}
+	sort.Slice(menu.Games, func(i, j int) bool {
+		return menu.Games[i].Name < menu.Games[j].Name
+	})
+
+	sub := filepath.Join(s.hyperSpin.dir, system)
+	if err := os.MkdirAll(sub, os.ModePerm); err != nil {
+		return err
+	}
+
+	return writeXML(filepath.Join(sub, system+".xml"), menu, "\t")
+}
@@ -0,0 +1,164 @@
+package synchronizer
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+const (
+	opCreate = "create"
+	opModify = "modify"
+	opDisk   = "disk"
+	opSample = "sample"
+)
+
+type journalEntry struct {
+	ID   uint64 `json:"id"`
+	Op   string `json:"op,omitempty"`
+	File string `json:"file,omitempty"`
+	Temp string `json:"temp,omitempty"`
+	Done bool   `json:"done,omitempty"`
+}
+
+type journal struct {
+	filename string
+	mutex    sync.Mutex
+	f        *os.File
+	enc      *json.Encoder
+	id       uint64
+}
+
+// Journal configures a file used to record each operation that modifies
+// the target directory as it is started and completed. If a previous update
+// was interrupted, any partially written files and temporary files that it
+// left behind are removed before the next update starts so it can carry on
+// where it left off. The file is removed once an update completes
+func Journal(filename string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.journal = &journal{filename: filename}
+		return nil
+	}
+}
+
+// SetJournal configures the journal file used by s
+func (s *Synchronizer) SetJournal(filename string) error {
+	return s.setOption(Journal(filename))
+}
+
+func readJournal(r io.Reader) []journalEntry {
+	pending := make(map[uint64]journalEntry)
+
+	d := json.NewDecoder(r)
+	for {
+		var e journalEntry
+		// Anything after a truncated entry is ignored
+		if err := d.Decode(&e); err != nil {
+			break
+		}
+		if e.Done {
+			delete(pending, e.ID)
+			continue
+		}
+		pending[e.ID] = e
+	}
+
+	entries := make([]journalEntry, 0, len(pending))
+	for _, e := range pending {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ID < entries[j].ID
+	})
+
+	return entries
+}
+
+// openJournal cleans up after any operations left incomplete by a
+// previous update, removing them from db, and then starts a new journal
+func (s *Synchronizer) openJournal(db *DB) error {
+	if s.journal == nil || s.dryRun {
+		return nil
+	}
+
+	f, err := os.Open(s.journal.filename)
+	switch {
+	case err == nil:
+		entries := readJournal(f)
+		f.Close()
+
+		for _, e := range entries {
+			if e.Temp != "" {
+				s.log(LevelInfo, []Field{{FieldFile, e.Temp}, {FieldAction, "cleanup"}}, "Cleaning up", e.Temp)
+				if err := os.RemoveAll(e.Temp); err != nil {
+					return err
+				}
+			}
+			if e.Op == opCreate && e.File != "" {
+				s.log(LevelInfo, []Field{{FieldFile, e.File}, {FieldAction, "cleanup"}}, "Cleaning up", e.File)
+				if err := os.RemoveAll(e.File); err != nil {
+					return err
+				}
+				db.invalidate(e.File)
+			}
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	if s.journal.f, err = os.Create(s.journal.filename); err != nil {
+		return err
+	}
+	s.journal.enc = json.NewEncoder(s.journal.f)
+
+	return nil
+}
+
+// closeJournal closes the journal, removing it if err is nil, as every
+// operation must have completed
+func (s *Synchronizer) closeJournal(err error) error {
+	if s.journal == nil || s.journal.f == nil {
+		return nil
+	}
+
+	cerr := s.journal.f.Close()
+	s.journal.f, s.journal.enc = nil, nil
+	if cerr != nil || err != nil {
+		return cerr
+	}
+
+	return os.Remove(s.journal.filename)
+}
+
+// begin records the start of an operation that writes to file, using temp
+// if it's not empty, and returns an identifier to pass to end
+func (s *Synchronizer) begin(op, file, temp string) (uint64, error) {
+	if s.journal == nil || s.journal.enc == nil {
+		return 0, nil
+	}
+
+	s.journal.mutex.Lock()
+	defer s.journal.mutex.Unlock()
+
+	s.journal.id++
+	if err := s.journal.enc.Encode(journalEntry{ID: s.journal.id, Op: op, File: file, Temp: temp}); err != nil {
+		return 0, err
+	}
+
+	return s.journal.id, s.journal.f.Sync()
+}
+
+// end records the completion of the operation started with begin
+func (s *Synchronizer) end(id uint64) error {
+	if id == 0 {
+		return nil
+	}
+
+	s.journal.mutex.Lock()
+	defer s.journal.mutex.Unlock()
+
+	return s.journal.enc.Encode(journalEntry{ID: id, Done: true})
+}
@@ -0,0 +1,93 @@
+package synchronizer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadJournal(t *testing.T) {
+	tables := map[string]struct {
+		journal string
+		entries []journalEntry
+	}{
+		"empty": {
+			"",
+			[]journalEntry{},
+		},
+		"done": {
+			`{"id":1,"op":"create","file":"a.zip","temp":"tmp1"}
+{"id":2,"op":"modify","file":"b.zip","temp":"tmp2"}
+{"id":1,"done":true}
+`,
+			[]journalEntry{
+				{ID: 2, Op: opModify, File: "b.zip", Temp: "tmp2"},
+			},
+		},
+		"ordered": {
+			`{"id":2,"op":"disk","file":"b.chd"}
+{"id":1,"op":"create","file":"a.zip","temp":"tmp1"}
+`,
+			[]journalEntry{
+				{ID: 1, Op: opCreate, File: "a.zip", Temp: "tmp1"},
+				{ID: 2, Op: opDisk, File: "b.chd"},
+			},
+		},
+		"truncated": {
+			`{"id":1,"op":"create","file":"a.zip","temp":"tmp1"}
+{"id":1,"do`,
+			[]journalEntry{
+				{ID: 1, Op: opCreate, File: "a.zip", Temp: "tmp1"},
+			},
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, table.entries, readJournal(strings.NewReader(table.journal)))
+		})
+	}
+}
+
+func TestJournalRollback(t *testing.T) {
+	tmp := t.TempDir()
+	target := filepath.Join(tmp, "target")
+	journal := filepath.Join(tmp, "journal")
+
+	// Leave behind what an interrupted update would have
+	if err := os.MkdirAll(filepath.Join(target, "tmp1"), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	for _, file := range []string{"partial.zip", "tmp2", "old.zip"} {
+		if err := os.WriteFile(filepath.Join(target, file), nil, 0o666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries := `{"id":1,"op":"create","file":"` + filepath.Join(target, "partial.zip") + `","temp":"` + filepath.Join(target, "tmp1") + `"}
+{"id":2,"op":"modify","file":"` + filepath.Join(target, "old.zip") + `","temp":"` + filepath.Join(target, "tmp2") + `"}
+`
+	if err := os.WriteFile(journal, []byte(entries), 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := testUpdate(t, tmp, Journal(journal))
+	assert.Equal(t, nil, err)
+
+	for _, file := range []string{"partial.zip", "tmp1", "tmp2"} {
+		_, err := os.Stat(filepath.Join(target, file))
+		assert.True(t, errors.Is(err, os.ErrNotExist), file)
+	}
+	_, err = os.Stat(journal)
+	assert.True(t, errors.Is(err, os.ErrNotExist))
+
+	// A file that was only being modified is kept
+	_, err = os.Stat(filepath.Join(target, "old.zip"))
+	assert.Equal(t, nil, err)
+
+	_, err = os.Stat(filepath.Join(target, "test.zip"))
+	assert.Equal(t, nil, err)
+}
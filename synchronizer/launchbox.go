@@ -0,0 +1,162 @@
+package synchronizer
+
+import (
+	"crypto/sha1"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/bodgit/rom/dat"
+)
+
+type launchBox struct {
+	dir      string
+	platform string
+}
+
+type launchBoxEntry struct {
+	XMLName         xml.Name
+	ApplicationPath string       `xml:"ApplicationPath,omitempty"`
+	Other           []xmlElement `xml:",any"`
+}
+
+type launchBoxPlatform struct {
+	XMLName xml.Name         `xml:"LaunchBox"`
+	Entries []launchBoxEntry `xml:",any"`
+}
+
+// LaunchBox configures a directory, normally the Data\Platforms directory
+// of a LaunchBox installation, that Update writes a platform XML file to
+// listing every game present in the target directory. The platform is named
+// after the dat file, as with Subdirectory, unless platform is set. Any
+// games already in the file, along with any metadata they have, are kept as
+// long as their file still exists
+func LaunchBox(dir, platform string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.launchBox = launchBox{dir, platform}
+		return nil
+	}
+}
+
+// SetLaunchBox configures the directory s writes a LaunchBox platform XML
+// file to, along with an optional platform name
+func (s *Synchronizer) SetLaunchBox(dir, platform string) error {
+	return s.setOption(LaunchBox(dir, platform))
+}
+
+// launchBoxID returns a stable GUID for the game at path
+func launchBoxID(path string) string {
+	b := sha1.Sum([]byte(path))
+	b[6] = b[6]&0x0f | 0x50
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+func newLaunchBoxEntry(path, platform string, game dat.Game) launchBoxEntry {
+	title := game.Description
+	if title == "" {
+		title = game.Name
+	}
+
+	e := launchBoxEntry{
+		XMLName:         xml.Name{Local: "Game"},
+		ApplicationPath: path,
+		Other: []xmlElement{
+			textElement("ID", launchBoxID(path)),
+			textElement("Platform", platform),
+			textElement("Title", title),
+		},
+	}
+	if game.Category != "" {
+		e.Other = append(e.Other, textElement("Genre", game.Category))
+	}
+
+	return e
+}
+
+// writeLaunchBox merges the games found in dir into the platform XML file
+// for datfile
+func (s *Synchronizer) writeLaunchBox(dir string, datfile *dat.File) error {
+	if s.launchBox.dir == "" || s.dryRun {
+		return nil
+	}
+
+	platform := s.launchBox.platform
+	if platform == "" {
+		platform = Subdirectory(datfile)
+	}
+	if safeFilename(platform) == "" {
+		return errNoSubdirectory
+	}
+
+	s.finished.mutex.Lock()
+	defer s.finished.mutex.Unlock()
+
+	filename := filepath.Join(s.launchBox.dir, safeFilename(platform)+".xml")
+
+	var list launchBoxPlatform
+	b, err := os.ReadFile(filename)
+	switch {
+	case err == nil:
+		if err := xml.Unmarshal(b, &list); err != nil {
+			return err
+		}
+	case !errors.Is(err, os.ErrNotExist):
+		return err
+	}
+
+	// Relative paths are relative to the LaunchBox directory itself
+	root, err := filepath.Abs(filepath.Dir(filepath.Dir(s.launchBox.dir)))
+	if err != nil {
+		return err
+	}
+
+	// Keep anything other than a game and any game whose file still exists
+	entries := list.Entries[:0]
+	listed := make(map[string]struct{}, len(list.Entries))
+	for _, e := range list.Entries {
+		if e.XMLName.Local == "Game" {
+			path := filepath.FromSlash(e.ApplicationPath)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(root, path)
+			}
+			if _, err := os.Stat(path); err != nil {
+				continue
+			}
+			listed[filepath.Clean(path)] = struct{}{}
+		}
+		entries = append(entries, e)
+	}
+
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(s.finished.games))
+	for name := range s.finished.games {
+		path := filepath.Join(abs, name)
+		if _, ok := listed[path]; ok {
+			continue
+		}
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		entries = append(entries, newLaunchBoxEntry(filepath.Join(abs, name), platform, s.finished.games[name]))
+	}
+	list.Entries = entries
+
+	if err := os.MkdirAll(s.launchBox.dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	return writeXML(filename, list, "  ")
+}
@@ -0,0 +1,73 @@
+package synchronizer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/bodgit/rom"
+)
+
+// MaxOpenFiles limits the number of source files, such as an archive being
+// scanned or copied from, open at once across all of the workers to avoid
+// exhausting the file descriptor limit. Without a limit a game can have one
+// source open per ROM worker, so up to transfer workers × ROM workers are
+// open at once. With a limit every open source waits its turn, and a game
+// built from several sources one at a time may need some opening more than
+// once. The target directory needs, at most, a few more files per transfer
+// worker
+func MaxOpenFiles(count int) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.openFiles = nil
+		if count > 0 {
+			s.openFiles = make(chan struct{}, count)
+		}
+		return nil
+	}
+}
+
+// SetMaxOpenFiles limits the number of source files open at once by s
+func (s *Synchronizer) SetMaxOpenFiles(count int) error {
+	return s.setOption(MaxOpenFiles(count))
+}
+
+func (s *Synchronizer) acquireFile() {
+	if s.openFiles != nil {
+		s.openFiles <- struct{}{}
+	}
+}
+
+func (s *Synchronizer) releaseFile() {
+	if s.openFiles != nil {
+		<-s.openFiles
+	}
+}
+
+type limitedReader struct {
+	rom.Reader
+	once    sync.Once
+	release func()
+}
+
+func (r *limitedReader) Close() error {
+	err := r.Reader.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// openSource opens name, waiting until doing so doesn't exceed the limit on
+// open files. Closing the reader frees it up again
+func (s *Synchronizer) openSource(ctx context.Context, name string) (rom.Reader, error) {
+	s.acquireFile()
+
+	reader, err := rom.NewReaderContext(ctx, name)
+	if err != nil {
+		s.releaseFile()
+		return nil, err
+	}
+
+	if s.openFiles == nil {
+		return reader, nil
+	}
+
+	return &limitedReader{Reader: reader, release: s.releaseFile}, nil
+}
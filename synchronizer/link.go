@@ -0,0 +1,156 @@
+package synchronizer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+)
+
+// LinkMode determines how identical archives are shared between games
+type LinkMode int
+
+const (
+	// LinkNone always builds a new archive
+	LinkNone LinkMode = iota
+	// LinkHard creates a hard link to an identical archive
+	LinkHard
+	// LinkReflink creates a copy-on-write clone of an identical archive,
+	// which requires filesystem support such as Btrfs or XFS
+	LinkReflink
+)
+
+var errReflinkUnsupported = errors.New("reflinks are not supported on this platform")
+
+// Link configures how a game is created when an identical archive already
+// exists, such as for another dat file or a clone with the same ROMs. If the
+// format is reproducible, such as TorrentZip, an existing valid archive
+// containing exactly the same ROMs under the same names is byte-identical
+// so can be linked rather than stored twice. If linking fails, for example
+// across filesystems, the archive is built as normal
+func Link(mode LinkMode) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		if mode == LinkReflink && !reflinkSupported {
+			return errReflinkUnsupported
+		}
+		s.link = mode
+		return nil
+	}
+}
+
+// SetLink configures how s shares identical archives
+func (s *Synchronizer) SetLink(mode LinkMode) error {
+	return s.setOption(Link(mode))
+}
+
+// findArchive returns the name of an existing valid archive in the current
+// format that contains exactly the ROMs in roms under the same names. If
+// the format is reproducible it is identical to the archive that would be
+// built from them. Only archives accepted by fn are considered
+func (s *Synchronizer) findArchive(roms []dat.ROM, db *DB, fn func(string) bool) (string, error) {
+	candidates := make(map[string]int)
+
+	for _, r := range roms {
+		seen := make(map[string]struct{})
+		for _, src := range db.find(romChecksum(r, s.dbChecksum())) {
+			if src.File != r.Name || !fn(src.Name) {
+				continue
+			}
+			if _, ok := seen[src.Name]; ok {
+				continue
+			}
+			seen[src.Name] = struct{}{}
+			candidates[src.Name]++
+		}
+	}
+
+	for name, n := range candidates {
+		if n != len(roms) {
+			continue
+		}
+
+		// Anything not readable in the target format, such as a 7zip
+		// archive when writing zips, can't be reused as-is
+		reader, err := s.format.NewReader(name)
+		if err != nil {
+			continue
+		}
+		files := reader.Files()
+		valid := s.format.Valid(reader)
+		reader.Close()
+		atomic.AddUint64(&s.rx, reader.Rx())
+
+		if valid && len(files) == len(roms) {
+			return name, nil
+		}
+	}
+
+	return "", nil
+}
+
+// linkGame links the archive for game to an identical existing archive if
+// linking is enabled and one can be found
+func (s *Synchronizer) linkGame(game dat.Game, dir string, db *DB, sources map[string][]source) (bool, error) {
+	if s.link == LinkNone || !s.format.Reproducible {
+		return false, nil
+	}
+
+	var roms []dat.ROM
+	for _, r := range game.ROM {
+		if _, ok := sources[r.Name]; ok {
+			roms = append(roms, r)
+		}
+	}
+
+	filename := filepath.Join(dir, s.gameFilename(game))
+
+	name, err := s.findArchive(roms, db, func(name string) bool {
+		return filepath.Ext(name) == filepath.Ext(filename) && name != filename && !rom.IsRemote(name)
+	})
+	if err != nil || name == "" {
+		return false, err
+	}
+
+	s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldSource, name}, {FieldAction, "link"}}, "Linking", s.gameFilename(game), "to", name)
+
+	if s.dryRun {
+		s.note(game, ActionCreated, map[string][]source{"": {{name, ""}}}, nil)
+		return true, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return false, err
+	}
+
+	switch s.link {
+	case LinkHard:
+		err = os.Link(name, filename)
+	case LinkReflink:
+		err = reflink(name, filename)
+	}
+	if err != nil {
+		s.log(LevelWarn, []Field{{FieldGame, game.Name}, {FieldSource, name}, {FieldError, err}}, "Unable to link", s.gameFilename(game), err)
+		os.Remove(filename)
+		return false, nil
+	}
+
+	s.note(game, ActionCreated, map[string][]source{"": {{name, ""}}}, nil)
+
+	reader, err := s.format.NewReader(filename)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	if err = db.scan(reader, s.dbChecksum()); err != nil {
+		return false, err
+	}
+
+	reader.Close()
+	atomic.AddUint64(&s.rx, reader.Rx())
+
+	return true, nil
+}
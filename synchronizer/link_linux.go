@@ -0,0 +1,31 @@
+package synchronizer
+
+import (
+	"os"
+	"syscall"
+)
+
+const (
+	reflinkSupported = true
+	ficlone          = 0x40049409
+)
+
+func reflink(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	w, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o666)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, w.Fd(), ficlone, r.Fd()); errno != 0 {
+		return errno
+	}
+
+	return w.Close()
+}
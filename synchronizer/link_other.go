@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package synchronizer
+
+const reflinkSupported = false
+
+func reflink(src, dst string) error {
+	return errReflinkUnsupported
+}
@@ -0,0 +1,136 @@
+package synchronizer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+)
+
+// Level is the severity of a log message
+type Level int
+
+// Supported log levels
+const (
+	// LevelDebug is used for routine messages such as each file scanned
+	LevelDebug Level = iota
+	// LevelInfo is used for each change made to the target directory
+	LevelInfo
+	// LevelWarn is used for problems that don't stop synchronizing
+	LevelWarn
+	// LevelError is used for any game that failed
+	LevelError
+)
+
+var levelNames = map[Level]string{
+	LevelDebug: "debug",
+	LevelInfo:  "info",
+	LevelWarn:  "warn",
+	LevelError: "error",
+}
+
+func (l Level) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+// Keys used for the fields attached to log messages
+const (
+	FieldGame   = "game"
+	FieldFile   = "file"
+	FieldSource = "source"
+	FieldAction = "action"
+	FieldError  = "error"
+)
+
+// Field is a key/value pair attached to a log message
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// LeveledLogger is implemented by anything that receives log messages from
+// a Synchronizer. msg is the same human-readable message printed by a
+// standard logger
+type LeveledLogger interface {
+	Log(level Level, msg string, fields ...Field)
+}
+
+type stdLogger struct {
+	logger *log.Logger
+}
+
+func (l stdLogger) Log(_ Level, msg string, _ ...Field) {
+	l.logger.Println(msg)
+}
+
+// StdLogger returns a LeveledLogger that prints every message to logger,
+// ignoring the level and any fields
+func StdLogger(logger *log.Logger) LeveledLogger {
+	return stdLogger{logger}
+}
+
+type jsonLogger struct {
+	mutex sync.Mutex
+	enc   *json.Encoder
+}
+
+func (l *jsonLogger) Log(level Level, msg string, fields ...Field) {
+	m := make(map[string]interface{}, len(fields)+3)
+	for _, f := range fields {
+		if err, ok := f.Value.(error); ok {
+			m[f.Key] = err.Error()
+			continue
+		}
+		m[f.Key] = f.Value
+	}
+	m["time"] = time.Now().Format(time.RFC3339Nano)
+	m["level"] = level.String()
+	m["msg"] = msg
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	_ = l.enc.Encode(m)
+}
+
+// JSONLogger returns a LeveledLogger that writes every message to w as a
+// JSON object on its own line, with the time, level, message and any fields
+func JSONLogger(w io.Writer) LeveledLogger {
+	return &jsonLogger{enc: json.NewEncoder(w)}
+}
+
+// Logger configures the logger used, every message is printed regardless
+// of its level
+func Logger(logger *log.Logger) func(*Synchronizer) error {
+	return LevelLogger(StdLogger(logger))
+}
+
+// SetLogger configures the logger used by s
+func (s *Synchronizer) SetLogger(logger *log.Logger) error {
+	return s.setOption(Logger(logger))
+}
+
+// LevelLogger configures l to receive every log message along with its
+// level and fields describing the game, file, source and action involved
+func LevelLogger(l LeveledLogger) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.logger = l
+		return nil
+	}
+}
+
+// SetLevelLogger configures the LeveledLogger used by s
+func (s *Synchronizer) SetLevelLogger(l LeveledLogger) error {
+	return s.setOption(LevelLogger(l))
+}
+
+// log formats v as log.Println would and passes it to the logger
+func (s *Synchronizer) log(level Level, fields []Field, v ...interface{}) {
+	msg := fmt.Sprintln(v...)
+	s.logger.Log(level, msg[:len(msg)-1], fields...)
+}
@@ -0,0 +1,109 @@
+package synchronizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+)
+
+var errNotWritable = errors.New("writing to URL not supported")
+
+// Mirror configures a URL, such as a WebDAV collection, that each game is
+// uploaded to once Update has created, modified or renamed it, using the
+// same layout as the target directory, including the subdirectory for each
+// dat file with UpdateFiles. Games that Update deletes are also deleted from
+// the mirror
+func Mirror(name string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		if !rom.IsWritable(name) {
+			return fmt.Errorf("%s: %w", name, errNotWritable)
+		}
+		s.mirror = strings.TrimSuffix(name, "/")
+		if s.actions == nil {
+			s.actions = make(map[string]GameReport)
+		}
+		return nil
+	}
+}
+
+// SetMirror configures the URL s uploads each finished game to
+func (s *Synchronizer) SetMirror(name string) error {
+	return s.setOption(Mirror(name))
+}
+
+// mirrorName returns the URL of rel, relative to the target directory,
+// within the mirror
+func (s *Synchronizer) mirrorName(rel string) string {
+	parts := strings.Split(filepath.ToSlash(filepath.Join(s.subdir, rel)), "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return s.mirror + "/" + strings.Join(parts, "/")
+}
+
+func (s *Synchronizer) upload(ctx context.Context, dir, rel string) error {
+	f, err := os.Open(filepath.Join(dir, rel))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	// Games using a directory rather than an archive upload every file
+	if fi.IsDir() {
+		names, err := f.Readdirnames(-1)
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			if err := s.upload(ctx, dir, filepath.Join(rel, name)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	s.log(LevelInfo, []Field{{FieldFile, rel}, {FieldAction, "upload"}}, "Uploading", rel, "to", s.mirror)
+
+	return rom.PutContext(ctx, s.mirrorName(rel), f, fi.Size())
+}
+
+// mirrorGame uploads or deletes the file for game in the mirror according
+// to what Update just did with it
+func (s *Synchronizer) mirrorGame(ctx context.Context, game dat.Game, dir string) error {
+	if s.mirror == "" || s.dryRun {
+		return nil
+	}
+
+	s.reportMutex.Lock()
+	r, ok := s.actions[game.Name]
+	s.reportMutex.Unlock()
+	if !ok {
+		return nil
+	}
+
+	rel := s.gameFilename(game)
+
+	switch r.Action {
+	case ActionCreated, ActionModified, ActionRenamed:
+		return s.upload(ctx, dir, rel)
+	case ActionDeleted:
+		s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldAction, "delete"}}, "Deleting", rel, "from", s.mirror)
+		if err := rom.DeleteContext(ctx, s.mirrorName(rel)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
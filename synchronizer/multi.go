@@ -0,0 +1,149 @@
+package synchronizer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bodgit/rom/dat"
+)
+
+var errNoSubdirectory = errors.New("dat file has no name or description")
+
+// Subdirectory returns the name of the subdirectory used for datfile when
+// synchronizing several dat files at once. It is based on the name in the
+// header, falling back to the description, with any characters that aren't
+// safe to use in a filename replaced
+func Subdirectory(datfile *dat.File) string {
+	name := datfile.Header.Name
+	if name == "" {
+		name = datfile.Header.Description
+	}
+
+	return safeFilename(name)
+}
+
+// safeFilename replaces any characters in name that aren't safe to use in
+// a filename
+func safeFilename(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`<>:"/\|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+
+	return strings.Trim(name, " .")
+}
+
+func subdirectories(datfiles []*dat.File) ([]string, error) {
+	names := make([]string, 0, len(datfiles))
+	seen := make(map[string]struct{}, len(datfiles))
+	for _, datfile := range datfiles {
+		name := Subdirectory(datfile)
+		if name == "" {
+			return nil, errNoSubdirectory
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("more than one dat file uses the subdirectory %s", name)
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// UpdateFiles is like Update except each of the datfiles is synchronized
+// with its own subdirectory of dir, as returned by Subdirectory, so a
+// collection covering several systems can be maintained using the one db.
+// Any dat file whose games are unpacked uses the format set with
+// UnpackedFormat
+func (s *Synchronizer) UpdateFiles(dir string, db *DB, datfiles ...*dat.File) error {
+	return s.UpdateFilesContext(context.Background(), dir, db, datfiles...)
+}
+
+// UpdateFilesContext is like UpdateFiles but stops early if ctx is
+// cancelled. If continuing on error, an UpdateError is returned listing
+// every game that failed across all of the dat files
+func (s *Synchronizer) UpdateFilesContext(ctx context.Context, dir string, db *DB, datfiles ...*dat.File) error {
+	names, err := subdirectories(datfiles)
+	if err != nil {
+		return err
+	}
+
+	format := s.format
+	defer func() {
+		s.subdir = ""
+		s.format = format
+	}()
+
+	var errs []*GameError
+	for i, datfile := range datfiles {
+		sub := filepath.Join(dir, names[i])
+		// Keep each subdirectory in the mirror too
+		s.subdir = names[i]
+		s.format = s.formatFor(datfile, format)
+		if !s.dryRun {
+			if err := os.MkdirAll(sub, os.ModePerm); err != nil {
+				return err
+			}
+		}
+
+		var uerr *UpdateError
+		if err := s.UpdateContext(ctx, sub, datfile, db); err != nil {
+			if !errors.As(err, &uerr) {
+				return err
+			}
+			for _, e := range uerr.Errors {
+				errs = append(errs, &GameError{filepath.Join(names[i], e.Game), e.Err})
+			}
+		}
+	}
+
+	if len(errs) > 0 {
+		return &UpdateError{errs}
+	}
+
+	return nil
+}
+
+// DeleteFiles is like Delete for each of the datfiles synchronized with
+// UpdateFiles. Anything else directly within dir is left alone
+func (s *Synchronizer) DeleteFiles(dir string, datfiles ...*dat.File) error {
+	return s.DeleteFilesContext(context.Background(), dir, datfiles...)
+}
+
+// DeleteFilesContext is like DeleteFiles but stops early if ctx is
+// cancelled
+func (s *Synchronizer) DeleteFilesContext(ctx context.Context, dir string, datfiles ...*dat.File) error {
+	names, err := subdirectories(datfiles)
+	if err != nil {
+		return err
+	}
+
+	format := s.format
+	defer func() {
+		s.format = format
+	}()
+
+	for i, datfile := range datfiles {
+		s.format = s.formatFor(datfile, format)
+		if err := s.DeleteContext(ctx, filepath.Join(dir, names[i]), datfile); err != nil && !(s.dryRun && os.IsNotExist(err)) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatFor returns the format used for datfile, which is format unless
+// datfile is unpacked and an unpacked format has been set
+func (s *Synchronizer) formatFor(datfile *dat.File, format WriterFactory) WriterFactory {
+	if datfile.Header.Unpacked() && s.unpacked.NewWriter != nil {
+		return s.unpacked
+	}
+	return format
+}
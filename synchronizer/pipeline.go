@@ -2,7 +2,7 @@ package synchronizer
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"io"
 	"os"
 	"path/filepath"
@@ -21,33 +21,92 @@ func (s *Synchronizer) findFiles(ctx context.Context, dir string) (<-chan string
 	go func() {
 		defer close(out)
 		defer close(errc)
-		errc <- filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
 
-			// Ignore any hidden files or directories, otherwise we end up fighting with things like Spotlight, etc.
-			if info.Name()[0] == '.' && (info.Mode().IsDir() || strings.HasPrefix(info.Name(), "._")) {
-				s.logger.Println("Ignoring", filepath.Join(dir, info.Name()))
-				if info.Mode().IsDir() {
-					return filepath.SkipDir
+		if rom.IsRemote(dir) {
+			errc <- s.remoteFiles(ctx, dir, out)
+			return
+		}
+
+		// Real paths of any directories reached by following a symlink
+		followed := make(map[string]struct{})
+
+		var walk func(string, string) error
+		walk = func(root, prefix string) error {
+			return filepath.Walk(root, func(file string, info os.FileInfo, err error) error {
+				if err != nil {
+					return err
 				}
-				return nil
-			}
 
-			// Ignore anything that isn't a normal file
-			if !info.Mode().IsRegular() {
-				return nil
-			}
+				// Ignore any hidden files or directories, otherwise we end up fighting with things like Spotlight, etc.
+				if info.Name()[0] == '.' && (info.Mode().IsDir() || strings.HasPrefix(info.Name(), "._")) {
+					s.log(LevelDebug, []Field{{FieldFile, filepath.Join(root, info.Name())}}, "Ignoring", filepath.Join(root, info.Name()))
+					if info.Mode().IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
 
-			select {
-			case out <- file:
-			case <-ctx.Done():
-				return errors.New("walk cancelled")
-			}
+				rel, err := filepath.Rel(root, file)
+				if err != nil {
+					return err
+				}
+				rel = filepath.Join(prefix, rel)
+
+				if s.excluded(rel) {
+					s.log(LevelDebug, []Field{{FieldFile, file}}, "Excluding", file)
+					if info.Mode().IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
 
-			return nil
-		})
+				if info.Mode()&os.ModeSymlink != 0 && s.symlinks {
+					target, err := filepath.EvalSymlinks(file)
+					if err != nil {
+						s.log(LevelWarn, []Field{{FieldFile, file}, {FieldError, err}}, "Ignoring", file, err)
+						return nil
+					}
+
+					if info, err = os.Stat(target); err != nil {
+						return err
+					}
+
+					if info.IsDir() {
+						parent, err := filepath.EvalSymlinks(filepath.Dir(file))
+						if err != nil {
+							return err
+						}
+
+						if _, ok := followed[target]; ok || parent == target || strings.HasPrefix(parent, target+string(filepath.Separator)) {
+							s.log(LevelDebug, []Field{{FieldFile, file}}, "Ignoring", file, "as it has already been followed or would cause a loop")
+							return nil
+						}
+						followed[target] = struct{}{}
+
+						return walk(target, rel)
+					}
+				}
+
+				// Ignore anything that isn't a normal file
+				if !info.Mode().IsRegular() {
+					return nil
+				}
+
+				s.reportProgress(func(p *progress) {
+					p.totalFiles++
+				})
+
+				select {
+				case out <- file:
+				case <-ctx.Done():
+					return ctx.Err()
+				}
+
+				return nil
+			})
+		}
+
+		errc <- walk(dir, "")
 	}()
 	return out, errc, nil
 }
@@ -77,20 +136,54 @@ func (s *Synchronizer) mergeFiles(ctx context.Context, in ...<-chan string) (<-c
 	return out, errc, nil
 }
 
-func (s *Synchronizer) scanROM(db *DB, file string) error {
-	reader, err := rom.NewReader(file)
+func (s *Synchronizer) scanROM(ctx context.Context, db *DB, file string) error {
+	// Remote files are never cached as there's nothing to tell if they
+	// have changed
+	remote := rom.IsRemote(file)
+
+	if filepath.Ext(file) == chdExtension && !remote {
+		s.acquireFile()
+		ok, err := s.scanDisk(db, file)
+		s.releaseFile()
+		if ok || err != nil {
+			return err
+		}
+	}
+
+	if s.cache != nil && !remote {
+		if files, ok := s.cache.get(file, s.dbChecksum()); ok {
+			db.add(file, files)
+			return nil
+		}
+	}
+
+	reader, err := s.openSource(ctx, file)
 	if err != nil {
 		return err
 	}
 	defer reader.Close()
 
-	s.logger.Println("Scanning", reader.Name())
+	s.log(LevelDebug, []Field{{FieldFile, reader.Name()}}, "Scanning", reader.Name())
 
-	if err = db.scan(reader, s.checksum); err != nil {
+	files, err := scanReader(reader, s.dbChecksum(), s.sizeWanted)
+	if err != nil {
 		return err
 	}
 
+	db.add(reader.Name(), files)
+
 	atomic.AddUint64(&s.rx, reader.Rx())
+	s.throttle.wait(int64(reader.Rx()))
+
+	// Don't cache an incomplete scan
+	if len(files) < len(reader.Files()) {
+		db.skipped(reader.Name())
+		return nil
+	}
+
+	if s.cache != nil && !remote {
+		return s.cache.put(file, s.dbChecksum(), files)
+	}
 
 	return nil
 }
@@ -100,37 +193,97 @@ func (s *Synchronizer) scanFiles(ctx context.Context, db *DB, in <-chan string)
 	go func() {
 		defer close(errc)
 		for file := range in {
-			if err := s.scanROM(db, file); err != nil {
+			if err := ctx.Err(); err != nil {
 				errc <- err
 				return
 			}
+			if err := s.scanROM(ctx, db, file); err != nil {
+				errc <- err
+				return
+			}
+
+			s.reportProgress(func(p *progress) {
+				p.files++
+			})
 		}
 	}()
 	return errc, nil
 }
 
-func (s *Synchronizer) allGames(ctx context.Context, datfile *dat.File) (<-chan dat.Game, <-chan error) {
+func (s *Synchronizer) allGames(ctx context.Context, games func(func(dat.Game) error) error) (<-chan dat.Game, <-chan error) {
 	out := make(chan dat.Game)
 	errc := make(chan error, 1)
 	go func() {
 		defer close(out)
 		defer close(errc)
-		for _, game := range datfile.Game {
+		errc <- games(func(game dat.Game) error {
+			if s.filtered(game) {
+				// Hide the game from any remaining dat file
+				game.Matched()
+				// The total includes every game so count it as done
+				s.reportProgress(func(p *progress) {
+					p.games++
+				})
+				return nil
+			}
 			if _, ok := s.missing[game.Name]; ok {
-				s.logger.Println("Skipping", game.Name)
+				s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldAction, ActionSkipped}}, "Skipping", game.Name)
+				atomic.AddUint64(&s.stats.Skipped, 1)
+				if err := s.emit(game, ActionSkipped, nil); err != nil {
+					return err
+				}
+				s.haveMiss.record(s.subdir, game)
 				game.Matched()
-				continue
+				s.reportProgress(func(p *progress) {
+					p.games++
+				})
+				return nil
+			}
+			for _, w := range game.Normalize() {
+				s.log(LevelWarn, []Field{{FieldGame, game.Name}}, "Warning:", w)
+			}
+			mia := 0
+			for i, r := range game.ROM {
+				if r.Missing() {
+					game.ROM[i].Matched()
+					mia++
+				}
+			}
+			if mia > 0 && mia == len(game.ROM) && len(game.Disk) == 0 {
+				s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldAction, ActionSkipped}}, "Skipping", game.Name)
+				atomic.AddUint64(&s.stats.Skipped, 1)
+				if err := s.emit(game, ActionSkipped, nil); err != nil {
+					return err
+				}
+				s.haveMiss.record(s.subdir, game)
+				game.Matched()
+				s.reportProgress(func(p *progress) {
+					p.games++
+				})
+				return nil
 			}
 			select {
 			case out <- game:
 			case <-ctx.Done():
-				errc <- errors.New("cancelled")
+				return ctx.Err()
 			}
-		}
+			return nil
+		})
 	}()
 	return out, errc
 }
 
+func fileGames(datfile *dat.File) func(func(dat.Game) error) error {
+	return func(fn func(dat.Game) error) error {
+		for _, game := range datfile.Game {
+			if err := fn(game); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
 func popularSource(sources map[string][]source) string {
 	m := make(map[string]int)
 	for _, v := range sources {
@@ -157,14 +310,20 @@ func popularSource(sources map[string][]source) string {
 	}
 
 	sort.Slice(ss, func(i, j int) bool {
+		if ss[i].v == ss[j].v {
+			return ss[i].k < ss[j].k
+		}
 		return ss[i].v > ss[j].v
 	})
 
 	return ss[0].k
 }
 
-func (s *Synchronizer) transfer(writer rom.Writer, game dat.Game, sources map[string][]source) error {
-	// Reduce the sources down to the fewest that provide the most
+// reduceSources reduces the sources down to the best ranked and then the
+// fewest that provide the most
+func (s *Synchronizer) reduceSources(sources map[string][]source) {
+	s.rankSources(sources)
+
 	for name := popularSource(sources); name != ""; name = popularSource(sources) {
 		for k, v := range sources {
 			if len(v) == 1 {
@@ -178,8 +337,36 @@ func (s *Synchronizer) transfer(writer rom.Writer, game dat.Game, sources map[st
 			}
 		}
 	}
+}
+
+func (s *Synchronizer) transfer(ctx context.Context, writer rom.Writer, game dat.Game, sources map[string][]source) error {
+	s.reduceSources(sources)
+
+	if s.concurrentTransfer(sources) {
+		return s.transferConcurrently(ctx, writer, game, sources)
+	}
+
+	// Close each source as soon as nothing else needs it
+	remaining := make(map[string]int)
+	for _, r := range game.ROM {
+		if source, ok := sources[r.Name]; ok {
+			remaining[source[0].Name]++
+		}
+	}
 
 	readers := make(map[string]rom.Reader)
+	defer func() {
+		for _, reader := range readers {
+			reader.Close()
+		}
+	}()
+
+	closeReader := func(name string) {
+		reader := readers[name]
+		reader.Close()
+		atomic.AddUint64(&s.rx, reader.Rx())
+		delete(readers, name)
+	}
 
 	for _, r := range game.ROM {
 		source, ok := sources[r.Name]
@@ -191,52 +378,111 @@ func (s *Synchronizer) transfer(writer rom.Writer, game dat.Game, sources map[st
 
 		reader, ok := readers[src.Name]
 		if !ok {
+			// Only hold one source open at a time when limited
+			if s.openFiles != nil {
+				for name := range readers {
+					closeReader(name)
+				}
+			}
+
 			var err error
-			if reader, err = rom.NewReader(src.Name); err != nil {
+			if reader, err = s.openSource(ctx, src.Name); err != nil {
 				return err
 			}
-			defer reader.Close()
 			readers[src.Name] = reader
 		}
 
-		rr, err := reader.Open(src.File)
-		if err != nil {
+		if err := s.writeROM(writer, r.Name, func(w io.Writer) error {
+			return s.copyROM(w, writer.Name(), reader, game, r, src)
+		}); err != nil {
 			return err
 		}
-		defer rr.Close()
 
-		rw, err := writer.Create(r.Name)
-		if err != nil {
-			return err
+		if remaining[src.Name]--; remaining[src.Name] == 0 {
+			closeReader(src.Name)
 		}
-		defer rw.Close()
+	}
 
-		s.logger.Println("Copying", src.File, "from", reader.Name(), "to", writer.Name(), "as", r.Name)
+	return nil
+}
+
+// writeROM creates name with writer and then fills it using fn
+func (s *Synchronizer) writeROM(writer rom.Writer, name string, fn func(io.Writer) error) error {
+	w, err := writer.Create(name)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	if err := fn(w); err != nil {
+		return err
+	}
+
+	w.Close()
+
+	return nil
+}
 
-		if _, err = io.Copy(rw, rr); err != nil {
+// copyROM copies src from reader to w as r, stripping any header and
+// verifying the checksum as configured. target is the name of the archive
+// being written
+func (s *Synchronizer) copyROM(w io.Writer, target string, reader rom.Reader, game dat.Game, r dat.ROM, src source) error {
+	rr, err := reader.Open(src.File)
+	if err != nil {
+		return err
+	}
+	defer rr.Close()
+
+	strip, err := s.stripHeader(reader, src.File, r)
+	if err != nil {
+		return err
+	}
+	if strip > 0 {
+		s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldFile, r.Name}, {FieldSource, reader.Name()}, {FieldAction, "strip"}}, "Stripping", strip, "byte header from", src.File)
+		if _, err := io.CopyN(io.Discard, rr, int64(strip)); err != nil {
 			return err
 		}
+	}
 
-		rw.Close()
-		rr.Close()
+	_, header, err := reader.Size(src.File)
+	if err != nil {
+		return err
+	}
+	if strip > 0 {
+		header = 0
 	}
 
-	for _, reader := range readers {
-		reader.Close()
-		atomic.AddUint64(&s.rx, reader.Rx())
+	s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldFile, r.Name}, {FieldSource, reader.Name()}, {FieldAction, "copy"}}, "Copying", src.File, "from", reader.Name(), "to", target, "as", r.Name)
+
+	v := s.newVerifier(r, header)
+	if v != nil {
+		w = io.MultiWriter(w, v)
+	}
+
+	if _, err = io.Copy(w, s.throttled(rr)); err != nil {
+		return err
+	}
+
+	if v != nil && checksumToString(v.Sum(nil)) != r.Checksum(s.checksum) {
+		return fmt.Errorf("%s in %s: %w", src.File, reader.Name(), errChecksumMismatch)
 	}
 
+	rr.Close()
+
 	return nil
 }
 
-func (s *Synchronizer) create(game dat.Game, dir string, db *DB) error {
+func (s *Synchronizer) create(ctx context.Context, game dat.Game, dir string, db *DB) error {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
 
 	sources := make(map[string][]source, len(game.ROM))
 
 	for _, r := range game.ROM {
-		if s := db.find(romChecksum(r, s.checksum)); len(s) > 0 {
+		if !wanted(r) {
+			continue
+		}
+		if s := db.find(romChecksum(r, s.dbChecksum())); len(s) > 0 {
 			sources[r.Name] = s
 		}
 	}
@@ -245,32 +491,72 @@ func (s *Synchronizer) create(game dat.Game, dir string, db *DB) error {
 		return nil
 	}
 
-	s.logger.Println("Creating", gameFilename(game))
+	linked, err := s.linkGame(game, dir, db, sources)
+	if err != nil || linked {
+		if linked {
+			atomic.AddUint64(&s.stats.Created, 1)
+		}
+		return err
+	}
+
+	s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldAction, ActionCreated}}, "Creating", s.gameFilename(game))
+	atomic.AddUint64(&s.stats.Created, 1)
+
+	changes := s.changes(game, filepath.Join(dir, s.gameFilename(game)), nil, sources)
 
 	if s.dryRun {
+		s.note(game, ActionCreated, sources, changes)
 		return nil
 	}
 
-	writer, err := rom.NewTorrentZipWriter(filepath.Join(dir, gameFilename(game)))
+	if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, s.gameFilename(game))), os.ModePerm); err != nil {
+		return err
+	}
+
+	// Build the game somewhere else first so a failure part way through
+	// never leaves an incomplete game behind
+	temp, err := os.MkdirTemp(dir, "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(temp)
+
+	id, err := s.begin(opCreate, filepath.Join(dir, s.gameFilename(game)), temp)
+	if err != nil {
+		return err
+	}
+
+	filename := filepath.Join(temp, filepath.Base(s.gameFilename(game)))
+	writer, err := s.format.NewWriter(filename)
 	if err != nil {
 		return err
 	}
 	defer writer.Close()
 
-	if err := s.transfer(writer, game, sources); err != nil {
+	if err := s.transfer(ctx, writer, game, sources); err != nil {
 		return err
 	}
 
 	writer.Close()
 	atomic.AddUint64(&s.tx, writer.Tx())
 
-	reader, err := rom.NewTorrentZipReader(filepath.Join(dir, gameFilename(game)))
+	if err := os.Rename(filename, filepath.Join(dir, s.gameFilename(game))); err != nil {
+		return err
+	}
+
+	if err := s.end(id); err != nil {
+		return err
+	}
+
+	s.note(game, ActionCreated, sources, changes)
+
+	reader, err := s.format.NewReader(filepath.Join(dir, s.gameFilename(game)))
 	if err != nil {
 		return err
 	}
 	defer reader.Close()
 
-	if err = db.scan(reader, s.checksum); err != nil {
+	if err = db.scan(reader, s.dbChecksum()); err != nil {
 		return err
 	}
 
@@ -280,38 +566,47 @@ func (s *Synchronizer) create(game dat.Game, dir string, db *DB) error {
 	return nil
 }
 
-func (s *Synchronizer) modify(game dat.Game, dir string, db *DB) error {
+func (s *Synchronizer) modify(ctx context.Context, game dat.Game, dir string, db *DB) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	var reader rom.Reader
-	var err error
-
-	rewrite := false
-
-	if reader, err = rom.NewTorrentZipReader(filepath.Join(dir, gameFilename(game))); err != nil {
-		if err != rom.ErrNotTorrentZip {
-			return err
-		}
-
-		if reader, err = rom.NewZipReader(filepath.Join(dir, gameFilename(game))); err != nil {
-			return err
-		}
+	reader, valid, err := s.openArchive(filepath.Join(dir, s.gameFilename(game)))
+	if err != nil {
+		return err
 	}
 	defer reader.Close()
 
-	if v, ok := reader.(rom.Validator); !ok || !v.Valid() {
-		rewrite = true
+	rewrite := !valid
+
+	// Any disks share the directory of the game when using a directory
+	// layout so aren't counted
+	files := 0
+	for _, file := range reader.Files() {
+		if !containsString(diskFiles(game), file) {
+			files++
+		}
 	}
 
 	sources := make(map[string][]source, len(game.ROM))
 
 rom:
 	for _, r := range game.ROM {
-		if srcs := db.find(romChecksum(r, s.checksum)); len(srcs) > 0 {
+		if !wanted(r) {
+			// Leave any existing copy alone but never look for one
+			if files := reader.Files(); containsString(files, r.Name) {
+				sources[r.Name] = []source{{reader.Name(), r.Name}}
+			}
+			continue
+		}
+		if srcs := db.find(romChecksum(r, s.dbChecksum())); len(srcs) > 0 {
 			for _, src := range srcs {
-				if src.Name == reader.Name() && src.File == r.Name {
+				if inArchive(src, reader.Name(), r.Name) {
 					sources[r.Name] = []source{{reader.Name(), r.Name}}
+					if strip, err := s.stripHeader(reader, r.Name, r); err != nil {
+						return err
+					} else if strip > 0 {
+						rewrite = true
+					}
 					continue rom
 				}
 			}
@@ -324,24 +619,41 @@ rom:
 	reader.Close()
 	atomic.AddUint64(&s.rx, reader.Rx())
 
-	if !rewrite && len(sources) == len(reader.Files()) {
+	if !rewrite && len(sources) == files {
 		return nil
 	}
 
 	switch len(sources) {
 	case 0:
-		s.logger.Println("Deleting", reader.Name())
+		s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldAction, ActionDeleted}}, "Deleting", reader.Name())
+		atomic.AddUint64(&s.stats.Deleted, 1)
+		s.note(game, ActionDeleted, nil, nil)
 		if s.dryRun {
 			return nil
 		}
-		return os.RemoveAll(reader.Name())
-	case len(reader.Files()):
-		s.logger.Println("Rebuilding", reader.Name())
+		if len(game.Disk) > 0 && isDir(reader.Name()) {
+			for _, file := range reader.Files() {
+				if containsString(diskFiles(game), file) {
+					continue
+				}
+				if err := s.remove(dir, filepath.Join(reader.Name(), file)); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		return s.remove(dir, reader.Name())
+	case files:
+		s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldAction, ActionModified}}, "Rebuilding", reader.Name())
 	default:
-		s.logger.Println("Modifying", reader.Name())
+		s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldAction, ActionModified}}, "Modifying", reader.Name())
 	}
+	atomic.AddUint64(&s.stats.Modified, 1)
+
+	changes := s.changes(game, reader.Name(), reader.Files(), sources)
 
 	if s.dryRun {
+		s.note(game, ActionModified, sources, changes)
 		return nil
 	}
 
@@ -351,33 +663,63 @@ rom:
 	}
 	defer os.RemoveAll(temp)
 
-	filename := filepath.Join(temp, gameFilename(game))
-	writer, err := rom.NewTorrentZipWriter(filename)
+	id, err := s.begin(opModify, reader.Name(), temp)
+	if err != nil {
+		return err
+	}
+
+	filename := filepath.Join(temp, filepath.Base(s.gameFilename(game)))
+	writer, err := s.format.NewWriter(filename)
 	if err != nil {
 		return err
 	}
 	defer writer.Close()
 
-	if err := s.transfer(writer, game, sources); err != nil {
+	if err := s.transfer(ctx, writer, game, sources); err != nil {
 		return err
 	}
 
 	writer.Close()
 	atomic.AddUint64(&s.tx, writer.Tx())
 
+	s.note(game, ActionModified, sources, changes)
+
+	if isDir(reader.Name()) {
+		// Carry over any disks before replacing the whole directory
+		for _, file := range diskFiles(game) {
+			if err := os.Rename(filepath.Join(reader.Name(), file), filepath.Join(filename, file)); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+
+	if err := s.backup(dir, reader.Name()); err != nil {
+		return err
+	}
+
+	if isDir(reader.Name()) {
+		if err := os.RemoveAll(reader.Name()); err != nil {
+			return err
+		}
+	}
+
 	if err := os.Rename(filename, reader.Name()); err != nil {
 		return err
 	}
 
+	if err := s.end(id); err != nil {
+		return err
+	}
+
 	db.invalidate(reader.Name())
 
-	reader, err = rom.NewTorrentZipReader(filepath.Join(dir, gameFilename(game)))
+	reader, err = s.format.NewReader(filepath.Join(dir, s.gameFilename(game)))
 	if err != nil {
 		return err
 	}
 	defer reader.Close()
 
-	if err = db.scan(reader, s.checksum); err != nil {
+	if err = db.scan(reader, s.dbChecksum()); err != nil {
 		return err
 	}
 
@@ -387,63 +729,188 @@ rom:
 	return nil
 }
 
-func (s *Synchronizer) gameWorker(ctx context.Context, dir string, datfile *dat.File, db *DB, in <-chan dat.Game) <-chan error {
+// rename looks for an existing valid archive in dir that doesn't belong
+// to any of the known games but contains exactly the ROMs required by game,
+// typically because the game has been renamed in a newer dat file, and
+// renames it rather than rebuilding it from the sources
+func (s *Synchronizer) rename(game dat.Game, dir string, db *DB, games map[string]struct{}) (bool, error) {
+	if games == nil {
+		return false, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var roms []dat.ROM
+	for _, r := range game.ROM {
+		if wanted(r) {
+			roms = append(roms, r)
+		}
+	}
+
+	name, err := s.findArchive(roms, db, func(name string) bool {
+		rel, err := filepath.Rel(dir, name)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return false
+		}
+		_, ok := games[rel]
+		return !ok
+	})
+	if err != nil || name == "" {
+		return false, err
+	}
+
+	s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldSource, name}, {FieldAction, ActionRenamed}}, "Renaming", name, "to", s.gameFilename(game))
+	atomic.AddUint64(&s.stats.Renamed, 1)
+	s.note(game, ActionRenamed, map[string][]source{"": {{name, ""}}}, nil)
+
+	if s.dryRun {
+		return true, nil
+	}
+
+	filename := filepath.Join(dir, s.gameFilename(game))
+	if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+		return false, err
+	}
+
+	if err := os.Rename(name, filename); err != nil {
+		return false, err
+	}
+
+	db.invalidate(name)
+
+	reader, err := s.format.NewReader(filename)
+	if err != nil {
+		return false, err
+	}
+	defer reader.Close()
+
+	if err = db.scan(reader, s.dbChecksum()); err != nil {
+		return false, err
+	}
+
+	reader.Close()
+	atomic.AddUint64(&s.rx, reader.Rx())
+
+	return true, nil
+}
+
+func (s *Synchronizer) roms(ctx context.Context, game dat.Game, dir string, db *DB, games map[string]struct{}) error {
+	if reader, _, err := s.openArchive(filepath.Join(dir, s.gameFilename(game))); err != nil {
+		if !os.IsNotExist(err) {
+			return err
+		}
+		renamed, err := s.rename(game, dir, db, games)
+		if err != nil {
+			return err
+		}
+		if !renamed {
+			if err := s.create(ctx, game, dir, db); err != nil {
+				return err
+			}
+		}
+	} else {
+		reader.Close()
+		atomic.AddUint64(&s.rx, reader.Rx())
+
+		if err := s.modify(ctx, game, dir, db); err != nil {
+			return err
+		}
+	}
+
+	reader, _, err := s.openArchive(filepath.Join(dir, s.gameFilename(game)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	defer reader.Close()
+
+	files := reader.Files()
+	sort.Strings(files)
+
+	for i, r := range game.ROM {
+		if !wanted(r) {
+			continue
+		}
+		if j := sort.SearchStrings(files, r.Name); j < len(files) && files[j] == r.Name {
+			game.ROM[i].Matched()
+		}
+	}
+
+	reader.Close()
+	atomic.AddUint64(&s.rx, reader.Rx())
+
+	return nil
+}
+
+func (s *Synchronizer) game(ctx context.Context, game dat.Game, dir string, db *DB, games map[string]struct{}) error {
+	if err := s.roms(ctx, game, dir, db, games); err != nil {
+		return err
+	}
+
+	if err := s.disks(game, dir, db); err != nil {
+		return err
+	}
+
+	return s.samples(ctx, game, db)
+}
+
+func (s *Synchronizer) gameWorker(ctx context.Context, dir string, db *DB, games map[string]struct{}, in <-chan dat.Game, done func(dat.Game)) <-chan error {
 	errc := make(chan error, 1)
 	go func() {
 		defer close(errc)
 		for game := range in {
-			if reader, err := rom.NewZipReader(filepath.Join(dir, gameFilename(game))); err != nil {
-				if !os.IsNotExist(err) {
-					errc <- err
-					return
-				}
-				if err := s.create(game, dir, db); err != nil {
-					errc <- err
-					return
-				}
-			} else {
-				reader.Close()
-				atomic.AddUint64(&s.rx, reader.Rx())
+			if err := ctx.Err(); err != nil {
+				errc <- err
+				return
+			}
 
-				if err := s.modify(game, dir, db); err != nil {
+			err := s.game(ctx, game, dir, db, games)
+			if err == nil {
+				err = s.mirrorGame(ctx, game, dir)
+			}
+			if err != nil {
+				if !s.continueOnError {
 					errc <- err
 					return
 				}
+				s.log(LevelError, []Field{{FieldGame, game.Name}, {FieldAction, ActionFailed}, {FieldError, err}}, "Failed", game.Name, err)
+				s.failures.add(game, err)
 			}
 
-			reader, err := rom.NewZipReader(filepath.Join(dir, gameFilename(game)))
-			if err != nil {
-				if os.IsNotExist(err) {
-					continue
-				}
+			if !game.Complete() {
+				atomic.AddUint64(&s.stats.Missing, 1)
+			}
+			s.haveMiss.record(s.subdir, game)
+
+			if err := s.emit(game, ActionNone, err); err != nil {
 				errc <- err
 				return
 			}
 
-			files := reader.Files()
-			sort.Strings(files)
+			done(game)
 
-			for i, r := range game.ROM {
-				if j := sort.SearchStrings(files, r.Name); j < len(files) && files[j] == r.Name {
-					game.ROM[i].Matched()
-				}
-			}
-
-			reader.Close()
-			atomic.AddUint64(&s.rx, reader.Rx())
+			s.reportProgress(func(p *progress) {
+				p.games++
+			})
 		}
 	}()
 	return errc
 }
 
-func waitForPipeline(errs ...<-chan error) error {
-	errc := mergeErrors(errs...)
-	for err := range errc {
-		if err != nil {
-			return err
+// waitForPipeline returns the first error from any stage. The remaining
+// stages are cancelled and waited for so nothing is left half-written
+func waitForPipeline(cancel context.CancelFunc, errs ...<-chan error) error {
+	var first error
+	for err := range mergeErrors(errs...) {
+		if err != nil && first == nil {
+			first = err
+			cancel()
 		}
 	}
-	return nil
+	return first
 }
 
 func mergeErrors(cs ...<-chan error) <-chan error {
@@ -0,0 +1,85 @@
+package synchronizer
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// Phase is used to identify what the Synchronizer is currently doing
+type Phase int
+
+const (
+	// Scanning is the phase where source files are read and checksummed
+	Scanning Phase = iota
+	// Updating is the phase where games are created or modified
+	Updating
+)
+
+// ProgressEvent describes how far through a Scan or Update the Synchronizer
+// has got. Totals are zero if they aren't known, for example the total number
+// of games when using UpdateReader
+type ProgressEvent struct {
+	Phase      Phase
+	Files      uint64
+	TotalFiles uint64
+	Bytes      uint64
+	Games      uint64
+	TotalGames uint64
+}
+
+type progress struct {
+	mutex      sync.Mutex
+	fn         func(ProgressEvent)
+	phase      Phase
+	files      uint64
+	totalFiles uint64
+	games      uint64
+	totalGames uint64
+}
+
+// Progress configures fn to be called each time a file is scanned or a game
+// is processed. Calls are never made concurrently
+func Progress(fn func(ProgressEvent)) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.progress.fn = fn
+		return nil
+	}
+}
+
+// SetProgress configures the function called by s to report progress
+func (s *Synchronizer) SetProgress(fn func(ProgressEvent)) error {
+	return s.setOption(Progress(fn))
+}
+
+func (s *Synchronizer) startProgress(phase Phase, totalGames uint64) {
+	p := &s.progress
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.phase = phase
+	p.files, p.totalFiles = 0, 0
+	p.games, p.totalGames = 0, totalGames
+}
+
+func (s *Synchronizer) reportProgress(update func(*progress)) {
+	p := &s.progress
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	update(p)
+
+	if p.fn == nil {
+		return
+	}
+
+	p.fn(ProgressEvent{
+		Phase:      p.phase,
+		Files:      p.files,
+		TotalFiles: p.totalFiles,
+		Bytes:      atomic.LoadUint64(&s.rx),
+		Games:      p.games,
+		TotalGames: p.totalGames,
+	})
+}
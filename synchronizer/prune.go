@@ -0,0 +1,97 @@
+package synchronizer
+
+import (
+	"context"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bodgit/rom"
+)
+
+// within returns true if name is dir or anything beneath it
+func within(dir, name string) bool {
+	rel, err := filepath.Rel(dir, name)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// Prune removes every file found within the sources when db was scanned
+// whose contents are all now present in dir, so only files that still
+// provide something missing are left behind. Files only partially scanned
+// due to ScanSizes are left alone. Any samples directory counts
+// as part of dir. If a backup directory is configured then files are moved
+// there instead. It should only be called once Update and Delete have
+// succeeded
+func (s *Synchronizer) Prune(dir string, db *DB, sources ...string) error {
+	return s.PruneContext(context.Background(), dir, db, sources...)
+}
+
+// PruneContext is like Prune but stops early if ctx is cancelled
+func (s *Synchronizer) PruneContext(ctx context.Context, dir string, db *DB, sources ...string) error {
+	db.mutex.Lock()
+	files := make(map[string][]checksum)
+	present := make(map[checksum]bool)
+	for c, v := range db.checksums {
+		for _, src := range v {
+			if within(dir, src.Name) || (s.samplesDir != "" && within(s.samplesDir, src.Name)) {
+				present[c] = true
+				continue
+			}
+			if s.backupDir != "" && within(s.backupDir, src.Name) {
+				continue
+			}
+			// Anything not scanned may still be needed and
+			// anything remote can't be removed
+			if _, ok := db.partial[src.Name]; ok || rom.IsRemote(src.Name) {
+				continue
+			}
+			files[src.Name] = append(files[src.Name], c)
+		}
+	}
+	db.mutex.Unlock()
+
+	names := make([]string, 0, len(files))
+file:
+	for name, checksums := range files {
+		for _, c := range checksums {
+			if !present[c] {
+				continue file
+			}
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		for _, source := range sources {
+			if within(dir, source) || !within(source, name) {
+				continue
+			}
+
+			s.log(LevelInfo, []Field{{FieldFile, name}, {FieldAction, "prune"}}, "Pruning", name)
+			atomic.AddUint64(&s.stats.Pruned, 1)
+			if s.dryRun {
+				break
+			}
+
+			root := source
+			if filepath.Clean(source) == name {
+				root = filepath.Dir(source)
+			}
+
+			if err := s.remove(root, name); err != nil {
+				return err
+			}
+			db.invalidate(name)
+
+			break
+		}
+	}
+
+	return nil
+}
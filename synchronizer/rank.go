@@ -0,0 +1,137 @@
+package synchronizer
+
+import (
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/bodgit/rom"
+)
+
+// SourceRank scores a source file, such as an archive, that provides a
+// ROM. Lower scores are preferred
+type SourceRank func(name string) int
+
+// PreferTorrentZip ranks valid TorrentZip archives ahead of any other
+// source
+func PreferTorrentZip() SourceRank {
+	var mutex sync.Mutex
+	valid := make(map[string]bool)
+
+	return func(name string) int {
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		v, ok := valid[name]
+		if !ok {
+			if reader, err := rom.NewTorrentZipReader(name); err == nil {
+				v = reader.Valid()
+				reader.Close()
+			}
+			valid[name] = v
+		}
+
+		if v {
+			return 0
+		}
+		return 1
+	}
+}
+
+// AvoidPaths ranks any source within one of dirs, such as a slow network
+// mount, behind any other source
+func AvoidPaths(dirs ...string) SourceRank {
+	abs := make([]string, 0, len(dirs))
+	for _, dir := range dirs {
+		if a, err := filepath.Abs(dir); err == nil {
+			abs = append(abs, a)
+		}
+	}
+
+	return func(name string) int {
+		if a, err := filepath.Abs(name); err == nil {
+			name = a
+		}
+		for _, dir := range abs {
+			if within(dir, name) {
+				return 1
+			}
+		}
+		return 0
+	}
+}
+
+// PreferSources configures how to choose between sources when more than
+// one provides the same ROM. Only the sources with the best scores are
+// considered, compared by each rank in turn, after which the fewest
+// sources that provide every ROM are chosen with any ties broken by name
+func PreferSources(ranks ...SourceRank) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.ranks = ranks
+		return nil
+	}
+}
+
+// SetPreferSources configures how s chooses between sources
+func (s *Synchronizer) SetPreferSources(ranks ...SourceRank) error {
+	return s.setOption(PreferSources(ranks...))
+}
+
+// compareScores returns -1, 0 or 1 if a is preferred, equal or not
+// preferred compared to b
+func compareScores(a, b []int) int {
+	for i := range a {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// rankSources removes any source that is not one of the best ranked for
+// each ROM
+func (s *Synchronizer) rankSources(sources map[string][]source) {
+	if len(s.ranks) == 0 {
+		return
+	}
+
+	scores := make(map[string][]int)
+	score := func(name string) []int {
+		if v, ok := scores[name]; ok {
+			return v
+		}
+		v := make([]int, len(s.ranks))
+		for i, rank := range s.ranks {
+			v[i] = rank(name)
+		}
+		scores[name] = v
+		return v
+	}
+
+	for k, v := range sources {
+		if len(v) < 2 {
+			continue
+		}
+
+		var best []source
+		for _, src := range v {
+			if len(best) == 0 {
+				best = append(best, src)
+				continue
+			}
+			switch compareScores(score(src.Name), score(best[0].Name)) {
+			case -1:
+				best = append(best[:0], src)
+			case 0:
+				best = append(best, src)
+			}
+		}
+		sort.SliceStable(best, func(i, j int) bool {
+			return best[i].Name < best[j].Name
+		})
+		sources[k] = best
+	}
+}
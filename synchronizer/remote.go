@@ -0,0 +1,46 @@
+package synchronizer
+
+import (
+	"context"
+	"net/url"
+	"strings"
+
+	"github.com/bodgit/rom"
+)
+
+// remoteFiles sends the URL of a remote file to out as if it had been found
+// by walking a directory. A URL ending with a "/" is a directory and every
+// file listed beneath it is sent instead
+func (s *Synchronizer) remoteFiles(ctx context.Context, name string, out chan<- string) error {
+	files := []string{name}
+	if strings.HasSuffix(name, "/") {
+		var err error
+		if files, err = rom.ListContext(ctx, name); err != nil {
+			return err
+		}
+	}
+
+	for _, file := range files {
+		rel := strings.TrimPrefix(file, name)
+		if unescaped, err := url.PathUnescape(rel); err == nil {
+			rel = unescaped
+		}
+
+		if rel != "" && s.excluded(rel) {
+			s.log(LevelDebug, []Field{{FieldFile, file}}, "Excluding", file)
+			continue
+		}
+
+		s.reportProgress(func(p *progress) {
+			p.totalFiles++
+		})
+
+		select {
+		case out <- file:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
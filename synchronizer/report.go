@@ -0,0 +1,148 @@
+package synchronizer
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/bodgit/rom/dat"
+)
+
+// Possible actions taken for a game
+const (
+	ActionNone     = "none"
+	ActionCreated  = "created"
+	ActionModified = "modified"
+	ActionRenamed  = "renamed"
+	ActionDeleted  = "deleted"
+	ActionSkipped  = "skipped"
+	ActionFailed   = "failed"
+)
+
+// Possible changes made to a ROM within an archive
+const (
+	ChangeAdded   = "added"
+	ChangeRemoved = "removed"
+	ChangeRenamed = "renamed"
+)
+
+// ROMChange describes how one ROM within the archive of a game is changed
+type ROMChange struct {
+	ROM    string `json:"rom"`
+	Change string `json:"change"`
+	Source string `json:"source,omitempty"`
+	File   string `json:"file,omitempty"`
+}
+
+// MissingROM describes a ROM that could not be found in any source
+type MissingROM struct {
+	Name  string `json:"name"`
+	Size  uint64 `json:"size"`
+	CRC32 string `json:"crc,omitempty"`
+	MD5   string `json:"md5,omitempty"`
+	SHA1  string `json:"sha1,omitempty"`
+}
+
+// GameReport describes what was done to synchronize one game
+type GameReport struct {
+	Name    string       `json:"name"`
+	Action  string       `json:"action"`
+	Sources []string     `json:"sources,omitempty"`
+	Changes []ROMChange  `json:"changes,omitempty"`
+	Missing []MissingROM `json:"missing,omitempty"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// Report configures w to receive a GameReport encoded as JSON for every game
+// processed by Update, one per line
+func Report(w io.Writer) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.report = json.NewEncoder(w)
+		if s.actions == nil {
+			s.actions = make(map[string]GameReport)
+		}
+		return nil
+	}
+}
+
+// SetReport configures the writer used by s for the JSON report
+func (s *Synchronizer) SetReport(w io.Writer) error {
+	return s.setOption(Report(w))
+}
+
+// note records the action taken for game and which sources were used
+func (s *Synchronizer) note(game dat.Game, action string, sources map[string][]source, changes []ROMChange) {
+	if s.actions == nil {
+		return
+	}
+
+	names := make(map[string]struct{})
+	for _, v := range sources {
+		if len(v) > 0 {
+			names[v[0].Name] = struct{}{}
+		}
+	}
+
+	r := GameReport{
+		Name:    game.Name,
+		Action:  action,
+		Changes: changes,
+	}
+	for name := range names {
+		r.Sources = append(r.Sources, name)
+	}
+	sort.Strings(r.Sources)
+
+	s.reportMutex.Lock()
+	defer s.reportMutex.Unlock()
+
+	s.actions[game.Name] = r
+}
+
+// emit writes the report for game, which should have been fully processed
+// unless err is set, and calls any hooks
+func (s *Synchronizer) emit(game dat.Game, action string, err error) error {
+	if s.actions == nil {
+		return nil
+	}
+
+	s.reportMutex.Lock()
+	r, ok := s.actions[game.Name]
+	if !ok {
+		r = GameReport{
+			Name:   game.Name,
+			Action: action,
+		}
+	}
+	delete(s.actions, game.Name)
+	s.reportMutex.Unlock()
+
+	if err != nil {
+		r.Action = ActionFailed
+		r.Error = err.Error()
+	}
+
+	for _, rom := range game.ROM {
+		if rom.Complete() {
+			continue
+		}
+		r.Missing = append(r.Missing, MissingROM{
+			Name:  rom.Name,
+			Size:  rom.Size,
+			CRC32: rom.CRC32,
+			MD5:   rom.MD5,
+			SHA1:  rom.SHA1,
+		})
+	}
+
+	s.callHooks(r, err)
+
+	if s.report == nil {
+		return nil
+	}
+
+	s.reportMutex.Lock()
+	defer s.reportMutex.Unlock()
+
+	return s.report.Encode(r)
+}
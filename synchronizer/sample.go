@@ -0,0 +1,180 @@
+package synchronizer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+)
+
+const sampleExtension = ".wav"
+
+func sampleSet(game dat.Game) string {
+	if game.SampleOf != "" {
+		return game.SampleOf
+	}
+	return game.Name
+}
+
+// A sample can only be identified by name so it must come from either an
+// archive or a directory named after the sample set
+func sampleSources(db *DB, set, file string) []source {
+	var sources []source
+	for _, src := range db.findName(file) {
+		base := filepath.Base(src.Name)
+		if strings.TrimSuffix(base, filepath.Ext(base)) == set || base == src.File && filepath.Base(filepath.Dir(src.Name)) == set {
+			sources = append(sources, src)
+		}
+	}
+	return sources
+}
+
+func (s *Synchronizer) samples(ctx context.Context, game dat.Game, db *DB) error {
+	if len(game.Sample) == 0 {
+		return nil
+	}
+
+	// Without somewhere to keep the samples there's nothing to match
+	if s.samplesDir == "" {
+		for i := range game.Sample {
+			game.Sample[i].Matched()
+		}
+		return nil
+	}
+
+	// Sample sets are often shared so only build one at a time
+	s.sampleMutex.Lock()
+	defer s.sampleMutex.Unlock()
+
+	set := sampleSet(game)
+	filename := filepath.Join(s.samplesDir, set+".zip")
+
+	sources := make(map[string][]source)
+
+	have, err := s.sampleFiles(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range have {
+		sources[file] = []source{{filename, file}}
+	}
+
+	rebuild := false
+	s.mutex.RLock()
+	for _, sample := range game.Sample {
+		file := sample.Name + sampleExtension
+		if _, ok := sources[file]; ok {
+			continue
+		}
+		if srcs := sampleSources(db, set, file); len(srcs) > 0 {
+			sources[file] = srcs
+			rebuild = true
+		}
+	}
+	s.mutex.RUnlock()
+
+	if rebuild {
+		if len(have) > 0 {
+			s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldFile, filename}, {FieldAction, ActionModified}}, "Modifying", filename)
+		} else {
+			s.log(LevelInfo, []Field{{FieldGame, game.Name}, {FieldFile, filename}, {FieldAction, ActionCreated}}, "Creating", filename)
+		}
+
+		if !s.dryRun {
+			if err := s.buildSamples(ctx, filename, set, sources); err != nil {
+				return err
+			}
+
+			// Invalidating filters the slices other games may be
+			// reading in place
+			s.mutex.Lock()
+			db.invalidate(filename)
+			s.mutex.Unlock()
+
+			if have, err = s.sampleFiles(filename); err != nil {
+				return err
+			}
+		}
+	}
+
+	sort.Strings(have)
+
+	for i, sample := range game.Sample {
+		file := sample.Name + sampleExtension
+		if j := sort.SearchStrings(have, file); j < len(have) && have[j] == file {
+			game.Sample[i].Matched()
+		}
+	}
+
+	return nil
+}
+
+func (s *Synchronizer) sampleFiles(filename string) ([]string, error) {
+	reader, err := rom.NewZipReader(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer reader.Close()
+
+	files := reader.Files()
+
+	reader.Close()
+	atomic.AddUint64(&s.rx, reader.Rx())
+
+	return files, nil
+}
+
+func (s *Synchronizer) buildSamples(ctx context.Context, filename, set string, sources map[string][]source) error {
+	if err := os.MkdirAll(s.samplesDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	// Treat the sample set as a game so the normal transfer can be used
+	game := dat.Game{
+		Name: set,
+	}
+	for file := range sources {
+		game.ROM = append(game.ROM, dat.ROM{Name: file})
+	}
+
+	temp, err := os.MkdirTemp(s.samplesDir, "")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(temp)
+
+	id, err := s.begin(opSample, filename, temp)
+	if err != nil {
+		return err
+	}
+
+	writer, err := rom.NewTorrentZipWriter(filepath.Join(temp, filepath.Base(filename)))
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+
+	if err := s.transfer(ctx, writer, game, sources); err != nil {
+		return err
+	}
+
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	atomic.AddUint64(&s.tx, writer.Tx())
+
+	if err := os.Rename(writer.Name(), filename); err != nil {
+		return err
+	}
+
+	return s.end(id)
+}
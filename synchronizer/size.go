@@ -0,0 +1,44 @@
+package synchronizer
+
+import (
+	"path/filepath"
+
+	"github.com/bodgit/rom/dat"
+)
+
+// ScanSizes restricts scanning to files whose size, less any header,
+// matches a ROM in one of datfiles. Any other file is skipped without being
+// checksummed, which saves a lot of time when the sources contain many
+// unrelated files. Samples are only matched by name so they are always
+// scanned
+func ScanSizes(datfiles ...*dat.File) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		if s.sizes == nil {
+			s.sizes = make(map[uint64]struct{})
+		}
+		for _, datfile := range datfiles {
+			for _, game := range datfile.Game {
+				for _, r := range game.ROM {
+					s.sizes[r.Size] = struct{}{}
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// SetScanSizes configures the dat files used by s to skip files when
+// scanning
+func (s *Synchronizer) SetScanSizes(datfiles ...*dat.File) error {
+	return s.setOption(ScanSizes(datfiles...))
+}
+
+// sizeWanted returns true if file of size, less any header, could possibly
+// match something and so is worth checksumming
+func (s *Synchronizer) sizeWanted(file string, size uint64) bool {
+	if s.sizes == nil || filepath.Ext(file) == sampleExtension {
+		return true
+	}
+	_, ok := s.sizes[size]
+	return ok
+}
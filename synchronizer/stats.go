@@ -0,0 +1,43 @@
+package synchronizer
+
+import "sync/atomic"
+
+// Stats holds counts of what the Synchronizer has done since it was last
+// reset. When running in dry-run mode, the counts are of what would have
+// been done
+type Stats struct {
+	// Created is the number of games created
+	Created uint64
+	// Modified is the number of games modified or rebuilt
+	Modified uint64
+	// Renamed is the number of games renamed from an existing archive
+	Renamed uint64
+	// Deleted is the number of files or directories deleted
+	Deleted uint64
+	// Skipped is the number of games skipped as they are known to be
+	// missing
+	Skipped uint64
+	// Missing is the number of games that are still incomplete
+	Missing uint64
+	// Pruned is the number of source files removed by Prune
+	Pruned uint64
+	// Rx is the number of bytes read
+	Rx uint64
+	// Tx is the number of bytes written
+	Tx uint64
+}
+
+// Stats returns the counts of what s has done since it was last reset
+func (s *Synchronizer) Stats() Stats {
+	return Stats{
+		Created:  atomic.LoadUint64(&s.stats.Created),
+		Modified: atomic.LoadUint64(&s.stats.Modified),
+		Renamed:  atomic.LoadUint64(&s.stats.Renamed),
+		Deleted:  atomic.LoadUint64(&s.stats.Deleted),
+		Skipped:  atomic.LoadUint64(&s.stats.Skipped),
+		Missing:  atomic.LoadUint64(&s.stats.Missing),
+		Pruned:   atomic.LoadUint64(&s.stats.Pruned),
+		Rx:       atomic.LoadUint64(&s.rx),
+		Tx:       atomic.LoadUint64(&s.tx),
+	}
+}
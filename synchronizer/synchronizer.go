@@ -1,19 +1,29 @@
 /*
 Package synchronizer implements a set of methods to maintain a pristine
-directory of TorrentZip files representing the games in a dat file.
+directory of TorrentZip files representing the games in a dat file. Other
+formats, such as plain zip archives, can be used instead with the Format
+option.
+
+Any CHD files listed as disks for a game are kept in a directory named after
+the game alongside its TorrentZip file and are matched using the checksums in
+the CHD header.
 */
 package synchronizer
 
 import (
 	"bufio"
 	"context"
+	"encoding/json"
+	"errors"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"sync"
 	"sync/atomic"
+	"text/template"
 
 	"github.com/bodgit/rom"
 	"github.com/bodgit/rom/dat"
@@ -25,10 +35,63 @@ type Synchronizer struct {
 	workers  int
 	dryRun   bool
 	checksum rom.Checksum
-	logger   *log.Logger
+	logger   LeveledLogger
 	rx       uint64
 	tx       uint64
 	missing  map[string]struct{}
+	cache    *Cache
+	progress progress
+	exclude  []string
+	symlinks bool
+	stats    Stats
+	link     LinkMode
+	format   WriterFactory
+	unpacked WriterFactory
+	template *template.Template
+	journal  *journal
+	throttle *throttle
+
+	trustTarget  bool
+	stripHeaders bool
+	filters      []func(dat.Game) bool
+	haveMiss     haveMiss
+	sizes        map[uint64]struct{}
+	fastScan     bool
+	ranks        []SourceRank
+
+	scanWorkers     int
+	transferWorkers int
+	romWorkers      int
+	openFiles       chan struct{}
+
+	report      *json.Encoder
+	reportMutex sync.Mutex
+	actions     map[string]GameReport
+	hooks       hooks
+
+	continueOnError bool
+	failures        failures
+
+	backupDir   string
+	backupOnce  sync.Once
+	backupStamp string
+
+	samplesDir  string
+	sampleMutex sync.Mutex
+
+	mirror string
+
+	// subdir is the subdirectory of the dat file being synchronized by
+	// UpdateFiles
+	subdir string
+
+	gameList  bool
+	launchBox launchBox
+	hyperSpin hyperSpin
+	finished  finishedGames
+
+	seenGames   map[string]struct{}
+	seenParents map[string]struct{}
 }
 
 // NewSynchronizer returns a new Synchronizer configured with any optional
@@ -36,7 +99,8 @@ type Synchronizer struct {
 func NewSynchronizer(options ...func(*Synchronizer) error) (*Synchronizer, error) {
 	s := new(Synchronizer)
 
-	s.logger = log.New(os.Stderr, "", log.LstdFlags)
+	s.logger = StdLogger(log.New(os.Stderr, "", log.LstdFlags))
+	s.format = TorrentZip
 
 	if err := s.setOption(options...); err != nil {
 		return nil, err
@@ -54,7 +118,8 @@ func (s *Synchronizer) setOption(options ...func(*Synchronizer) error) error {
 	return nil
 }
 
-// Workers sets the numbers of workers used
+// Workers sets the numbers of workers used, unless overridden by
+// ScanWorkers or TransferWorkers
 func Workers(count int) func(*Synchronizer) error {
 	return func(s *Synchronizer) error {
 		s.workers = count
@@ -67,30 +132,59 @@ func (s *Synchronizer) SetWorkers(count int) error {
 	return s.setOption(Workers(count))
 }
 
-// DryRun configures whether changes are only logged
-func DryRun(v bool) func(*Synchronizer) error {
+// ScanWorkers sets the number of workers used to read and checksum files
+// when scanning, which is mostly CPU-bound
+func ScanWorkers(count int) func(*Synchronizer) error {
 	return func(s *Synchronizer) error {
-		s.dryRun = v
+		s.scanWorkers = count
 		return nil
 	}
 }
 
-// SetDryRun configures whether changes are only logged by s
-func (s *Synchronizer) SetDryRun(v bool) error {
-	return s.setOption(DryRun(v))
+// SetScanWorkers sets the number of workers used by s when scanning
+func (s *Synchronizer) SetScanWorkers(count int) error {
+	return s.setOption(ScanWorkers(count))
+}
+
+// TransferWorkers sets the number of workers used to build each game when
+// updating, which is mostly I/O-bound. Using a single worker avoids
+// thrashing a spinning disk
+func TransferWorkers(count int) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.transferWorkers = count
+		return nil
+	}
+}
+
+// SetTransferWorkers sets the number of workers used by s when updating
+func (s *Synchronizer) SetTransferWorkers(count int) error {
+	return s.setOption(TransferWorkers(count))
+}
+
+// workerCount returns count if set, otherwise the general number of
+// workers, defaulting to the number of CPUs
+func (s *Synchronizer) workerCount(count int) int {
+	switch {
+	case count > 0:
+		return count
+	case s.workers > 0:
+		return s.workers
+	default:
+		return runtime.NumCPU()
+	}
 }
 
-// Logger configures the logger used
-func Logger(logger *log.Logger) func(*Synchronizer) error {
+// DryRun configures whether changes are only logged
+func DryRun(v bool) func(*Synchronizer) error {
 	return func(s *Synchronizer) error {
-		s.logger = logger
+		s.dryRun = v
 		return nil
 	}
 }
 
-// SetLogger configures the logger used by s
-func (s *Synchronizer) SetLogger(logger *log.Logger) error {
-	return s.setOption(Logger(logger))
+// SetDryRun configures whether changes are only logged by s
+func (s *Synchronizer) SetDryRun(v bool) error {
+	return s.setOption(DryRun(v))
 }
 
 // Checksum configures the checksum algorithm used
@@ -123,12 +217,63 @@ func (s *Synchronizer) SetMissing(r io.Reader) error {
 	return s.setOption(Missing(r))
 }
 
+// Samples configures the directory used to maintain the zip archives of
+// audio samples required by some games. If not set, samples are ignored
+func Samples(dir string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.samplesDir = dir
+		return nil
+	}
+}
+
+// SetSamples configures the directory used by s to maintain any samples
+func (s *Synchronizer) SetSamples(dir string) error {
+	return s.setOption(Samples(dir))
+}
+
+// FollowSymlinks configures whether symbolic links to files and directories
+// are followed when scanning. Any link that would cause a loop, or a
+// directory that has already been reached through another link, is ignored
+func FollowSymlinks(v bool) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.symlinks = v
+		return nil
+	}
+}
+
+// SetFollowSymlinks configures whether s follows symbolic links when
+// scanning
+func (s *Synchronizer) SetFollowSymlinks(v bool) error {
+	return s.setOption(FollowSymlinks(v))
+}
+
+// UseCache configures c to be consulted when scanning so that any file that
+// has not changed since it was last scanned is not read again
+func UseCache(c *Cache) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.cache = c
+		return nil
+	}
+}
+
+// SetCache configures the Cache used by s when scanning
+func (s *Synchronizer) SetCache(c *Cache) error {
+	return s.setOption(UseCache(c))
+}
+
 // Scan reads one or more directories and any archives within and stores the
 // checksum of every file using the chosen checksum algorithm
 func (s *Synchronizer) Scan(dirs ...string) (*DB, error) {
-	ctx, cancelFunc := context.WithCancel(context.Background())
+	return s.ScanContext(context.Background(), dirs...)
+}
+
+// ScanContext is like Scan but stops early if ctx is cancelled
+func (s *Synchronizer) ScanContext(ctx context.Context, dirs ...string) (*DB, error) {
+	ctx, cancelFunc := context.WithCancel(ctx)
 	defer cancelFunc()
 
+	s.startProgress(Scanning, 0)
+
 	var filecList []<-chan string
 	var errcList []<-chan error
 
@@ -152,10 +297,7 @@ func (s *Synchronizer) Scan(dirs ...string) (*DB, error) {
 		return nil, err
 	}
 
-	workers := s.workers
-	if workers <= 0 {
-		workers = runtime.NumCPU()
-	}
+	workers := s.workerCount(s.scanWorkers)
 
 	for i := 0; i < workers; i++ {
 		errc, err := s.scanFiles(ctx, db, mergec)
@@ -165,7 +307,7 @@ func (s *Synchronizer) Scan(dirs ...string) (*DB, error) {
 		errcList = append(errcList, errc)
 	}
 
-	if err := waitForPipeline(errcList...); err != nil {
+	if err := waitForPipeline(cancelFunc, errcList...); err != nil {
 		return nil, err
 	}
 
@@ -175,39 +317,224 @@ func (s *Synchronizer) Scan(dirs ...string) (*DB, error) {
 // Update attempts to keep dir synchronized with the provided datfile using
 // db to find any missing files based on the checksum value
 func (s *Synchronizer) Update(dir string, datfile *dat.File, db *DB) error {
-	ctx, cancelFunc := context.WithCancel(context.Background())
+	return s.UpdateContext(context.Background(), dir, datfile, db)
+}
+
+// UpdateContext is like Update but stops early if ctx is cancelled. If
+// continuing on error, an UpdateError is returned listing every game that
+// failed
+func (s *Synchronizer) UpdateContext(ctx context.Context, dir string, datfile *dat.File, db *DB) error {
+	games := make(map[string]struct{}, len(datfile.Game))
+	for _, game := range datfile.Game {
+		if !s.filtered(game) {
+			games[s.gameFilename(game)] = struct{}{}
+		}
+	}
+
+	err := s.update(ctx, dir, fileGames(datfile), uint64(len(datfile.Game)), db, games, func(dat.Game) {})
+
+	var uerr *UpdateError
+	if err == nil || errors.As(err, &uerr) {
+		if lerr := s.writeLists(dir, datfile); lerr != nil {
+			return lerr
+		}
+	}
+
+	return err
+}
+
+// UpdateReader is like Update however the dat file is decoded from r one
+// game at a time rather than requiring it to be unmarshalled beforehand,
+// which keeps memory usage down when using very large dat files. It returns
+// a dat.File containing the header and only those games that are not
+// complete, in the same order as r, along with any UpdateError. As the
+// full list of games isn't known up front, renamed games are always rebuilt
+// rather than renamed. Use DeleteSeen rather than Delete afterwards
+func (s *Synchronizer) UpdateReader(dir string, r io.Reader, db *DB) (*dat.File, error) {
+	return s.UpdateReaderContext(context.Background(), dir, r, db)
+}
+
+// UpdateReaderContext is like UpdateReader but stops early if ctx is
+// cancelled
+func (s *Synchronizer) UpdateReaderContext(ctx context.Context, dir string, r io.Reader, db *DB) (*dat.File, error) {
+	var mutex sync.Mutex
+	var incomplete []dat.Game
+
+	// Only the filenames of every game are kept for DeleteSeen, along with
+	// the position of each game so the remaining games keep the dat order
+	s.seenGames = make(map[string]struct{})
+	s.seenParents = make(map[string]struct{})
+	order := make(map[string]int)
+
+	var datfile *dat.File
+	err := s.update(ctx, dir, func(fn func(dat.Game) error) (err error) {
+		datfile, err = dat.Decode(r, func(game dat.Game) error {
+			s.knownFile(game, s.seenGames, s.seenParents)
+			order[game.Name] = len(order)
+			return fn(game)
+		})
+		return
+	}, 0, db, nil, func(game dat.Game) {
+		if game.Complete() {
+			return
+		}
+		mutex.Lock()
+		defer mutex.Unlock()
+		incomplete = append(incomplete, game)
+	})
+
+	var uerr *UpdateError
+	if err != nil && !errors.As(err, &uerr) {
+		return nil, err
+	}
+
+	if lerr := s.writeLists(dir, datfile); lerr != nil {
+		return nil, lerr
+	}
+
+	sort.SliceStable(incomplete, func(i, j int) bool {
+		return order[incomplete[i].Name] < order[incomplete[j].Name]
+	})
+	datfile.Game = incomplete
+
+	return datfile, err
+}
+
+func (s *Synchronizer) update(ctx context.Context, dir string, games func(func(dat.Game) error) error, total uint64, db *DB, known map[string]struct{}, done func(dat.Game)) error {
+	ctx, cancelFunc := context.WithCancel(ctx)
 	defer cancelFunc()
 
+	if err := s.openJournal(db); err != nil {
+		return err
+	}
+
+	s.startProgress(Updating, total)
+
+	if s.listsGames() {
+		s.finished.reset()
+		update := done
+		done = func(game dat.Game) {
+			s.finished.add(s.gameFilename(game), game)
+			update(game)
+		}
+	}
+
 	var errcList []<-chan error
 
-	gamec, errc := s.allGames(ctx, datfile)
+	gamec, errc := s.allGames(ctx, games)
 	errcList = append(errcList, errc)
 
-	workers := s.workers
-	if workers <= 0 {
-		workers = runtime.NumCPU()
-	}
+	workers := s.workerCount(s.transferWorkers)
 
 	for i := 0; i < workers; i++ {
-		errc := s.gameWorker(ctx, dir, datfile, db, gamec)
+		errc := s.gameWorker(ctx, dir, db, known, gamec, done)
 		errcList = append(errcList, errc)
 	}
 
-	if err := waitForPipeline(errcList...); err != nil {
-		return err
+	err := waitForPipeline(cancelFunc, errcList...)
+	// Any game that failed while continuing on error means the journal is
+	// still needed to clean up after it
+	if ferr := s.failures.take(); err == nil {
+		err = ferr
+	}
+	if jerr := s.closeJournal(err); err == nil {
+		err = jerr
 	}
 
-	return nil
+	var uerr *UpdateError
+	if err == nil || errors.As(err, &uerr) {
+		if gerr := s.writeGameList(dir); gerr != nil {
+			return gerr
+		}
+	}
+
+	return err
 }
 
 // Delete removes any file from dir that doesn't match a known game
 func (s *Synchronizer) Delete(dir string, datfile *dat.File) error {
+	return s.DeleteContext(context.Background(), dir, datfile)
+}
+
+// DeleteContext is like Delete but stops early if ctx is cancelled
+func (s *Synchronizer) DeleteContext(ctx context.Context, dir string, datfile *dat.File) error {
+	games, parents := s.knownFiles(datfile)
+
+	return s.unknownFiles(ctx, dir, ".", games, parents, func(name string) error {
+		s.log(LevelInfo, []Field{{FieldFile, name}, {FieldAction, ActionDeleted}}, "Deleting", name)
+		atomic.AddUint64(&s.stats.Deleted, 1)
+		if s.dryRun {
+			return nil
+		}
+		return s.remove(dir, filepath.Join(dir, name))
+	})
+}
+
+var errNothingSeen = errors.New("no dat file has been decoded by UpdateReader")
+
+// DeleteSeen is like Delete but keeps the games decoded by the last
+// UpdateReader rather than those in a dat file
+func (s *Synchronizer) DeleteSeen(dir string) error {
+	return s.DeleteSeenContext(context.Background(), dir)
+}
+
+// DeleteSeenContext is like DeleteSeen but stops early if ctx is cancelled
+func (s *Synchronizer) DeleteSeenContext(ctx context.Context, dir string) error {
+	if s.seenGames == nil {
+		return errNothingSeen
+	}
+
+	games := s.seenGames
+	if s.gameList {
+		games[GameListFile] = struct{}{}
+	}
+
+	return s.unknownFiles(ctx, dir, ".", games, s.seenParents, func(name string) error {
+		s.log(LevelInfo, []Field{{FieldFile, name}, {FieldAction, ActionDeleted}}, "Deleting", name)
+		atomic.AddUint64(&s.stats.Deleted, 1)
+		if s.dryRun {
+			return nil
+		}
+		return s.remove(dir, filepath.Join(dir, name))
+	})
+}
+
+// knownFiles returns the filenames of every game in datfile, including any
+// directories holding disks, and any subdirectories they are within
+func (s *Synchronizer) knownFiles(datfile *dat.File) (map[string]struct{}, map[string]struct{}) {
 	games := make(map[string]struct{}, len(datfile.Game))
+	parents := make(map[string]struct{})
 	for _, game := range datfile.Game {
-		games[gameFilename(game)] = struct{}{}
+		s.knownFile(game, games, parents)
 	}
+	if s.gameList {
+		games[GameListFile] = struct{}{}
+	}
+
+	return games, parents
+}
+
+// knownFile adds the filenames of game to games and parents, unless it is
+// filtered out
+func (s *Synchronizer) knownFile(game dat.Game, games, parents map[string]struct{}) {
+	if s.filtered(game) {
+		return
+	}
+	name := s.gameFilename(game)
+	games[name] = struct{}{}
+	for parent := filepath.Dir(name); parent != "."; parent = filepath.Dir(parent) {
+		parents[parent] = struct{}{}
+	}
+	if len(game.Disk) > 0 {
+		games[game.Name] = struct{}{}
+	}
+}
 
-	f, err := os.Open(dir)
+// unknownFiles calls fn with anything within the subdirectory rel of dir
+// that isn't a known game, descending into any subdirectories that contain
+// games
+func (s *Synchronizer) unknownFiles(ctx context.Context, dir, rel string, games, parents map[string]struct{}, fn func(string) error) error {
+	f, err := os.Open(filepath.Join(dir, rel))
 	if err != nil {
 		return err
 	}
@@ -219,14 +546,23 @@ func (s *Synchronizer) Delete(dir string, datfile *dat.File) error {
 	}
 
 	for _, file := range files {
-		if _, ok := games[file]; ok || file[0] == '.' {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if file[0] == '.' {
 			continue
 		}
-		s.logger.Println("Deleting", file)
-		if s.dryRun {
+		name := filepath.Join(rel, file)
+		if _, ok := games[name]; ok {
 			continue
 		}
-		if err := os.RemoveAll(filepath.Join(dir, file)); err != nil {
+		if _, ok := parents[name]; ok {
+			if err := s.unknownFiles(ctx, dir, name, games, parents, fn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := fn(name); err != nil {
 			return err
 		}
 	}
@@ -234,10 +570,14 @@ func (s *Synchronizer) Delete(dir string, datfile *dat.File) error {
 	return nil
 }
 
-// Reset zeroes the bytes read & written counters
+// Reset zeroes the bytes read & written counters and the Stats
 func (s *Synchronizer) Reset() {
 	atomic.StoreUint64(&s.rx, 0)
 	atomic.StoreUint64(&s.tx, 0)
+	for _, p := range []*uint64{&s.stats.Created, &s.stats.Modified, &s.stats.Renamed, &s.stats.Deleted, &s.stats.Skipped, &s.stats.Missing, &s.stats.Pruned} {
+		atomic.StoreUint64(p, 0)
+	}
+	s.haveMiss.reset()
 }
 
 // Rx returns how many bytes have been read by s
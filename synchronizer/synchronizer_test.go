@@ -0,0 +1,150 @@
+package synchronizer
+
+import (
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/bodgit/rom/dat"
+	"github.com/stretchr/testify/assert"
+)
+
+func testDat() *dat.File {
+	return &dat.File{
+		Header: dat.Header{
+			Name:        "Test",
+			Description: "Test",
+		},
+		Game: []dat.Game{
+			{
+				Name:        "test",
+				Description: "test",
+				ROM: []dat.ROM{
+					{
+						Name:  "test.bin",
+						Size:  20,
+						CRC32: "d580a153",
+						MD5:   "f202a9e83272626f0353a305e1147dc9",
+						SHA1:  "4ebc20b46ea4d010ed9ac1fde4c251cf231a661f",
+					},
+					{
+						Name:  "test.nes",
+						Size:  4,
+						CRC32: "4473ef85",
+						MD5:   "6c9997754fec0660056fb1eddbe7a400",
+						SHA1:  "c45ef3c8dcb569a58feba8d5aee1f47e93ac5cdd",
+					},
+				},
+			},
+		},
+	}
+}
+
+// testUpdate scans a copy of the test archive and updates a new target
+// directory with it, returning the target and the result of Update
+func testUpdate(t *testing.T, tmp string, options ...func(*Synchronizer) error) (string, error) {
+	t.Helper()
+
+	src := filepath.Join(tmp, "src")
+	target := filepath.Join(tmp, "target")
+	for _, dir := range []string{src, target} {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join("..", "testdata", "test.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(src, "test.zip"), b, 0o666); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSynchronizer(append([]func(*Synchronizer) error{Logger(log.New(io.Discard, "", 0))}, options...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := s.Scan(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return target, s.Update(target, testDat(), db)
+}
+
+func TestUpdateBadDump(t *testing.T) {
+	tmp := t.TempDir()
+
+	if _, err := testUpdate(t, tmp); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewSynchronizer(Logger(log.New(io.Discard, "", 0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	target := filepath.Join(tmp, "target")
+
+	// Run twice so the second run finds the bad dump already in the target
+	for i := 0; i < 2; i++ {
+		s.Reset()
+
+		db, err := s.Scan(target, filepath.Join(tmp, "src"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		datfile := testDat()
+		datfile.Game[0].ROM[1].Status = dat.StatusBadDump
+
+		assert.Equal(t, nil, s.Update(target, datfile, db))
+		assert.Equal(t, uint64(0), s.Stats().Missing)
+		assert.True(t, datfile.Game[0].Complete())
+		assert.Equal(t, []string{"test"}, s.HaveMiss().Have)
+	}
+}
+
+func TestUpdateReaderOrder(t *testing.T) {
+	tmp := t.TempDir()
+
+	s, err := NewSynchronizer(Logger(log.New(io.Discard, "", 0)), TransferWorkers(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := s.Scan(filepath.Join("..", "testdata", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enough games that the workers finish some of them out of order
+	names := make([]string, 0, 500)
+	for i := 500; i > 0; i-- {
+		names = append(names, strconv.Itoa(i))
+	}
+
+	var b strings.Builder
+	b.WriteString("<datafile><header><name>Test</name></header>")
+	for _, name := range names {
+		b.WriteString(`<game name="` + name + `"><rom name="missing.bin" size="4" crc="12345678"/></game>`)
+	}
+	b.WriteString("</datafile>")
+
+	datfile, err := s.UpdateReader(tmp, strings.NewReader(b.String()), db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	remaining := make([]string, 0, len(datfile.Game))
+	for _, game := range datfile.Game {
+		remaining = append(remaining, game.Name)
+	}
+	assert.Equal(t, names, remaining)
+}
@@ -0,0 +1,72 @@
+package synchronizer
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode/utf8"
+
+	"github.com/bodgit/rom/dat"
+)
+
+const defaultTemplate = "{{.Name}}{{.Extension}}"
+
+var errInvalidFilename = errors.New("filename must be relative and within the target directory")
+
+var templateFuncs = template.FuncMap{
+	"lower":   strings.ToLower,
+	"upper":   strings.ToUpper,
+	"replace": strings.ReplaceAll,
+	"first": func(s string) string {
+		r, _ := utf8.DecodeRuneInString(s)
+		if r == utf8.RuneError {
+			return ""
+		}
+		return string(r)
+	},
+}
+
+type templateData struct {
+	dat.Game
+	Extension string
+}
+
+// Template configures the filename used for each game, relative to the
+// target directory, using the text/template syntax. The template is
+// executed with the game alongside the extension of the output format, so
+// the default is "{{.Name}}{{.Extension}}". Any "/" places the game in a
+// subdirectory and the functions lower, upper, replace and first are
+// available, for example "{{first .Name | upper}}/{{.Name}}{{.Extension}}"
+func Template(text string) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		t, err := template.New("filename").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return err
+		}
+		if _, err := executeTemplate(t, dat.Game{Name: "game", Description: "game"}, ""); err != nil {
+			return err
+		}
+		s.template = t
+		return nil
+	}
+}
+
+// SetTemplate configures the filename template used by s for each game
+func (s *Synchronizer) SetTemplate(text string) error {
+	return s.setOption(Template(text))
+}
+
+func executeTemplate(t *template.Template, game dat.Game, extension string) (string, error) {
+	var b strings.Builder
+	if err := t.Execute(&b, templateData{game, extension}); err != nil {
+		return "", err
+	}
+
+	name := filepath.Clean(filepath.FromSlash(b.String()))
+	if name == "." || filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+		return "", errInvalidFilename
+	}
+
+	return name, nil
+}
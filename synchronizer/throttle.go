@@ -0,0 +1,72 @@
+package synchronizer
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+var errInvalidRate = errors.New("rate must be greater than zero")
+
+type throttle struct {
+	mutex sync.Mutex
+	rate  float64
+	next  time.Time
+}
+
+// wait blocks until n bytes would have been transferred at the configured
+// rate, accounting for every other transfer sharing t
+func (t *throttle) wait(n int64) {
+	if t == nil || n <= 0 {
+		return
+	}
+
+	t.mutex.Lock()
+	now := time.Now()
+	if t.next.Before(now) {
+		t.next = now
+	}
+	t.next = t.next.Add(time.Duration(float64(n) / t.rate * float64(time.Second)))
+	d := t.next.Sub(now)
+	t.mutex.Unlock()
+
+	time.Sleep(d)
+}
+
+type throttledReader struct {
+	r io.Reader
+	t *throttle
+}
+
+func (tr *throttledReader) Read(p []byte) (int, error) {
+	n, err := tr.r.Read(p)
+	tr.t.wait(int64(n))
+	return n, err
+}
+
+// Throttle limits the combined rate that files are read when scanning and
+// copied when updating to bytesPerSecond, so a sync can run in the
+// background without starving anything else using the same storage
+func Throttle(bytesPerSecond int64) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		if bytesPerSecond <= 0 {
+			return errInvalidRate
+		}
+		s.throttle = &throttle{rate: float64(bytesPerSecond)}
+		return nil
+	}
+}
+
+// SetThrottle limits the rate that s reads and copies files
+func (s *Synchronizer) SetThrottle(bytesPerSecond int64) error {
+	return s.setOption(Throttle(bytesPerSecond))
+}
+
+// throttled returns r limited to the configured rate, if any
+func (s *Synchronizer) throttled(r io.Reader) io.Reader {
+	if s.throttle == nil {
+		return r
+	}
+	return &throttledReader{r, s.throttle}
+}
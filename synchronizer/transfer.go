@@ -0,0 +1,191 @@
+package synchronizer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+)
+
+var errTransferAborted = errors.New("transfer aborted")
+
+// ROMWorkers sets the number of source archives read at once when copying
+// the ROMs of a single game, which overlaps reading and decompressing one
+// source with writing another. If the format can only write one file at a
+// time, such as a zip archive, then each ROM is extracted to a temporary
+// file first, within the directory returned by os.TempDir. ROMs from the
+// same source are always read one at a time
+func ROMWorkers(count int) func(*Synchronizer) error {
+	return func(s *Synchronizer) error {
+		s.romWorkers = count
+		return nil
+	}
+}
+
+// SetROMWorkers sets the number of source archives read at once by s when
+// copying the ROMs of a single game
+func (s *Synchronizer) SetROMWorkers(count int) error {
+	return s.setOption(ROMWorkers(count))
+}
+
+// concurrentTransfer returns true if sources, which should already be
+// reduced, are worth reading concurrently
+func (s *Synchronizer) concurrentTransfer(sources map[string][]source) bool {
+	if s.romWorkers < 2 {
+		return false
+	}
+
+	names := make(map[string]struct{})
+	for _, v := range sources {
+		names[v[0].Name] = struct{}{}
+	}
+
+	return len(names) > 1
+}
+
+type romJob struct {
+	r    dat.ROM
+	src  source
+	temp string
+	done chan error
+}
+
+// transferConcurrently is like transfer but reads up to the configured
+// number of sources at once
+func (s *Synchronizer) transferConcurrently(ctx context.Context, writer rom.Writer, game dat.Game, sources map[string][]source) error {
+	// A directory can have all of its files written at once
+	_, direct := writer.(*rom.DirectoryWriter)
+
+	var temp string
+	if !direct {
+		var err error
+		// Keep the extracted ROMs off the target so it isn't written
+		// to twice and doesn't need room for both copies
+		if temp, err = os.MkdirTemp("", ""); err != nil {
+			return err
+		}
+		defer os.RemoveAll(temp)
+	}
+
+	var jobs []*romJob
+	groups := make(map[string][]*romJob)
+	for _, r := range game.ROM {
+		source, ok := sources[r.Name]
+		if !ok {
+			continue
+		}
+
+		j := &romJob{r: r, src: source[0], done: make(chan error, 1)}
+		jobs = append(jobs, j)
+		groups[j.src.Name] = append(groups[j.src.Name], j)
+	}
+
+	stop := make(chan struct{})
+	var stopOnce sync.Once
+	abort := func() {
+		stopOnce.Do(func() {
+			close(stop)
+		})
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, s.romWorkers)
+
+	for name, group := range groups {
+		wg.Add(1)
+		go func(name string, group []*romJob) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() {
+				<-sem
+			}()
+
+			reader, err := s.openSource(ctx, name)
+			if err != nil {
+				for _, j := range group {
+					j.done <- err
+				}
+				return
+			}
+			defer func() {
+				reader.Close()
+				atomic.AddUint64(&s.rx, reader.Rx())
+			}()
+
+			for i, j := range group {
+				select {
+				case <-stop:
+					for _, j := range group[i:] {
+						j.done <- errTransferAborted
+					}
+					return
+				default:
+				}
+
+				j.done <- s.extractROM(writer, temp, reader, game, j)
+			}
+		}(name, group)
+	}
+
+	var err error
+	for _, j := range jobs {
+		e := <-j.done
+		if e == nil && !direct {
+			e = s.copyTemp(writer, j)
+		}
+		if e != nil && err == nil {
+			err = e
+			abort()
+		}
+	}
+
+	wg.Wait()
+
+	return err
+}
+
+// extractROM copies the ROM for j from reader either directly to writer or
+// to a temporary file within temp
+func (s *Synchronizer) extractROM(writer rom.Writer, temp string, reader rom.Reader, game dat.Game, j *romJob) error {
+	copyROM := func(w io.Writer) error {
+		return s.copyROM(w, writer.Name(), reader, game, j.r, j.src)
+	}
+
+	if temp == "" {
+		return s.writeROM(writer, j.r.Name, copyROM)
+	}
+
+	f, err := os.CreateTemp(temp, "")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	j.temp = f.Name()
+
+	if err := copyROM(f); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
+
+// copyTemp copies the temporary file for j to writer
+func (s *Synchronizer) copyTemp(writer rom.Writer, j *romJob) error {
+	f, err := os.Open(j.temp)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(j.temp)
+	defer f.Close()
+
+	return s.writeROM(writer, j.r.Name, func(w io.Writer) error {
+		_, err := io.Copy(w, f)
+		return err
+	})
+}
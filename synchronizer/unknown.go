@@ -0,0 +1,67 @@
+package synchronizer
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	"github.com/bodgit/rom/dat"
+)
+
+// Unknown returns the name, relative to dir, of everything in dir that
+// doesn't match a known game and so would be removed by Delete
+func (s *Synchronizer) Unknown(dir string, datfile *dat.File) ([]string, error) {
+	return s.UnknownContext(context.Background(), dir, datfile)
+}
+
+// UnknownContext is like Unknown but stops early if ctx is cancelled
+func (s *Synchronizer) UnknownContext(ctx context.Context, dir string, datfile *dat.File) ([]string, error) {
+	games, parents := s.knownFiles(datfile)
+
+	var names []string
+	if err := s.unknownFiles(ctx, dir, ".", games, parents, func(name string) error {
+		names = append(names, name)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	return names, nil
+}
+
+// UnknownFiles is like Unknown for each of the datfiles synchronized with
+// UpdateFiles, returning everything that would be removed by DeleteFiles
+func (s *Synchronizer) UnknownFiles(dir string, datfiles ...*dat.File) ([]string, error) {
+	return s.UnknownFilesContext(context.Background(), dir, datfiles...)
+}
+
+// UnknownFilesContext is like UnknownFiles but stops early if ctx is
+// cancelled
+func (s *Synchronizer) UnknownFilesContext(ctx context.Context, dir string, datfiles ...*dat.File) ([]string, error) {
+	subs, err := subdirectories(datfiles)
+	if err != nil {
+		return nil, err
+	}
+
+	format := s.format
+	defer func() {
+		s.format = format
+	}()
+
+	var names []string
+	for i, datfile := range datfiles {
+		s.format = s.formatFor(datfile, format)
+		files, err := s.UnknownContext(ctx, filepath.Join(dir, subs[i]), datfile)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, file := range files {
+			names = append(names, filepath.Join(subs[i], file))
+		}
+	}
+
+	return names, nil
+}
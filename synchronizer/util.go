@@ -1,12 +1,48 @@
 package synchronizer
 
 import (
+	"os"
+	"path/filepath"
+
 	"github.com/bodgit/rom"
 	"github.com/bodgit/rom/dat"
 )
 
-func gameFilename(game dat.Game) string {
-	return game.Name + ".zip"
+// gameFilename returns the filename of game relative to the target
+// directory, falling back to the default if the template can't be applied
+func (s *Synchronizer) gameFilename(game dat.Game) string {
+	if s.template != nil {
+		name, err := executeTemplate(s.template, game, s.format.Extension)
+		if err == nil {
+			return name
+		}
+		s.log(LevelWarn, []Field{{FieldGame, game.Name}, {FieldError, err}}, "Unable to apply template to", game.Name, err)
+	}
+	return game.Name + s.format.Extension
+}
+
+func diskFilename(game dat.Game, disk dat.Disk) string {
+	return filepath.Join(game.Name, disk.Name+chdExtension)
+}
+
+// diskFiles returns the filenames of any disks used by game
+func diskFiles(game dat.Game) []string {
+	files := make([]string, 0, len(game.Disk))
+	for _, d := range game.Disk {
+		files = append(files, d.Name+chdExtension)
+	}
+	return files
+}
+
+// inArchive returns true if src is file within the archive name. When using
+// a directory layout, each file may also have been found on its own
+func inArchive(src source, name, file string) bool {
+	return src.File == file && (src.Name == name || src.Name == filepath.Join(name, file))
+}
+
+func isDir(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && info.IsDir()
 }
 
 func romChecksum(r dat.ROM, c rom.Checksum) checksum {
@@ -16,3 +52,33 @@ func romChecksum(r dat.ROM, c rom.Checksum) checksum {
 		Size:  r.Size,
 	}
 }
+
+// Anything that has never been dumped has no checksums to look for and
+// anything missing in action isn't worth looking for. Bad dumps are matched
+// by their listed checksums like any other ROM, the same as clrmamepro, so
+// a game with one can still be complete
+func wanted(r dat.ROM) bool {
+	return !r.NoDump() && !r.Missing()
+}
+
+func containsString(s []string, v string) bool {
+	for _, x := range s {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+// Disks have no size so prefer the strongest checksum available
+func diskChecksum(d dat.Disk) checksum {
+	c := checksum{
+		Type:  rom.SHA1,
+		Value: d.Checksum(rom.SHA1),
+	}
+	if c.Value == "" {
+		c.Type = rom.MD5
+		c.Value = d.Checksum(rom.MD5)
+	}
+	return c
+}
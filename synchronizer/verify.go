@@ -0,0 +1,232 @@
+package synchronizer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bodgit/rom"
+	"github.com/bodgit/rom/dat"
+)
+
+// ProblemKind describes what is wrong with a game in the target directory
+type ProblemKind string
+
+const (
+	// ProblemMissingGame means the game has no archive
+	ProblemMissingGame ProblemKind = "missing game"
+	// ProblemInvalidArchive means the archive isn't valid for the format,
+	// such as not being a TorrentZip
+	ProblemInvalidArchive ProblemKind = "invalid archive"
+	// ProblemMissingROM means a ROM isn't in the archive
+	ProblemMissingROM ProblemKind = "missing rom"
+	// ProblemWrongSize means a ROM is the wrong size
+	ProblemWrongSize ProblemKind = "wrong size"
+	// ProblemWrongChecksum means a ROM has the wrong checksum
+	ProblemWrongChecksum ProblemKind = "wrong checksum"
+	// ProblemUnexpectedFile means the archive contains a file that isn't
+	// one of the ROMs of the game
+	ProblemUnexpectedFile ProblemKind = "unexpected file"
+	// ProblemMissingDisk means a disk isn't in the target directory
+	ProblemMissingDisk ProblemKind = "missing disk"
+	// ProblemBadDisk means a disk has the wrong checksum
+	ProblemBadDisk ProblemKind = "bad disk"
+	// ProblemUnknownFile means a file in the target directory doesn't
+	// belong to any game
+	ProblemUnknownFile ProblemKind = "unknown file"
+)
+
+// Problem records something wrong with the target directory
+type Problem struct {
+	// Game is the name of the game, empty for an unknown file
+	Game string `json:"game,omitempty"`
+	// File is the name of the ROM, disk or unknown file, if any
+	File string `json:"file,omitempty"`
+	// Kind is what is wrong
+	Kind ProblemKind `json:"kind"`
+	// Detail is any additional information
+	Detail string `json:"detail,omitempty"`
+}
+
+func (p Problem) String() string {
+	var b strings.Builder
+	b.WriteString(p.Game)
+	if p.File != "" {
+		if p.Game != "" {
+			b.WriteString(": ")
+		}
+		b.WriteString(p.File)
+	}
+	b.WriteString(": ")
+	b.WriteString(string(p.Kind))
+	if p.Detail != "" {
+		b.WriteString(" (" + p.Detail + ")")
+	}
+	return b.String()
+}
+
+// Verification is the result of verifying a target directory
+type Verification struct {
+	// Games is the number of games checked
+	Games int `json:"games"`
+	// Good is the number of games without any problems
+	Good int `json:"good"`
+	// OK lists the names of the games without any problems, sorted
+	OK []string `json:"ok"`
+	// Problems lists everything found to be wrong, sorted by game
+	Problems []Problem `json:"problems"`
+}
+
+// Verify audits dir against datfile without scanning any sources or
+// changing anything. Every game is checked for the correct filename, a
+// valid archive for the configured format, and the correct names, sizes
+// and checksums of its ROMs and disks. Any files that don't belong to a
+// game are also reported
+func (s *Synchronizer) Verify(dir string, datfile *dat.File) (*Verification, error) {
+	return s.VerifyContext(context.Background(), dir, datfile)
+}
+
+// VerifyContext is like Verify but stops early if ctx is cancelled
+func (s *Synchronizer) VerifyContext(ctx context.Context, dir string, datfile *dat.File) (*Verification, error) {
+	v := new(Verification)
+
+	for _, game := range datfile.Game {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if _, ok := s.missing[game.Name]; ok || s.filtered(game) {
+			continue
+		}
+
+		problems, err := s.verifyGame(game, dir)
+		if err != nil {
+			return nil, err
+		}
+
+		v.Games++
+		if len(problems) == 0 {
+			v.Good++
+			v.OK = append(v.OK, game.Name)
+		}
+		v.Problems = append(v.Problems, problems...)
+	}
+
+	games, parents := s.knownFiles(datfile)
+	if err := s.unknownFiles(ctx, dir, ".", games, parents, func(name string) error {
+		v.Problems = append(v.Problems, Problem{File: name, Kind: ProblemUnknownFile})
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(v.OK)
+	sort.SliceStable(v.Problems, func(i, j int) bool {
+		if v.Problems[i].Game == v.Problems[j].Game {
+			return v.Problems[i].File < v.Problems[j].File
+		}
+		return v.Problems[i].Game < v.Problems[j].Game
+	})
+
+	return v, nil
+}
+
+func (s *Synchronizer) verifyGame(game dat.Game, dir string) ([]Problem, error) {
+	game.Normalize()
+
+	var problems []Problem
+
+	for _, d := range game.Disk {
+		c := diskChecksum(d)
+		if d.NoDump() || c.Value == "" {
+			continue
+		}
+		filename := filepath.Join(dir, diskFilename(game, d))
+		ok, err := validDisk(filename, c)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			kind := ProblemBadDisk
+			if _, err := os.Stat(filename); os.IsNotExist(err) {
+				kind = ProblemMissingDisk
+			}
+			problems = append(problems, Problem{Game: game.Name, File: d.Name + chdExtension, Kind: kind})
+		}
+	}
+
+	var roms []dat.ROM
+	for _, r := range game.ROM {
+		if wanted(r) {
+			roms = append(roms, r)
+		}
+	}
+	if len(roms) == 0 {
+		return problems, nil
+	}
+
+	reader, err := s.format.NewReader(filepath.Join(dir, s.gameFilename(game)))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return append([]Problem{{Game: game.Name, File: s.gameFilename(game), Kind: ProblemMissingGame}}, problems...), nil
+		}
+		return append([]Problem{{Game: game.Name, File: s.gameFilename(game), Kind: ProblemInvalidArchive, Detail: err.Error()}}, problems...), nil
+	}
+	defer reader.Close()
+
+	if !s.format.Valid(reader) {
+		problems = append(problems, Problem{Game: game.Name, File: s.gameFilename(game), Kind: ProblemInvalidArchive})
+	}
+
+	files := reader.Files()
+	for _, r := range roms {
+		if !containsString(files, r.Name) {
+			problems = append(problems, Problem{Game: game.Name, File: r.Name, Kind: ProblemMissingROM})
+			continue
+		}
+
+		size, header, err := reader.Size(r.Name)
+		if err != nil {
+			return nil, err
+		}
+		if size-header != r.Size {
+			problems = append(problems, Problem{Game: game.Name, File: r.Name, Kind: ProblemWrongSize, Detail: fmt.Sprintf("%d, expected %d", size-header, r.Size)})
+			continue
+		}
+
+		for _, t := range []rom.Checksum{rom.CRC32, rom.MD5, rom.SHA1} {
+			want := r.Checksum(t)
+			if want == "" {
+				continue
+			}
+			b, err := reader.Checksum(r.Name, t)
+			if err != nil {
+				return nil, err
+			}
+			if got := checksumToString(b); got != want {
+				problems = append(problems, Problem{Game: game.Name, File: r.Name, Kind: ProblemWrongChecksum, Detail: fmt.Sprintf("%s %s, expected %s", checksumNames[t], got, want)})
+				break
+			}
+		}
+	}
+
+	for _, file := range files {
+		if containsString(diskFiles(game), file) {
+			continue
+		}
+		expected := false
+		for _, r := range game.ROM {
+			if r.Name == file {
+				expected = true
+				break
+			}
+		}
+		if !expected {
+			problems = append(problems, Problem{Game: game.Name, File: file, Kind: ProblemUnexpectedFile})
+		}
+	}
+
+	return problems, nil
+}
@@ -0,0 +1,51 @@
+package torrent
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// encode appends the bencoded form of v, which must be built from
+// integers, strings, byte slices, lists and maps with string keys
+func encode(b *bytes.Buffer, v interface{}) error {
+	switch v := v.(type) {
+	case int:
+		b.WriteString("i" + strconv.Itoa(v) + "e")
+	case int64:
+		b.WriteString("i" + strconv.FormatInt(v, 10) + "e")
+	case string:
+		b.WriteString(strconv.Itoa(len(v)) + ":" + v)
+	case []byte:
+		b.WriteString(strconv.Itoa(len(v)) + ":")
+		b.Write(v)
+	case []interface{}:
+		b.WriteByte('l')
+		for _, e := range v {
+			if err := encode(b, e); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('e')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// Keys are sorted as raw bytes, which is how Go compares strings
+		sort.Strings(keys)
+
+		b.WriteByte('d')
+		for _, k := range keys {
+			_ = encode(b, k)
+			if err := encode(b, v[k]); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('e')
+	default:
+		return fmt.Errorf("can't bencode %T", v)
+	}
+	return nil
+}
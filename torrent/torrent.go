@@ -0,0 +1,440 @@
+/*
+Package torrent implements creating BitTorrent metainfo files for a
+directory, such as a set of TorrentZip files. Version 1 (BEP 3), version 2
+(BEP 52) and hybrid torrents are supported.
+
+Nothing that varies between runs, such as the creation date, is included so
+identical directories always produce identical torrents.
+*/
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Version is the version of the BitTorrent protocol a torrent is for
+type Version int
+
+const (
+	// V1 is the original protocol using SHA-1 piece hashes
+	V1 Version = iota + 1
+	// V2 uses per-file SHA-256 merkle trees
+	V2
+	// Hybrid torrents can be used by clients supporting either version
+	Hybrid
+)
+
+func (v Version) String() string {
+	switch v {
+	case V1:
+		return "v1"
+	case V2:
+		return "v2"
+	case Hybrid:
+		return "hybrid"
+	}
+	return "unknown"
+}
+
+const (
+	blockSize      = 16 << 10
+	minPieceLength = blockSize
+	maxPieceLength = 16 << 20
+	// targetPieces is roughly how many pieces an automatically chosen
+	// piece length aims for
+	targetPieces = 1500
+	createdBy    = "rom"
+)
+
+var (
+	// ErrPieceLength is returned if the piece length isn't a power of two
+	// of at least 16KiB
+	ErrPieceLength = errors.New("piece length must be a power of two of at least 16KiB")
+	// ErrVersion is returned for an unknown Version
+	ErrVersion = errors.New("unknown torrent version")
+	// ErrNoFiles is returned if the directory has no files in it
+	ErrNoFiles = errors.New("no files to add")
+)
+
+// Options configures the torrent created
+type Options struct {
+	// Version defaults to V1
+	Version Version
+	// PieceLength is chosen from the total size if zero
+	PieceLength int64
+	// Trackers are the announce URLs, each tried in turn
+	Trackers []string
+	// Name defaults to the name of the directory
+	Name string
+	// Comment is an optional comment
+	Comment string
+	// Private prevents clients using DHT or peer exchange
+	Private bool
+}
+
+type file struct {
+	path   []string
+	length int64
+}
+
+// files returns every regular file beneath dir in the order both versions
+// need, skipping anything hidden and any torrent files
+func files(dir string) ([]file, int64, error) {
+	var files []file
+	var total int64
+
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != dir && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.EqualFold(filepath.Ext(p), ".torrent") {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		files = append(files, file{strings.Split(filepath.ToSlash(rel), "/"), info.Size()})
+		total += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(files) == 0 {
+		return nil, 0, ErrNoFiles
+	}
+
+	return files, total, nil
+}
+
+// pieceLength picks a power of two giving about targetPieces pieces
+func pieceLength(total int64) int64 {
+	length := int64(minPieceLength)
+	for length < maxPieceLength && total/length > targetPieces {
+		length <<= 1
+	}
+	return length
+}
+
+// v1Hasher hashes a stream in pieces with SHA-1
+type v1Hasher struct {
+	length int64
+	n      int64
+	hash   []byte
+	pieces []byte
+}
+
+func (h *v1Hasher) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		if h.n == 0 {
+			h.hash = h.hash[:0]
+		}
+
+		n := h.length - h.n
+		if n > int64(len(p)) {
+			n = int64(len(p))
+		}
+		h.hash = append(h.hash, p[:n]...)
+		h.n += n
+		p = p[n:]
+
+		if h.n == h.length {
+			h.flush()
+		}
+	}
+	return written, nil
+}
+
+// flush finishes the current piece, which may be short at the end
+func (h *v1Hasher) flush() {
+	if h.n == 0 {
+		return
+	}
+	sum := sha1.Sum(h.hash)
+	h.pieces = append(h.pieces, sum[:]...)
+	h.n = 0
+}
+
+// merkleRoot returns the root of the tree with hashes as its lowest layer,
+// padded to width with pad
+func merkleRoot(hashes [][]byte, width int, pad []byte) []byte {
+	layer := make([][]byte, width)
+	copy(layer, hashes)
+	for i := len(hashes); i < width; i++ {
+		layer[i] = pad
+	}
+
+	for len(layer) > 1 {
+		next := make([][]byte, len(layer)/2)
+		for i := range next {
+			sum := sha256.Sum256(append(append([]byte{}, layer[i*2]...), layer[i*2+1]...))
+			next[i] = sum[:]
+		}
+		layer = next
+	}
+
+	return layer[0]
+}
+
+func powerOfTwo(n int) int {
+	width := 1
+	for width < n {
+		width <<= 1
+	}
+	return width
+}
+
+// v2Hasher hashes a file in 16KiB blocks with SHA-256
+type v2Hasher struct {
+	block  []byte
+	leaves [][]byte
+}
+
+func (h *v2Hasher) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := blockSize - len(h.block)
+		if n > len(p) {
+			n = len(p)
+		}
+		h.block = append(h.block, p[:n]...)
+		p = p[n:]
+
+		if len(h.block) == blockSize {
+			h.flush()
+		}
+	}
+	return written, nil
+}
+
+func (h *v2Hasher) flush() {
+	if len(h.block) == 0 {
+		return
+	}
+	sum := sha256.Sum256(h.block)
+	h.leaves = append(h.leaves, sum[:])
+	h.block = h.block[:0]
+}
+
+// root returns the pieces root of the file and, if it is bigger than one
+// piece, its piece layer
+func (h *v2Hasher) root(pieceLength int64) ([]byte, []byte) {
+	h.flush()
+
+	perPiece := int(pieceLength / blockSize)
+	zero := make([]byte, sha256.Size)
+
+	if len(h.leaves) <= perPiece {
+		return merkleRoot(h.leaves, powerOfTwo(len(h.leaves)), zero), nil
+	}
+
+	var pieces [][]byte
+	var layer []byte
+	for i := 0; i < len(h.leaves); i += perPiece {
+		end := i + perPiece
+		if end > len(h.leaves) {
+			end = len(h.leaves)
+		}
+		piece := merkleRoot(h.leaves[i:end], perPiece, zero)
+		pieces = append(pieces, piece)
+		layer = append(layer, piece...)
+	}
+
+	return merkleRoot(pieces, powerOfTwo(len(pieces)), merkleRoot(nil, perPiece, zero)), layer
+}
+
+// metainfo returns the metainfo dictionary for dir
+func metainfo(dir string, opts Options) (map[string]interface{}, error) {
+	version := opts.Version
+	if version == 0 {
+		version = V1
+	}
+	if version < V1 || version > Hybrid {
+		return nil, ErrVersion
+	}
+
+	files, total, err := files(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	length := opts.PieceLength
+	if length == 0 {
+		length = pieceLength(total)
+	}
+	if length < minPieceLength || length&(length-1) != 0 {
+		return nil, ErrPieceLength
+	}
+
+	name := opts.Name
+	if name == "" {
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, err
+		}
+		name = filepath.Base(abs)
+	}
+
+	info := map[string]interface{}{
+		"name":         name,
+		"piece length": length,
+	}
+	if opts.Private {
+		info["private"] = 1
+	}
+
+	v1 := &v1Hasher{length: length}
+	var v1Files []interface{}
+	tree := make(map[string]interface{})
+	layers := make(map[string]interface{})
+
+	for i, f := range files {
+		v2 := new(v2Hasher)
+
+		var w io.Writer
+		switch version {
+		case V1:
+			w = v1
+		case V2:
+			w = v2
+		case Hybrid:
+			w = io.MultiWriter(v1, v2)
+		}
+
+		if err := hashFile(w, filepath.Join(append([]string{dir}, f.path...)...)); err != nil {
+			return nil, err
+		}
+
+		if version != V2 {
+			path := make([]interface{}, len(f.path))
+			for j, p := range f.path {
+				path[j] = p
+			}
+			v1Files = append(v1Files, map[string]interface{}{
+				"length": f.length,
+				"path":   path,
+			})
+
+			// Hybrid torrents align each file to a piece with padding
+			if pad := (length - f.length%length) % length; version == Hybrid && pad > 0 && i < len(files)-1 {
+				_, _ = v1.Write(make([]byte, pad))
+				v1Files = append(v1Files, map[string]interface{}{
+					"attr":   "p",
+					"length": pad,
+					"path":   []interface{}{".pad", strconv.FormatInt(pad, 10)},
+				})
+			}
+		}
+
+		if version != V1 {
+			entry := map[string]interface{}{
+				"length": f.length,
+			}
+			if f.length > 0 {
+				root, layer := v2.root(length)
+				entry["pieces root"] = root
+				if layer != nil {
+					layers[string(root)] = layer
+				}
+			}
+
+			node := tree
+			for _, p := range f.path[:len(f.path)-1] {
+				child, ok := node[p].(map[string]interface{})
+				if !ok {
+					child = make(map[string]interface{})
+					node[p] = child
+				}
+				node = child
+			}
+			node[f.path[len(f.path)-1]] = map[string]interface{}{"": entry}
+		}
+	}
+
+	if version != V2 {
+		v1.flush()
+		info["files"] = v1Files
+		info["pieces"] = v1.pieces
+	}
+
+	m := map[string]interface{}{
+		"created by": createdBy,
+		"info":       info,
+	}
+
+	if version != V1 {
+		info["meta version"] = 2
+		info["file tree"] = tree
+		m["piece layers"] = layers
+	}
+
+	if len(opts.Trackers) > 0 {
+		m["announce"] = opts.Trackers[0]
+		tiers := make([]interface{}, len(opts.Trackers))
+		for i, t := range opts.Trackers {
+			tiers[i] = []interface{}{t}
+		}
+		m["announce-list"] = tiers
+	}
+
+	if opts.Comment != "" {
+		m["comment"] = opts.Comment
+	}
+
+	return m, nil
+}
+
+func hashFile(w io.Writer, name string) error {
+	f, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+
+	return err
+}
+
+// Create writes a torrent for every file beneath dir to w
+func Create(w io.Writer, dir string, opts Options) error {
+	m, err := metainfo(dir, opts)
+	if err != nil {
+		return err
+	}
+
+	b := new(bytes.Buffer)
+	if err := encode(b, m); err != nil {
+		return err
+	}
+
+	_, err = w.Write(b.Bytes())
+
+	return err
+}
@@ -0,0 +1,186 @@
+package torrent
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func sha256Sum(parts ...[]byte) []byte {
+	sum := sha256.Sum256(bytes.Join(parts, nil))
+	return sum[:]
+}
+
+func writeFiles(t *testing.T, files map[string][]byte) string {
+	dir := t.TempDir()
+	for name, b := range files {
+		name = filepath.Join(dir, filepath.FromSlash(name))
+		assert.Equal(t, nil, os.MkdirAll(filepath.Dir(name), os.ModePerm))
+		assert.Equal(t, nil, os.WriteFile(name, b, 0o644))
+	}
+	return dir
+}
+
+func TestEncode(t *testing.T) {
+	tables := map[string]struct {
+		v       interface{}
+		encoded string
+	}{
+		"int":    {42, "i42e"},
+		"int64":  {int64(-1), "i-1e"},
+		"string": {"spam", "4:spam"},
+		"bytes":  {[]byte{0, 1}, "2:\x00\x01"},
+		"list":   {[]interface{}{"spam", 1}, "l4:spami1ee"},
+		"dict":   {map[string]interface{}{"spam": "eggs", "cow": "moo"}, "d3:cow3:moo4:spam4:eggse"},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			b := new(bytes.Buffer)
+			assert.Equal(t, nil, encode(b, table.v))
+			assert.Equal(t, table.encoded, b.String())
+		})
+	}
+
+	assert.NotEqual(t, nil, encode(new(bytes.Buffer), 1.5))
+}
+
+func TestV2Root(t *testing.T) {
+	small := bytes.Repeat([]byte{1}, 1000)
+	large := bytes.Repeat([]byte{2}, 2*blockSize+100)
+	zero := make([]byte, sha256.Size)
+
+	tables := map[string]struct {
+		data        []byte
+		pieceLength int64
+		root        []byte
+		layer       []byte
+	}{
+		"one block": {
+			small,
+			blockSize,
+			sha256Sum(small),
+			nil,
+		},
+		"one piece": {
+			large,
+			4 * blockSize,
+			sha256Sum(sha256Sum(sha256Sum(large[:blockSize]), sha256Sum(large[blockSize:2*blockSize])), sha256Sum(sha256Sum(large[2*blockSize:]), zero)),
+			nil,
+		},
+		"pieces": {
+			large,
+			blockSize,
+			sha256Sum(sha256Sum(sha256Sum(large[:blockSize]), sha256Sum(large[blockSize:2*blockSize])), sha256Sum(sha256Sum(large[2*blockSize:]), zero)),
+			bytes.Join([][]byte{sha256Sum(large[:blockSize]), sha256Sum(large[blockSize : 2*blockSize]), sha256Sum(large[2*blockSize:])}, nil),
+		},
+		"padded pieces": {
+			large,
+			2 * blockSize,
+			sha256Sum(sha256Sum(sha256Sum(large[:blockSize]), sha256Sum(large[blockSize:2*blockSize])), sha256Sum(sha256Sum(large[2*blockSize:]), zero)),
+			bytes.Join([][]byte{sha256Sum(sha256Sum(large[:blockSize]), sha256Sum(large[blockSize:2*blockSize])), sha256Sum(sha256Sum(large[2*blockSize:]), zero)}, nil),
+		},
+	}
+
+	for name, table := range tables {
+		t.Run(name, func(t *testing.T) {
+			h := new(v2Hasher)
+			_, _ = h.Write(table.data)
+			root, layer := h.root(table.pieceLength)
+			assert.Equal(t, table.root, root)
+			assert.Equal(t, table.layer, layer)
+		})
+	}
+}
+
+func TestMetainfo(t *testing.T) {
+	a := bytes.Repeat([]byte{'a'}, blockSize+1)
+	b := []byte("b")
+	dir := writeFiles(t, map[string][]byte{
+		"a.zip":         a,
+		"sub/b.zip":     b,
+		".hidden":       []byte("hidden"),
+		"set.torrent":   []byte("torrent"),
+		".git/ignored":  []byte("ignored"),
+		"sub/empty.zip": nil,
+	})
+
+	m, err := metainfo(dir, Options{PieceLength: blockSize, Trackers: []string{"udp://one", "udp://two"}, Name: "set"})
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "udp://one", m["announce"])
+	assert.Equal(t, []interface{}{[]interface{}{"udp://one"}, []interface{}{"udp://two"}}, m["announce-list"])
+
+	info := m["info"].(map[string]interface{})
+	assert.Equal(t, "set", info["name"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"length": int64(len(a)), "path": []interface{}{"a.zip"}},
+		map[string]interface{}{"length": int64(len(b)), "path": []interface{}{"sub", "b.zip"}},
+		map[string]interface{}{"length": int64(0), "path": []interface{}{"sub", "empty.zip"}},
+	}, info["files"])
+
+	stream := append(append([]byte{}, a...), b...)
+	p1, p2 := sha1.Sum(stream[:blockSize]), sha1.Sum(stream[blockSize:])
+	assert.Equal(t, append(p1[:], p2[:]...), info["pieces"])
+	_, ok := info["file tree"]
+	assert.False(t, ok)
+
+	m, err = metainfo(dir, Options{Version: Hybrid, PieceLength: blockSize})
+	assert.Equal(t, nil, err)
+
+	info = m["info"].(map[string]interface{})
+	assert.Equal(t, filepath.Base(dir), info["name"])
+	assert.Equal(t, 2, info["meta version"])
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"length": int64(len(a)), "path": []interface{}{"a.zip"}},
+		map[string]interface{}{"attr": "p", "length": int64(blockSize - 1), "path": []interface{}{".pad", "16383"}},
+		map[string]interface{}{"length": int64(len(b)), "path": []interface{}{"sub", "b.zip"}},
+		map[string]interface{}{"attr": "p", "length": int64(blockSize - 1), "path": []interface{}{".pad", "16383"}},
+		map[string]interface{}{"length": int64(0), "path": []interface{}{"sub", "empty.zip"}},
+	}, info["files"])
+
+	padded := append(append([]byte{}, b...), make([]byte, blockSize-1)...)
+	p2, p3 := sha1.Sum(append(append([]byte{}, a[blockSize:]...), make([]byte, blockSize-1)...)), sha1.Sum(padded)
+	assert.Equal(t, bytes.Join([][]byte{p1[:], p2[:], p3[:]}, nil), info["pieces"])
+
+	rootA := sha256Sum(sha256Sum(a[:blockSize]), sha256Sum(a[blockSize:]))
+	assert.Equal(t, map[string]interface{}{
+		"a.zip": map[string]interface{}{"": map[string]interface{}{"length": int64(len(a)), "pieces root": rootA}},
+		"sub": map[string]interface{}{
+			"b.zip":     map[string]interface{}{"": map[string]interface{}{"length": int64(len(b)), "pieces root": sha256Sum(b)}},
+			"empty.zip": map[string]interface{}{"": map[string]interface{}{"length": int64(0)}},
+		},
+	}, info["file tree"])
+	assert.Equal(t, map[string]interface{}{
+		string(rootA): append(sha256Sum(a[:blockSize]), sha256Sum(a[blockSize:])...),
+	}, m["piece layers"])
+
+	_, err = metainfo(dir, Options{PieceLength: 1000})
+	assert.Equal(t, ErrPieceLength, err)
+
+	_, err = metainfo(dir, Options{Version: 4})
+	assert.Equal(t, ErrVersion, err)
+
+	_, err = metainfo(t.TempDir(), Options{})
+	assert.Equal(t, ErrNoFiles, err)
+}
+
+func TestCreate(t *testing.T) {
+	dir := writeFiles(t, map[string][]byte{"a.zip": []byte("a")})
+
+	var first, second bytes.Buffer
+	assert.Equal(t, nil, Create(&first, dir, Options{Version: V2, Name: "set"}))
+	assert.Equal(t, nil, Create(&second, dir, Options{Version: V2, Name: "set"}))
+	assert.Equal(t, first.Bytes(), second.Bytes())
+	assert.True(t, bytes.HasPrefix(first.Bytes(), []byte("d10:created by3:rom4:infod9:file treed5:a.zipd0:d6:lengthi1e11:pieces root32:")))
+}
+
+func TestPieceLength(t *testing.T) {
+	assert.Equal(t, int64(minPieceLength), pieceLength(0))
+	assert.Equal(t, int64(1<<20), pieceLength(1<<30))
+	assert.Equal(t, int64(maxPieceLength), pieceLength(1<<50))
+}
@@ -0,0 +1,295 @@
+package rom
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	webDAVUserEnv     = "WEBDAV_USER"
+	webDAVPasswordEnv = "WEBDAV_PASSWORD"
+	webDAVPropfind    = `<?xml version="1.0" encoding="utf-8"?><propfind xmlns="DAV:"><prop><resourcetype/></prop></propfind>`
+)
+
+var webDAVCollections = struct {
+	sync.Mutex
+	m map[string]struct{}
+}{
+	m: make(map[string]struct{}),
+}
+
+func init() {
+	for _, scheme := range []string{"dav", "davs"} {
+		RegisterScheme(scheme, OpenWebDAV)
+		RegisterLister(scheme, ListWebDAV)
+		RegisterPutter(scheme, PutWebDAV, DeleteWebDAV)
+	}
+}
+
+type webDAVClient struct {
+	url      *url.URL
+	user     string
+	password string
+}
+
+// newWebDAVClient turns a dav:// or davs:// URL into the http or https URL
+// of the server. The user and password are taken from the URL first and
+// then the environment
+func newWebDAVClient(name string) (*webDAVClient, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c := &webDAVClient{
+		user:     getenv(webDAVUserEnv),
+		password: getenv(webDAVPasswordEnv),
+	}
+	if u.User != nil {
+		c.user = u.User.Username()
+		if p, ok := u.User.Password(); ok {
+			c.password = p
+		}
+	}
+
+	c.url = &url.URL{
+		Scheme: "http",
+		Host:   u.Host,
+		Path:   u.Path,
+	}
+	if strings.EqualFold(u.Scheme, "davs") {
+		c.url.Scheme = "https"
+	}
+
+	return c, nil
+}
+
+func (c *webDAVClient) sign(req *http.Request) {
+	if c.user != "" {
+		req.SetBasicAuth(c.user, c.password)
+	}
+}
+
+func (c *webDAVClient) do(ctx context.Context, method, p string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	u := *c.url
+	u.Path = p
+
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = size
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	c.sign(req)
+
+	return httpClient.Do(req)
+}
+
+// OpenWebDAV returns a RemoteFile for a dav:// or davs:// URL, which are
+// read with range requests over http or https respectively. A user and
+// password can be given in the URL or with the WEBDAV_USER and
+// WEBDAV_PASSWORD environment variables
+func OpenWebDAV(ctx context.Context, name string) (RemoteFile, error) {
+	c, err := newWebDAVClient(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return openHTTP(ctx, c.url.String(), c.sign)
+}
+
+type webDAVMultistatus struct {
+	Response []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+			} `xml:"prop"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+// ListWebDAV returns the URL of every file beneath the collection in the
+// dav:// or davs:// URL name, skipping anything hidden. Each collection is
+// listed in turn as many servers refuse to list everything at once
+func ListWebDAV(ctx context.Context, name string) ([]string, error) {
+	u, err := url.Parse(name)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := newWebDAVClient(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	var list func(string) error
+	list = func(dir string) error {
+		resp, err := c.do(ctx, "PROPFIND", dir, strings.NewReader(webDAVPropfind), int64(len(webDAVPropfind)), http.Header{
+			"Depth":        {"1"},
+			"Content-Type": {"application/xml"},
+		})
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusMultiStatus {
+			return fmt.Errorf("%s: %s", dir, resp.Status)
+		}
+
+		var ms webDAVMultistatus
+		if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+			return fmt.Errorf("%s: %w", dir, err)
+		}
+
+		for _, r := range ms.Response {
+			ref, err := url.Parse(r.Href)
+			if err != nil {
+				continue
+			}
+
+			p := ref.Path
+			collection := false
+			for _, ps := range r.Propstat {
+				if ps.Prop.ResourceType.Collection != nil {
+					collection = true
+				}
+			}
+
+			// The collection itself is included in the listing
+			if rel := strings.TrimSuffix(strings.TrimPrefix(p, dir), "/"); !strings.HasPrefix(p, dir) || rel == "" || strings.Contains(rel, "/") || rel[0] == '.' {
+				continue
+			}
+
+			if collection {
+				if err := list(strings.TrimSuffix(p, "/") + "/"); err != nil {
+					return err
+				}
+				continue
+			}
+
+			file := *u
+			file.Path = p
+			file.RawPath = ""
+			files = append(files, file.String())
+		}
+
+		return nil
+	}
+
+	if err := list(strings.TrimSuffix(c.url.Path, "/") + "/"); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// mkcol creates the collection p and any parents it needs
+func (c *webDAVClient) mkcol(ctx context.Context, p string) error {
+	if p == "/" || p == "." {
+		return nil
+	}
+
+	key := c.url.Host + p
+
+	webDAVCollections.Lock()
+	_, ok := webDAVCollections.m[key]
+	webDAVCollections.Unlock()
+	if ok {
+		return nil
+	}
+
+	for retry := true; ; retry = false {
+		resp, err := c.do(ctx, "MKCOL", p+"/", nil, 0, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+
+		switch resp.StatusCode {
+		case http.StatusCreated, http.StatusMethodNotAllowed:
+			// Not allowed means the collection already exists
+			webDAVCollections.Lock()
+			webDAVCollections.m[key] = struct{}{}
+			webDAVCollections.Unlock()
+			return nil
+		case http.StatusConflict:
+			if retry {
+				if err := c.mkcol(ctx, path.Dir(p)); err != nil {
+					return err
+				}
+				continue
+			}
+		}
+
+		return fmt.Errorf("%s: %s", p, resp.Status)
+	}
+}
+
+// PutWebDAV uploads size bytes from r to the dav:// or davs:// URL name,
+// creating any collections needed first
+func PutWebDAV(ctx context.Context, name string, r io.Reader, size int64) error {
+	c, err := newWebDAVClient(name)
+	if err != nil {
+		return err
+	}
+
+	if err := c.mkcol(ctx, path.Dir(c.url.Path)); err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodPut, c.url.Path, r, size, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return nil
+	}
+
+	return fmt.Errorf("%s: %s", c.url.Path, resp.Status)
+}
+
+// DeleteWebDAV removes the file at the dav:// or davs:// URL name
+func DeleteWebDAV(ctx context.Context, name string) error {
+	c, err := newWebDAVClient(name)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.do(ctx, http.MethodDelete, c.url.Path, nil, 0, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		return nil
+	case http.StatusNotFound:
+		return fmt.Errorf("%s: %w", c.url.Path, os.ErrNotExist)
+	}
+
+	return fmt.Errorf("%s: %s", c.url.Path, resp.Status)
+}
@@ -0,0 +1,68 @@
+package rom
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/webdav"
+)
+
+func TestWebDAV(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"test.zip", "test/test.bin", "test/test.nes"} {
+		b, err := os.ReadFile(filepath.Join("testdata", file))
+		assert.Equal(t, nil, err)
+		assert.Equal(t, nil, os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), os.ModePerm))
+		assert.Equal(t, nil, os.WriteFile(filepath.Join(dir, file), b, 0o644))
+	}
+
+	handler := &webdav.Handler{
+		FileSystem: webdav.Dir(dir),
+		LockSystem: webdav.NewMemLS(),
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, password, ok := r.BasicAuth(); !ok || user != "user" || password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	base := "dav://user@" + strings.TrimPrefix(ts.URL, "http://")
+	t.Setenv(webDAVPasswordEnv, "secret")
+
+	files, err := List(base + "/")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []string{base + "/test.zip", base + "/test/test.bin", base + "/test/test.nes"}, files)
+
+	r, err := NewReader(base + "/test.zip")
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "*rom.ZipReader", fmt.Sprintf("%T", r))
+
+	checksum, err := r.Checksum("test.bin", SHA1)
+	assert.Equal(t, nil, err)
+	assert.Equal(t, []byte{0x4e, 0xbc, 0x20, 0xb4, 0x6e, 0xa4, 0xd0, 0x10, 0xed, 0x9a, 0xc1, 0xfd, 0xe4, 0xc2, 0x51, 0xcf, 0x23, 0x1a, 0x66, 0x1f}, checksum)
+	assert.Equal(t, nil, r.Close())
+
+	assert.True(t, IsWritable(base+"/new/dir/test.bin"))
+	assert.Equal(t, nil, Put(base+"/new/dir/test.bin", strings.NewReader("test"), 4))
+	b, err := os.ReadFile(filepath.Join(dir, "new", "dir", "test.bin"))
+	assert.Equal(t, nil, err)
+	assert.Equal(t, "test", string(b))
+
+	assert.Equal(t, nil, Delete(base+"/new/dir/test.bin"))
+	_, err = os.Stat(filepath.Join(dir, "new", "dir", "test.bin"))
+	assert.True(t, os.IsNotExist(err))
+
+	assert.True(t, errors.Is(Delete(base+"/new/dir/test.bin"), os.ErrNotExist))
+	assert.True(t, errors.Is(Put("nothing://test", io.LimitReader(nil, 0), 0), errNoPutter))
+}